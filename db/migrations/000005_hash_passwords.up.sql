@@ -0,0 +1,4 @@
+-- Passwords are now stored as bcrypt hashes. The length policy is
+-- enforced in Go since it can not be checked on a hash.
+-- Existing plaintext passwords are rehashed on the next successful login.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_password_check;
@@ -0,0 +1,6 @@
+-- Named roles replace the is_admin flag. Permissions per role are defined in Go.
+CREATE TYPE user_role AS ENUM ('cashier', 'pharmacist', 'store_keeper', 'manager', 'admin');
+
+ALTER TABLE users ADD COLUMN role user_role NOT NULL DEFAULT 'cashier';
+UPDATE users SET role = 'admin' WHERE is_admin = TRUE;
+ALTER TABLE users DROP COLUMN is_admin;
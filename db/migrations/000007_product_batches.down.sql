@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS create_opening_batch_trigger ON products;
+DROP FUNCTION IF EXISTS create_opening_batch;
+
+DROP TRIGGER IF EXISTS product_batches_sync_trigger ON product_batches;
+DROP FUNCTION IF EXISTS sync_product_stock_from_batches;
+
+DROP INDEX IF EXISTS product_batches_product_id_idx;
+DROP TABLE IF EXISTS product_batches;
+
+ALTER TABLE stock_in DROP COLUMN IF EXISTS batch_number;
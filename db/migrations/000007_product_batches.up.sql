@@ -0,0 +1,114 @@
+-- Stock is tracked per batch. products.quantity and products.expiry_dates
+-- are derived from the batches by the product_batches_sync_trigger.
+ALTER TABLE stock_in ADD COLUMN batch_number VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS product_batches (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    -- NULL for opening stock and stock not received on an invoice.
+    stock_in_id INTEGER UNIQUE,
+    batch_number VARCHAR(100) NOT NULL DEFAULT '',
+    expiry_date DATE,
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK(quantity >= 0),
+    cost_price DOUBLE PRECISION NOT NULL DEFAULT 0.00,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- FOREIGN KEYS
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+    FOREIGN KEY (stock_in_id) REFERENCES stock_in(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS product_batches_product_id_idx ON product_batches(product_id);
+
+-- Back-fill batches from the current stock. Units still on hand are assumed to
+-- come from the most recent stock_in rows. Whatever can not be matched to a
+-- stock_in row becomes an opening batch expiring on the earliest known date.
+DO $$
+DECLARE
+    p RECORD;
+    s RECORD;
+    remaining INTEGER;
+    taken INTEGER;
+BEGIN
+    FOR p IN SELECT id, quantity, cost_price, expiry_dates FROM products WHERE quantity > 0
+    LOOP
+        remaining := p.quantity;
+
+        FOR s IN SELECT id, quantity, cost_price, expiry_date, batch_number FROM stock_in
+            WHERE product_id = p.id AND quantity > 0
+            ORDER BY created_at DESC, id DESC
+        LOOP
+            EXIT WHEN remaining <= 0;
+            taken := LEAST(remaining, s.quantity);
+
+            INSERT INTO product_batches (product_id, stock_in_id, batch_number, expiry_date, quantity, cost_price)
+            VALUES (p.id, s.id, s.batch_number, NULLIF(s.expiry_date, '0001-01-01'::date), taken, s.cost_price);
+
+            remaining := remaining - taken;
+        END LOOP;
+
+        IF remaining > 0 THEN
+            INSERT INTO product_batches (product_id, batch_number, expiry_date, quantity, cost_price)
+            VALUES (p.id, 'OPENING', (SELECT MIN(d) FROM unnest(p.expiry_dates) d), remaining, p.cost_price);
+        END IF;
+    END LOOP;
+END $$;
+
+
+-- Keep products.quantity and products.expiry_dates in sync with the batches.
+CREATE OR REPLACE FUNCTION sync_product_stock_from_batches()
+RETURNS TRIGGER AS $$
+DECLARE
+    pid INTEGER;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        pid := OLD.product_id;
+    ELSE
+        pid := NEW.product_id;
+    END IF;
+
+    UPDATE products SET
+        quantity = (
+            SELECT COALESCE(SUM(quantity), 0) FROM product_batches WHERE product_id = pid
+        ),
+        expiry_dates = (
+            SELECT COALESCE(ARRAY_AGG(DISTINCT expiry_date ORDER BY expiry_date), '{}')
+            FROM product_batches
+            WHERE product_id = pid AND quantity > 0 AND expiry_date IS NOT NULL
+        ),
+        updated_at = CURRENT_TIMESTAMP
+    WHERE id = pid;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_batches_sync_trigger
+AFTER INSERT OR UPDATE OR DELETE ON product_batches
+FOR EACH ROW
+EXECUTE FUNCTION sync_product_stock_from_batches();
+
+
+-- Products created with an initial quantity (form or CSV import) get an opening batch.
+CREATE OR REPLACE FUNCTION create_opening_batch()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.quantity > 0 THEN
+        INSERT INTO product_batches (product_id, batch_number, expiry_date, quantity, cost_price)
+        VALUES (NEW.id, 'OPENING', (SELECT MIN(d) FROM unnest(NEW.expiry_dates) d), NEW.quantity, NEW.cost_price);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER create_opening_batch_trigger
+AFTER INSERT ON products
+FOR EACH ROW
+EXECUTE FUNCTION create_opening_batch();
+
+
+-- Derive the expiry dates of existing products from the back-filled batches.
+UPDATE products SET expiry_dates = (
+    SELECT COALESCE(ARRAY_AGG(DISTINCT expiry_date ORDER BY expiry_date), '{}')
+    FROM product_batches
+    WHERE product_id = products.id AND quantity > 0 AND expiry_date IS NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS transaction_batches_transaction_id_idx;
+DROP TABLE IF EXISTS transaction_batches;
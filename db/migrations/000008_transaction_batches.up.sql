@@ -0,0 +1,16 @@
+-- Batches each sold line was drawn from, so that a cancelled
+-- transaction returns units to the same batches.
+CREATE TABLE IF NOT EXISTS transaction_batches (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    -- NULL if the batch has since been deleted.
+    batch_id INTEGER,
+    quantity INTEGER NOT NULL CHECK(quantity > 0),
+    -- FOREIGN KEYS
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+    FOREIGN KEY (batch_id) REFERENCES product_batches(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS transaction_batches_transaction_id_idx ON transaction_batches(transaction_id);
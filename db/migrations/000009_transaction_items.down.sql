@@ -0,0 +1,144 @@
+DROP VIEW IF EXISTS sales_reports;
+DROP VIEW IF EXISTS product_sales;
+DROP VIEW IF EXISTS product_aggregates;
+DROP VIEW IF EXISTS stock_card;
+
+DROP TRIGGER IF EXISTS update_stock_balance_trigger ON transaction_items;
+
+-- Rebuild the JSON items from the line items.
+ALTER TABLE transactions ADD COLUMN items JSONB NOT NULL DEFAULT '[]';
+
+UPDATE transactions SET items = (
+    SELECT COALESCE(jsonb_agg(jsonb_build_object(
+        'id', ti.product_id,
+        'generic_name', COALESCE(p.generic_name, ti.product_name),
+        'brand_name', COALESCE(p.brand_name, ''),
+        'quantity', ti.quantity,
+        'cost_price', ti.unit_cost,
+        'selling_price', ti.unit_price,
+        'expiry_dates', COALESCE(to_jsonb(p.expiry_dates), '[]'::jsonb),
+        'barcode', COALESCE(p.barcode, ''),
+        'created_at', transactions.created_at,
+        'updated_at', COALESCE(p.updated_at, transactions.created_at)
+    ) ORDER BY ti.id), '[]'::jsonb)
+    FROM transaction_items ti
+    LEFT JOIN products p ON ti.product_id = p.id
+    WHERE ti.transaction_id = transactions.id
+);
+
+ALTER TABLE transactions ALTER COLUMN items DROP DEFAULT;
+
+CREATE OR REPLACE FUNCTION update_stock_balance()
+RETURNS TRIGGER AS $$
+DECLARE
+    item_id int; 
+    prod_quantity int;
+BEGIN
+    -- Iterate over each item in the transactions.items array
+    FOR item_id IN SELECT (jsonb_array_elements(NEW.items)->>'id')::INTEGER
+    LOOP
+        -- Check if the item's product_id already has a stock balance record for the current date
+        IF NOT EXISTS (
+            SELECT 1 FROM stock_balances
+            WHERE product_id = item_id
+            AND DATE_TRUNC('day', created_at) = DATE_TRUNC('day', CURRENT_TIMESTAMP)
+        ) THEN
+            -- Select the current stock balance for the product
+            SELECT products.quantity INTO prod_quantity FROM products WHERE id = item_id;
+            
+            -- Create a new stock balance record for the product for the current date
+            INSERT INTO stock_balances (product_id, opening_quantity, quantity_in)
+            VALUES (item_id, prod_quantity, 0);
+        END IF;
+    END LOOP;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_stock_balance_trigger
+BEFORE INSERT ON transactions
+FOR EACH ROW
+EXECUTE FUNCTION update_stock_balance();
+
+DROP INDEX IF EXISTS transaction_items_product_id_idx;
+DROP INDEX IF EXISTS transaction_items_transaction_id_idx;
+DROP TABLE IF EXISTS transaction_items;
+
+CREATE OR REPLACE VIEW stock_card AS
+WITH QuantityOutCTE AS (
+    SELECT 
+        created_at::date AS date,
+        (item->>'id')::INTEGER AS product_id,
+        SUM((item->>'quantity')::INTEGER) AS total_quantity_out
+    FROM transactions,
+        LATERAL jsonb_array_elements(items) AS items(item)
+    WHERE (item->>'id')::INTEGER IN (SELECT id FROM products)
+    GROUP BY date, product_id
+)
+
+SELECT 
+    stock_balances.created_at::date,
+    products.id AS product_id,
+    products.generic_name,
+    products.brand_name,
+    stock_balances.opening_quantity,
+    stock_balances.quantity_in,
+    COALESCE(total_quantity_out, 0) AS quantity_out,
+    stock_balances.opening_quantity + stock_balances.quantity_in - COALESCE(total_quantity_out, 0) AS closing_quantity
+FROM stock_balances
+JOIN products ON stock_balances.product_id = products.id
+LEFT JOIN QuantityOutCTE ON stock_balances.product_id = QuantityOutCTE.product_id 
+AND stock_balances.created_at::date = QuantityOutCTE.date
+ORDER BY stock_balances.created_at DESC, products.id;
+
+CREATE VIEW product_aggregates AS
+SELECT
+    t.created_at::date AS transaction_date,
+    (item.product->>'id')::int AS product_id,
+    SUM((item.product->>'quantity')::int) AS quantity_sold,
+    SUM((item.product->>'quantity')::int * (item.product->>'selling_price')::numeric) AS income
+FROM
+    transactions t
+CROSS JOIN LATERAL jsonb_array_elements(t.items) AS item(product)
+GROUP BY
+    t.created_at::date,
+    (item.product->>'id')::int;
+
+CREATE VIEW product_sales AS
+WITH product_info AS (
+    SELECT
+        id,
+        generic_name AS product_name,
+        cost_price,
+        selling_price
+    FROM
+        products
+)
+SELECT
+    pa.transaction_date,
+    pa.product_id,
+    pi.product_name,
+    pi.cost_price,
+    pi.selling_price,
+    pa.quantity_sold,
+    pa.income::double precision AS income,
+    (pi.selling_price * pa.quantity_sold - pi.cost_price * pa.quantity_sold)::double precision AS profit
+FROM
+    product_aggregates pa
+JOIN
+    product_info pi ON pa.product_id = pi.id
+ORDER BY
+    pa.transaction_date DESC,
+    pa.product_id;
+
+CREATE VIEW sales_reports AS
+SELECT
+    transaction_date,
+    SUM(income)::double precision AS total_income
+FROM
+    product_sales
+GROUP BY
+    transaction_date
+ORDER BY
+    transaction_date DESC;
@@ -0,0 +1,159 @@
+-- Line items of a sale, previously stored as JSON in transactions.items.
+-- Lines of products deleted before the line items were moved here have
+-- no product and keep the name the product was sold under.
+CREATE TABLE IF NOT EXISTS transaction_items (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL,
+    product_id INTEGER,
+    product_name TEXT NOT NULL DEFAULT '',
+    quantity INTEGER NOT NULL CHECK(quantity > 0),
+    unit_price DOUBLE PRECISION NOT NULL DEFAULT 0.00,
+    unit_cost DOUBLE PRECISION NOT NULL DEFAULT 0.00,
+    discount DOUBLE PRECISION NOT NULL DEFAULT 0.00 CHECK(discount >= 0),
+    line_total DOUBLE PRECISION NOT NULL GENERATED ALWAYS AS (quantity * unit_price - discount) STORED,
+    CONSTRAINT transaction_items_product_check CHECK (product_id IS NOT NULL OR product_name <> ''),
+    -- FOREIGN KEYS
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS transaction_items_transaction_id_idx ON transaction_items(transaction_id);
+CREATE INDEX IF NOT EXISTS transaction_items_product_id_idx ON transaction_items(product_id);
+
+-- Back-fill from the JSON items. The items hold the product as it was at the
+-- time of sale so the prices are the historical ones. Every line is kept, so
+-- the sale totals still add up: lines of products that have since been deleted
+-- are stored without a product under the name they were sold with.
+INSERT INTO transaction_items (transaction_id, product_id, product_name, quantity, unit_price, unit_cost)
+SELECT
+    t.id,
+    products.id,
+    CASE WHEN products.id IS NULL THEN
+        COALESCE(NULLIF(TRIM(CONCAT_WS(' ', item->>'generic_name', item->>'brand_name')), ''),
+            'Deleted product ' || COALESCE(item->>'id', ''))
+    ELSE '' END,
+    (item->>'quantity')::int,
+    COALESCE((item->>'selling_price')::double precision, 0),
+    COALESCE((item->>'cost_price')::double precision, 0)
+FROM transactions t
+CROSS JOIN LATERAL jsonb_array_elements(t.items) AS items(item)
+LEFT JOIN products ON products.id = (item->>'id')::int
+WHERE (item->>'quantity')::int > 0
+ORDER BY t.id;
+
+
+-- Views and triggers reading transactions.items are rebuilt over transaction_items.
+DROP VIEW IF EXISTS sales_reports;
+DROP VIEW IF EXISTS product_sales;
+DROP VIEW IF EXISTS product_aggregates;
+DROP VIEW IF EXISTS stock_card;
+
+DROP TRIGGER IF EXISTS update_stock_balance_trigger ON transactions;
+
+ALTER TABLE transactions DROP COLUMN items;
+
+
+-- Snapshot the opening balance of the product before its first sale of the day.
+CREATE OR REPLACE FUNCTION update_stock_balance()
+RETURNS TRIGGER AS $$
+DECLARE
+    prod_quantity int;
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM stock_balances
+        WHERE product_id = NEW.product_id
+        AND DATE_TRUNC('day', created_at) = DATE_TRUNC('day', CURRENT_TIMESTAMP)
+    ) THEN
+        SELECT products.quantity INTO prod_quantity FROM products WHERE id = NEW.product_id;
+
+        INSERT INTO stock_balances (product_id, opening_quantity, quantity_in)
+        VALUES (NEW.product_id, prod_quantity, 0);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_stock_balance_trigger
+BEFORE INSERT ON transaction_items
+FOR EACH ROW
+EXECUTE FUNCTION update_stock_balance();
+
+
+CREATE OR REPLACE VIEW stock_card AS
+WITH QuantityOutCTE AS (
+    SELECT 
+        transactions.created_at::date AS date,
+        transaction_items.product_id,
+        SUM(transaction_items.quantity) AS total_quantity_out
+    FROM transaction_items
+    JOIN transactions ON transaction_items.transaction_id = transactions.id
+    GROUP BY date, transaction_items.product_id
+)
+
+SELECT 
+    stock_balances.created_at::date,
+    products.id AS product_id,
+    products.generic_name,
+    products.brand_name,
+    stock_balances.opening_quantity,
+    stock_balances.quantity_in,
+    COALESCE(total_quantity_out, 0) AS quantity_out,
+    stock_balances.opening_quantity + stock_balances.quantity_in - COALESCE(total_quantity_out, 0) AS closing_quantity
+FROM stock_balances
+JOIN products ON stock_balances.product_id = products.id
+LEFT JOIN QuantityOutCTE ON stock_balances.product_id = QuantityOutCTE.product_id 
+AND stock_balances.created_at::date = QuantityOutCTE.date
+ORDER BY stock_balances.created_at DESC, products.id;
+
+
+CREATE VIEW product_aggregates AS
+SELECT
+    t.created_at::date AS transaction_date,
+    ti.product_id,
+    SUM(ti.quantity) AS quantity_sold,
+    SUM(ti.line_total)::double precision AS income
+FROM
+    transaction_items ti
+JOIN transactions t ON ti.transaction_id = t.id
+GROUP BY
+    t.created_at::date,
+    ti.product_id;
+
+CREATE VIEW product_sales AS
+WITH product_info AS (
+    SELECT
+        id,
+        generic_name AS product_name,
+        cost_price,
+        selling_price
+    FROM
+        products
+)
+SELECT
+    pa.transaction_date,
+    pa.product_id,
+    pi.product_name,
+    pi.cost_price,
+    pi.selling_price,
+    pa.quantity_sold,
+    pa.income::double precision AS income,
+    (pi.selling_price * pa.quantity_sold - pi.cost_price * pa.quantity_sold)::double precision AS profit
+FROM
+    product_aggregates pa
+JOIN
+    product_info pi ON pa.product_id = pi.id
+ORDER BY
+    pa.transaction_date DESC,
+    pa.product_id;
+
+CREATE VIEW sales_reports AS
+SELECT
+    transaction_date,
+    SUM(income)::double precision AS total_income
+FROM
+    product_sales
+GROUP BY
+    transaction_date
+ORDER BY
+    transaction_date DESC;
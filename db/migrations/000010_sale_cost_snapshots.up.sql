@@ -0,0 +1,58 @@
+-- Cost of the units taken from each batch at the time of sale.
+ALTER TABLE transaction_batches ADD COLUMN unit_cost DOUBLE PRECISION NOT NULL DEFAULT 0.00;
+
+UPDATE transaction_batches SET unit_cost = product_batches.cost_price
+FROM product_batches
+WHERE transaction_batches.batch_id = product_batches.id;
+
+-- The cost of a sold line is the weighted cost of the batches it was drawn from.
+UPDATE transaction_items SET unit_cost = allocations.unit_cost
+FROM (
+    SELECT transaction_id, product_id,
+        SUM(quantity * unit_cost) / SUM(quantity) AS unit_cost
+    FROM transaction_batches
+    WHERE batch_id IS NOT NULL
+    GROUP BY transaction_id, product_id
+) allocations
+WHERE transaction_items.transaction_id = allocations.transaction_id
+AND transaction_items.product_id = allocations.product_id;
+
+
+-- Income and profit are computed from the price and cost captured at the time
+-- of sale, so later price changes do not rewrite past reports.
+DROP VIEW IF EXISTS sales_reports;
+DROP VIEW IF EXISTS product_sales;
+
+CREATE VIEW product_sales AS
+SELECT
+    t.created_at::date AS transaction_date,
+    ti.product_id,
+    p.generic_name AS product_name,
+    (SUM(ti.quantity * ti.unit_cost) / SUM(ti.quantity))::double precision AS cost_price,
+    (SUM(ti.quantity * ti.unit_price) / SUM(ti.quantity))::double precision AS selling_price,
+    SUM(ti.quantity) AS quantity_sold,
+    SUM(ti.line_total)::double precision AS income,
+    SUM(ti.quantity * ti.unit_cost)::double precision AS cost,
+    (SUM(ti.line_total) - SUM(ti.quantity * ti.unit_cost))::double precision AS profit
+FROM
+    transaction_items ti
+JOIN transactions t ON ti.transaction_id = t.id
+JOIN products p ON ti.product_id = p.id
+GROUP BY
+    t.created_at::date,
+    ti.product_id,
+    p.generic_name
+ORDER BY
+    transaction_date DESC,
+    ti.product_id;
+
+CREATE VIEW sales_reports AS
+SELECT
+    transaction_date,
+    SUM(income)::double precision AS total_income
+FROM
+    product_sales
+GROUP BY
+    transaction_date
+ORDER BY
+    transaction_date DESC;
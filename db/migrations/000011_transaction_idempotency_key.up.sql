@@ -0,0 +1,3 @@
+-- Key sent by the POS with a sale so that a retried request
+-- returns the recorded sale instead of creating a duplicate.
+ALTER TABLE transactions ADD COLUMN idempotency_key VARCHAR(100) UNIQUE;
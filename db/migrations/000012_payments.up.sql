@@ -0,0 +1,20 @@
+CREATE TYPE payment_method AS ENUM ('cash', 'mobile_money', 'card', 'insurance', 'credit');
+
+-- Tenders received for a sale. A sale may be split across several tenders.
+-- amount is the part of the sale settled by the tender, tendered is what the
+-- customer handed over; only cash can be tendered in excess and given change.
+CREATE TABLE IF NOT EXISTS payments (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL,
+    method payment_method NOT NULL,
+    amount DOUBLE PRECISION NOT NULL CHECK (amount >= 0),
+    tendered DOUBLE PRECISION NOT NULL,
+    change DOUBLE PRECISION NOT NULL GENERATED ALWAYS AS (tendered - amount) STORED,
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+    CHECK (tendered >= amount),
+    CHECK (method = 'cash' OR tendered = amount)
+);
+
+CREATE INDEX IF NOT EXISTS payments_transaction_id_idx ON payments(transaction_id);
@@ -0,0 +1,41 @@
+DROP VIEW IF EXISTS sales_reports;
+DROP VIEW IF EXISTS product_sales;
+
+CREATE VIEW product_sales AS
+SELECT
+    t.created_at::date AS transaction_date,
+    ti.product_id,
+    p.generic_name AS product_name,
+    (SUM(ti.quantity * ti.unit_cost) / SUM(ti.quantity))::double precision AS cost_price,
+    (SUM(ti.quantity * ti.unit_price) / SUM(ti.quantity))::double precision AS selling_price,
+    SUM(ti.quantity) AS quantity_sold,
+    SUM(ti.line_total)::double precision AS income,
+    SUM(ti.quantity * ti.unit_cost)::double precision AS cost,
+    (SUM(ti.line_total) - SUM(ti.quantity * ti.unit_cost))::double precision AS profit
+FROM
+    transaction_items ti
+JOIN transactions t ON ti.transaction_id = t.id
+JOIN products p ON ti.product_id = p.id
+GROUP BY
+    t.created_at::date,
+    ti.product_id,
+    p.generic_name
+ORDER BY
+    transaction_date DESC,
+    ti.product_id;
+
+CREATE VIEW sales_reports AS
+SELECT
+    transaction_date,
+    SUM(income)::double precision AS total_income
+FROM
+    product_sales
+GROUP BY
+    transaction_date
+ORDER BY
+    transaction_date DESC;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS discount_approved_by;
+ALTER TABLE transaction_items DROP COLUMN IF EXISTS basket_discount;
+DROP TABLE IF EXISTS user_pins;
+DROP TABLE IF EXISTS role_discount_limits;
@@ -0,0 +1,69 @@
+-- Largest discount, as a percentage of a line, each role may give without
+-- a manager override.
+CREATE TABLE IF NOT EXISTS role_discount_limits (
+    role user_role PRIMARY KEY,
+    max_percent DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (max_percent >= 0 AND max_percent <= 100)
+);
+
+INSERT INTO role_discount_limits (role, max_percent) VALUES
+    ('cashier', 5),
+    ('pharmacist', 10),
+    ('store_keeper', 0),
+    ('manager', 100),
+    ('admin', 100)
+ON CONFLICT (role) DO NOTHING;
+
+-- PINs entered at the till to approve discounts above the cashier's limit.
+CREATE TABLE IF NOT EXISTS user_pins (
+    user_id INTEGER PRIMARY KEY,
+    pin_hash TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- discount is the whole discount of the line, basket_discount is the part of
+-- it that is the line's share of a discount on the whole sale.
+ALTER TABLE transaction_items ADD COLUMN basket_discount DOUBLE PRECISION NOT NULL DEFAULT 0.00;
+ALTER TABLE transaction_items ADD CONSTRAINT transaction_items_basket_discount_check
+    CHECK (basket_discount >= 0 AND basket_discount <= discount);
+
+-- User whose PIN approved a discount above the cashier's limit.
+ALTER TABLE transactions ADD COLUMN discount_approved_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+DROP VIEW IF EXISTS sales_reports;
+DROP VIEW IF EXISTS product_sales;
+
+CREATE VIEW product_sales AS
+SELECT
+    t.created_at::date AS transaction_date,
+    ti.product_id,
+    p.generic_name AS product_name,
+    (SUM(ti.quantity * ti.unit_cost) / SUM(ti.quantity))::double precision AS cost_price,
+    (SUM(ti.quantity * ti.unit_price) / SUM(ti.quantity))::double precision AS selling_price,
+    SUM(ti.quantity) AS quantity_sold,
+    SUM(ti.line_total)::double precision AS income,
+    SUM(ti.quantity * ti.unit_cost)::double precision AS cost,
+    (SUM(ti.line_total) - SUM(ti.quantity * ti.unit_cost))::double precision AS profit,
+    SUM(ti.discount)::double precision AS discount
+FROM
+    transaction_items ti
+JOIN transactions t ON ti.transaction_id = t.id
+JOIN products p ON ti.product_id = p.id
+GROUP BY
+    t.created_at::date,
+    ti.product_id,
+    p.generic_name
+ORDER BY
+    transaction_date DESC,
+    ti.product_id;
+
+CREATE VIEW sales_reports AS
+SELECT
+    transaction_date,
+    SUM(income)::double precision AS total_income
+FROM
+    product_sales
+GROUP BY
+    transaction_date
+ORDER BY
+    transaction_date DESC;
@@ -0,0 +1,46 @@
+DROP VIEW IF EXISTS sales_reports;
+DROP VIEW IF EXISTS product_sales;
+
+CREATE VIEW product_sales AS
+SELECT
+    t.created_at::date AS transaction_date,
+    ti.product_id,
+    p.generic_name AS product_name,
+    (SUM(ti.quantity * ti.unit_cost) / SUM(ti.quantity))::double precision AS cost_price,
+    (SUM(ti.quantity * ti.unit_price) / SUM(ti.quantity))::double precision AS selling_price,
+    SUM(ti.quantity) AS quantity_sold,
+    SUM(ti.line_total)::double precision AS income,
+    SUM(ti.quantity * ti.unit_cost)::double precision AS cost,
+    (SUM(ti.line_total) - SUM(ti.quantity * ti.unit_cost))::double precision AS profit,
+    SUM(ti.discount)::double precision AS discount
+FROM
+    transaction_items ti
+JOIN transactions t ON ti.transaction_id = t.id
+JOIN products p ON ti.product_id = p.id
+GROUP BY
+    t.created_at::date,
+    ti.product_id,
+    p.generic_name
+ORDER BY
+    transaction_date DESC,
+    ti.product_id;
+
+CREATE VIEW sales_reports AS
+SELECT
+    transaction_date,
+    SUM(income)::double precision AS total_income
+FROM
+    product_sales
+GROUP BY
+    transaction_date
+ORDER BY
+    transaction_date DESC;
+
+ALTER TABLE transaction_items DROP COLUMN IF EXISTS tax_inclusive;
+ALTER TABLE transaction_items DROP COLUMN IF EXISTS tax;
+ALTER TABLE transaction_items DROP COLUMN IF EXISTS tax_rate;
+ALTER TABLE transaction_items DROP COLUMN IF EXISTS tax_class_id;
+ALTER TABLE products DROP COLUMN IF EXISTS price_includes_tax;
+ALTER TABLE products DROP COLUMN IF EXISTS tax_class_id;
+DROP TABLE IF EXISTS tax_classes;
+DROP TYPE IF EXISTS tax_kind;
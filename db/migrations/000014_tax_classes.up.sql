@@ -0,0 +1,69 @@
+CREATE TYPE tax_kind AS ENUM ('standard', 'zero_rated', 'exempt');
+
+-- Tax classes assigned to products. Only standard rated classes charge tax.
+CREATE TABLE IF NOT EXISTS tax_classes (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    kind tax_kind NOT NULL,
+    rate DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (rate >= 0 AND rate <= 100),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (kind = 'standard' OR rate = 0)
+);
+
+INSERT INTO tax_classes (name, kind, rate) VALUES
+    ('Standard', 'standard', 18),
+    ('Zero rated', 'zero_rated', 0),
+    ('Exempt', 'exempt', 0)
+ON CONFLICT (name) DO NOTHING;
+
+-- Products without a tax class are not taxed.
+-- price_includes_tax tells whether the selling price includes the tax
+-- or the tax is added on top of it at the till.
+ALTER TABLE products ADD COLUMN tax_class_id INTEGER REFERENCES tax_classes(id) ON DELETE SET NULL;
+ALTER TABLE products ADD COLUMN price_includes_tax BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Tax of each sold line at the rate in force at the time of sale.
+ALTER TABLE transaction_items ADD COLUMN tax_class_id INTEGER REFERENCES tax_classes(id) ON DELETE SET NULL;
+ALTER TABLE transaction_items ADD COLUMN tax_rate DOUBLE PRECISION NOT NULL DEFAULT 0.00;
+ALTER TABLE transaction_items ADD COLUMN tax DOUBLE PRECISION NOT NULL DEFAULT 0.00 CHECK (tax >= 0);
+ALTER TABLE transaction_items ADD COLUMN tax_inclusive BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Income is reported net of tax.
+DROP VIEW IF EXISTS sales_reports;
+DROP VIEW IF EXISTS product_sales;
+
+CREATE VIEW product_sales AS
+SELECT
+    t.created_at::date AS transaction_date,
+    ti.product_id,
+    p.generic_name AS product_name,
+    (SUM(ti.quantity * ti.unit_cost) / SUM(ti.quantity))::double precision AS cost_price,
+    (SUM(ti.quantity * ti.unit_price) / SUM(ti.quantity))::double precision AS selling_price,
+    SUM(ti.quantity) AS quantity_sold,
+    SUM(ti.line_total - CASE WHEN ti.tax_inclusive THEN ti.tax ELSE 0 END)::double precision AS income,
+    SUM(ti.quantity * ti.unit_cost)::double precision AS cost,
+    (SUM(ti.line_total - CASE WHEN ti.tax_inclusive THEN ti.tax ELSE 0 END)
+        - SUM(ti.quantity * ti.unit_cost))::double precision AS profit,
+    SUM(ti.discount)::double precision AS discount
+FROM
+    transaction_items ti
+JOIN transactions t ON ti.transaction_id = t.id
+JOIN products p ON ti.product_id = p.id
+GROUP BY
+    t.created_at::date,
+    ti.product_id,
+    p.generic_name
+ORDER BY
+    transaction_date DESC,
+    ti.product_id;
+
+CREATE VIEW sales_reports AS
+SELECT
+    transaction_date,
+    SUM(income)::double precision AS total_income
+FROM
+    product_sales
+GROUP BY
+    transaction_date
+ORDER BY
+    transaction_date DESC;
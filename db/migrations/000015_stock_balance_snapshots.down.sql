@@ -0,0 +1,90 @@
+DROP VIEW IF EXISTS stock_card;
+
+CREATE VIEW stock_card AS
+WITH QuantityOutCTE AS (
+    SELECT
+        transactions.created_at::date AS date,
+        transaction_items.product_id,
+        SUM(transaction_items.quantity) AS total_quantity_out
+    FROM transaction_items
+    JOIN transactions ON transaction_items.transaction_id = transactions.id
+    GROUP BY date, transaction_items.product_id
+)
+
+SELECT
+    stock_balances.created_at::date,
+    products.id AS product_id,
+    products.generic_name,
+    products.brand_name,
+    stock_balances.opening_quantity,
+    stock_balances.quantity_in,
+    COALESCE(total_quantity_out, 0) AS quantity_out,
+    stock_balances.opening_quantity + stock_balances.quantity_in - COALESCE(total_quantity_out, 0) AS closing_quantity
+FROM stock_balances
+JOIN products ON stock_balances.product_id = products.id
+LEFT JOIN QuantityOutCTE ON stock_balances.product_id = QuantityOutCTE.product_id
+AND stock_balances.created_at::date = QuantityOutCTE.date
+ORDER BY stock_balances.created_at DESC, products.id;
+
+DROP TRIGGER IF EXISTS update_stock_balance_after_transaction_delete_trigger ON transactions;
+DROP FUNCTION IF EXISTS update_stock_balance_after_transaction_delete;
+
+DROP TRIGGER IF EXISTS update_stock_balance_after_stock_in_delete_trigger ON stock_in;
+DROP FUNCTION IF EXISTS update_stock_balance_after_stock_in_delete;
+
+CREATE OR REPLACE FUNCTION initialize_stock_balance()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO stock_balances (product_id, opening_quantity, quantity_in)
+    VALUES (NEW.id, 0, NEW.quantity);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION update_stock_balance_after_stock_in()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM stock_balances
+        WHERE product_id = NEW.product_id
+        AND DATE_TRUNC('day', created_at) = DATE_TRUNC('day', CURRENT_TIMESTAMP)
+    ) THEN
+        INSERT INTO stock_balances (product_id, opening_quantity, quantity_in)
+        VALUES (NEW.product_id, 0, NEW.quantity);
+    ELSE
+        UPDATE stock_balances
+        SET quantity_in = quantity_in + NEW.quantity
+        WHERE product_id = NEW.product_id
+        AND DATE_TRUNC('day', created_at) = DATE_TRUNC('day', CURRENT_TIMESTAMP);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION update_stock_balance()
+RETURNS TRIGGER AS $$
+DECLARE
+    prod_quantity int;
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM stock_balances
+        WHERE product_id = NEW.product_id
+        AND DATE_TRUNC('day', created_at) = DATE_TRUNC('day', CURRENT_TIMESTAMP)
+    ) THEN
+        SELECT products.quantity INTO prod_quantity FROM products WHERE id = NEW.product_id;
+
+        INSERT INTO stock_balances (product_id, opening_quantity, quantity_in)
+        VALUES (NEW.product_id, prod_quantity, 0);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS stock_balance_snapshots;
+
+ALTER TABLE stock_balances DROP CONSTRAINT IF EXISTS stock_balances_product_date_key;
+ALTER TABLE stock_balances DROP COLUMN IF EXISTS balance_date;
+
+DROP FUNCTION IF EXISTS pharmacy_date;
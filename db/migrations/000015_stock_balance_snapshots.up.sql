@@ -0,0 +1,149 @@
+-- Stock balances are kept per day of the pharmacy, in Africa/Kampala time,
+-- whatever the time zone of the database session.
+CREATE OR REPLACE FUNCTION pharmacy_date(ts TIMESTAMPTZ)
+RETURNS DATE AS $$
+    SELECT (ts AT TIME ZONE 'Africa/Kampala')::date;
+$$ LANGUAGE sql STABLE;
+
+
+ALTER TABLE stock_balances ADD COLUMN balance_date DATE;
+UPDATE stock_balances SET balance_date = pharmacy_date(created_at);
+
+-- Merge the balances recorded more than once on a day into the first of them.
+UPDATE stock_balances SET quantity_in = merged.quantity_in
+FROM (
+    SELECT MIN(id) AS id, SUM(quantity_in) AS quantity_in
+    FROM stock_balances
+    GROUP BY product_id, balance_date
+    HAVING COUNT(*) > 1
+) merged
+WHERE stock_balances.id = merged.id;
+
+DELETE FROM stock_balances
+WHERE id NOT IN (SELECT MIN(id) FROM stock_balances GROUP BY product_id, balance_date);
+
+ALTER TABLE stock_balances ALTER COLUMN balance_date SET NOT NULL;
+ALTER TABLE stock_balances ALTER COLUMN balance_date SET DEFAULT pharmacy_date(CURRENT_TIMESTAMP);
+ALTER TABLE stock_balances ADD CONSTRAINT stock_balances_product_date_key UNIQUE (product_id, balance_date);
+
+
+-- Days on which the opening balances of all products were recorded.
+CREATE TABLE IF NOT EXISTS stock_balance_snapshots (
+    balance_date DATE PRIMARY KEY,
+    products INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+
+-- Snapshot the opening balance of the product before its first sale of the day.
+CREATE OR REPLACE FUNCTION update_stock_balance()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO stock_balances (product_id, opening_quantity, quantity_in)
+    SELECT id, quantity, 0 FROM products WHERE id = NEW.product_id
+    ON CONFLICT (product_id, balance_date) DO NOTHING;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+
+-- Stock received on a day it has no balance opens with the quantity in stock
+-- before it is received. The batch of the stock in is created after it.
+CREATE OR REPLACE FUNCTION update_stock_balance_after_stock_in()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO stock_balances (product_id, opening_quantity, quantity_in)
+    SELECT id, quantity, NEW.quantity FROM products WHERE id = NEW.product_id
+    ON CONFLICT (product_id, balance_date)
+    DO UPDATE SET quantity_in = stock_balances.quantity_in + EXCLUDED.quantity_in;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+
+-- The quantity a product is created with is received on the day it is created.
+CREATE OR REPLACE FUNCTION initialize_stock_balance()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO stock_balances (product_id, opening_quantity, quantity_in)
+    VALUES (NEW.id, 0, NEW.quantity)
+    ON CONFLICT (product_id, balance_date) DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+
+-- Deleted stock in is taken off the day it was received
+-- and the balances of the days after it.
+CREATE OR REPLACE FUNCTION update_stock_balance_after_stock_in_delete()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE stock_balances SET quantity_in = quantity_in - OLD.quantity
+    WHERE product_id = OLD.product_id AND balance_date = pharmacy_date(OLD.created_at);
+
+    UPDATE stock_balances SET opening_quantity = opening_quantity - OLD.quantity
+    WHERE product_id = OLD.product_id AND balance_date > pharmacy_date(OLD.created_at);
+
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_stock_balance_after_stock_in_delete_trigger
+AFTER DELETE ON stock_in
+FOR EACH ROW
+EXECUTE FUNCTION update_stock_balance_after_stock_in_delete();
+
+
+-- Stock returned by a deleted sale is added back to the balances of the days
+-- after the sale. The sale itself no longer counts as stock out.
+CREATE OR REPLACE FUNCTION update_stock_balance_after_transaction_delete()
+RETURNS TRIGGER AS $$
+DECLARE
+    item RECORD;
+BEGIN
+    FOR item IN SELECT product_id, SUM(quantity) AS quantity
+        FROM transaction_items WHERE transaction_id = OLD.id GROUP BY product_id
+    LOOP
+        UPDATE stock_balances SET opening_quantity = opening_quantity + item.quantity
+        WHERE product_id = item.product_id AND balance_date > pharmacy_date(OLD.created_at);
+    END LOOP;
+
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_stock_balance_after_transaction_delete_trigger
+BEFORE DELETE ON transactions
+FOR EACH ROW
+EXECUTE FUNCTION update_stock_balance_after_transaction_delete();
+
+
+DROP VIEW IF EXISTS stock_card;
+
+CREATE VIEW stock_card AS
+WITH QuantityOutCTE AS (
+    SELECT
+        pharmacy_date(transactions.created_at) AS date,
+        transaction_items.product_id,
+        SUM(transaction_items.quantity) AS total_quantity_out
+    FROM transaction_items
+    JOIN transactions ON transaction_items.transaction_id = transactions.id
+    GROUP BY date, transaction_items.product_id
+)
+
+SELECT
+    stock_balances.balance_date,
+    products.id AS product_id,
+    products.generic_name,
+    products.brand_name,
+    stock_balances.opening_quantity,
+    stock_balances.quantity_in,
+    COALESCE(total_quantity_out, 0) AS quantity_out,
+    stock_balances.opening_quantity + stock_balances.quantity_in - COALESCE(total_quantity_out, 0) AS closing_quantity
+FROM stock_balances
+JOIN products ON stock_balances.product_id = products.id
+LEFT JOIN QuantityOutCTE ON stock_balances.product_id = QuantityOutCTE.product_id
+AND stock_balances.balance_date = QuantityOutCTE.date
+ORDER BY stock_balances.balance_date DESC, products.id;
@@ -0,0 +1,36 @@
+DROP VIEW IF EXISTS stock_card;
+
+CREATE VIEW stock_card AS
+WITH QuantityOutCTE AS (
+    SELECT
+        pharmacy_date(transactions.created_at) AS date,
+        transaction_items.product_id,
+        SUM(transaction_items.quantity) AS total_quantity_out
+    FROM transaction_items
+    JOIN transactions ON transaction_items.transaction_id = transactions.id
+    GROUP BY date, transaction_items.product_id
+)
+
+SELECT
+    stock_balances.balance_date,
+    products.id AS product_id,
+    products.generic_name,
+    products.brand_name,
+    stock_balances.opening_quantity,
+    stock_balances.quantity_in,
+    COALESCE(total_quantity_out, 0) AS quantity_out,
+    stock_balances.opening_quantity + stock_balances.quantity_in - COALESCE(total_quantity_out, 0) AS closing_quantity
+FROM stock_balances
+JOIN products ON stock_balances.product_id = products.id
+LEFT JOIN QuantityOutCTE ON stock_balances.product_id = QuantityOutCTE.product_id
+AND stock_balances.balance_date = QuantityOutCTE.date
+ORDER BY stock_balances.balance_date DESC, products.id;
+
+DROP TRIGGER IF EXISTS update_stock_balance_after_adjustment_trigger ON stock_adjustments;
+DROP FUNCTION IF EXISTS update_stock_balance_after_adjustment;
+
+ALTER TABLE stock_balances DROP COLUMN IF EXISTS quantity_adjusted;
+
+DROP TABLE IF EXISTS stock_adjustments;
+DROP TYPE IF EXISTS adjustment_status;
+DROP TYPE IF EXISTS adjustment_reason;
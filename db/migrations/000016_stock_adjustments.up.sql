@@ -0,0 +1,82 @@
+-- Stock removed or corrected outside of sales and stock in. Adjustments change
+-- the quantity in stock once they are approved by a manager.
+CREATE TYPE adjustment_reason AS ENUM ('damaged', 'expired', 'theft', 'count_correction', 'returned_to_supplier');
+CREATE TYPE adjustment_status AS ENUM ('pending', 'approved', 'rejected');
+
+CREATE TABLE IF NOT EXISTS stock_adjustments (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    -- Units added, or removed if negative. Only count corrections add stock.
+    quantity INTEGER NOT NULL CHECK(quantity <> 0),
+    reason adjustment_reason NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    status adjustment_status NOT NULL DEFAULT 'pending',
+    requested_by INTEGER NOT NULL,
+    reviewed_by INTEGER,
+    reviewed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK(quantity < 0 OR reason = 'count_correction'),
+    -- FOREIGN KEYS
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+    FOREIGN KEY (requested_by) REFERENCES users(id) ON DELETE RESTRICT,
+    FOREIGN KEY (reviewed_by) REFERENCES users(id) ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS stock_adjustments_product_id_idx ON stock_adjustments(product_id);
+CREATE INDEX IF NOT EXISTS stock_adjustments_status_idx ON stock_adjustments(status);
+
+
+ALTER TABLE stock_balances ADD COLUMN quantity_adjusted INTEGER NOT NULL DEFAULT 0;
+
+-- Record an approved adjustment on the stock balance of the day. The adjustment
+-- is approved before the batches are changed so the day opens with the
+-- quantity in stock before it.
+CREATE OR REPLACE FUNCTION update_stock_balance_after_adjustment()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status = 'approved' AND OLD.status <> 'approved' THEN
+        INSERT INTO stock_balances (product_id, balance_date, opening_quantity, quantity_adjusted)
+        SELECT id, pharmacy_date(NEW.reviewed_at), quantity, NEW.quantity FROM products WHERE id = NEW.product_id
+        ON CONFLICT (product_id, balance_date)
+        DO UPDATE SET quantity_adjusted = stock_balances.quantity_adjusted + EXCLUDED.quantity_adjusted;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_stock_balance_after_adjustment_trigger
+AFTER UPDATE OF status ON stock_adjustments
+FOR EACH ROW
+EXECUTE FUNCTION update_stock_balance_after_adjustment();
+
+
+DROP VIEW IF EXISTS stock_card;
+
+CREATE VIEW stock_card AS
+WITH QuantityOutCTE AS (
+    SELECT
+        pharmacy_date(transactions.created_at) AS date,
+        transaction_items.product_id,
+        SUM(transaction_items.quantity) AS total_quantity_out
+    FROM transaction_items
+    JOIN transactions ON transaction_items.transaction_id = transactions.id
+    GROUP BY date, transaction_items.product_id
+)
+
+SELECT
+    stock_balances.balance_date,
+    products.id AS product_id,
+    products.generic_name,
+    products.brand_name,
+    stock_balances.opening_quantity,
+    stock_balances.quantity_in,
+    COALESCE(total_quantity_out, 0) AS quantity_out,
+    stock_balances.quantity_adjusted,
+    stock_balances.opening_quantity + stock_balances.quantity_in - COALESCE(total_quantity_out, 0)
+        + stock_balances.quantity_adjusted AS closing_quantity
+FROM stock_balances
+JOIN products ON stock_balances.product_id = products.id
+LEFT JOIN QuantityOutCTE ON stock_balances.product_id = QuantityOutCTE.product_id
+AND stock_balances.balance_date = QuantityOutCTE.date
+ORDER BY stock_balances.balance_date DESC, products.id;
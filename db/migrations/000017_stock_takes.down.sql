@@ -0,0 +1,4 @@
+ALTER TABLE stock_adjustments DROP COLUMN IF EXISTS stock_take_id;
+DROP TABLE IF EXISTS stock_take_items;
+DROP TABLE IF EXISTS stock_takes;
+DROP TYPE IF EXISTS stock_take_status;
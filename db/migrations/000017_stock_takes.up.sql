@@ -0,0 +1,40 @@
+-- Physical counts of the stock. Opening a stock take freezes the quantity
+-- expected of every product. Approving it posts a count correction for
+-- every product counted with a different quantity.
+CREATE TYPE stock_take_status AS ENUM ('open', 'approved', 'cancelled');
+
+CREATE TABLE IF NOT EXISTS stock_takes (
+    id SERIAL PRIMARY KEY,
+    note TEXT NOT NULL DEFAULT '',
+    status stock_take_status NOT NULL DEFAULT 'open',
+    opened_by INTEGER NOT NULL,
+    closed_by INTEGER,
+    closed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- FOREIGN KEYS
+    FOREIGN KEY (opened_by) REFERENCES users(id) ON DELETE RESTRICT,
+    FOREIGN KEY (closed_by) REFERENCES users(id) ON DELETE RESTRICT
+);
+
+-- Only one stock take can be open at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS stock_takes_one_open_idx ON stock_takes ((TRUE)) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS stock_take_items (
+    id SERIAL PRIMARY KEY,
+    stock_take_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    expected_quantity INTEGER NOT NULL,
+    unit_cost DOUBLE PRECISION NOT NULL DEFAULT 0.00,
+    -- NULL until the product is counted.
+    counted_quantity INTEGER CHECK(counted_quantity >= 0),
+    counted_by INTEGER,
+    counted_at TIMESTAMPTZ,
+    UNIQUE (stock_take_id, product_id),
+    -- FOREIGN KEYS
+    FOREIGN KEY (stock_take_id) REFERENCES stock_takes(id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+    FOREIGN KEY (counted_by) REFERENCES users(id) ON DELETE RESTRICT
+);
+
+-- Adjustments posted by a stock take.
+ALTER TABLE stock_adjustments ADD COLUMN stock_take_id INTEGER REFERENCES stock_takes(id) ON DELETE SET NULL;
@@ -0,0 +1,11 @@
+ALTER TABLE invoices ADD COLUMN supplier VARCHAR(100) NOT NULL DEFAULT '';
+
+UPDATE invoices SET supplier = suppliers.name
+FROM suppliers
+WHERE suppliers.id = invoices.supplier_id;
+
+ALTER TABLE invoices ALTER COLUMN supplier DROP DEFAULT;
+ALTER TABLE invoices DROP COLUMN supplier_id;
+
+DROP TABLE IF EXISTS suppliers;
+DROP FUNCTION IF EXISTS supplier_key;
@@ -0,0 +1,50 @@
+-- Suppliers the pharmacy buys from. Invoices refer to a supplier
+-- instead of a supplier name typed on every invoice.
+CREATE TABLE IF NOT EXISTS suppliers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL CHECK(TRIM(name) <> ''),
+    contact_person VARCHAR(100) NOT NULL DEFAULT '',
+    phone VARCHAR(50) NOT NULL DEFAULT '',
+    email VARCHAR(100) NOT NULL DEFAULT '',
+    tin VARCHAR(50) NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    -- Days after the purchase date an invoice is due.
+    payment_terms_days INTEGER NOT NULL DEFAULT 30 CHECK(payment_terms_days >= 0),
+    -- Days from ordering to delivery.
+    lead_time_days INTEGER NOT NULL DEFAULT 7 CHECK(lead_time_days >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The names of suppliers typed differently in case, spacing or punctuation
+-- have the same key. "Quality Chemicals Ltd." and "quality chemicals ltd"
+-- are one supplier.
+CREATE OR REPLACE FUNCTION supplier_key(name TEXT)
+RETURNS TEXT AS $$
+    SELECT TRIM(REGEXP_REPLACE(LOWER(name), '[^a-z0-9]+', ' ', 'g'));
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS suppliers_name_key ON suppliers (supplier_key(name));
+
+UPDATE invoices SET supplier = 'Unknown supplier' WHERE supplier_key(supplier) = '';
+
+-- One supplier per key, named as it is typed on most invoices.
+INSERT INTO suppliers (name)
+SELECT DISTINCT ON (supplier_key(name)) name
+FROM (
+    SELECT TRIM(supplier) AS name, COUNT(*) AS invoices
+    FROM invoices
+    GROUP BY TRIM(supplier)
+) names
+ORDER BY supplier_key(name), invoices DESC, name;
+
+ALTER TABLE invoices ADD COLUMN supplier_id INTEGER REFERENCES suppliers(id) ON DELETE RESTRICT;
+
+UPDATE invoices SET supplier_id = suppliers.id
+FROM suppliers
+WHERE supplier_key(suppliers.name) = supplier_key(invoices.supplier);
+
+ALTER TABLE invoices ALTER COLUMN supplier_id SET NOT NULL;
+ALTER TABLE invoices DROP COLUMN supplier;
+
+CREATE INDEX IF NOT EXISTS invoices_supplier_id_idx ON invoices(supplier_id);
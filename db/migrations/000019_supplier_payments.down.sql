@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS update_invoice_amount_paid_trigger ON supplier_payments;
+DROP FUNCTION IF EXISTS update_invoice_amount_paid;
+DROP TABLE IF EXISTS supplier_payments;
+DROP TYPE IF EXISTS supplier_payment_method;
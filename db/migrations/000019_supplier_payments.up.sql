@@ -0,0 +1,56 @@
+CREATE TYPE supplier_payment_method AS ENUM ('cash', 'bank_transfer', 'mobile_money', 'cheque', 'other');
+
+-- Payments made to suppliers against their invoices. The amount paid on an
+-- invoice is the sum of its payments and is kept up to date by a trigger.
+CREATE TABLE IF NOT EXISTS supplier_payments (
+    id SERIAL PRIMARY KEY,
+    invoice_id INTEGER NOT NULL,
+    payment_date DATE NOT NULL,
+    amount DOUBLE PRECISION NOT NULL CHECK(amount > 0),
+    method supplier_payment_method NOT NULL,
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- FOREIGN KEYS
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE RESTRICT,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS supplier_payments_invoice_id_idx ON supplier_payments(invoice_id);
+
+-- The amounts paid before the ledger are recorded as one payment
+-- on the purchase date of each invoice.
+INSERT INTO supplier_payments (invoice_id, payment_date, amount, method, reference, user_id, created_at)
+SELECT id, purchase_date, amount_paid, 'other', 'Paid before the payments ledger', user_id, created_at
+FROM invoices
+WHERE amount_paid > 0;
+
+CREATE OR REPLACE FUNCTION update_invoice_amount_paid()
+RETURNS TRIGGER AS $$
+DECLARE
+    invoice INTEGER;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        invoice := OLD.invoice_id;
+    ELSE
+        invoice := NEW.invoice_id;
+    END IF;
+
+    UPDATE invoices SET amount_paid = (
+        SELECT COALESCE(SUM(amount), 0) FROM supplier_payments WHERE invoice_id = invoice
+    ) WHERE id = invoice;
+
+    IF TG_OP = 'UPDATE' AND OLD.invoice_id <> NEW.invoice_id THEN
+        UPDATE invoices SET amount_paid = (
+            SELECT COALESCE(SUM(amount), 0) FROM supplier_payments WHERE invoice_id = OLD.invoice_id
+        ) WHERE id = OLD.invoice_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_invoice_amount_paid_trigger
+AFTER INSERT OR UPDATE OR DELETE ON supplier_payments
+FOR EACH ROW
+EXECUTE FUNCTION update_invoice_amount_paid();
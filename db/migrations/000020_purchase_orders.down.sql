@@ -0,0 +1,10 @@
+-- Stock received against orders that were never invoiced is removed with them.
+DELETE FROM stock_in WHERE invoice_id IS NULL;
+
+ALTER TABLE stock_in DROP CONSTRAINT IF EXISTS stock_in_invoice_or_order;
+ALTER TABLE stock_in DROP COLUMN IF EXISTS purchase_order_item_id;
+ALTER TABLE stock_in ALTER COLUMN invoice_id SET NOT NULL;
+
+DROP TABLE IF EXISTS purchase_order_items;
+DROP TABLE IF EXISTS purchase_orders;
+DROP TYPE IF EXISTS purchase_order_status;
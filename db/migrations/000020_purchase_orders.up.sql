@@ -0,0 +1,43 @@
+CREATE TYPE purchase_order_status AS ENUM ('draft', 'sent', 'partially_received', 'closed');
+
+-- Orders for stock placed with a supplier. Stock received against an order
+-- is recorded as stock in on its lines and is put on the supplier's invoice
+-- when the order is converted.
+CREATE TABLE IF NOT EXISTS purchase_orders (
+    id SERIAL PRIMARY KEY,
+    supplier_id INTEGER NOT NULL,
+    status purchase_order_status NOT NULL DEFAULT 'draft',
+    expected_date DATE,
+    note TEXT NOT NULL DEFAULT '',
+    -- The supplier's invoice the received stock was put on.
+    invoice_id INTEGER UNIQUE,
+    created_by INTEGER NOT NULL,
+    sent_at TIMESTAMPTZ,
+    closed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- FOREIGN KEYS
+    FOREIGN KEY (supplier_id) REFERENCES suppliers(id) ON DELETE RESTRICT,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE SET NULL,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS purchase_orders_supplier_id_idx ON purchase_orders(supplier_id);
+
+CREATE TABLE IF NOT EXISTS purchase_order_items (
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK(quantity > 0),
+    unit_cost DOUBLE PRECISION NOT NULL DEFAULT 0.00 CHECK(unit_cost >= 0),
+    UNIQUE (purchase_order_id, product_id),
+    -- FOREIGN KEYS
+    FOREIGN KEY (purchase_order_id) REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE RESTRICT
+);
+
+-- Stock received against an order has no invoice until the order is converted.
+ALTER TABLE stock_in ALTER COLUMN invoice_id DROP NOT NULL;
+ALTER TABLE stock_in ADD COLUMN purchase_order_item_id INTEGER REFERENCES purchase_order_items(id) ON DELETE RESTRICT;
+ALTER TABLE stock_in ADD CONSTRAINT stock_in_invoice_or_order CHECK(invoice_id IS NOT NULL OR purchase_order_item_id IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS stock_in_purchase_order_item_id_idx ON stock_in(purchase_order_item_id);
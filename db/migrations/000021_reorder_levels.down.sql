@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS products_preferred_supplier_id_idx;
+
+ALTER TABLE products DROP COLUMN IF EXISTS preferred_supplier_id;
+ALTER TABLE products DROP COLUMN IF EXISTS reorder_quantity;
+ALTER TABLE products DROP COLUMN IF EXISTS reorder_level;
@@ -0,0 +1,17 @@
+-- A product is reordered when its stock falls to its reorder level.
+-- The reorder quantity is the least quantity ordered at a time.
+ALTER TABLE products ADD COLUMN reorder_level INTEGER NOT NULL DEFAULT 0 CHECK(reorder_level >= 0);
+ALTER TABLE products ADD COLUMN reorder_quantity INTEGER NOT NULL DEFAULT 0 CHECK(reorder_quantity >= 0);
+ALTER TABLE products ADD COLUMN preferred_supplier_id INTEGER REFERENCES suppliers(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS products_preferred_supplier_id_idx ON products(preferred_supplier_id);
+
+-- The preferred supplier of a product starts as the supplier that last invoiced it.
+UPDATE products SET preferred_supplier_id = latest.supplier_id
+FROM (
+    SELECT DISTINCT ON (stock_in.product_id) stock_in.product_id, invoices.supplier_id
+    FROM stock_in
+    JOIN invoices ON invoices.id = stock_in.invoice_id
+    ORDER BY stock_in.product_id, invoices.purchase_date DESC, stock_in.id DESC
+) latest
+WHERE latest.product_id = products.id;
@@ -0,0 +1,3 @@
+-- An invoice is closed once the stock in on it adds up to its total.
+-- The stock in on a closed invoice is locked.
+ALTER TABLE invoices ADD COLUMN closed_at TIMESTAMPTZ;
@@ -0,0 +1,41 @@
+-- Sales of products deleted before the line items were moved out of
+-- transactions.items are kept by 000009 without a product. They count
+-- towards the sales reports under product 0 and the name they were sold with.
+DROP VIEW IF EXISTS sales_reports;
+DROP VIEW IF EXISTS product_sales;
+
+CREATE VIEW product_sales AS
+SELECT
+    t.created_at::date AS transaction_date,
+    COALESCE(ti.product_id, 0) AS product_id,
+    COALESCE(p.generic_name, ti.product_name) AS product_name,
+    (SUM(ti.quantity * ti.unit_cost) / SUM(ti.quantity))::double precision AS cost_price,
+    (SUM(ti.quantity * ti.unit_price) / SUM(ti.quantity))::double precision AS selling_price,
+    SUM(ti.quantity) AS quantity_sold,
+    SUM(ti.line_total - CASE WHEN ti.tax_inclusive THEN ti.tax ELSE 0 END)::double precision AS income,
+    SUM(ti.quantity * ti.unit_cost)::double precision AS cost,
+    (SUM(ti.line_total - CASE WHEN ti.tax_inclusive THEN ti.tax ELSE 0 END)
+        - SUM(ti.quantity * ti.unit_cost))::double precision AS profit,
+    SUM(ti.discount)::double precision AS discount
+FROM
+    transaction_items ti
+JOIN transactions t ON ti.transaction_id = t.id
+LEFT JOIN products p ON ti.product_id = p.id
+GROUP BY
+    t.created_at::date,
+    COALESCE(ti.product_id, 0),
+    COALESCE(p.generic_name, ti.product_name)
+ORDER BY
+    transaction_date DESC,
+    product_id;
+
+CREATE VIEW sales_reports AS
+SELECT
+    transaction_date,
+    SUM(income)::double precision AS total_income
+FROM
+    product_sales
+GROUP BY
+    transaction_date
+ORDER BY
+    transaction_date DESC;
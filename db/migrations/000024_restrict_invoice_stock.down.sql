@@ -0,0 +1,3 @@
+ALTER TABLE stock_in DROP CONSTRAINT IF EXISTS stock_in_invoice_id_fkey;
+ALTER TABLE stock_in ADD CONSTRAINT stock_in_invoice_id_fkey
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE CASCADE;
@@ -0,0 +1,5 @@
+-- An invoice is deleted only once its stock in has been removed, one line
+-- at a time, so that stock already sold can not be deleted with it.
+ALTER TABLE stock_in DROP CONSTRAINT IF EXISTS stock_in_invoice_id_fkey;
+ALTER TABLE stock_in ADD CONSTRAINT stock_in_invoice_id_fkey
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id) ON DELETE RESTRICT;
@@ -0,0 +1,9 @@
+-- Suppliers merged by the up migration stay merged.
+DROP INDEX IF EXISTS suppliers_name_key;
+
+CREATE OR REPLACE FUNCTION supplier_key(name TEXT)
+RETURNS TEXT AS $$
+    SELECT TRIM(REGEXP_REPLACE(LOWER(name), '[^a-z0-9]+', ' ', 'g'));
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS suppliers_name_key ON suppliers (supplier_key(name));
@@ -0,0 +1,42 @@
+-- The key of a supplier also ignores the company form at the end of the name
+-- and plural words, so "Quality Chemicals", "Quality Chemical Ltd" and
+-- "Quality Chemical Co. Limited" are one supplier. Names that do not share
+-- their words, like an acronym ("QCIL"), still make separate suppliers and
+-- have to be merged by hand from the supplier page.
+DROP INDEX IF EXISTS suppliers_name_key;
+
+CREATE OR REPLACE FUNCTION supplier_key(name TEXT)
+RETURNS TEXT AS $$
+    SELECT REGEXP_REPLACE(
+        REGEXP_REPLACE(
+            TRIM(REGEXP_REPLACE(LOWER(name), '[^a-z0-9]+', ' ', 'g')),
+            '( (ltd|limited|co|company|inc|incorporated|plc|llc|corp|corporation))+$', ''),
+        '([a-z]{2})s\M', '\1', 'g');
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Suppliers that now share a key are merged into the one with the most
+-- invoices, the oldest if there is a tie, as MergeSupplier would.
+CREATE TEMPORARY TABLE supplier_merges AS
+SELECT suppliers.id AS from_id, kept.id AS into_id
+FROM suppliers
+JOIN (
+    SELECT DISTINCT ON (supplier_key(s.name)) s.id, supplier_key(s.name) AS key
+    FROM suppliers s
+    ORDER BY supplier_key(s.name), (SELECT COUNT(*) FROM invoices WHERE invoices.supplier_id = s.id) DESC, s.id
+) kept ON kept.key = supplier_key(suppliers.name)
+WHERE suppliers.id <> kept.id;
+
+UPDATE invoices SET supplier_id = supplier_merges.into_id
+FROM supplier_merges WHERE invoices.supplier_id = supplier_merges.from_id;
+
+UPDATE purchase_orders SET supplier_id = supplier_merges.into_id
+FROM supplier_merges WHERE purchase_orders.supplier_id = supplier_merges.from_id;
+
+UPDATE products SET preferred_supplier_id = supplier_merges.into_id
+FROM supplier_merges WHERE products.preferred_supplier_id = supplier_merges.from_id;
+
+DELETE FROM suppliers WHERE id IN (SELECT from_id FROM supplier_merges);
+
+DROP TABLE supplier_merges;
+
+CREATE UNIQUE INDEX IF NOT EXISTS suppliers_name_key ON suppliers (supplier_key(name));
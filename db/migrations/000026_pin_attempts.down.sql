@@ -0,0 +1 @@
+DROP TABLE IF EXISTS pin_attempts;
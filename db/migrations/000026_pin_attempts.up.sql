@@ -0,0 +1,11 @@
+-- Discount override PINs entered at the till. A cashier who enters too many
+-- wrong PINs is locked out for a while.
+CREATE TABLE IF NOT EXISTS pin_attempts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    approved_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    succeeded BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS pin_attempts_user_id_created_at_idx ON pin_attempts (user_id, created_at);
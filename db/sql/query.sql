@@ -3,9 +3,9 @@ SELECT * FROM users;
 
 -- name: CreateUser :one
 INSERT INTO
-    users (username, password)
+    users (username, password, role)
 VALUES
-    ($1, $2) RETURNING *;
+    ($1, $2, $3) RETURNING *;
 
 -- name: GetUser :one
 SELECT * FROM users WHERE id = $1;
@@ -22,21 +22,93 @@ THEN @password
     END
 WHERE id = sqlc.arg('id');
 
+-- name: UpdateUserPassword :exec
+UPDATE users SET password = $1 WHERE id = $2;
+
 -- name: ActivateUser :exec
 UPDATE users SET is_active = TRUE WHERE id = $1;
 
 -- name: DeactivateUser :exec
 UPDATE users SET is_active = FALSE WHERE id = $1;
 
--- name: PromoteUser :exec
-UPDATE users SET is_admin = TRUE WHERE id = $1;
-
--- name: DemoteUser :exec
-UPDATE users SET is_admin = FALSE WHERE id = $1;
+-- name: SetUserRole :exec
+UPDATE users SET role = $1 WHERE id = $2;
 
 -- name: DeleteUser :exec
 DELETE FROM users WHERE id = $1;
 
+-- -- Discount queries ----------------
+
+-- name: ListRoleDiscountLimits :many
+SELECT * FROM role_discount_limits ORDER BY role;
+
+-- name: GetRoleDiscountLimit :one
+SELECT max_percent FROM role_discount_limits WHERE role = $1;
+
+-- name: SetRoleDiscountLimit :exec
+INSERT INTO role_discount_limits (role, max_percent) VALUES ($1, $2)
+ON CONFLICT (role) DO UPDATE SET max_percent = EXCLUDED.max_percent;
+
+-- name: SetUserPin :exec
+INSERT INTO user_pins (user_id, pin_hash) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET pin_hash = EXCLUDED.pin_hash, updated_at = CURRENT_TIMESTAMP;
+
+-- name: DeleteUserPin :exec
+DELETE FROM user_pins WHERE user_id = $1;
+
+-- name: UserHasPin :one
+SELECT EXISTS(SELECT 1 FROM user_pins WHERE user_id = $1)::bool AS has_pin;
+
+-- name: ListDiscountApprovers :many
+-- Active users with a PIN whose role may give more than a discount, with
+-- the largest discount their role may give.
+SELECT users.id, users.username, user_pins.pin_hash, role_discount_limits.max_percent
+FROM users
+JOIN user_pins ON user_pins.user_id = users.id
+JOIN role_discount_limits ON role_discount_limits.role = users.role
+WHERE users.is_active AND role_discount_limits.max_percent > @above_percent::double precision
+ORDER BY users.id;
+
+-- name: CreatePinAttempt :exec
+INSERT INTO pin_attempts (user_id, approved_by, succeeded) VALUES ($1, $2, $3);
+
+-- name: RecentPinFailures :one
+-- Wrong override PINs entered by a user since a time and since their last
+-- right one, and when the latest was entered.
+SELECT
+    COUNT(*)::int AS failures,
+    COALESCE(MAX(created_at), @since::timestamptz)::timestamptz AS last_failure
+FROM pin_attempts
+WHERE user_id = @user_id AND NOT succeeded AND created_at > @since::timestamptz
+    AND created_at > COALESCE(
+        (SELECT MAX(created_at) FROM pin_attempts WHERE user_id = @user_id AND succeeded),
+        '-infinity'
+    );
+
+-- -- Session queries ----------------
+
+-- name: CreateSession :one
+INSERT INTO
+    sessions (id, user_id, user_agent, ip_address, expires_at)
+VALUES
+    ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: GetSession :one
+SELECT * FROM sessions WHERE id = $1;
+
+-- name: TouchSession :exec
+UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP WHERE id = $1;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions WHERE id = $1;
+
+-- name: DeleteUserSessions :exec
+-- Revoke every session of a user e.g when the account is deactivated.
+DELETE FROM sessions WHERE user_id = $1;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE expires_at < CURRENT_TIMESTAMP OR last_seen_at < @idle_since;
+
 
 -- -- Product queries ----------------
 
@@ -52,9 +124,10 @@ ORDER BY id LIMIT @lim OFFSET @off;
 -- name: CreateProduct :one
 INSERT INTO
     products (generic_name, brand_name, quantity, 
-    cost_price, selling_price, barcode, expiry_dates)
+    cost_price, selling_price, barcode, expiry_dates, tax_class_id, price_includes_tax,
+    reorder_level, reorder_quantity, preferred_supplier_id)
 VALUES
-    ($1, $2, $3, $4, $5, $6, $7) RETURNING *;
+    ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING *;
 
 
 -- name: CreateProducts :copyfrom
@@ -68,16 +141,16 @@ VALUES
 -- name: GetProduct :one
 SELECT * FROM products WHERE id = $1;
 
+-- Quantity and expiry dates are derived from the product batches.
 -- name: UpdateProduct :exec
 UPDATE products SET generic_name = $1, brand_name = $2, 
-    quantity = $3, cost_price = $4, selling_price = $5, 
-    barcode = $6, expiry_dates=$7 WHERE id = $8;
+    cost_price = $3, selling_price = $4, 
+    barcode = $5, tax_class_id = $6, price_includes_tax = $7,
+    reorder_level = $8, reorder_quantity = $9, preferred_supplier_id = $10 WHERE id = $11;
 
--- name: IncrementProduct :exec
-UPDATE products SET quantity=quantity + $2 WHERE id = $1;
-
--- name: DecrementProduct :exec
-UPDATE products SET quantity=quantity - $2 WHERE id = $1;
+-- name: LockProducts :many
+-- Lock the products in id order so that concurrent sales do not deadlock.
+SELECT * FROM products WHERE id = ANY(@ids::int[]) ORDER BY id FOR UPDATE;
 
 -- name: DeleteProduct :exec
 DELETE FROM products WHERE id = $1;
@@ -96,31 +169,163 @@ ORDER BY id LIMIT 50;
 -- name: CountProducts :one
 SELECT COUNT(*) AS count FROM products;
 
+-- -- Tax class queries ----------------
+
+-- name: ListTaxClasses :many
+SELECT * FROM tax_classes ORDER BY id;
+
+-- name: GetTaxClass :one
+SELECT * FROM tax_classes WHERE id = $1;
+
+-- name: CreateTaxClass :one
+INSERT INTO tax_classes (name, kind, rate) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: UpdateTaxClass :exec
+UPDATE tax_classes SET name = $1, kind = $2, rate = $3 WHERE id = $4;
+
+-- -- Product batch queries ----------------
+
+-- name: CreateProductBatch :one
+INSERT INTO
+    product_batches (product_id, stock_in_id, batch_number, expiry_date, quantity, cost_price)
+VALUES
+    (@product_id, sqlc.narg('stock_in_id'), @batch_number, 
+    NULLIF(@expiry_date::date, '0001-01-01'::date), @quantity, @cost_price) RETURNING *;
+
+-- name: ListProductBatches :many
+-- Batches with stock on hand, earliest expiry first.
+SELECT * FROM product_batches WHERE product_id = $1 AND quantity > 0
+ORDER BY expiry_date NULLS LAST, id;
+
+-- name: ListProductBatchesForSale :many
+-- Lock the unexpired batches with stock on hand, earliest expiry first (FEFO).
+SELECT * FROM product_batches 
+WHERE product_id = @product_id AND quantity > 0 
+    AND (expiry_date IS NULL OR expiry_date >= @today::date)
+ORDER BY expiry_date NULLS LAST, id FOR UPDATE;
+
+-- name: SellableQuantity :one
+-- Units of the product in unexpired batches.
+SELECT COALESCE(SUM(quantity), 0)::int AS quantity FROM product_batches
+WHERE product_id = @product_id AND quantity > 0
+    AND (expiry_date IS NULL OR expiry_date >= @today::date);
+
+-- name: ListProductBatchesForUpdate :many
+-- Lock all batches with stock on hand, earliest expiry first.
+-- Lock the product first, as sales do.
+SELECT * FROM product_batches WHERE product_id = $1 AND quantity > 0
+ORDER BY expiry_date NULLS LAST, id FOR UPDATE;
+
+-- name: GetLatestProductBatch :one
+SELECT * FROM product_batches WHERE product_id = $1 ORDER BY id DESC LIMIT 1;
+
+-- name: GetStockInBatch :one
+SELECT * FROM product_batches WHERE stock_in_id = $1;
+
+-- name: GetStockInBatchForUpdate :one
+-- Lock the batch of a stock in. Lock its product first, as sales do.
+SELECT * FROM product_batches WHERE stock_in_id = $1 FOR UPDATE;
+
+-- name: IncrementBatch :exec
+UPDATE product_batches SET quantity = quantity + @quantity WHERE id = @id;
+
+-- name: DecrementBatch :exec
+UPDATE product_batches SET quantity = quantity - @quantity WHERE id = @id;
+
 -- -- Transactions queries ----------------
 -- name: ListTransactionsPaginated :many
 SELECT * FROM transactions ORDER BY created_at DESC LIMIT $1 OFFSET $2;
 
 -- name: CreateTransaction :one
 INSERT INTO
-    transactions (items, user_id)
+    transactions (user_id, idempotency_key, discount_approved_by)
 VALUES
-    ($1, $2) RETURNING *;
+    ($1, $2, $3) RETURNING *;
 
 -- name: GetTransaction :one
 SELECT * FROM transactions WHERE id = $1;
 
+-- name: GetTransactionForUpdate :one
+-- Lock a sale against being cancelled twice at the same time.
+SELECT * FROM transactions WHERE id = $1 FOR UPDATE;
+
+-- name: GetTransactionByIdempotencyKey :one
+SELECT * FROM transactions WHERE idempotency_key = $1;
+
+-- name: LockIdempotencyKey :exec
+-- Serialise the submissions of a sale with the same idempotency key until
+-- the end of the transaction.
+SELECT pg_advisory_xact_lock(hashtext(@key::text));
+
 -- name: DeleteTransaction :exec
 DELETE FROM transactions WHERE id = $1;
 
+-- name: CreateTransactionItem :one
+INSERT INTO
+    transaction_items (transaction_id, product_id, quantity, unit_price, unit_cost, discount, basket_discount,
+    tax_class_id, tax_rate, tax, tax_inclusive)
+VALUES
+    ($1, $2::int, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING *;
+
+-- name: ListTransactionItems :many
+-- Line items of the given transactions with their product names.
+-- Lines of deleted products are named as they were sold.
+SELECT transaction_items.*,
+    COALESCE(products.generic_name, transaction_items.product_name)::text AS generic_name,
+    COALESCE(products.brand_name, '')::text AS brand_name,
+    COALESCE(products.barcode, '')::text AS barcode
+FROM transaction_items
+LEFT JOIN products ON transaction_items.product_id = products.id
+WHERE transaction_items.transaction_id = ANY(@transaction_ids::int[])
+ORDER BY transaction_items.id;
+
+-- name: CreateTransactionBatch :exec
+INSERT INTO
+    transaction_batches (transaction_id, product_id, batch_id, quantity, unit_cost)
+VALUES
+    ($1, $2, $3, $4, $5);
+
+-- name: SetTransactionItemCost :exec
+UPDATE transaction_items SET unit_cost = $2 WHERE id = $1;
+
+-- name: ListTransactionBatches :many
+SELECT transaction_batches.*,
+    COALESCE(product_batches.batch_number, '')::text AS batch_number,
+    product_batches.expiry_date
+FROM transaction_batches
+LEFT JOIN product_batches ON transaction_batches.batch_id = product_batches.id
+WHERE transaction_batches.transaction_id = $1
+ORDER BY transaction_batches.id;
+
+-- name: CreatePayment :one
+INSERT INTO
+    payments (transaction_id, method, amount, tendered, reference)
+VALUES
+    ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: ListPayments :many
+SELECT * FROM payments
+WHERE transaction_id = ANY(@transaction_ids::int[])
+ORDER BY id;
+
+-- name: PaymentMethodTotals :many
+-- Amount settled by each payment method for sales made between the dates.
+SELECT payments.method,
+    COUNT(DISTINCT payments.transaction_id)::int AS sales,
+    SUM(payments.amount)::double precision AS total
+FROM payments
+JOIN transactions ON payments.transaction_id = transactions.id
+WHERE transactions.created_at::date BETWEEN @from_date::date AND @to_date::date
+GROUP BY payments.method
+ORDER BY payments.method;
+
 -- Ruturn 10 most common products in transactions
 -- order by count
 -- name: MostCommonProducts :many
-SELECT DISTINCT * FROM products p
+SELECT p.*, t.count FROM products p
 JOIN (
-    SELECT DISTINCT (item->>'id')::int AS product_id,
-           COUNT(*) AS count
-    FROM transactions
-    CROSS JOIN LATERAL jsonb_array_elements(items) item
+    SELECT product_id, COUNT(*) AS count
+    FROM transaction_items
     GROUP BY product_id
 ) t ON p.id = t.product_id
 ORDER BY t.count DESC
@@ -129,27 +334,33 @@ LIMIT $1;
 -- -- Invoices queries ----------------
 
 -- name: ListInvoicesPaginated :many
-SELECT * FROM invoices ORDER BY id LIMIT $1 OFFSET $2;
+SELECT invoices.*, suppliers.name AS supplier_name
+FROM invoices
+JOIN suppliers ON suppliers.id = invoices.supplier_id
+ORDER BY invoices.id LIMIT $1 OFFSET $2;
 
 -- name: CreateInvoice :one
 INSERT INTO
     invoices (invoice_number, purchase_date, invoice_total,
-     amount_paid, supplier, user_id)
+     supplier_id, user_id)
 VALUES
-    ($1, $2, $3, $4, $5, $6) RETURNING *;
+    ($1, $2, $3, $4, $5) RETURNING *;
 
 -- name: GetInvoice :one
-SELECT * FROM invoices WHERE id = $1;
+SELECT invoices.*, suppliers.name AS supplier_name
+FROM invoices
+JOIN suppliers ON suppliers.id = invoices.supplier_id
+WHERE invoices.id = $1;
 
 -- name: UpdateInvoice :exec
+-- The amount paid is the sum of the payments of the invoice.
 UPDATE invoices SET 
         invoice_number = $1, 
         purchase_date = $2, 
         invoice_total = $3, 
-        amount_paid = $4, 
-        supplier = $5, 
-        user_id = $6 
-        WHERE id = $7;
+        supplier_id = $4, 
+        user_id = $5 
+        WHERE id = $6;
 
 -- name: DeleteInvoice :exec
 DELETE FROM invoices WHERE id = $1;
@@ -160,6 +371,141 @@ SELECT * FROM invoices WHERE invoice_number = $1;
 -- name: GetInvoiceByNumber :one
 SELECT * FROM invoices WHERE invoice_number = $1;
 
+-- name: ListSupplierInvoices :many
+SELECT * FROM invoices WHERE supplier_id = $1 ORDER BY purchase_date DESC, id DESC;
+
+-- name: GetInvoiceForUpdate :one
+-- Locks an invoice against being closed while its stock in is changed.
+SELECT * FROM invoices WHERE id = $1 FOR UPDATE;
+
+-- name: InvoiceLineTotal :one
+-- The value of the stock in on an invoice at cost.
+SELECT COALESCE(SUM(quantity * cost_price), 0)::float AS line_total
+FROM stock_in WHERE invoice_id = @invoice_id::int;
+
+-- name: CloseInvoice :one
+-- Closes an invoice. The stock in on a closed invoice can not be changed.
+UPDATE invoices SET closed_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND closed_at IS NULL RETURNING *;
+
+-- -- Supplier payments queries ----------------
+
+-- name: CreateSupplierPayment :one
+INSERT INTO supplier_payments (invoice_id, payment_date, amount, method, reference, user_id)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
+
+-- name: ListInvoicePayments :many
+SELECT supplier_payments.*, users.username
+FROM supplier_payments
+JOIN users ON users.id = supplier_payments.user_id
+WHERE supplier_payments.invoice_id = $1
+ORDER BY supplier_payments.payment_date, supplier_payments.id;
+
+-- name: DeleteSupplierPayment :execrows
+DELETE FROM supplier_payments WHERE id = $1 AND invoice_id = $2;
+
+-- name: SupplierOpeningBalance :one
+-- The amount owed to a supplier before a date.
+SELECT (
+    COALESCE((SELECT SUM(invoice_total) FROM invoices
+        WHERE supplier_id = @supplier_id AND purchase_date < @from_date), 0)
+    - COALESCE((SELECT SUM(supplier_payments.amount) FROM supplier_payments
+        JOIN invoices ON invoices.id = supplier_payments.invoice_id
+        WHERE invoices.supplier_id = @supplier_id AND supplier_payments.payment_date < @from_date), 0)
+)::double precision AS balance;
+
+-- name: SupplierStatement :many
+-- The invoices and payments of a supplier between two dates, oldest first.
+SELECT invoices.purchase_date AS entry_date, 'invoice'::text AS kind,
+    invoices.id AS invoice_id, invoices.invoice_number, ''::text AS method, ''::text AS reference,
+    invoices.invoice_total AS debit, 0::double precision AS credit, invoices.created_at
+FROM invoices
+WHERE invoices.supplier_id = @supplier_id
+AND invoices.purchase_date BETWEEN @from_date AND @to_date
+UNION ALL
+SELECT supplier_payments.payment_date, 'payment',
+    invoices.id, invoices.invoice_number, supplier_payments.method::text, supplier_payments.reference,
+    0, supplier_payments.amount, supplier_payments.created_at
+FROM supplier_payments
+JOIN invoices ON invoices.id = supplier_payments.invoice_id
+WHERE invoices.supplier_id = @supplier_id
+AND supplier_payments.payment_date BETWEEN @from_date AND @to_date
+ORDER BY entry_date, created_at;
+
+-- name: PayablesAgeing :many
+-- The balances owed to each supplier on a date by the days the invoices are
+-- overdue: not yet due, 1 to 30, 31 to 60, 61 to 90 and over 90 days. An
+-- invoice is due the supplier's payment terms after its purchase date.
+WITH balances AS (
+    SELECT invoices.supplier_id,
+        @as_of::date - (invoices.purchase_date + suppliers.payment_terms_days) AS overdue,
+        invoices.invoice_total - COALESCE((
+            SELECT SUM(amount) FROM supplier_payments
+            WHERE supplier_payments.invoice_id = invoices.id
+            AND supplier_payments.payment_date <= @as_of::date
+        ), 0) AS balance
+    FROM invoices
+    JOIN suppliers ON suppliers.id = invoices.supplier_id
+    WHERE invoices.purchase_date <= @as_of::date
+)
+SELECT suppliers.id AS supplier_id, suppliers.name AS supplier_name,
+    COALESCE(SUM(balance) FILTER (WHERE overdue <= 0), 0)::double precision AS current,
+    COALESCE(SUM(balance) FILTER (WHERE overdue BETWEEN 1 AND 30), 0)::double precision AS days_30,
+    COALESCE(SUM(balance) FILTER (WHERE overdue BETWEEN 31 AND 60), 0)::double precision AS days_60,
+    COALESCE(SUM(balance) FILTER (WHERE overdue BETWEEN 61 AND 90), 0)::double precision AS days_90,
+    COALESCE(SUM(balance) FILTER (WHERE overdue > 90), 0)::double precision AS over_90,
+    SUM(balance)::double precision AS total
+FROM balances
+JOIN suppliers ON suppliers.id = balances.supplier_id
+WHERE ABS(balance) >= 0.005
+GROUP BY suppliers.id
+ORDER BY suppliers.name;
+
+-- -- Suppliers queries ----------------
+
+-- name: ListSuppliers :many
+SELECT suppliers.*, COUNT(invoices.id) AS invoices
+FROM suppliers
+LEFT JOIN invoices ON invoices.supplier_id = suppliers.id
+GROUP BY suppliers.id
+ORDER BY suppliers.name;
+
+-- name: GetSupplier :one
+SELECT * FROM suppliers WHERE id = $1;
+
+-- name: CreateSupplier :one
+INSERT INTO suppliers (name, contact_person, phone, email, tin, address,
+    payment_terms_days, lead_time_days)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *;
+
+-- name: UpdateSupplier :exec
+UPDATE suppliers SET
+    name = $1,
+    contact_person = $2,
+    phone = $3,
+    email = $4,
+    tin = $5,
+    address = $6,
+    payment_terms_days = $7,
+    lead_time_days = $8,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $9;
+
+-- name: DeleteSupplier :exec
+DELETE FROM suppliers WHERE id = $1;
+
+-- name: MoveSupplierInvoices :execrows
+-- Moves the invoices of a duplicate supplier to the supplier kept.
+UPDATE invoices SET supplier_id = @into_id WHERE supplier_id = @from_id;
+
+-- name: MoveSupplierPurchaseOrders :execrows
+-- Moves the purchase orders of a duplicate supplier to the supplier kept.
+UPDATE purchase_orders SET supplier_id = @into_id WHERE supplier_id = @from_id;
+
+-- name: MoveSupplierProducts :execrows
+-- Makes the supplier kept the preferred supplier of the products of a duplicate supplier.
+UPDATE products SET preferred_supplier_id = @into_id::int WHERE preferred_supplier_id = @from_id::int;
+
 -- ================== StockIN Queries =========================
 -- name: InvoiceItems :many
 SELECT stock_in.*, 
@@ -169,37 +515,19 @@ JOIN products ON stock_in.product_id = products.id
 WHERE stock_in.invoice_id = (SELECT id FROM invoices WHERE invoice_number=$1 LIMIT 1)
 ORDER BY stock_in.id;
 
--- name: AddProductToInvoice :exec
-INSERT INTO stock_in (product_id, invoice_id, quantity, cost_price, expiry_date, comment)
-VALUES ($1, $2, $3, $4, $5, $6);
+-- name: AddProductToInvoice :one
+INSERT INTO stock_in (product_id, invoice_id, quantity, cost_price, expiry_date, comment, batch_number)
+VALUES (@product_id, @invoice_id::int, @quantity, @cost_price, @expiry_date, @comment, @batch_number) RETURNING *;
+
+-- name: ReceiveStockIn :one
+-- Records stock received against a line of a purchase order. It is put on
+-- an invoice when the order is converted.
+INSERT INTO stock_in (product_id, purchase_order_item_id, quantity, cost_price, expiry_date, comment, batch_number)
+VALUES (@product_id, @purchase_order_item_id::int, @quantity, @cost_price, @expiry_date, @comment, @batch_number) RETURNING *;
 
 -- name: DeleteStockIn :exec
 DELETE FROM stock_in WHERE id = $1;
 
--- name: IncrementProductQuantity :exec
-UPDATE products SET quantity = quantity + $1 WHERE id = $2;
-
--- name: DecrementProductQuantity :exec
-UPDATE products SET quantity = quantity - $1 WHERE id = $2 AND quantity >= $1;
-
--- name: ReplaceProductExpiry :exec
-UPDATE products SET expiry_dates = $1 WHERE id = $2;
-
--- Add a new expiry date to the product expiry dates.
--- Do this only if this expiry date is not already in the list.
--- name: AddProductExpiry :exec
-UPDATE products
-SET expiry_dates = COALESCE(expiry_dates, ARRAY[]::date[]) || @expiry_date::date
-WHERE id = @id::int
-  AND NOT (expiry_dates @> ARRAY[@expiry_date::date])
-  AND @expiry_date::date IS NOT NULL;
-
--- name: RemoveProductExpiry :exec
-UPDATE products
-SET expiry_dates = array_remove(expiry_dates, @expiry_date::date)
-WHERE id = @id::int;
-
-
 -- name: GetStockIn :one
 SELECT * FROM stock_in WHERE id = $1;
 
@@ -234,6 +562,164 @@ GROUP BY year
 ORDER BY year DESC;
 
 
+-- name: ProductStockCard :many
+-- Daily stock movements of a product between two dates, oldest first.
+SELECT * FROM stock_card
+WHERE product_id = @product_id AND balance_date BETWEEN @from_date::date AND @to_date::date
+ORDER BY balance_date;
+
+-- name: LastStockBalanceSnapshot :one
+-- Day of the last snapshot of opening balances, or yesterday if there is none.
+SELECT COALESCE(MAX(balance_date), pharmacy_date(CURRENT_TIMESTAMP) - 1)::date AS balance_date
+FROM stock_balance_snapshots;
+
+-- name: SnapshotStockBalances :execrows
+-- Records the opening balance on a day of the products that have no balance on it.
+-- The opening balance is the quantity in stock less the stock received and adjusted
+-- and plus the stock sold since the start of the day, so missed days can be recorded later.
+INSERT INTO stock_balances (product_id, balance_date, opening_quantity, quantity_in, quantity_adjusted)
+SELECT products.id, @balance_date::date,
+    products.quantity
+    - COALESCE((
+        SELECT SUM(stock_in.quantity) FROM stock_in
+        WHERE stock_in.product_id = products.id AND pharmacy_date(stock_in.created_at) >= @balance_date::date
+    ), 0)
+    + COALESCE((
+        SELECT SUM(transaction_items.quantity) FROM transaction_items
+        JOIN transactions ON transaction_items.transaction_id = transactions.id
+        WHERE transaction_items.product_id = products.id AND pharmacy_date(transactions.created_at) >= @balance_date::date
+    ), 0)
+    - COALESCE((
+        SELECT SUM(stock_adjustments.quantity) FROM stock_adjustments
+        WHERE stock_adjustments.product_id = products.id AND stock_adjustments.status = 'approved'
+        AND pharmacy_date(stock_adjustments.reviewed_at) >= @balance_date::date
+    ), 0),
+    COALESCE((
+        SELECT SUM(stock_in.quantity) FROM stock_in
+        WHERE stock_in.product_id = products.id AND pharmacy_date(stock_in.created_at) = @balance_date::date
+    ), 0),
+    COALESCE((
+        SELECT SUM(stock_adjustments.quantity) FROM stock_adjustments
+        WHERE stock_adjustments.product_id = products.id AND stock_adjustments.status = 'approved'
+        AND pharmacy_date(stock_adjustments.reviewed_at) = @balance_date::date
+    ), 0)
+FROM products
+WHERE pharmacy_date(products.created_at) <= @balance_date::date
+ON CONFLICT (product_id, balance_date) DO NOTHING;
+
+-- name: CreateStockBalanceSnapshot :exec
+INSERT INTO stock_balance_snapshots (balance_date, products) VALUES (@balance_date, @products)
+ON CONFLICT (balance_date) DO NOTHING;
+
+-- name: RebuildStockBalances :execrows
+-- Recomputes the balances of every day from a date to today from the stock received,
+-- sold and adjusted, for a product or for all products if product_id is 0. Working back
+-- from the quantity in stock, each day opens with the quantity in stock less the stock
+-- received and adjusted and plus the stock sold on and after it. The quantity a product
+-- was created with is received on the day it was created.
+WITH days AS (
+    SELECT products.id AS product_id, products.quantity, pharmacy_date(products.created_at) AS created_on,
+        day::date AS balance_date
+    FROM products,
+    LATERAL generate_series(
+        GREATEST(@from_date::date, pharmacy_date(products.created_at)),
+        pharmacy_date(CURRENT_TIMESTAMP),
+        INTERVAL '1 day'
+    ) AS day
+    WHERE @product_id::int = 0 OR products.id = @product_id::int
+),
+received AS (
+    SELECT product_id, pharmacy_date(created_at) AS balance_date, SUM(quantity) AS quantity
+    FROM stock_in
+    GROUP BY 1, 2
+),
+sold AS (
+    SELECT transaction_items.product_id, pharmacy_date(transactions.created_at) AS balance_date,
+        SUM(transaction_items.quantity) AS quantity
+    FROM transaction_items
+    JOIN transactions ON transaction_items.transaction_id = transactions.id
+    GROUP BY 1, 2
+),
+adjusted AS (
+    SELECT product_id, pharmacy_date(reviewed_at) AS balance_date, SUM(quantity) AS quantity
+    FROM stock_adjustments
+    WHERE status = 'approved'
+    GROUP BY 1, 2
+),
+balances AS (
+    SELECT days.product_id, days.balance_date, days.created_on,
+        COALESCE(received.quantity, 0) AS quantity_in,
+        COALESCE(adjusted.quantity, 0) AS quantity_adjusted,
+        days.quantity - SUM(COALESCE(received.quantity, 0) - COALESCE(sold.quantity, 0) + COALESCE(adjusted.quantity, 0))
+            OVER (PARTITION BY days.product_id ORDER BY days.balance_date DESC) AS opening_quantity
+    FROM days
+    LEFT JOIN received ON received.product_id = days.product_id AND received.balance_date = days.balance_date
+    LEFT JOIN sold ON sold.product_id = days.product_id AND sold.balance_date = days.balance_date
+    LEFT JOIN adjusted ON adjusted.product_id = days.product_id AND adjusted.balance_date = days.balance_date
+)
+INSERT INTO stock_balances (product_id, balance_date, opening_quantity, quantity_in, quantity_adjusted)
+SELECT product_id, balance_date,
+    CASE WHEN balance_date = created_on THEN 0 ELSE opening_quantity END,
+    quantity_in + CASE WHEN balance_date = created_on THEN opening_quantity ELSE 0 END,
+    quantity_adjusted
+FROM balances
+ON CONFLICT (product_id, balance_date)
+DO UPDATE SET opening_quantity = EXCLUDED.opening_quantity, quantity_in = EXCLUDED.quantity_in,
+    quantity_adjusted = EXCLUDED.quantity_adjusted;
+
+-- name: ReorderCandidates :many
+-- The products with a reorder level or sales since from_date, with their
+-- preferred supplier, the units sold since from_date and the units still to
+-- be delivered on purchase orders that are not closed. Products without
+-- a preferred supplier come last.
+SELECT products.id, products.generic_name, products.brand_name, products.quantity, products.cost_price,
+    products.reorder_level, products.reorder_quantity, products.preferred_supplier_id,
+    COALESCE(suppliers.name, '')::text AS supplier_name,
+    COALESCE(suppliers.lead_time_days, 0)::int AS lead_time_days,
+    COALESCE(sold.units, 0)::int AS units_sold,
+    COALESCE(ordered.units, 0)::int AS on_order
+FROM products
+LEFT JOIN suppliers ON suppliers.id = products.preferred_supplier_id
+LEFT JOIN (
+    SELECT transaction_items.product_id, SUM(transaction_items.quantity) AS units
+    FROM transaction_items
+    JOIN transactions ON transactions.id = transaction_items.transaction_id
+    WHERE pharmacy_date(transactions.created_at) >= @from_date::date
+    GROUP BY transaction_items.product_id
+) sold ON sold.product_id = products.id
+LEFT JOIN (
+    SELECT purchase_order_items.product_id,
+        SUM(GREATEST(purchase_order_items.quantity - COALESCE(received.units, 0), 0)) AS units
+    FROM purchase_order_items
+    JOIN purchase_orders ON purchase_orders.id = purchase_order_items.purchase_order_id
+    LEFT JOIN (
+        SELECT purchase_order_item_id, SUM(quantity) AS units FROM stock_in
+        WHERE purchase_order_item_id IS NOT NULL
+        GROUP BY purchase_order_item_id
+    ) received ON received.purchase_order_item_id = purchase_order_items.id
+    WHERE purchase_orders.status <> 'closed'
+    GROUP BY purchase_order_items.product_id
+) ordered ON ordered.product_id = products.id
+WHERE products.reorder_level > 0 OR sold.units > 0
+ORDER BY products.preferred_supplier_id IS NULL, suppliers.name, products.generic_name, products.brand_name;
+
+-- name: TaxSummary :many
+-- Sales and tax by period and tax class. Period is day, month or year.
+SELECT DATE_TRUNC(@period::text, transactions.created_at)::date AS period,
+    COALESCE(tax_classes.name, 'Unclassified')::text AS tax_class,
+    transaction_items.tax_rate,
+    SUM(transaction_items.line_total
+        - CASE WHEN transaction_items.tax_inclusive THEN transaction_items.tax ELSE 0 END)::double precision AS net_sales,
+    SUM(transaction_items.tax)::double precision AS tax,
+    SUM(transaction_items.line_total
+        + CASE WHEN transaction_items.tax_inclusive THEN 0 ELSE transaction_items.tax END)::double precision AS gross_sales
+FROM transaction_items
+JOIN transactions ON transaction_items.transaction_id = transactions.id
+LEFT JOIN tax_classes ON transaction_items.tax_class_id = tax_classes.id
+WHERE transactions.created_at::date BETWEEN @from_date::date AND @to_date::date
+GROUP BY period, tax_class, transaction_items.tax_rate
+ORDER BY period DESC, tax_class, transaction_items.tax_rate;
+
 -- name: DailyProductSales :many
 SELECT * FROM product_sales
 WHERE CASE WHEN @date::text != ''
@@ -244,28 +730,208 @@ END;
 
 -- name: MonthlyProductSales :many
 SELECT DATE_TRUNC('month', transaction_date)::date AS month, 
-    product_id, product_name, cost_price, selling_price,
+    product_id, product_name,
+    (SUM(cost) / SUM(quantity_sold))::double precision AS cost_price,
+    (SUM(selling_price * quantity_sold) / SUM(quantity_sold))::double precision AS selling_price,
     SUM(quantity_sold)::int AS quantity_sold,
     SUM(income)::double precision AS income,
+    SUM(cost)::double precision AS cost,
     SUM(profit)::double precision AS profit
 FROM product_sales
 WHERE CASE WHEN @date::text != ''
     THEN DATE_TRUNC('month', transaction_date)::date = @date::date
     ELSE TRUE
 END
-GROUP BY month, product_id, product_name, cost_price, selling_price
+GROUP BY month, product_id, product_name
 ORDER BY month DESC;
 
 -- name: AnnualProductSales :many
 SELECT DATE_TRUNC('year', transaction_date)::date AS year, 
-    product_id, product_name, cost_price, selling_price,
+    product_id, product_name,
+    (SUM(cost) / SUM(quantity_sold))::double precision AS cost_price,
+    (SUM(selling_price * quantity_sold) / SUM(quantity_sold))::double precision AS selling_price,
     SUM(quantity_sold)::int AS quantity_sold,
     SUM(income)::double precision AS income,
+    SUM(cost)::double precision AS cost,
     SUM(profit)::double precision AS profit
 FROM product_sales
 WHERE CASE WHEN @date::text != ''
     THEN DATE_TRUNC('year', transaction_date)::date = @date::date
     ELSE TRUE
 END
-GROUP BY year, product_id, product_name, cost_price, selling_price
-ORDER BY year DESC;
\ No newline at end of file
+GROUP BY year, product_id, product_name
+ORDER BY year DESC;
+
+-- name: CreateStockAdjustment :one
+INSERT INTO stock_adjustments (product_id, quantity, reason, note, requested_by, stock_take_id)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
+
+-- name: ReviewStockAdjustment :one
+-- Approves or rejects a pending adjustment.
+UPDATE stock_adjustments SET status = @status, reviewed_by = @reviewed_by, reviewed_at = CURRENT_TIMESTAMP
+WHERE id = @id AND status = 'pending' RETURNING *;
+
+-- name: ListStockAdjustments :many
+-- The latest adjustments with their product and users. An empty status
+-- or a product_id of 0 matches all adjustments.
+SELECT stock_adjustments.*, products.generic_name, products.brand_name,
+    requester.username AS requested_by_username,
+    COALESCE(reviewer.username, '')::text AS reviewed_by_username
+FROM stock_adjustments
+JOIN products ON products.id = stock_adjustments.product_id
+JOIN users requester ON requester.id = stock_adjustments.requested_by
+LEFT JOIN users reviewer ON reviewer.id = stock_adjustments.reviewed_by
+WHERE (@status::text = '' OR stock_adjustments.status::text = @status::text)
+AND (@product_id::int = 0 OR stock_adjustments.product_id = @product_id::int)
+ORDER BY stock_adjustments.id DESC
+LIMIT 200;
+
+-- name: CreateStockTake :one
+INSERT INTO stock_takes (note, opened_by) VALUES ($1, $2) RETURNING *;
+
+-- name: FreezeStockTakeItems :execrows
+-- Records the quantity in stock and cost of every product as expected by the stock take.
+INSERT INTO stock_take_items (stock_take_id, product_id, expected_quantity, unit_cost)
+SELECT @stock_take_id::int, id, quantity, cost_price FROM products;
+
+-- name: GetStockTake :one
+SELECT * FROM stock_takes WHERE id = $1;
+
+-- name: ListStockTakes :many
+-- The latest stock takes with how many products were counted
+-- and the net variance at cost.
+SELECT stock_takes.*, users.username AS opened_by_username,
+    COUNT(stock_take_items.id) AS products,
+    COUNT(stock_take_items.counted_quantity) AS counted,
+    COALESCE(SUM((stock_take_items.counted_quantity - stock_take_items.expected_quantity)
+        * stock_take_items.unit_cost), 0)::double precision AS variance_value
+FROM stock_takes
+JOIN users ON users.id = stock_takes.opened_by
+LEFT JOIN stock_take_items ON stock_take_items.stock_take_id = stock_takes.id
+GROUP BY stock_takes.id, users.username
+ORDER BY stock_takes.id DESC
+LIMIT 50;
+
+-- name: ListStockTakeItems :many
+SELECT stock_take_items.*, products.generic_name, products.brand_name, products.barcode
+FROM stock_take_items
+JOIN products ON products.id = stock_take_items.product_id
+WHERE stock_take_items.stock_take_id = $1
+ORDER BY products.generic_name, products.brand_name;
+
+-- name: CountStockTakeItem :one
+-- Records the counted quantity of a product. With add set the quantity
+-- is added to what was counted before, as when a product is kept in more
+-- than one place.
+UPDATE stock_take_items SET
+    counted_quantity = CASE WHEN @add::boolean THEN COALESCE(counted_quantity, 0) + @quantity::int
+        ELSE @quantity::int END,
+    counted_by = @counted_by,
+    counted_at = CURRENT_TIMESTAMP
+WHERE stock_take_id = @stock_take_id AND product_id = @product_id
+RETURNING *;
+
+-- name: CloseStockTake :one
+-- Approves or cancels an open stock take.
+UPDATE stock_takes SET status = @status, closed_by = @closed_by, closed_at = CURRENT_TIMESTAMP
+WHERE id = @id AND status = 'open' RETURNING *;
+
+-- -- Purchase orders queries ----------------
+
+-- name: CreatePurchaseOrder :one
+INSERT INTO purchase_orders (supplier_id, expected_date, note, created_by)
+VALUES (@supplier_id, NULLIF(@expected_date::date, '0001-01-01'::date), @note, @created_by) RETURNING *;
+
+-- name: GetPurchaseOrder :one
+SELECT purchase_orders.*, suppliers.name AS supplier_name, users.username AS created_by_username,
+    COALESCE(invoices.invoice_number, '')::text AS invoice_number
+FROM purchase_orders
+JOIN suppliers ON suppliers.id = purchase_orders.supplier_id
+JOIN users ON users.id = purchase_orders.created_by
+LEFT JOIN invoices ON invoices.id = purchase_orders.invoice_id
+WHERE purchase_orders.id = $1;
+
+-- name: GetPurchaseOrderForUpdate :one
+-- Locks a purchase order while stock is received against it or it is converted.
+SELECT * FROM purchase_orders WHERE id = $1 FOR UPDATE;
+
+-- name: GetPurchaseOrderItemOrderForUpdate :one
+-- Locks the purchase order of a line while stock received against it is removed.
+SELECT purchase_orders.* FROM purchase_orders
+JOIN purchase_order_items ON purchase_order_items.purchase_order_id = purchase_orders.id
+WHERE purchase_order_items.id = $1 FOR UPDATE OF purchase_orders;
+
+-- name: ListPurchaseOrders :many
+-- The latest purchase orders with their number of lines and value ordered.
+-- An empty status or a supplier_id of 0 matches all orders.
+SELECT purchase_orders.*, suppliers.name AS supplier_name,
+    COUNT(purchase_order_items.id) AS lines,
+    COALESCE(SUM(purchase_order_items.quantity * purchase_order_items.unit_cost), 0)::double precision AS ordered_value
+FROM purchase_orders
+JOIN suppliers ON suppliers.id = purchase_orders.supplier_id
+LEFT JOIN purchase_order_items ON purchase_order_items.purchase_order_id = purchase_orders.id
+WHERE (@status::text = '' OR purchase_orders.status::text = @status::text)
+AND (@supplier_id::int = 0 OR purchase_orders.supplier_id = @supplier_id::int)
+GROUP BY purchase_orders.id, suppliers.name
+ORDER BY purchase_orders.id DESC
+LIMIT 200;
+
+-- name: DeletePurchaseOrder :execrows
+-- Deletes a purchase order that has not been sent.
+DELETE FROM purchase_orders WHERE id = $1 AND status = 'draft';
+
+-- name: SendPurchaseOrder :one
+-- Marks a draft purchase order with lines as sent to the supplier.
+UPDATE purchase_orders SET status = 'sent', sent_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND status = 'draft'
+AND EXISTS (SELECT 1 FROM purchase_order_items WHERE purchase_order_id = purchase_orders.id)
+RETURNING *;
+
+-- name: SetPurchaseOrderStatus :one
+-- Marks a sent purchase order as partially received or closed.
+UPDATE purchase_orders SET status = @status,
+    closed_at = CASE WHEN @status = 'closed' THEN CURRENT_TIMESTAMP END
+WHERE id = @id AND status IN ('sent', 'partially_received') RETURNING *;
+
+-- name: SetPurchaseOrderInvoice :one
+-- Links a closed purchase order to the invoice it was converted into.
+UPDATE purchase_orders SET invoice_id = @invoice_id::int
+WHERE id = @id AND status = 'closed' AND invoice_id IS NULL RETURNING *;
+
+-- name: InvoicePurchaseOrderStock :execrows
+-- Puts the stock received against a purchase order on an invoice.
+UPDATE stock_in SET invoice_id = @invoice_id::int
+FROM purchase_order_items
+WHERE stock_in.purchase_order_item_id = purchase_order_items.id
+AND purchase_order_items.purchase_order_id = @purchase_order_id AND stock_in.invoice_id IS NULL;
+
+-- name: UninvoicePurchaseOrderStock :execrows
+-- Takes the stock received against purchase orders off an invoice, back
+-- onto the orders, so that the invoice can be deleted.
+UPDATE stock_in SET invoice_id = NULL
+WHERE invoice_id = @invoice_id::int AND purchase_order_item_id IS NOT NULL;
+
+-- name: AddPurchaseOrderItem :one
+-- Adds a product to a purchase order. If the product is on the order
+-- the quantity is added to it and the unit cost replaced.
+INSERT INTO purchase_order_items (purchase_order_id, product_id, quantity, unit_cost)
+VALUES (@purchase_order_id, @product_id, @quantity, @unit_cost)
+ON CONFLICT (purchase_order_id, product_id) DO UPDATE SET
+    quantity = purchase_order_items.quantity + EXCLUDED.quantity,
+    unit_cost = EXCLUDED.unit_cost
+RETURNING *;
+
+-- name: DeletePurchaseOrderItem :execrows
+DELETE FROM purchase_order_items WHERE id = @id AND purchase_order_id = @purchase_order_id;
+
+-- name: ListPurchaseOrderItems :many
+-- The lines of a purchase order with the quantity and value received against each.
+SELECT purchase_order_items.*, products.generic_name, products.brand_name,
+    COALESCE(SUM(stock_in.quantity), 0)::int AS quantity_received,
+    COALESCE(SUM(stock_in.quantity * stock_in.cost_price), 0)::double precision AS received_value
+FROM purchase_order_items
+JOIN products ON products.id = purchase_order_items.product_id
+LEFT JOIN stock_in ON stock_in.purchase_order_item_id = purchase_order_items.id
+WHERE purchase_order_items.purchase_order_id = $1
+GROUP BY purchase_order_items.id, products.id
+ORDER BY products.generic_name, products.brand_name;
@@ -5,11 +5,528 @@
 package epharma
 
 import (
+	"database/sql/driver"
+	"fmt"
 	"time"
 
 	"github.com/abiiranathan/dbtypes"
 )
 
+type AdjustmentReason string
+
+const (
+	AdjustmentReasonDamaged            AdjustmentReason = "damaged"
+	AdjustmentReasonExpired            AdjustmentReason = "expired"
+	AdjustmentReasonTheft              AdjustmentReason = "theft"
+	AdjustmentReasonCountCorrection    AdjustmentReason = "count_correction"
+	AdjustmentReasonReturnedToSupplier AdjustmentReason = "returned_to_supplier"
+)
+
+func (e *AdjustmentReason) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = AdjustmentReason(s)
+	case string:
+		*e = AdjustmentReason(s)
+	default:
+		return fmt.Errorf("unsupported scan type for AdjustmentReason: %T", src)
+	}
+	return nil
+}
+
+type NullAdjustmentReason struct {
+	AdjustmentReason AdjustmentReason `json:"adjustment_reason"`
+	Valid            bool             `json:"valid"` // Valid is true if AdjustmentReason is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullAdjustmentReason) Scan(value interface{}) error {
+	if value == nil {
+		ns.AdjustmentReason, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.AdjustmentReason.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullAdjustmentReason) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.AdjustmentReason), nil
+}
+
+func (e AdjustmentReason) Valid() bool {
+	switch e {
+	case AdjustmentReasonDamaged,
+		AdjustmentReasonExpired,
+		AdjustmentReasonTheft,
+		AdjustmentReasonCountCorrection,
+		AdjustmentReasonReturnedToSupplier:
+		return true
+	}
+	return false
+}
+
+func AllAdjustmentReasonValues() []AdjustmentReason {
+	return []AdjustmentReason{
+		AdjustmentReasonDamaged,
+		AdjustmentReasonExpired,
+		AdjustmentReasonTheft,
+		AdjustmentReasonCountCorrection,
+		AdjustmentReasonReturnedToSupplier,
+	}
+}
+
+type AdjustmentStatus string
+
+const (
+	AdjustmentStatusPending  AdjustmentStatus = "pending"
+	AdjustmentStatusApproved AdjustmentStatus = "approved"
+	AdjustmentStatusRejected AdjustmentStatus = "rejected"
+)
+
+func (e *AdjustmentStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = AdjustmentStatus(s)
+	case string:
+		*e = AdjustmentStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for AdjustmentStatus: %T", src)
+	}
+	return nil
+}
+
+type NullAdjustmentStatus struct {
+	AdjustmentStatus AdjustmentStatus `json:"adjustment_status"`
+	Valid            bool             `json:"valid"` // Valid is true if AdjustmentStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullAdjustmentStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.AdjustmentStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.AdjustmentStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullAdjustmentStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.AdjustmentStatus), nil
+}
+
+func (e AdjustmentStatus) Valid() bool {
+	switch e {
+	case AdjustmentStatusPending,
+		AdjustmentStatusApproved,
+		AdjustmentStatusRejected:
+		return true
+	}
+	return false
+}
+
+func AllAdjustmentStatusValues() []AdjustmentStatus {
+	return []AdjustmentStatus{
+		AdjustmentStatusPending,
+		AdjustmentStatusApproved,
+		AdjustmentStatusRejected,
+	}
+}
+
+type PaymentMethod string
+
+const (
+	PaymentMethodCash        PaymentMethod = "cash"
+	PaymentMethodMobileMoney PaymentMethod = "mobile_money"
+	PaymentMethodCard        PaymentMethod = "card"
+	PaymentMethodInsurance   PaymentMethod = "insurance"
+	PaymentMethodCredit      PaymentMethod = "credit"
+)
+
+func (e *PaymentMethod) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = PaymentMethod(s)
+	case string:
+		*e = PaymentMethod(s)
+	default:
+		return fmt.Errorf("unsupported scan type for PaymentMethod: %T", src)
+	}
+	return nil
+}
+
+type NullPaymentMethod struct {
+	PaymentMethod PaymentMethod `json:"payment_method"`
+	Valid         bool          `json:"valid"` // Valid is true if PaymentMethod is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullPaymentMethod) Scan(value interface{}) error {
+	if value == nil {
+		ns.PaymentMethod, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.PaymentMethod.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullPaymentMethod) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.PaymentMethod), nil
+}
+
+func (e PaymentMethod) Valid() bool {
+	switch e {
+	case PaymentMethodCash,
+		PaymentMethodMobileMoney,
+		PaymentMethodCard,
+		PaymentMethodInsurance,
+		PaymentMethodCredit:
+		return true
+	}
+	return false
+}
+
+func AllPaymentMethodValues() []PaymentMethod {
+	return []PaymentMethod{
+		PaymentMethodCash,
+		PaymentMethodMobileMoney,
+		PaymentMethodCard,
+		PaymentMethodInsurance,
+		PaymentMethodCredit,
+	}
+}
+
+type PurchaseOrderStatus string
+
+const (
+	PurchaseOrderStatusDraft             PurchaseOrderStatus = "draft"
+	PurchaseOrderStatusSent              PurchaseOrderStatus = "sent"
+	PurchaseOrderStatusPartiallyReceived PurchaseOrderStatus = "partially_received"
+	PurchaseOrderStatusClosed            PurchaseOrderStatus = "closed"
+)
+
+func (e *PurchaseOrderStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = PurchaseOrderStatus(s)
+	case string:
+		*e = PurchaseOrderStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for PurchaseOrderStatus: %T", src)
+	}
+	return nil
+}
+
+type NullPurchaseOrderStatus struct {
+	PurchaseOrderStatus PurchaseOrderStatus `json:"purchase_order_status"`
+	Valid               bool                `json:"valid"` // Valid is true if PurchaseOrderStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullPurchaseOrderStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.PurchaseOrderStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.PurchaseOrderStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullPurchaseOrderStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.PurchaseOrderStatus), nil
+}
+
+func (e PurchaseOrderStatus) Valid() bool {
+	switch e {
+	case PurchaseOrderStatusDraft,
+		PurchaseOrderStatusSent,
+		PurchaseOrderStatusPartiallyReceived,
+		PurchaseOrderStatusClosed:
+		return true
+	}
+	return false
+}
+
+func AllPurchaseOrderStatusValues() []PurchaseOrderStatus {
+	return []PurchaseOrderStatus{
+		PurchaseOrderStatusDraft,
+		PurchaseOrderStatusSent,
+		PurchaseOrderStatusPartiallyReceived,
+		PurchaseOrderStatusClosed,
+	}
+}
+
+type StockTakeStatus string
+
+const (
+	StockTakeStatusOpen      StockTakeStatus = "open"
+	StockTakeStatusApproved  StockTakeStatus = "approved"
+	StockTakeStatusCancelled StockTakeStatus = "cancelled"
+)
+
+func (e *StockTakeStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = StockTakeStatus(s)
+	case string:
+		*e = StockTakeStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for StockTakeStatus: %T", src)
+	}
+	return nil
+}
+
+type NullStockTakeStatus struct {
+	StockTakeStatus StockTakeStatus `json:"stock_take_status"`
+	Valid           bool            `json:"valid"` // Valid is true if StockTakeStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullStockTakeStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.StockTakeStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.StockTakeStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullStockTakeStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.StockTakeStatus), nil
+}
+
+func (e StockTakeStatus) Valid() bool {
+	switch e {
+	case StockTakeStatusOpen,
+		StockTakeStatusApproved,
+		StockTakeStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func AllStockTakeStatusValues() []StockTakeStatus {
+	return []StockTakeStatus{
+		StockTakeStatusOpen,
+		StockTakeStatusApproved,
+		StockTakeStatusCancelled,
+	}
+}
+
+type SupplierPaymentMethod string
+
+const (
+	SupplierPaymentMethodCash         SupplierPaymentMethod = "cash"
+	SupplierPaymentMethodBankTransfer SupplierPaymentMethod = "bank_transfer"
+	SupplierPaymentMethodMobileMoney  SupplierPaymentMethod = "mobile_money"
+	SupplierPaymentMethodCheque       SupplierPaymentMethod = "cheque"
+	SupplierPaymentMethodOther        SupplierPaymentMethod = "other"
+)
+
+func (e *SupplierPaymentMethod) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = SupplierPaymentMethod(s)
+	case string:
+		*e = SupplierPaymentMethod(s)
+	default:
+		return fmt.Errorf("unsupported scan type for SupplierPaymentMethod: %T", src)
+	}
+	return nil
+}
+
+type NullSupplierPaymentMethod struct {
+	SupplierPaymentMethod SupplierPaymentMethod `json:"supplier_payment_method"`
+	Valid                 bool                  `json:"valid"` // Valid is true if SupplierPaymentMethod is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullSupplierPaymentMethod) Scan(value interface{}) error {
+	if value == nil {
+		ns.SupplierPaymentMethod, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.SupplierPaymentMethod.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullSupplierPaymentMethod) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.SupplierPaymentMethod), nil
+}
+
+func (e SupplierPaymentMethod) Valid() bool {
+	switch e {
+	case SupplierPaymentMethodCash,
+		SupplierPaymentMethodBankTransfer,
+		SupplierPaymentMethodMobileMoney,
+		SupplierPaymentMethodCheque,
+		SupplierPaymentMethodOther:
+		return true
+	}
+	return false
+}
+
+func AllSupplierPaymentMethodValues() []SupplierPaymentMethod {
+	return []SupplierPaymentMethod{
+		SupplierPaymentMethodCash,
+		SupplierPaymentMethodBankTransfer,
+		SupplierPaymentMethodMobileMoney,
+		SupplierPaymentMethodCheque,
+		SupplierPaymentMethodOther,
+	}
+}
+
+type TaxKind string
+
+const (
+	TaxKindStandard  TaxKind = "standard"
+	TaxKindZeroRated TaxKind = "zero_rated"
+	TaxKindExempt    TaxKind = "exempt"
+)
+
+func (e *TaxKind) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = TaxKind(s)
+	case string:
+		*e = TaxKind(s)
+	default:
+		return fmt.Errorf("unsupported scan type for TaxKind: %T", src)
+	}
+	return nil
+}
+
+type NullTaxKind struct {
+	TaxKind TaxKind `json:"tax_kind"`
+	Valid   bool    `json:"valid"` // Valid is true if TaxKind is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullTaxKind) Scan(value interface{}) error {
+	if value == nil {
+		ns.TaxKind, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.TaxKind.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullTaxKind) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.TaxKind), nil
+}
+
+func (e TaxKind) Valid() bool {
+	switch e {
+	case TaxKindStandard,
+		TaxKindZeroRated,
+		TaxKindExempt:
+		return true
+	}
+	return false
+}
+
+func AllTaxKindValues() []TaxKind {
+	return []TaxKind{
+		TaxKindStandard,
+		TaxKindZeroRated,
+		TaxKindExempt,
+	}
+}
+
+type UserRole string
+
+const (
+	UserRoleCashier     UserRole = "cashier"
+	UserRolePharmacist  UserRole = "pharmacist"
+	UserRoleStoreKeeper UserRole = "store_keeper"
+	UserRoleManager     UserRole = "manager"
+	UserRoleAdmin       UserRole = "admin"
+)
+
+func (e *UserRole) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = UserRole(s)
+	case string:
+		*e = UserRole(s)
+	default:
+		return fmt.Errorf("unsupported scan type for UserRole: %T", src)
+	}
+	return nil
+}
+
+type NullUserRole struct {
+	UserRole UserRole `json:"user_role"`
+	Valid    bool     `json:"valid"` // Valid is true if UserRole is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullUserRole) Scan(value interface{}) error {
+	if value == nil {
+		ns.UserRole, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.UserRole.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullUserRole) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.UserRole), nil
+}
+
+func (e UserRole) Valid() bool {
+	switch e {
+	case UserRoleCashier,
+		UserRolePharmacist,
+		UserRoleStoreKeeper,
+		UserRoleManager,
+		UserRoleAdmin:
+		return true
+	}
+	return false
+}
+
+func AllUserRoleValues() []UserRole {
+	return []UserRole{
+		UserRoleCashier,
+		UserRolePharmacist,
+		UserRoleStoreKeeper,
+		UserRoleManager,
+		UserRoleAdmin,
+	}
+}
+
 type Invoice struct {
 	ID            int32        `json:"id"`
 	InvoiceNumber string       `json:"invoice_number"`
@@ -17,29 +534,65 @@ type Invoice struct {
 	InvoiceTotal  float64      `json:"invoice_total"`
 	AmountPaid    float64      `json:"amount_paid"`
 	Balance       float64      `json:"balance"`
-	Supplier      string       `json:"supplier"`
 	UserID        int32        `json:"user_id"`
 	CreatedAt     time.Time    `json:"created_at"`
+	SupplierID    int32        `json:"supplier_id"`
+	ClosedAt      *time.Time   `json:"closed_at"`
+}
+
+type Payment struct {
+	ID            int32         `json:"id"`
+	TransactionID int32         `json:"transaction_id"`
+	Method        PaymentMethod `json:"method"`
+	Amount        float64       `json:"amount"`
+	Tendered      float64       `json:"tendered"`
+	Change        float64       `json:"change"`
+	Reference     string        `json:"reference"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type PinAttempt struct {
+	ID         int32     `json:"id"`
+	UserID     int32     `json:"user_id"`
+	ApprovedBy *int32    `json:"approved_by"`
+	Succeeded  bool      `json:"succeeded"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type Product struct {
-	ID           int32          `json:"id"`
-	GenericName  string         `json:"generic_name"`
-	BrandName    string         `json:"brand_name"`
-	Quantity     int32          `json:"quantity"`
-	CostPrice    float64        `json:"cost_price"`
-	SellingPrice float64        `json:"selling_price"`
-	ExpiryDates  []dbtypes.Date `json:"expiry_dates"`
-	Barcode      string         `json:"barcode"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
+	ID                  int32          `json:"id"`
+	GenericName         string         `json:"generic_name"`
+	BrandName           string         `json:"brand_name"`
+	Quantity            int32          `json:"quantity"`
+	CostPrice           float64        `json:"cost_price"`
+	SellingPrice        float64        `json:"selling_price"`
+	ExpiryDates         []dbtypes.Date `json:"expiry_dates"`
+	Barcode             string         `json:"barcode"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	TaxClassID          *int32         `json:"tax_class_id"`
+	PriceIncludesTax    bool           `json:"price_includes_tax"`
+	ReorderLevel        int32          `json:"reorder_level"`
+	ReorderQuantity     int32          `json:"reorder_quantity"`
+	PreferredSupplierID *int32         `json:"preferred_supplier_id"`
 }
 
 type ProductAggregate struct {
 	TransactionDate dbtypes.Date `json:"transaction_date"`
 	ProductID       int32        `json:"product_id"`
 	QuantitySold    int64        `json:"quantity_sold"`
-	Income          int64        `json:"income"`
+	Income          float64      `json:"income"`
+}
+
+type ProductBatch struct {
+	ID          int32        `json:"id"`
+	ProductID   int32        `json:"product_id"`
+	StockInID   *int32       `json:"stock_in_id"`
+	BatchNumber string       `json:"batch_number"`
+	ExpiryDate  dbtypes.Date `json:"expiry_date"`
+	Quantity    int32        `json:"quantity"`
+	CostPrice   float64      `json:"cost_price"`
+	CreatedAt   time.Time    `json:"created_at"`
 }
 
 type ProductSale struct {
@@ -50,7 +603,35 @@ type ProductSale struct {
 	SellingPrice    float64      `json:"selling_price"`
 	QuantitySold    int64        `json:"quantity_sold"`
 	Income          float64      `json:"income"`
+	Cost            float64      `json:"cost"`
 	Profit          float64      `json:"profit"`
+	Discount        float64      `json:"discount"`
+}
+
+type PurchaseOrder struct {
+	ID           int32               `json:"id"`
+	SupplierID   int32               `json:"supplier_id"`
+	Status       PurchaseOrderStatus `json:"status"`
+	ExpectedDate dbtypes.Date        `json:"expected_date"`
+	Note         string              `json:"note"`
+	InvoiceID    *int32              `json:"invoice_id"`
+	CreatedBy    int32               `json:"created_by"`
+	SentAt       *time.Time          `json:"sent_at"`
+	ClosedAt     *time.Time          `json:"closed_at"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+type PurchaseOrderItem struct {
+	ID              int32   `json:"id"`
+	PurchaseOrderID int32   `json:"purchase_order_id"`
+	ProductID       int32   `json:"product_id"`
+	Quantity        int32   `json:"quantity"`
+	UnitCost        float64 `json:"unit_cost"`
+}
+
+type RoleDiscountLimit struct {
+	Role       UserRole `json:"role"`
+	MaxPercent float64  `json:"max_percent"`
 }
 
 type SalesReport struct {
@@ -58,41 +639,157 @@ type SalesReport struct {
 	TotalIncome     float64      `json:"total_income"`
 }
 
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int32     `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IpAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type StockAdjustment struct {
+	ID          int32            `json:"id"`
+	ProductID   int32            `json:"product_id"`
+	Quantity    int32            `json:"quantity"`
+	Reason      AdjustmentReason `json:"reason"`
+	Note        string           `json:"note"`
+	Status      AdjustmentStatus `json:"status"`
+	RequestedBy int32            `json:"requested_by"`
+	ReviewedBy  *int32           `json:"reviewed_by"`
+	ReviewedAt  *time.Time       `json:"reviewed_at"`
+	CreatedAt   time.Time        `json:"created_at"`
+	StockTakeID *int32           `json:"stock_take_id"`
+}
+
 type StockBalance struct {
-	ID              int32     `json:"id"`
-	ProductID       int32     `json:"product_id"`
-	OpeningQuantity int32     `json:"opening_quantity"`
-	QuantityIn      int32     `json:"quantity_in"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID               int32        `json:"id"`
+	ProductID        int32        `json:"product_id"`
+	OpeningQuantity  int32        `json:"opening_quantity"`
+	QuantityIn       int32        `json:"quantity_in"`
+	CreatedAt        time.Time    `json:"created_at"`
+	BalanceDate      dbtypes.Date `json:"balance_date"`
+	QuantityAdjusted int32        `json:"quantity_adjusted"`
+}
+
+type StockBalanceSnapshot struct {
+	BalanceDate dbtypes.Date `json:"balance_date"`
+	Products    int32        `json:"products"`
+	CreatedAt   time.Time    `json:"created_at"`
 }
 
 type StockCard struct {
-	StockBalancesCreatedAt dbtypes.Date `json:"stock_balances_created_at"`
-	ProductID              int32        `json:"product_id"`
-	GenericName            string       `json:"generic_name"`
-	BrandName              string       `json:"brand_name"`
-	OpeningQuantity        int32        `json:"opening_quantity"`
-	QuantityIn             int32        `json:"quantity_in"`
-	QuantityOut            int64        `json:"quantity_out"`
-	ClosingQuantity        int32        `json:"closing_quantity"`
+	BalanceDate      dbtypes.Date `json:"balance_date"`
+	ProductID        int32        `json:"product_id"`
+	GenericName      string       `json:"generic_name"`
+	BrandName        string       `json:"brand_name"`
+	OpeningQuantity  int32        `json:"opening_quantity"`
+	QuantityIn       int32        `json:"quantity_in"`
+	QuantityOut      int64        `json:"quantity_out"`
+	QuantityAdjusted int32        `json:"quantity_adjusted"`
+	ClosingQuantity  int32        `json:"closing_quantity"`
 }
 
 type StockIn struct {
-	ID         int32        `json:"id"`
-	ProductID  int32        `json:"product_id"`
-	InvoiceID  int32        `json:"invoice_id"`
-	Quantity   int32        `json:"quantity"`
-	CostPrice  float64      `json:"cost_price"`
-	ExpiryDate dbtypes.Date `json:"expiry_date"`
-	Comment    string       `json:"comment"`
-	CreatedAt  time.Time    `json:"created_at"`
+	ID                  int32        `json:"id"`
+	ProductID           int32        `json:"product_id"`
+	InvoiceID           *int32       `json:"invoice_id"`
+	Quantity            int32        `json:"quantity"`
+	CostPrice           float64      `json:"cost_price"`
+	ExpiryDate          dbtypes.Date `json:"expiry_date"`
+	Comment             string       `json:"comment"`
+	CreatedAt           time.Time    `json:"created_at"`
+	BatchNumber         string       `json:"batch_number"`
+	PurchaseOrderItemID *int32       `json:"purchase_order_item_id"`
 }
 
-type Transaction struct {
+type StockTake struct {
+	ID        int32           `json:"id"`
+	Note      string          `json:"note"`
+	Status    StockTakeStatus `json:"status"`
+	OpenedBy  int32           `json:"opened_by"`
+	ClosedBy  *int32          `json:"closed_by"`
+	ClosedAt  *time.Time      `json:"closed_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type StockTakeItem struct {
+	ID               int32      `json:"id"`
+	StockTakeID      int32      `json:"stock_take_id"`
+	ProductID        int32      `json:"product_id"`
+	ExpectedQuantity int32      `json:"expected_quantity"`
+	UnitCost         float64    `json:"unit_cost"`
+	CountedQuantity  *int32     `json:"counted_quantity"`
+	CountedBy        *int32     `json:"counted_by"`
+	CountedAt        *time.Time `json:"counted_at"`
+}
+
+type Supplier struct {
+	ID               int32     `json:"id"`
+	Name             string    `json:"name"`
+	ContactPerson    string    `json:"contact_person"`
+	Phone            string    `json:"phone"`
+	Email            string    `json:"email"`
+	Tin              string    `json:"tin"`
+	Address          string    `json:"address"`
+	PaymentTermsDays int32     `json:"payment_terms_days"`
+	LeadTimeDays     int32     `json:"lead_time_days"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type SupplierPayment struct {
+	ID          int32                 `json:"id"`
+	InvoiceID   int32                 `json:"invoice_id"`
+	PaymentDate dbtypes.Date          `json:"payment_date"`
+	Amount      float64               `json:"amount"`
+	Method      SupplierPaymentMethod `json:"method"`
+	Reference   string                `json:"reference"`
+	UserID      int32                 `json:"user_id"`
+	CreatedAt   time.Time             `json:"created_at"`
+}
+
+type TaxClass struct {
 	ID        int32     `json:"id"`
-	Items     []byte    `json:"items"`
+	Name      string    `json:"name"`
+	Kind      TaxKind   `json:"kind"`
+	Rate      float64   `json:"rate"`
 	CreatedAt time.Time `json:"created_at"`
-	UserID    int32     `json:"user_id"`
+}
+
+type Transaction struct {
+	ID                 int32     `json:"id"`
+	CreatedAt          time.Time `json:"created_at"`
+	UserID             int32     `json:"user_id"`
+	IdempotencyKey     *string   `json:"idempotency_key"`
+	DiscountApprovedBy *int32    `json:"discount_approved_by"`
+}
+
+type TransactionBatch struct {
+	ID            int32   `json:"id"`
+	TransactionID int32   `json:"transaction_id"`
+	ProductID     int32   `json:"product_id"`
+	BatchID       *int32  `json:"batch_id"`
+	Quantity      int32   `json:"quantity"`
+	UnitCost      float64 `json:"unit_cost"`
+}
+
+type TransactionItem struct {
+	ID             int32   `json:"id"`
+	TransactionID  int32   `json:"transaction_id"`
+	ProductID      *int32  `json:"product_id"`
+	ProductName    string  `json:"product_name"`
+	Quantity       int32   `json:"quantity"`
+	UnitPrice      float64 `json:"unit_price"`
+	UnitCost       float64 `json:"unit_cost"`
+	Discount       float64 `json:"discount"`
+	LineTotal      float64 `json:"line_total"`
+	BasketDiscount float64 `json:"basket_discount"`
+	TaxClassID     *int32  `json:"tax_class_id"`
+	TaxRate        float64 `json:"tax_rate"`
+	Tax            float64 `json:"tax"`
+	TaxInclusive   bool    `json:"tax_inclusive"`
 }
 
 type User struct {
@@ -100,6 +797,12 @@ type User struct {
 	Username  string    `json:"username"`
 	Password  string    `json:"password"`
 	IsActive  bool      `json:"is_active"`
-	IsAdmin   bool      `json:"is_admin"`
 	CreatedAt time.Time `json:"created_at"`
+	Role      UserRole  `json:"role"`
+}
+
+type UserPin struct {
+	UserID    int32     `json:"user_id"`
+	PinHash   string    `json:"pin_hash"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
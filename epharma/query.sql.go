@@ -21,64 +21,98 @@ func (q *Queries) ActivateUser(ctx context.Context, id int32) error {
 	return err
 }
 
-const addProductExpiry = `-- name: AddProductExpiry :exec
-UPDATE products
-SET expiry_dates = COALESCE(expiry_dates, ARRAY[]::date[]) || $1::date
-WHERE id = $2::int
-  AND NOT (expiry_dates @> ARRAY[$1::date])
-  AND $1::date IS NOT NULL
+const addProductToInvoice = `-- name: AddProductToInvoice :one
+INSERT INTO stock_in (product_id, invoice_id, quantity, cost_price, expiry_date, comment, batch_number)
+VALUES ($1, $2::int, $3, $4, $5, $6, $7) RETURNING id, product_id, invoice_id, quantity, cost_price, expiry_date, comment, created_at, batch_number, purchase_order_item_id
 `
 
-type AddProductExpiryParams struct {
-	ExpiryDate dbtypes.Date `json:"expiry_date"`
-	ID         int32        `json:"id"`
+type AddProductToInvoiceParams struct {
+	ProductID   int32        `json:"product_id"`
+	InvoiceID   int32        `json:"invoice_id"`
+	Quantity    int32        `json:"quantity"`
+	CostPrice   float64      `json:"cost_price"`
+	ExpiryDate  dbtypes.Date `json:"expiry_date"`
+	Comment     string       `json:"comment"`
+	BatchNumber string       `json:"batch_number"`
 }
 
-// Add a new expiry date to the product expiry dates.
-// Do this only if this expiry date is not already in the list.
-func (q *Queries) AddProductExpiry(ctx context.Context, arg AddProductExpiryParams) error {
-	_, err := q.db.Exec(ctx, addProductExpiry, arg.ExpiryDate, arg.ID)
-	return err
+func (q *Queries) AddProductToInvoice(ctx context.Context, arg AddProductToInvoiceParams) (StockIn, error) {
+	row := q.db.QueryRow(ctx, addProductToInvoice,
+		arg.ProductID,
+		arg.InvoiceID,
+		arg.Quantity,
+		arg.CostPrice,
+		arg.ExpiryDate,
+		arg.Comment,
+		arg.BatchNumber,
+	)
+	var i StockIn
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.InvoiceID,
+		&i.Quantity,
+		&i.CostPrice,
+		&i.ExpiryDate,
+		&i.Comment,
+		&i.CreatedAt,
+		&i.BatchNumber,
+		&i.PurchaseOrderItemID,
+	)
+	return i, err
 }
 
-const addProductToInvoice = `-- name: AddProductToInvoice :exec
-INSERT INTO stock_in (product_id, invoice_id, quantity, cost_price, expiry_date, comment)
-VALUES ($1, $2, $3, $4, $5, $6)
+const addPurchaseOrderItem = `-- name: AddPurchaseOrderItem :one
+INSERT INTO purchase_order_items (purchase_order_id, product_id, quantity, unit_cost)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (purchase_order_id, product_id) DO UPDATE SET
+    quantity = purchase_order_items.quantity + EXCLUDED.quantity,
+    unit_cost = EXCLUDED.unit_cost
+RETURNING id, purchase_order_id, product_id, quantity, unit_cost
 `
 
-type AddProductToInvoiceParams struct {
-	ProductID  int32        `json:"product_id"`
-	InvoiceID  int32        `json:"invoice_id"`
-	Quantity   int32        `json:"quantity"`
-	CostPrice  float64      `json:"cost_price"`
-	ExpiryDate dbtypes.Date `json:"expiry_date"`
-	Comment    string       `json:"comment"`
+type AddPurchaseOrderItemParams struct {
+	PurchaseOrderID int32   `json:"purchase_order_id"`
+	ProductID       int32   `json:"product_id"`
+	Quantity        int32   `json:"quantity"`
+	UnitCost        float64 `json:"unit_cost"`
 }
 
-func (q *Queries) AddProductToInvoice(ctx context.Context, arg AddProductToInvoiceParams) error {
-	_, err := q.db.Exec(ctx, addProductToInvoice,
+// Adds a product to a purchase order. If the product is on the order
+// the quantity is added to it and the unit cost replaced.
+func (q *Queries) AddPurchaseOrderItem(ctx context.Context, arg AddPurchaseOrderItemParams) (PurchaseOrderItem, error) {
+	row := q.db.QueryRow(ctx, addPurchaseOrderItem,
+		arg.PurchaseOrderID,
 		arg.ProductID,
-		arg.InvoiceID,
 		arg.Quantity,
-		arg.CostPrice,
-		arg.ExpiryDate,
-		arg.Comment,
+		arg.UnitCost,
 	)
-	return err
+	var i PurchaseOrderItem
+	err := row.Scan(
+		&i.ID,
+		&i.PurchaseOrderID,
+		&i.ProductID,
+		&i.Quantity,
+		&i.UnitCost,
+	)
+	return i, err
 }
 
 const annualProductSales = `-- name: AnnualProductSales :many
 SELECT DATE_TRUNC('year', transaction_date)::date AS year, 
-    product_id, product_name, cost_price, selling_price,
+    product_id, product_name,
+    (SUM(cost) / SUM(quantity_sold))::double precision AS cost_price,
+    (SUM(selling_price * quantity_sold) / SUM(quantity_sold))::double precision AS selling_price,
     SUM(quantity_sold)::int AS quantity_sold,
     SUM(income)::double precision AS income,
+    SUM(cost)::double precision AS cost,
     SUM(profit)::double precision AS profit
 FROM product_sales
 WHERE CASE WHEN $1::text != ''
     THEN DATE_TRUNC('year', transaction_date)::date = $1::date
     ELSE TRUE
 END
-GROUP BY year, product_id, product_name, cost_price, selling_price
+GROUP BY year, product_id, product_name
 ORDER BY year DESC
 `
 
@@ -90,6 +124,7 @@ type AnnualProductSalesRow struct {
 	SellingPrice float64      `json:"selling_price"`
 	QuantitySold int32        `json:"quantity_sold"`
 	Income       float64      `json:"income"`
+	Cost         float64      `json:"cost"`
 	Profit       float64      `json:"profit"`
 }
 
@@ -110,6 +145,7 @@ func (q *Queries) AnnualProductSales(ctx context.Context, date string) ([]Annual
 			&i.SellingPrice,
 			&i.QuantitySold,
 			&i.Income,
+			&i.Cost,
 			&i.Profit,
 		); err != nil {
 			return nil, err
@@ -158,6 +194,57 @@ func (q *Queries) AnnualSalesReports(ctx context.Context, date string) ([]Annual
 	return items, nil
 }
 
+const closeInvoice = `-- name: CloseInvoice :one
+UPDATE invoices SET closed_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND closed_at IS NULL RETURNING id, invoice_number, purchase_date, invoice_total, amount_paid, balance, user_id, created_at, supplier_id, closed_at
+`
+
+// Closes an invoice. The stock in on a closed invoice can not be changed.
+func (q *Queries) CloseInvoice(ctx context.Context, id int32) (Invoice, error) {
+	row := q.db.QueryRow(ctx, closeInvoice, id)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.InvoiceNumber,
+		&i.PurchaseDate,
+		&i.InvoiceTotal,
+		&i.AmountPaid,
+		&i.Balance,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.SupplierID,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const closeStockTake = `-- name: CloseStockTake :one
+UPDATE stock_takes SET status = $1, closed_by = $2, closed_at = CURRENT_TIMESTAMP
+WHERE id = $3 AND status = 'open' RETURNING id, note, status, opened_by, closed_by, closed_at, created_at
+`
+
+type CloseStockTakeParams struct {
+	Status   StockTakeStatus `json:"status"`
+	ClosedBy *int32          `json:"closed_by"`
+	ID       int32           `json:"id"`
+}
+
+// Approves or cancels an open stock take.
+func (q *Queries) CloseStockTake(ctx context.Context, arg CloseStockTakeParams) (StockTake, error) {
+	row := q.db.QueryRow(ctx, closeStockTake, arg.Status, arg.ClosedBy, arg.ID)
+	var i StockTake
+	err := row.Scan(
+		&i.ID,
+		&i.Note,
+		&i.Status,
+		&i.OpenedBy,
+		&i.ClosedBy,
+		&i.ClosedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const countProducts = `-- name: CountProducts :one
 SELECT COUNT(*) AS count FROM products
 `
@@ -169,20 +256,62 @@ func (q *Queries) CountProducts(ctx context.Context) (int64, error) {
 	return count, err
 }
 
+const countStockTakeItem = `-- name: CountStockTakeItem :one
+UPDATE stock_take_items SET
+    counted_quantity = CASE WHEN $1::boolean THEN COALESCE(counted_quantity, 0) + $2::int
+        ELSE $2::int END,
+    counted_by = $3,
+    counted_at = CURRENT_TIMESTAMP
+WHERE stock_take_id = $4 AND product_id = $5
+RETURNING id, stock_take_id, product_id, expected_quantity, unit_cost, counted_quantity, counted_by, counted_at
+`
+
+type CountStockTakeItemParams struct {
+	Add         bool   `json:"add"`
+	Quantity    int32  `json:"quantity"`
+	CountedBy   *int32 `json:"counted_by"`
+	StockTakeID int32  `json:"stock_take_id"`
+	ProductID   int32  `json:"product_id"`
+}
+
+// Records the counted quantity of a product. With add set the quantity
+// is added to what was counted before, as when a product is kept in more
+// than one place.
+func (q *Queries) CountStockTakeItem(ctx context.Context, arg CountStockTakeItemParams) (StockTakeItem, error) {
+	row := q.db.QueryRow(ctx, countStockTakeItem,
+		arg.Add,
+		arg.Quantity,
+		arg.CountedBy,
+		arg.StockTakeID,
+		arg.ProductID,
+	)
+	var i StockTakeItem
+	err := row.Scan(
+		&i.ID,
+		&i.StockTakeID,
+		&i.ProductID,
+		&i.ExpectedQuantity,
+		&i.UnitCost,
+		&i.CountedQuantity,
+		&i.CountedBy,
+		&i.CountedAt,
+	)
+	return i, err
+}
+
 const createInvoice = `-- name: CreateInvoice :one
 INSERT INTO
     invoices (invoice_number, purchase_date, invoice_total,
-     amount_paid, supplier, user_id)
+     supplier_id, user_id)
 VALUES
-    ($1, $2, $3, $4, $5, $6) RETURNING id, invoice_number, purchase_date, invoice_total, amount_paid, balance, supplier, user_id, created_at
+    ($1, $2, $3, $4, $5) RETURNING id, invoice_number, purchase_date, invoice_total, amount_paid, balance, user_id, created_at, supplier_id, closed_at
 `
 
 type CreateInvoiceParams struct {
 	InvoiceNumber string       `json:"invoice_number"`
 	PurchaseDate  dbtypes.Date `json:"purchase_date"`
 	InvoiceTotal  float64      `json:"invoice_total"`
-	AmountPaid    float64      `json:"amount_paid"`
-	Supplier      string       `json:"supplier"`
+	SupplierID    int32        `json:"supplier_id"`
 	UserID        int32        `json:"user_id"`
 }
 
@@ -191,8 +320,7 @@ func (q *Queries) CreateInvoice(ctx context.Context, arg CreateInvoiceParams) (I
 		arg.InvoiceNumber,
 		arg.PurchaseDate,
 		arg.InvoiceTotal,
-		arg.AmountPaid,
-		arg.Supplier,
+		arg.SupplierID,
 		arg.UserID,
 	)
 	var i Invoice
@@ -203,29 +331,88 @@ func (q *Queries) CreateInvoice(ctx context.Context, arg CreateInvoiceParams) (I
 		&i.InvoiceTotal,
 		&i.AmountPaid,
 		&i.Balance,
-		&i.Supplier,
 		&i.UserID,
 		&i.CreatedAt,
+		&i.SupplierID,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const createPayment = `-- name: CreatePayment :one
+INSERT INTO
+    payments (transaction_id, method, amount, tendered, reference)
+VALUES
+    ($1, $2, $3, $4, $5) RETURNING id, transaction_id, method, amount, tendered, change, reference, created_at
+`
+
+type CreatePaymentParams struct {
+	TransactionID int32         `json:"transaction_id"`
+	Method        PaymentMethod `json:"method"`
+	Amount        float64       `json:"amount"`
+	Tendered      float64       `json:"tendered"`
+	Reference     string        `json:"reference"`
+}
+
+func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
+	row := q.db.QueryRow(ctx, createPayment,
+		arg.TransactionID,
+		arg.Method,
+		arg.Amount,
+		arg.Tendered,
+		arg.Reference,
+	)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.TransactionID,
+		&i.Method,
+		&i.Amount,
+		&i.Tendered,
+		&i.Change,
+		&i.Reference,
+		&i.CreatedAt,
 	)
 	return i, err
 }
 
+const createPinAttempt = `-- name: CreatePinAttempt :exec
+INSERT INTO pin_attempts (user_id, approved_by, succeeded) VALUES ($1, $2, $3)
+`
+
+type CreatePinAttemptParams struct {
+	UserID     int32  `json:"user_id"`
+	ApprovedBy *int32 `json:"approved_by"`
+	Succeeded  bool   `json:"succeeded"`
+}
+
+func (q *Queries) CreatePinAttempt(ctx context.Context, arg CreatePinAttemptParams) error {
+	_, err := q.db.Exec(ctx, createPinAttempt, arg.UserID, arg.ApprovedBy, arg.Succeeded)
+	return err
+}
+
 const createProduct = `-- name: CreateProduct :one
 INSERT INTO
     products (generic_name, brand_name, quantity, 
-    cost_price, selling_price, barcode, expiry_dates)
+    cost_price, selling_price, barcode, expiry_dates, tax_class_id, price_includes_tax,
+    reorder_level, reorder_quantity, preferred_supplier_id)
 VALUES
-    ($1, $2, $3, $4, $5, $6, $7) RETURNING id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at
+    ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at, tax_class_id, price_includes_tax, reorder_level, reorder_quantity, preferred_supplier_id
 `
 
 type CreateProductParams struct {
-	GenericName  string         `json:"generic_name"`
-	BrandName    string         `json:"brand_name"`
-	Quantity     int32          `json:"quantity"`
-	CostPrice    float64        `json:"cost_price"`
-	SellingPrice float64        `json:"selling_price"`
-	Barcode      string         `json:"barcode"`
-	ExpiryDates  []dbtypes.Date `json:"expiry_dates"`
+	GenericName         string         `json:"generic_name"`
+	BrandName           string         `json:"brand_name"`
+	Quantity            int32          `json:"quantity"`
+	CostPrice           float64        `json:"cost_price"`
+	SellingPrice        float64        `json:"selling_price"`
+	Barcode             string         `json:"barcode"`
+	ExpiryDates         []dbtypes.Date `json:"expiry_dates"`
+	TaxClassID          *int32         `json:"tax_class_id"`
+	PriceIncludesTax    bool           `json:"price_includes_tax"`
+	ReorderLevel        int32          `json:"reorder_level"`
+	ReorderQuantity     int32          `json:"reorder_quantity"`
+	PreferredSupplierID *int32         `json:"preferred_supplier_id"`
 }
 
 func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (Product, error) {
@@ -237,6 +424,11 @@ func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (P
 		arg.SellingPrice,
 		arg.Barcode,
 		arg.ExpiryDates,
+		arg.TaxClassID,
+		arg.PriceIncludesTax,
+		arg.ReorderLevel,
+		arg.ReorderQuantity,
+		arg.PreferredSupplierID,
 	)
 	var i Product
 	err := row.Scan(
@@ -250,6 +442,11 @@ func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (P
 		&i.Barcode,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.TaxClassID,
+		&i.PriceIncludesTax,
+		&i.ReorderLevel,
+		&i.ReorderQuantity,
+		&i.PreferredSupplierID,
 	)
 	return i, err
 }
@@ -264,443 +461,2338 @@ type CreateProductsParams struct {
 	ExpiryDates  []dbtypes.Date `json:"expiry_dates"`
 }
 
-const createTransaction = `-- name: CreateTransaction :one
+const createProductBatch = `-- name: CreateProductBatch :one
 INSERT INTO
-    transactions (items, user_id)
+    product_batches (product_id, stock_in_id, batch_number, expiry_date, quantity, cost_price)
 VALUES
-    ($1, $2) RETURNING id, items, created_at, user_id
+    ($1, $2, $3, 
+    NULLIF($4::date, '0001-01-01'::date), $5, $6) RETURNING id, product_id, stock_in_id, batch_number, expiry_date, quantity, cost_price, created_at
 `
 
-type CreateTransactionParams struct {
-	Items  []byte `json:"items"`
-	UserID int32  `json:"user_id"`
+type CreateProductBatchParams struct {
+	ProductID   int32        `json:"product_id"`
+	StockInID   *int32       `json:"stock_in_id"`
+	BatchNumber string       `json:"batch_number"`
+	ExpiryDate  dbtypes.Date `json:"expiry_date"`
+	Quantity    int32        `json:"quantity"`
+	CostPrice   float64      `json:"cost_price"`
 }
 
-func (q *Queries) CreateTransaction(ctx context.Context, arg CreateTransactionParams) (Transaction, error) {
-	row := q.db.QueryRow(ctx, createTransaction, arg.Items, arg.UserID)
-	var i Transaction
+func (q *Queries) CreateProductBatch(ctx context.Context, arg CreateProductBatchParams) (ProductBatch, error) {
+	row := q.db.QueryRow(ctx, createProductBatch,
+		arg.ProductID,
+		arg.StockInID,
+		arg.BatchNumber,
+		arg.ExpiryDate,
+		arg.Quantity,
+		arg.CostPrice,
+	)
+	var i ProductBatch
 	err := row.Scan(
 		&i.ID,
-		&i.Items,
+		&i.ProductID,
+		&i.StockInID,
+		&i.BatchNumber,
+		&i.ExpiryDate,
+		&i.Quantity,
+		&i.CostPrice,
 		&i.CreatedAt,
-		&i.UserID,
 	)
 	return i, err
 }
 
-const createUser = `-- name: CreateUser :one
-INSERT INTO
-    users (username, password)
-VALUES
-    ($1, $2) RETURNING id, username, password, is_active, is_admin, created_at
+const createPurchaseOrder = `-- name: CreatePurchaseOrder :one
+INSERT INTO purchase_orders (supplier_id, expected_date, note, created_by)
+VALUES ($1, NULLIF($2::date, '0001-01-01'::date), $3, $4) RETURNING id, supplier_id, status, expected_date, note, invoice_id, created_by, sent_at, closed_at, created_at
 `
 
-type CreateUserParams struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+type CreatePurchaseOrderParams struct {
+	SupplierID   int32        `json:"supplier_id"`
+	ExpectedDate dbtypes.Date `json:"expected_date"`
+	Note         string       `json:"note"`
+	CreatedBy    int32        `json:"created_by"`
 }
 
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRow(ctx, createUser, arg.Username, arg.Password)
-	var i User
+func (q *Queries) CreatePurchaseOrder(ctx context.Context, arg CreatePurchaseOrderParams) (PurchaseOrder, error) {
+	row := q.db.QueryRow(ctx, createPurchaseOrder,
+		arg.SupplierID,
+		arg.ExpectedDate,
+		arg.Note,
+		arg.CreatedBy,
+	)
+	var i PurchaseOrder
 	err := row.Scan(
 		&i.ID,
-		&i.Username,
-		&i.Password,
-		&i.IsActive,
-		&i.IsAdmin,
+		&i.SupplierID,
+		&i.Status,
+		&i.ExpectedDate,
+		&i.Note,
+		&i.InvoiceID,
+		&i.CreatedBy,
+		&i.SentAt,
+		&i.ClosedAt,
 		&i.CreatedAt,
 	)
 	return i, err
 }
 
-const dailyProductSales = `-- name: DailyProductSales :many
-SELECT transaction_date, product_id, product_name, cost_price, selling_price, quantity_sold, income, profit FROM product_sales
-WHERE CASE WHEN $1::text != ''
-    THEN DATE_TRUNC('day', transaction_date)::date = $1::date
-    ELSE TRUE
-END
+const createSession = `-- name: CreateSession :one
+INSERT INTO
+    sessions (id, user_id, user_agent, ip_address, expires_at)
+VALUES
+    ($1, $2, $3, $4, $5) RETURNING id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at
 `
 
-func (q *Queries) DailyProductSales(ctx context.Context, date string) ([]ProductSale, error) {
-	rows, err := q.db.Query(ctx, dailyProductSales, date)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	items := []ProductSale{}
-	for rows.Next() {
-		var i ProductSale
-		if err := rows.Scan(
-			&i.TransactionDate,
-			&i.ProductID,
-			&i.ProductName,
-			&i.CostPrice,
-			&i.SellingPrice,
-			&i.QuantitySold,
-			&i.Income,
-			&i.Profit,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+type CreateSessionParams struct {
+	ID        string    `json:"id"`
+	UserID    int32     `json:"user_id"`
+	UserAgent string    `json:"user_agent"`
+	IpAddress string    `json:"ip_address"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
-const dailySalesReports = `-- name: DailySalesReports :many
-SELECT transaction_date, total_income FROM sales_reports
-WHERE CASE WHEN $1::text != ''
-    THEN DATE_TRUNC('day', transaction_date)::date = $1::date
-    ELSE TRUE
-END
-ORDER BY transaction_date DESC
-`
-
-// Fetch reports
-func (q *Queries) DailySalesReports(ctx context.Context, date string) ([]SalesReport, error) {
-	rows, err := q.db.Query(ctx, dailySalesReports, date)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	items := []SalesReport{}
-	for rows.Next() {
-		var i SalesReport
-		if err := rows.Scan(&i.TransactionDate, &i.TotalIncome); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	row := q.db.QueryRow(ctx, createSession,
+		arg.ID,
+		arg.UserID,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.ExpiresAt,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.ExpiresAt,
+	)
+	return i, err
 }
 
-const deactivateUser = `-- name: DeactivateUser :exec
-UPDATE users SET is_active = FALSE WHERE id = $1
+const createStockAdjustment = `-- name: CreateStockAdjustment :one
+INSERT INTO stock_adjustments (product_id, quantity, reason, note, requested_by, stock_take_id)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, product_id, quantity, reason, note, status, requested_by, reviewed_by, reviewed_at, created_at, stock_take_id
 `
 
-func (q *Queries) DeactivateUser(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deactivateUser, id)
-	return err
+type CreateStockAdjustmentParams struct {
+	ProductID   int32            `json:"product_id"`
+	Quantity    int32            `json:"quantity"`
+	Reason      AdjustmentReason `json:"reason"`
+	Note        string           `json:"note"`
+	RequestedBy int32            `json:"requested_by"`
+	StockTakeID *int32           `json:"stock_take_id"`
+}
+
+func (q *Queries) CreateStockAdjustment(ctx context.Context, arg CreateStockAdjustmentParams) (StockAdjustment, error) {
+	row := q.db.QueryRow(ctx, createStockAdjustment,
+		arg.ProductID,
+		arg.Quantity,
+		arg.Reason,
+		arg.Note,
+		arg.RequestedBy,
+		arg.StockTakeID,
+	)
+	var i StockAdjustment
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.Quantity,
+		&i.Reason,
+		&i.Note,
+		&i.Status,
+		&i.RequestedBy,
+		&i.ReviewedBy,
+		&i.ReviewedAt,
+		&i.CreatedAt,
+		&i.StockTakeID,
+	)
+	return i, err
 }
 
-const decrementProduct = `-- name: DecrementProduct :exec
-UPDATE products SET quantity=quantity - $2 WHERE id = $1
+const createStockBalanceSnapshot = `-- name: CreateStockBalanceSnapshot :exec
+INSERT INTO stock_balance_snapshots (balance_date, products) VALUES ($1, $2)
+ON CONFLICT (balance_date) DO NOTHING
 `
 
-type DecrementProductParams struct {
-	ID       int32 `json:"id"`
-	Quantity int32 `json:"quantity"`
+type CreateStockBalanceSnapshotParams struct {
+	BalanceDate dbtypes.Date `json:"balance_date"`
+	Products    int32        `json:"products"`
 }
 
-func (q *Queries) DecrementProduct(ctx context.Context, arg DecrementProductParams) error {
-	_, err := q.db.Exec(ctx, decrementProduct, arg.ID, arg.Quantity)
+func (q *Queries) CreateStockBalanceSnapshot(ctx context.Context, arg CreateStockBalanceSnapshotParams) error {
+	_, err := q.db.Exec(ctx, createStockBalanceSnapshot, arg.BalanceDate, arg.Products)
 	return err
 }
 
-const decrementProductQuantity = `-- name: DecrementProductQuantity :exec
-UPDATE products SET quantity = quantity - $1 WHERE id = $2 AND quantity >= $1
+const createStockTake = `-- name: CreateStockTake :one
+INSERT INTO stock_takes (note, opened_by) VALUES ($1, $2) RETURNING id, note, status, opened_by, closed_by, closed_at, created_at
 `
 
-type DecrementProductQuantityParams struct {
-	Quantity int32 `json:"quantity"`
-	ID       int32 `json:"id"`
+type CreateStockTakeParams struct {
+	Note     string `json:"note"`
+	OpenedBy int32  `json:"opened_by"`
 }
 
-func (q *Queries) DecrementProductQuantity(ctx context.Context, arg DecrementProductQuantityParams) error {
-	_, err := q.db.Exec(ctx, decrementProductQuantity, arg.Quantity, arg.ID)
-	return err
+func (q *Queries) CreateStockTake(ctx context.Context, arg CreateStockTakeParams) (StockTake, error) {
+	row := q.db.QueryRow(ctx, createStockTake, arg.Note, arg.OpenedBy)
+	var i StockTake
+	err := row.Scan(
+		&i.ID,
+		&i.Note,
+		&i.Status,
+		&i.OpenedBy,
+		&i.ClosedBy,
+		&i.ClosedAt,
+		&i.CreatedAt,
+	)
+	return i, err
 }
 
-const deleteInvoice = `-- name: DeleteInvoice :exec
-DELETE FROM invoices WHERE id = $1
+const createSupplier = `-- name: CreateSupplier :one
+INSERT INTO suppliers (name, contact_person, phone, email, tin, address,
+    payment_terms_days, lead_time_days)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, name, contact_person, phone, email, tin, address, payment_terms_days, lead_time_days, created_at, updated_at
 `
 
-func (q *Queries) DeleteInvoice(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deleteInvoice, id)
-	return err
+type CreateSupplierParams struct {
+	Name             string `json:"name"`
+	ContactPerson    string `json:"contact_person"`
+	Phone            string `json:"phone"`
+	Email            string `json:"email"`
+	Tin              string `json:"tin"`
+	Address          string `json:"address"`
+	PaymentTermsDays int32  `json:"payment_terms_days"`
+	LeadTimeDays     int32  `json:"lead_time_days"`
+}
+
+func (q *Queries) CreateSupplier(ctx context.Context, arg CreateSupplierParams) (Supplier, error) {
+	row := q.db.QueryRow(ctx, createSupplier,
+		arg.Name,
+		arg.ContactPerson,
+		arg.Phone,
+		arg.Email,
+		arg.Tin,
+		arg.Address,
+		arg.PaymentTermsDays,
+		arg.LeadTimeDays,
+	)
+	var i Supplier
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.ContactPerson,
+		&i.Phone,
+		&i.Email,
+		&i.Tin,
+		&i.Address,
+		&i.PaymentTermsDays,
+		&i.LeadTimeDays,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
 }
 
-const deleteProduct = `-- name: DeleteProduct :exec
-DELETE FROM products WHERE id = $1
+const createSupplierPayment = `-- name: CreateSupplierPayment :one
+INSERT INTO supplier_payments (invoice_id, payment_date, amount, method, reference, user_id)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, invoice_id, payment_date, amount, method, reference, user_id, created_at
 `
 
-func (q *Queries) DeleteProduct(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deleteProduct, id)
-	return err
+type CreateSupplierPaymentParams struct {
+	InvoiceID   int32                 `json:"invoice_id"`
+	PaymentDate dbtypes.Date          `json:"payment_date"`
+	Amount      float64               `json:"amount"`
+	Method      SupplierPaymentMethod `json:"method"`
+	Reference   string                `json:"reference"`
+	UserID      int32                 `json:"user_id"`
 }
 
-const deleteStockIn = `-- name: DeleteStockIn :exec
-DELETE FROM stock_in WHERE id = $1
-`
-
-func (q *Queries) DeleteStockIn(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deleteStockIn, id)
-	return err
-}
-
-const deleteTransaction = `-- name: DeleteTransaction :exec
-DELETE FROM transactions WHERE id = $1
-`
-
-func (q *Queries) DeleteTransaction(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deleteTransaction, id)
-	return err
-}
-
-const deleteUser = `-- name: DeleteUser :exec
-DELETE FROM users WHERE id = $1
-`
-
-func (q *Queries) DeleteUser(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deleteUser, id)
-	return err
-}
-
-const demoteUser = `-- name: DemoteUser :exec
-UPDATE users SET is_admin = FALSE WHERE id = $1
-`
-
-func (q *Queries) DemoteUser(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, demoteUser, id)
-	return err
-}
-
-const getInvoice = `-- name: GetInvoice :one
-SELECT id, invoice_number, purchase_date, invoice_total, amount_paid, balance, supplier, user_id, created_at FROM invoices WHERE id = $1
-`
-
-func (q *Queries) GetInvoice(ctx context.Context, id int32) (Invoice, error) {
-	row := q.db.QueryRow(ctx, getInvoice, id)
-	var i Invoice
+func (q *Queries) CreateSupplierPayment(ctx context.Context, arg CreateSupplierPaymentParams) (SupplierPayment, error) {
+	row := q.db.QueryRow(ctx, createSupplierPayment,
+		arg.InvoiceID,
+		arg.PaymentDate,
+		arg.Amount,
+		arg.Method,
+		arg.Reference,
+		arg.UserID,
+	)
+	var i SupplierPayment
 	err := row.Scan(
 		&i.ID,
-		&i.InvoiceNumber,
-		&i.PurchaseDate,
-		&i.InvoiceTotal,
-		&i.AmountPaid,
-		&i.Balance,
-		&i.Supplier,
+		&i.InvoiceID,
+		&i.PaymentDate,
+		&i.Amount,
+		&i.Method,
+		&i.Reference,
 		&i.UserID,
 		&i.CreatedAt,
 	)
 	return i, err
 }
 
-const getInvoiceByNumber = `-- name: GetInvoiceByNumber :one
-SELECT id, invoice_number, purchase_date, invoice_total, amount_paid, balance, supplier, user_id, created_at FROM invoices WHERE invoice_number = $1
+const createTaxClass = `-- name: CreateTaxClass :one
+INSERT INTO tax_classes (name, kind, rate) VALUES ($1, $2, $3) RETURNING id, name, kind, rate, created_at
 `
 
-func (q *Queries) GetInvoiceByNumber(ctx context.Context, invoiceNumber string) (Invoice, error) {
-	row := q.db.QueryRow(ctx, getInvoiceByNumber, invoiceNumber)
-	var i Invoice
+type CreateTaxClassParams struct {
+	Name string  `json:"name"`
+	Kind TaxKind `json:"kind"`
+	Rate float64 `json:"rate"`
+}
+
+func (q *Queries) CreateTaxClass(ctx context.Context, arg CreateTaxClassParams) (TaxClass, error) {
+	row := q.db.QueryRow(ctx, createTaxClass, arg.Name, arg.Kind, arg.Rate)
+	var i TaxClass
 	err := row.Scan(
 		&i.ID,
-		&i.InvoiceNumber,
-		&i.PurchaseDate,
-		&i.InvoiceTotal,
-		&i.AmountPaid,
-		&i.Balance,
-		&i.Supplier,
-		&i.UserID,
+		&i.Name,
+		&i.Kind,
+		&i.Rate,
 		&i.CreatedAt,
 	)
 	return i, err
 }
 
-const getProduct = `-- name: GetProduct :one
-SELECT id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at FROM products WHERE id = $1
+const createTransaction = `-- name: CreateTransaction :one
+INSERT INTO
+    transactions (user_id, idempotency_key, discount_approved_by)
+VALUES
+    ($1, $2, $3) RETURNING id, created_at, user_id, idempotency_key, discount_approved_by
 `
 
-func (q *Queries) GetProduct(ctx context.Context, id int32) (Product, error) {
-	row := q.db.QueryRow(ctx, getProduct, id)
-	var i Product
+type CreateTransactionParams struct {
+	UserID             int32   `json:"user_id"`
+	IdempotencyKey     *string `json:"idempotency_key"`
+	DiscountApprovedBy *int32  `json:"discount_approved_by"`
+}
+
+func (q *Queries) CreateTransaction(ctx context.Context, arg CreateTransactionParams) (Transaction, error) {
+	row := q.db.QueryRow(ctx, createTransaction, arg.UserID, arg.IdempotencyKey, arg.DiscountApprovedBy)
+	var i Transaction
 	err := row.Scan(
 		&i.ID,
-		&i.GenericName,
-		&i.BrandName,
-		&i.Quantity,
-		&i.CostPrice,
-		&i.SellingPrice,
-		&i.ExpiryDates,
-		&i.Barcode,
 		&i.CreatedAt,
-		&i.UpdatedAt,
+		&i.UserID,
+		&i.IdempotencyKey,
+		&i.DiscountApprovedBy,
 	)
 	return i, err
 }
 
-const getProductByBarcode = `-- name: GetProductByBarcode :one
-SELECT id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at FROM products WHERE barcode = $1
+const createTransactionBatch = `-- name: CreateTransactionBatch :exec
+INSERT INTO
+    transaction_batches (transaction_id, product_id, batch_id, quantity, unit_cost)
+VALUES
+    ($1, $2, $3, $4, $5)
 `
 
-func (q *Queries) GetProductByBarcode(ctx context.Context, barcode string) (Product, error) {
-	row := q.db.QueryRow(ctx, getProductByBarcode, barcode)
-	var i Product
-	err := row.Scan(
-		&i.ID,
-		&i.GenericName,
-		&i.BrandName,
-		&i.Quantity,
-		&i.CostPrice,
-		&i.SellingPrice,
-		&i.ExpiryDates,
-		&i.Barcode,
-		&i.CreatedAt,
-		&i.UpdatedAt,
+type CreateTransactionBatchParams struct {
+	TransactionID int32   `json:"transaction_id"`
+	ProductID     int32   `json:"product_id"`
+	BatchID       *int32  `json:"batch_id"`
+	Quantity      int32   `json:"quantity"`
+	UnitCost      float64 `json:"unit_cost"`
+}
+
+func (q *Queries) CreateTransactionBatch(ctx context.Context, arg CreateTransactionBatchParams) error {
+	_, err := q.db.Exec(ctx, createTransactionBatch,
+		arg.TransactionID,
+		arg.ProductID,
+		arg.BatchID,
+		arg.Quantity,
+		arg.UnitCost,
 	)
-	return i, err
+	return err
 }
 
-const getStockIn = `-- name: GetStockIn :one
-SELECT id, product_id, invoice_id, quantity, cost_price, expiry_date, comment, created_at FROM stock_in WHERE id = $1
+const createTransactionItem = `-- name: CreateTransactionItem :one
+INSERT INTO
+    transaction_items (transaction_id, product_id, quantity, unit_price, unit_cost, discount, basket_discount,
+    tax_class_id, tax_rate, tax, tax_inclusive)
+VALUES
+    ($1, $2::int, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id, transaction_id, product_id, product_name, quantity, unit_price, unit_cost, discount, line_total, basket_discount, tax_class_id, tax_rate, tax, tax_inclusive
 `
 
-func (q *Queries) GetStockIn(ctx context.Context, id int32) (StockIn, error) {
-	row := q.db.QueryRow(ctx, getStockIn, id)
-	var i StockIn
+type CreateTransactionItemParams struct {
+	TransactionID  int32   `json:"transaction_id"`
+	ProductID      int32   `json:"product_id"`
+	Quantity       int32   `json:"quantity"`
+	UnitPrice      float64 `json:"unit_price"`
+	UnitCost       float64 `json:"unit_cost"`
+	Discount       float64 `json:"discount"`
+	BasketDiscount float64 `json:"basket_discount"`
+	TaxClassID     *int32  `json:"tax_class_id"`
+	TaxRate        float64 `json:"tax_rate"`
+	Tax            float64 `json:"tax"`
+	TaxInclusive   bool    `json:"tax_inclusive"`
+}
+
+func (q *Queries) CreateTransactionItem(ctx context.Context, arg CreateTransactionItemParams) (TransactionItem, error) {
+	row := q.db.QueryRow(ctx, createTransactionItem,
+		arg.TransactionID,
+		arg.ProductID,
+		arg.Quantity,
+		arg.UnitPrice,
+		arg.UnitCost,
+		arg.Discount,
+		arg.BasketDiscount,
+		arg.TaxClassID,
+		arg.TaxRate,
+		arg.Tax,
+		arg.TaxInclusive,
+	)
+	var i TransactionItem
 	err := row.Scan(
 		&i.ID,
+		&i.TransactionID,
 		&i.ProductID,
-		&i.InvoiceID,
+		&i.ProductName,
 		&i.Quantity,
-		&i.CostPrice,
-		&i.ExpiryDate,
-		&i.Comment,
-		&i.CreatedAt,
+		&i.UnitPrice,
+		&i.UnitCost,
+		&i.Discount,
+		&i.LineTotal,
+		&i.BasketDiscount,
+		&i.TaxClassID,
+		&i.TaxRate,
+		&i.Tax,
+		&i.TaxInclusive,
 	)
 	return i, err
 }
 
-const getTransaction = `-- name: GetTransaction :one
-SELECT id, items, created_at, user_id FROM transactions WHERE id = $1
+const createUser = `-- name: CreateUser :one
+INSERT INTO
+    users (username, password, role)
+VALUES
+    ($1, $2, $3) RETURNING id, username, password, is_active, created_at, role
 `
 
-func (q *Queries) GetTransaction(ctx context.Context, id int32) (Transaction, error) {
-	row := q.db.QueryRow(ctx, getTransaction, id)
-	var i Transaction
-	err := row.Scan(
-		&i.ID,
-		&i.Items,
-		&i.CreatedAt,
-		&i.UserID,
-	)
-	return i, err
+type CreateUserParams struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Role     UserRole `json:"role"`
 }
 
-const getUser = `-- name: GetUser :one
-SELECT id, username, password, is_active, is_admin, created_at FROM users WHERE id = $1
-`
-
-func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
-	row := q.db.QueryRow(ctx, getUser, id)
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, createUser, arg.Username, arg.Password, arg.Role)
 	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.Username,
 		&i.Password,
 		&i.IsActive,
-		&i.IsAdmin,
 		&i.CreatedAt,
+		&i.Role,
 	)
 	return i, err
 }
 
-const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, username, password, is_active, is_admin, created_at FROM users WHERE username = $1 LIMIT 1
+const dailyProductSales = `-- name: DailyProductSales :many
+SELECT transaction_date, product_id, product_name, cost_price, selling_price, quantity_sold, income, cost, profit, discount FROM product_sales
+WHERE CASE WHEN $1::text != ''
+    THEN DATE_TRUNC('day', transaction_date)::date = $1::date
+    ELSE TRUE
+END
 `
 
-func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
-	row := q.db.QueryRow(ctx, getUserByUsername, username)
-	var i User
-	err := row.Scan(
-		&i.ID,
-		&i.Username,
-		&i.Password,
-		&i.IsActive,
-		&i.IsAdmin,
-		&i.CreatedAt,
-	)
-	return i, err
+func (q *Queries) DailyProductSales(ctx context.Context, date string) ([]ProductSale, error) {
+	rows, err := q.db.Query(ctx, dailyProductSales, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ProductSale{}
+	for rows.Next() {
+		var i ProductSale
+		if err := rows.Scan(
+			&i.TransactionDate,
+			&i.ProductID,
+			&i.ProductName,
+			&i.CostPrice,
+			&i.SellingPrice,
+			&i.QuantitySold,
+			&i.Income,
+			&i.Cost,
+			&i.Profit,
+			&i.Discount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const incrementProduct = `-- name: IncrementProduct :exec
-UPDATE products SET quantity=quantity + $2 WHERE id = $1
+const dailySalesReports = `-- name: DailySalesReports :many
+SELECT transaction_date, total_income FROM sales_reports
+WHERE CASE WHEN $1::text != ''
+    THEN DATE_TRUNC('day', transaction_date)::date = $1::date
+    ELSE TRUE
+END
+ORDER BY transaction_date DESC
 `
 
-type IncrementProductParams struct {
-	ID       int32 `json:"id"`
-	Quantity int32 `json:"quantity"`
+// Fetch reports
+func (q *Queries) DailySalesReports(ctx context.Context, date string) ([]SalesReport, error) {
+	rows, err := q.db.Query(ctx, dailySalesReports, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SalesReport{}
+	for rows.Next() {
+		var i SalesReport
+		if err := rows.Scan(&i.TransactionDate, &i.TotalIncome); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-func (q *Queries) IncrementProduct(ctx context.Context, arg IncrementProductParams) error {
-	_, err := q.db.Exec(ctx, incrementProduct, arg.ID, arg.Quantity)
+const deactivateUser = `-- name: DeactivateUser :exec
+UPDATE users SET is_active = FALSE WHERE id = $1
+`
+
+func (q *Queries) DeactivateUser(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, deactivateUser, id)
 	return err
 }
 
-const incrementProductQuantity = `-- name: IncrementProductQuantity :exec
-UPDATE products SET quantity = quantity + $1 WHERE id = $2
+const decrementBatch = `-- name: DecrementBatch :exec
+UPDATE product_batches SET quantity = quantity - $1 WHERE id = $2
 `
 
-type IncrementProductQuantityParams struct {
+type DecrementBatchParams struct {
 	Quantity int32 `json:"quantity"`
 	ID       int32 `json:"id"`
 }
 
-func (q *Queries) IncrementProductQuantity(ctx context.Context, arg IncrementProductQuantityParams) error {
-	_, err := q.db.Exec(ctx, incrementProductQuantity, arg.Quantity, arg.ID)
+func (q *Queries) DecrementBatch(ctx context.Context, arg DecrementBatchParams) error {
+	_, err := q.db.Exec(ctx, decrementBatch, arg.Quantity, arg.ID)
 	return err
 }
 
-const invoiceItems = `-- name: InvoiceItems :many
-SELECT stock_in.id, stock_in.product_id, stock_in.invoice_id, stock_in.quantity, stock_in.cost_price, stock_in.expiry_date, stock_in.comment, stock_in.created_at, 
-    products.generic_name, products.brand_name
-FROM stock_in
-JOIN products ON stock_in.product_id = products.id
-WHERE stock_in.invoice_id = (SELECT id FROM invoices WHERE invoice_number=$1 LIMIT 1)
-ORDER BY stock_in.id
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE expires_at < CURRENT_TIMESTAMP OR last_seen_at < $1
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, idleSince time.Time) error {
+	_, err := q.db.Exec(ctx, deleteExpiredSessions, idleSince)
+	return err
+}
+
+const deleteInvoice = `-- name: DeleteInvoice :exec
+DELETE FROM invoices WHERE id = $1
+`
+
+func (q *Queries) DeleteInvoice(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, deleteInvoice, id)
+	return err
+}
+
+const deleteProduct = `-- name: DeleteProduct :exec
+DELETE FROM products WHERE id = $1
+`
+
+func (q *Queries) DeleteProduct(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, deleteProduct, id)
+	return err
+}
+
+const deletePurchaseOrder = `-- name: DeletePurchaseOrder :execrows
+DELETE FROM purchase_orders WHERE id = $1 AND status = 'draft'
+`
+
+// Deletes a purchase order that has not been sent.
+func (q *Queries) DeletePurchaseOrder(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePurchaseOrder, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deletePurchaseOrderItem = `-- name: DeletePurchaseOrderItem :execrows
+DELETE FROM purchase_order_items WHERE id = $1 AND purchase_order_id = $2
+`
+
+type DeletePurchaseOrderItemParams struct {
+	ID              int32 `json:"id"`
+	PurchaseOrderID int32 `json:"purchase_order_id"`
+}
+
+func (q *Queries) DeletePurchaseOrderItem(ctx context.Context, arg DeletePurchaseOrderItemParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePurchaseOrderItem, arg.ID, arg.PurchaseOrderID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteSession = `-- name: DeleteSession :exec
+DELETE FROM sessions WHERE id = $1
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, deleteSession, id)
+	return err
+}
+
+const deleteStockIn = `-- name: DeleteStockIn :exec
+DELETE FROM stock_in WHERE id = $1
+`
+
+func (q *Queries) DeleteStockIn(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, deleteStockIn, id)
+	return err
+}
+
+const deleteSupplier = `-- name: DeleteSupplier :exec
+DELETE FROM suppliers WHERE id = $1
+`
+
+func (q *Queries) DeleteSupplier(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, deleteSupplier, id)
+	return err
+}
+
+const deleteSupplierPayment = `-- name: DeleteSupplierPayment :execrows
+DELETE FROM supplier_payments WHERE id = $1 AND invoice_id = $2
+`
+
+type DeleteSupplierPaymentParams struct {
+	ID        int32 `json:"id"`
+	InvoiceID int32 `json:"invoice_id"`
+}
+
+func (q *Queries) DeleteSupplierPayment(ctx context.Context, arg DeleteSupplierPaymentParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteSupplierPayment, arg.ID, arg.InvoiceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteTransaction = `-- name: DeleteTransaction :exec
+DELETE FROM transactions WHERE id = $1
+`
+
+func (q *Queries) DeleteTransaction(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, deleteTransaction, id)
+	return err
+}
+
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, deleteUser, id)
+	return err
+}
+
+const deleteUserPin = `-- name: DeleteUserPin :exec
+DELETE FROM user_pins WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserPin(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, deleteUserPin, userID)
+	return err
+}
+
+const deleteUserSessions = `-- name: DeleteUserSessions :exec
+DELETE FROM sessions WHERE user_id = $1
+`
+
+// Revoke every session of a user e.g when the account is deactivated.
+func (q *Queries) DeleteUserSessions(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, deleteUserSessions, userID)
+	return err
+}
+
+const freezeStockTakeItems = `-- name: FreezeStockTakeItems :execrows
+INSERT INTO stock_take_items (stock_take_id, product_id, expected_quantity, unit_cost)
+SELECT $1::int, id, quantity, cost_price FROM products
+`
+
+// Records the quantity in stock and cost of every product as expected by the stock take.
+func (q *Queries) FreezeStockTakeItems(ctx context.Context, stockTakeID int32) (int64, error) {
+	result, err := q.db.Exec(ctx, freezeStockTakeItems, stockTakeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getInvoice = `-- name: GetInvoice :one
+SELECT invoices.id, invoices.invoice_number, invoices.purchase_date, invoices.invoice_total, invoices.amount_paid, invoices.balance, invoices.user_id, invoices.created_at, invoices.supplier_id, invoices.closed_at, suppliers.name AS supplier_name
+FROM invoices
+JOIN suppliers ON suppliers.id = invoices.supplier_id
+WHERE invoices.id = $1
+`
+
+type GetInvoiceRow struct {
+	ID            int32        `json:"id"`
+	InvoiceNumber string       `json:"invoice_number"`
+	PurchaseDate  dbtypes.Date `json:"purchase_date"`
+	InvoiceTotal  float64      `json:"invoice_total"`
+	AmountPaid    float64      `json:"amount_paid"`
+	Balance       float64      `json:"balance"`
+	UserID        int32        `json:"user_id"`
+	CreatedAt     time.Time    `json:"created_at"`
+	SupplierID    int32        `json:"supplier_id"`
+	ClosedAt      *time.Time   `json:"closed_at"`
+	SupplierName  string       `json:"supplier_name"`
+}
+
+func (q *Queries) GetInvoice(ctx context.Context, id int32) (GetInvoiceRow, error) {
+	row := q.db.QueryRow(ctx, getInvoice, id)
+	var i GetInvoiceRow
+	err := row.Scan(
+		&i.ID,
+		&i.InvoiceNumber,
+		&i.PurchaseDate,
+		&i.InvoiceTotal,
+		&i.AmountPaid,
+		&i.Balance,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.SupplierID,
+		&i.ClosedAt,
+		&i.SupplierName,
+	)
+	return i, err
+}
+
+const getInvoiceByNumber = `-- name: GetInvoiceByNumber :one
+SELECT id, invoice_number, purchase_date, invoice_total, amount_paid, balance, user_id, created_at, supplier_id, closed_at FROM invoices WHERE invoice_number = $1
+`
+
+func (q *Queries) GetInvoiceByNumber(ctx context.Context, invoiceNumber string) (Invoice, error) {
+	row := q.db.QueryRow(ctx, getInvoiceByNumber, invoiceNumber)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.InvoiceNumber,
+		&i.PurchaseDate,
+		&i.InvoiceTotal,
+		&i.AmountPaid,
+		&i.Balance,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.SupplierID,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const getInvoiceForUpdate = `-- name: GetInvoiceForUpdate :one
+SELECT id, invoice_number, purchase_date, invoice_total, amount_paid, balance, user_id, created_at, supplier_id, closed_at FROM invoices WHERE id = $1 FOR UPDATE
+`
+
+// Locks an invoice against being closed while its stock in is changed.
+func (q *Queries) GetInvoiceForUpdate(ctx context.Context, id int32) (Invoice, error) {
+	row := q.db.QueryRow(ctx, getInvoiceForUpdate, id)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.InvoiceNumber,
+		&i.PurchaseDate,
+		&i.InvoiceTotal,
+		&i.AmountPaid,
+		&i.Balance,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.SupplierID,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const getLatestProductBatch = `-- name: GetLatestProductBatch :one
+SELECT id, product_id, stock_in_id, batch_number, expiry_date, quantity, cost_price, created_at FROM product_batches WHERE product_id = $1 ORDER BY id DESC LIMIT 1
+`
+
+func (q *Queries) GetLatestProductBatch(ctx context.Context, productID int32) (ProductBatch, error) {
+	row := q.db.QueryRow(ctx, getLatestProductBatch, productID)
+	var i ProductBatch
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.StockInID,
+		&i.BatchNumber,
+		&i.ExpiryDate,
+		&i.Quantity,
+		&i.CostPrice,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getProduct = `-- name: GetProduct :one
+SELECT id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at, tax_class_id, price_includes_tax, reorder_level, reorder_quantity, preferred_supplier_id FROM products WHERE id = $1
+`
+
+func (q *Queries) GetProduct(ctx context.Context, id int32) (Product, error) {
+	row := q.db.QueryRow(ctx, getProduct, id)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.GenericName,
+		&i.BrandName,
+		&i.Quantity,
+		&i.CostPrice,
+		&i.SellingPrice,
+		&i.ExpiryDates,
+		&i.Barcode,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TaxClassID,
+		&i.PriceIncludesTax,
+		&i.ReorderLevel,
+		&i.ReorderQuantity,
+		&i.PreferredSupplierID,
+	)
+	return i, err
+}
+
+const getProductByBarcode = `-- name: GetProductByBarcode :one
+SELECT id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at, tax_class_id, price_includes_tax, reorder_level, reorder_quantity, preferred_supplier_id FROM products WHERE barcode = $1
+`
+
+func (q *Queries) GetProductByBarcode(ctx context.Context, barcode string) (Product, error) {
+	row := q.db.QueryRow(ctx, getProductByBarcode, barcode)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.GenericName,
+		&i.BrandName,
+		&i.Quantity,
+		&i.CostPrice,
+		&i.SellingPrice,
+		&i.ExpiryDates,
+		&i.Barcode,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TaxClassID,
+		&i.PriceIncludesTax,
+		&i.ReorderLevel,
+		&i.ReorderQuantity,
+		&i.PreferredSupplierID,
+	)
+	return i, err
+}
+
+const getPurchaseOrder = `-- name: GetPurchaseOrder :one
+SELECT purchase_orders.id, purchase_orders.supplier_id, purchase_orders.status, purchase_orders.expected_date, purchase_orders.note, purchase_orders.invoice_id, purchase_orders.created_by, purchase_orders.sent_at, purchase_orders.closed_at, purchase_orders.created_at, suppliers.name AS supplier_name, users.username AS created_by_username,
+    COALESCE(invoices.invoice_number, '')::text AS invoice_number
+FROM purchase_orders
+JOIN suppliers ON suppliers.id = purchase_orders.supplier_id
+JOIN users ON users.id = purchase_orders.created_by
+LEFT JOIN invoices ON invoices.id = purchase_orders.invoice_id
+WHERE purchase_orders.id = $1
+`
+
+type GetPurchaseOrderRow struct {
+	ID                int32               `json:"id"`
+	SupplierID        int32               `json:"supplier_id"`
+	Status            PurchaseOrderStatus `json:"status"`
+	ExpectedDate      dbtypes.Date        `json:"expected_date"`
+	Note              string              `json:"note"`
+	InvoiceID         *int32              `json:"invoice_id"`
+	CreatedBy         int32               `json:"created_by"`
+	SentAt            *time.Time          `json:"sent_at"`
+	ClosedAt          *time.Time          `json:"closed_at"`
+	CreatedAt         time.Time           `json:"created_at"`
+	SupplierName      string              `json:"supplier_name"`
+	CreatedByUsername string              `json:"created_by_username"`
+	InvoiceNumber     string              `json:"invoice_number"`
+}
+
+func (q *Queries) GetPurchaseOrder(ctx context.Context, id int32) (GetPurchaseOrderRow, error) {
+	row := q.db.QueryRow(ctx, getPurchaseOrder, id)
+	var i GetPurchaseOrderRow
+	err := row.Scan(
+		&i.ID,
+		&i.SupplierID,
+		&i.Status,
+		&i.ExpectedDate,
+		&i.Note,
+		&i.InvoiceID,
+		&i.CreatedBy,
+		&i.SentAt,
+		&i.ClosedAt,
+		&i.CreatedAt,
+		&i.SupplierName,
+		&i.CreatedByUsername,
+		&i.InvoiceNumber,
+	)
+	return i, err
+}
+
+const getPurchaseOrderForUpdate = `-- name: GetPurchaseOrderForUpdate :one
+SELECT id, supplier_id, status, expected_date, note, invoice_id, created_by, sent_at, closed_at, created_at FROM purchase_orders WHERE id = $1 FOR UPDATE
+`
+
+// Locks a purchase order while stock is received against it or it is converted.
+func (q *Queries) GetPurchaseOrderForUpdate(ctx context.Context, id int32) (PurchaseOrder, error) {
+	row := q.db.QueryRow(ctx, getPurchaseOrderForUpdate, id)
+	var i PurchaseOrder
+	err := row.Scan(
+		&i.ID,
+		&i.SupplierID,
+		&i.Status,
+		&i.ExpectedDate,
+		&i.Note,
+		&i.InvoiceID,
+		&i.CreatedBy,
+		&i.SentAt,
+		&i.ClosedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getPurchaseOrderItemOrderForUpdate = `-- name: GetPurchaseOrderItemOrderForUpdate :one
+SELECT purchase_orders.id, purchase_orders.supplier_id, purchase_orders.status, purchase_orders.expected_date, purchase_orders.note, purchase_orders.invoice_id, purchase_orders.created_by, purchase_orders.sent_at, purchase_orders.closed_at, purchase_orders.created_at FROM purchase_orders
+JOIN purchase_order_items ON purchase_order_items.purchase_order_id = purchase_orders.id
+WHERE purchase_order_items.id = $1 FOR UPDATE OF purchase_orders
+`
+
+// Locks the purchase order of a line while stock received against it is removed.
+func (q *Queries) GetPurchaseOrderItemOrderForUpdate(ctx context.Context, id int32) (PurchaseOrder, error) {
+	row := q.db.QueryRow(ctx, getPurchaseOrderItemOrderForUpdate, id)
+	var i PurchaseOrder
+	err := row.Scan(
+		&i.ID,
+		&i.SupplierID,
+		&i.Status,
+		&i.ExpectedDate,
+		&i.Note,
+		&i.InvoiceID,
+		&i.CreatedBy,
+		&i.SentAt,
+		&i.ClosedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getRoleDiscountLimit = `-- name: GetRoleDiscountLimit :one
+SELECT max_percent FROM role_discount_limits WHERE role = $1
+`
+
+func (q *Queries) GetRoleDiscountLimit(ctx context.Context, role UserRole) (float64, error) {
+	row := q.db.QueryRow(ctx, getRoleDiscountLimit, role)
+	var max_percent float64
+	err := row.Scan(&max_percent)
+	return max_percent, err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at FROM sessions WHERE id = $1
+`
+
+func (q *Queries) GetSession(ctx context.Context, id string) (Session, error) {
+	row := q.db.QueryRow(ctx, getSession, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const getStockIn = `-- name: GetStockIn :one
+SELECT id, product_id, invoice_id, quantity, cost_price, expiry_date, comment, created_at, batch_number, purchase_order_item_id FROM stock_in WHERE id = $1
+`
+
+func (q *Queries) GetStockIn(ctx context.Context, id int32) (StockIn, error) {
+	row := q.db.QueryRow(ctx, getStockIn, id)
+	var i StockIn
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.InvoiceID,
+		&i.Quantity,
+		&i.CostPrice,
+		&i.ExpiryDate,
+		&i.Comment,
+		&i.CreatedAt,
+		&i.BatchNumber,
+		&i.PurchaseOrderItemID,
+	)
+	return i, err
+}
+
+const getStockInBatch = `-- name: GetStockInBatch :one
+SELECT id, product_id, stock_in_id, batch_number, expiry_date, quantity, cost_price, created_at FROM product_batches WHERE stock_in_id = $1
+`
+
+func (q *Queries) GetStockInBatch(ctx context.Context, stockInID *int32) (ProductBatch, error) {
+	row := q.db.QueryRow(ctx, getStockInBatch, stockInID)
+	var i ProductBatch
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.StockInID,
+		&i.BatchNumber,
+		&i.ExpiryDate,
+		&i.Quantity,
+		&i.CostPrice,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getStockInBatchForUpdate = `-- name: GetStockInBatchForUpdate :one
+SELECT id, product_id, stock_in_id, batch_number, expiry_date, quantity, cost_price, created_at FROM product_batches WHERE stock_in_id = $1 FOR UPDATE
+`
+
+// Lock the batch of a stock in. Lock its product first, as sales do.
+func (q *Queries) GetStockInBatchForUpdate(ctx context.Context, stockInID *int32) (ProductBatch, error) {
+	row := q.db.QueryRow(ctx, getStockInBatchForUpdate, stockInID)
+	var i ProductBatch
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.StockInID,
+		&i.BatchNumber,
+		&i.ExpiryDate,
+		&i.Quantity,
+		&i.CostPrice,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getStockTake = `-- name: GetStockTake :one
+SELECT id, note, status, opened_by, closed_by, closed_at, created_at FROM stock_takes WHERE id = $1
+`
+
+func (q *Queries) GetStockTake(ctx context.Context, id int32) (StockTake, error) {
+	row := q.db.QueryRow(ctx, getStockTake, id)
+	var i StockTake
+	err := row.Scan(
+		&i.ID,
+		&i.Note,
+		&i.Status,
+		&i.OpenedBy,
+		&i.ClosedBy,
+		&i.ClosedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getSupplier = `-- name: GetSupplier :one
+SELECT id, name, contact_person, phone, email, tin, address, payment_terms_days, lead_time_days, created_at, updated_at FROM suppliers WHERE id = $1
+`
+
+func (q *Queries) GetSupplier(ctx context.Context, id int32) (Supplier, error) {
+	row := q.db.QueryRow(ctx, getSupplier, id)
+	var i Supplier
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.ContactPerson,
+		&i.Phone,
+		&i.Email,
+		&i.Tin,
+		&i.Address,
+		&i.PaymentTermsDays,
+		&i.LeadTimeDays,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getTaxClass = `-- name: GetTaxClass :one
+SELECT id, name, kind, rate, created_at FROM tax_classes WHERE id = $1
+`
+
+func (q *Queries) GetTaxClass(ctx context.Context, id int32) (TaxClass, error) {
+	row := q.db.QueryRow(ctx, getTaxClass, id)
+	var i TaxClass
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Kind,
+		&i.Rate,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getTransaction = `-- name: GetTransaction :one
+SELECT id, created_at, user_id, idempotency_key, discount_approved_by FROM transactions WHERE id = $1
+`
+
+func (q *Queries) GetTransaction(ctx context.Context, id int32) (Transaction, error) {
+	row := q.db.QueryRow(ctx, getTransaction, id)
+	var i Transaction
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.IdempotencyKey,
+		&i.DiscountApprovedBy,
+	)
+	return i, err
+}
+
+const getTransactionByIdempotencyKey = `-- name: GetTransactionByIdempotencyKey :one
+SELECT id, created_at, user_id, idempotency_key, discount_approved_by FROM transactions WHERE idempotency_key = $1
+`
+
+func (q *Queries) GetTransactionByIdempotencyKey(ctx context.Context, idempotencyKey *string) (Transaction, error) {
+	row := q.db.QueryRow(ctx, getTransactionByIdempotencyKey, idempotencyKey)
+	var i Transaction
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.IdempotencyKey,
+		&i.DiscountApprovedBy,
+	)
+	return i, err
+}
+
+const getTransactionForUpdate = `-- name: GetTransactionForUpdate :one
+SELECT id, created_at, user_id, idempotency_key, discount_approved_by FROM transactions WHERE id = $1 FOR UPDATE
+`
+
+// Lock a sale against being cancelled twice at the same time.
+func (q *Queries) GetTransactionForUpdate(ctx context.Context, id int32) (Transaction, error) {
+	row := q.db.QueryRow(ctx, getTransactionForUpdate, id)
+	var i Transaction
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.IdempotencyKey,
+		&i.DiscountApprovedBy,
+	)
+	return i, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, username, password, is_active, created_at, role FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Password,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Role,
+	)
+	return i, err
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, username, password, is_active, created_at, role FROM users WHERE username = $1 LIMIT 1
+`
+
+func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByUsername, username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Password,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Role,
+	)
+	return i, err
+}
+
+const incrementBatch = `-- name: IncrementBatch :exec
+UPDATE product_batches SET quantity = quantity + $1 WHERE id = $2
+`
+
+type IncrementBatchParams struct {
+	Quantity int32 `json:"quantity"`
+	ID       int32 `json:"id"`
+}
+
+func (q *Queries) IncrementBatch(ctx context.Context, arg IncrementBatchParams) error {
+	_, err := q.db.Exec(ctx, incrementBatch, arg.Quantity, arg.ID)
+	return err
+}
+
+const invoiceItems = `-- name: InvoiceItems :many
+SELECT stock_in.id, stock_in.product_id, stock_in.invoice_id, stock_in.quantity, stock_in.cost_price, stock_in.expiry_date, stock_in.comment, stock_in.created_at, stock_in.batch_number, stock_in.purchase_order_item_id, 
+    products.generic_name, products.brand_name
+FROM stock_in
+JOIN products ON stock_in.product_id = products.id
+WHERE stock_in.invoice_id = (SELECT id FROM invoices WHERE invoice_number=$1 LIMIT 1)
+ORDER BY stock_in.id
+`
+
+type InvoiceItemsRow struct {
+	ID                  int32        `json:"id"`
+	ProductID           int32        `json:"product_id"`
+	InvoiceID           *int32       `json:"invoice_id"`
+	Quantity            int32        `json:"quantity"`
+	CostPrice           float64      `json:"cost_price"`
+	ExpiryDate          dbtypes.Date `json:"expiry_date"`
+	Comment             string       `json:"comment"`
+	CreatedAt           time.Time    `json:"created_at"`
+	BatchNumber         string       `json:"batch_number"`
+	PurchaseOrderItemID *int32       `json:"purchase_order_item_id"`
+	GenericName         string       `json:"generic_name"`
+	BrandName           string       `json:"brand_name"`
+}
+
+// ================== StockIN Queries =========================
+func (q *Queries) InvoiceItems(ctx context.Context, invoiceNumber string) ([]InvoiceItemsRow, error) {
+	rows, err := q.db.Query(ctx, invoiceItems, invoiceNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []InvoiceItemsRow{}
+	for rows.Next() {
+		var i InvoiceItemsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.InvoiceID,
+			&i.Quantity,
+			&i.CostPrice,
+			&i.ExpiryDate,
+			&i.Comment,
+			&i.CreatedAt,
+			&i.BatchNumber,
+			&i.PurchaseOrderItemID,
+			&i.GenericName,
+			&i.BrandName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const invoiceLineTotal = `-- name: InvoiceLineTotal :one
+SELECT COALESCE(SUM(quantity * cost_price), 0)::float AS line_total
+FROM stock_in WHERE invoice_id = $1::int
+`
+
+// The value of the stock in on an invoice at cost.
+func (q *Queries) InvoiceLineTotal(ctx context.Context, invoiceID int32) (float64, error) {
+	row := q.db.QueryRow(ctx, invoiceLineTotal, invoiceID)
+	var line_total float64
+	err := row.Scan(&line_total)
+	return line_total, err
+}
+
+const invoicePurchaseOrderStock = `-- name: InvoicePurchaseOrderStock :execrows
+UPDATE stock_in SET invoice_id = $1::int
+FROM purchase_order_items
+WHERE stock_in.purchase_order_item_id = purchase_order_items.id
+AND purchase_order_items.purchase_order_id = $2 AND stock_in.invoice_id IS NULL
+`
+
+type InvoicePurchaseOrderStockParams struct {
+	InvoiceID       int32 `json:"invoice_id"`
+	PurchaseOrderID int32 `json:"purchase_order_id"`
+}
+
+// Puts the stock received against a purchase order on an invoice.
+func (q *Queries) InvoicePurchaseOrderStock(ctx context.Context, arg InvoicePurchaseOrderStockParams) (int64, error) {
+	result, err := q.db.Exec(ctx, invoicePurchaseOrderStock, arg.InvoiceID, arg.PurchaseOrderID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const lastStockBalanceSnapshot = `-- name: LastStockBalanceSnapshot :one
+SELECT COALESCE(MAX(balance_date), pharmacy_date(CURRENT_TIMESTAMP) - 1)::date AS balance_date
+FROM stock_balance_snapshots
+`
+
+// Day of the last snapshot of opening balances, or yesterday if there is none.
+func (q *Queries) LastStockBalanceSnapshot(ctx context.Context) (dbtypes.Date, error) {
+	row := q.db.QueryRow(ctx, lastStockBalanceSnapshot)
+	var balance_date dbtypes.Date
+	err := row.Scan(&balance_date)
+	return balance_date, err
+}
+
+const listDiscountApprovers = `-- name: ListDiscountApprovers :many
+SELECT users.id, users.username, user_pins.pin_hash, role_discount_limits.max_percent
+FROM users
+JOIN user_pins ON user_pins.user_id = users.id
+JOIN role_discount_limits ON role_discount_limits.role = users.role
+WHERE users.is_active AND role_discount_limits.max_percent > $1::double precision
+ORDER BY users.id
+`
+
+type ListDiscountApproversRow struct {
+	ID         int32   `json:"id"`
+	Username   string  `json:"username"`
+	PinHash    string  `json:"pin_hash"`
+	MaxPercent float64 `json:"max_percent"`
+}
+
+// Active users with a PIN whose role may give more than a discount, with
+// the largest discount their role may give.
+func (q *Queries) ListDiscountApprovers(ctx context.Context, abovePercent float64) ([]ListDiscountApproversRow, error) {
+	rows, err := q.db.Query(ctx, listDiscountApprovers, abovePercent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListDiscountApproversRow{}
+	for rows.Next() {
+		var i ListDiscountApproversRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.PinHash,
+			&i.MaxPercent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listInvoicePayments = `-- name: ListInvoicePayments :many
+SELECT supplier_payments.id, supplier_payments.invoice_id, supplier_payments.payment_date, supplier_payments.amount, supplier_payments.method, supplier_payments.reference, supplier_payments.user_id, supplier_payments.created_at, users.username
+FROM supplier_payments
+JOIN users ON users.id = supplier_payments.user_id
+WHERE supplier_payments.invoice_id = $1
+ORDER BY supplier_payments.payment_date, supplier_payments.id
+`
+
+type ListInvoicePaymentsRow struct {
+	ID          int32                 `json:"id"`
+	InvoiceID   int32                 `json:"invoice_id"`
+	PaymentDate dbtypes.Date          `json:"payment_date"`
+	Amount      float64               `json:"amount"`
+	Method      SupplierPaymentMethod `json:"method"`
+	Reference   string                `json:"reference"`
+	UserID      int32                 `json:"user_id"`
+	CreatedAt   time.Time             `json:"created_at"`
+	Username    string                `json:"username"`
+}
+
+func (q *Queries) ListInvoicePayments(ctx context.Context, invoiceID int32) ([]ListInvoicePaymentsRow, error) {
+	rows, err := q.db.Query(ctx, listInvoicePayments, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListInvoicePaymentsRow{}
+	for rows.Next() {
+		var i ListInvoicePaymentsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.InvoiceID,
+			&i.PaymentDate,
+			&i.Amount,
+			&i.Method,
+			&i.Reference,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listInvoicesPaginated = `-- name: ListInvoicesPaginated :many
+
+SELECT invoices.id, invoices.invoice_number, invoices.purchase_date, invoices.invoice_total, invoices.amount_paid, invoices.balance, invoices.user_id, invoices.created_at, invoices.supplier_id, invoices.closed_at, suppliers.name AS supplier_name
+FROM invoices
+JOIN suppliers ON suppliers.id = invoices.supplier_id
+ORDER BY invoices.id LIMIT $1 OFFSET $2
+`
+
+type ListInvoicesPaginatedParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+type ListInvoicesPaginatedRow struct {
+	ID            int32        `json:"id"`
+	InvoiceNumber string       `json:"invoice_number"`
+	PurchaseDate  dbtypes.Date `json:"purchase_date"`
+	InvoiceTotal  float64      `json:"invoice_total"`
+	AmountPaid    float64      `json:"amount_paid"`
+	Balance       float64      `json:"balance"`
+	UserID        int32        `json:"user_id"`
+	CreatedAt     time.Time    `json:"created_at"`
+	SupplierID    int32        `json:"supplier_id"`
+	ClosedAt      *time.Time   `json:"closed_at"`
+	SupplierName  string       `json:"supplier_name"`
+}
+
+func (q *Queries) ListInvoicesPaginated(ctx context.Context, arg ListInvoicesPaginatedParams) ([]ListInvoicesPaginatedRow, error) {
+	rows, err := q.db.Query(ctx, listInvoicesPaginated, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListInvoicesPaginatedRow{}
+	for rows.Next() {
+		var i ListInvoicesPaginatedRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.InvoiceNumber,
+			&i.PurchaseDate,
+			&i.InvoiceTotal,
+			&i.AmountPaid,
+			&i.Balance,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.SupplierID,
+			&i.ClosedAt,
+			&i.SupplierName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPayments = `-- name: ListPayments :many
+SELECT id, transaction_id, method, amount, tendered, change, reference, created_at FROM payments
+WHERE transaction_id = ANY($1::int[])
+ORDER BY id
+`
+
+func (q *Queries) ListPayments(ctx context.Context, transactionIds []int32) ([]Payment, error) {
+	rows, err := q.db.Query(ctx, listPayments, transactionIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Payment{}
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.TransactionID,
+			&i.Method,
+			&i.Amount,
+			&i.Tendered,
+			&i.Change,
+			&i.Reference,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProductBatches = `-- name: ListProductBatches :many
+SELECT id, product_id, stock_in_id, batch_number, expiry_date, quantity, cost_price, created_at FROM product_batches WHERE product_id = $1 AND quantity > 0
+ORDER BY expiry_date NULLS LAST, id
+`
+
+// Batches with stock on hand, earliest expiry first.
+func (q *Queries) ListProductBatches(ctx context.Context, productID int32) ([]ProductBatch, error) {
+	rows, err := q.db.Query(ctx, listProductBatches, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ProductBatch{}
+	for rows.Next() {
+		var i ProductBatch
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.StockInID,
+			&i.BatchNumber,
+			&i.ExpiryDate,
+			&i.Quantity,
+			&i.CostPrice,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProductBatchesForSale = `-- name: ListProductBatchesForSale :many
+SELECT id, product_id, stock_in_id, batch_number, expiry_date, quantity, cost_price, created_at FROM product_batches 
+WHERE product_id = $1 AND quantity > 0 
+    AND (expiry_date IS NULL OR expiry_date >= $2::date)
+ORDER BY expiry_date NULLS LAST, id FOR UPDATE
+`
+
+type ListProductBatchesForSaleParams struct {
+	ProductID int32        `json:"product_id"`
+	Today     dbtypes.Date `json:"today"`
+}
+
+// Lock the unexpired batches with stock on hand, earliest expiry first (FEFO).
+func (q *Queries) ListProductBatchesForSale(ctx context.Context, arg ListProductBatchesForSaleParams) ([]ProductBatch, error) {
+	rows, err := q.db.Query(ctx, listProductBatchesForSale, arg.ProductID, arg.Today)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ProductBatch{}
+	for rows.Next() {
+		var i ProductBatch
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.StockInID,
+			&i.BatchNumber,
+			&i.ExpiryDate,
+			&i.Quantity,
+			&i.CostPrice,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProductBatchesForUpdate = `-- name: ListProductBatchesForUpdate :many
+SELECT id, product_id, stock_in_id, batch_number, expiry_date, quantity, cost_price, created_at FROM product_batches WHERE product_id = $1 AND quantity > 0
+ORDER BY expiry_date NULLS LAST, id FOR UPDATE
+`
+
+// Lock all batches with stock on hand, earliest expiry first.
+// Lock the product first, as sales do.
+func (q *Queries) ListProductBatchesForUpdate(ctx context.Context, productID int32) ([]ProductBatch, error) {
+	rows, err := q.db.Query(ctx, listProductBatchesForUpdate, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ProductBatch{}
+	for rows.Next() {
+		var i ProductBatch
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.StockInID,
+			&i.BatchNumber,
+			&i.ExpiryDate,
+			&i.Quantity,
+			&i.CostPrice,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProductsPaginated = `-- name: ListProductsPaginated :many
+
+SELECT id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at, tax_class_id, price_includes_tax, reorder_level, reorder_quantity, preferred_supplier_id FROM products WHERE 
+CASE WHEN $1::text != ''
+    THEN generic_name ILIKE '%' || $1::text || '%' OR brand_name ILIKE '%' || $1::text || '%'
+    ELSE TRUE
+END
+ORDER BY id LIMIT $3 OFFSET $2
+`
+
+type ListProductsPaginatedParams struct {
+	Name string `json:"name"`
+	Off  int32  `json:"off"`
+	Lim  int32  `json:"lim"`
+}
+
+// -- Product queries ----------------
+// Filter by name if provided.
+func (q *Queries) ListProductsPaginated(ctx context.Context, arg ListProductsPaginatedParams) ([]Product, error) {
+	rows, err := q.db.Query(ctx, listProductsPaginated, arg.Name, arg.Off, arg.Lim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Product{}
+	for rows.Next() {
+		var i Product
+		if err := rows.Scan(
+			&i.ID,
+			&i.GenericName,
+			&i.BrandName,
+			&i.Quantity,
+			&i.CostPrice,
+			&i.SellingPrice,
+			&i.ExpiryDates,
+			&i.Barcode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.TaxClassID,
+			&i.PriceIncludesTax,
+			&i.ReorderLevel,
+			&i.ReorderQuantity,
+			&i.PreferredSupplierID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPurchaseOrderItems = `-- name: ListPurchaseOrderItems :many
+SELECT purchase_order_items.id, purchase_order_items.purchase_order_id, purchase_order_items.product_id, purchase_order_items.quantity, purchase_order_items.unit_cost, products.generic_name, products.brand_name,
+    COALESCE(SUM(stock_in.quantity), 0)::int AS quantity_received,
+    COALESCE(SUM(stock_in.quantity * stock_in.cost_price), 0)::double precision AS received_value
+FROM purchase_order_items
+JOIN products ON products.id = purchase_order_items.product_id
+LEFT JOIN stock_in ON stock_in.purchase_order_item_id = purchase_order_items.id
+WHERE purchase_order_items.purchase_order_id = $1
+GROUP BY purchase_order_items.id, products.id
+ORDER BY products.generic_name, products.brand_name
+`
+
+type ListPurchaseOrderItemsRow struct {
+	ID               int32   `json:"id"`
+	PurchaseOrderID  int32   `json:"purchase_order_id"`
+	ProductID        int32   `json:"product_id"`
+	Quantity         int32   `json:"quantity"`
+	UnitCost         float64 `json:"unit_cost"`
+	GenericName      string  `json:"generic_name"`
+	BrandName        string  `json:"brand_name"`
+	QuantityReceived int32   `json:"quantity_received"`
+	ReceivedValue    float64 `json:"received_value"`
+}
+
+// The lines of a purchase order with the quantity and value received against each.
+func (q *Queries) ListPurchaseOrderItems(ctx context.Context, purchaseOrderID int32) ([]ListPurchaseOrderItemsRow, error) {
+	rows, err := q.db.Query(ctx, listPurchaseOrderItems, purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListPurchaseOrderItemsRow{}
+	for rows.Next() {
+		var i ListPurchaseOrderItemsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PurchaseOrderID,
+			&i.ProductID,
+			&i.Quantity,
+			&i.UnitCost,
+			&i.GenericName,
+			&i.BrandName,
+			&i.QuantityReceived,
+			&i.ReceivedValue,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPurchaseOrders = `-- name: ListPurchaseOrders :many
+SELECT purchase_orders.id, purchase_orders.supplier_id, purchase_orders.status, purchase_orders.expected_date, purchase_orders.note, purchase_orders.invoice_id, purchase_orders.created_by, purchase_orders.sent_at, purchase_orders.closed_at, purchase_orders.created_at, suppliers.name AS supplier_name,
+    COUNT(purchase_order_items.id) AS lines,
+    COALESCE(SUM(purchase_order_items.quantity * purchase_order_items.unit_cost), 0)::double precision AS ordered_value
+FROM purchase_orders
+JOIN suppliers ON suppliers.id = purchase_orders.supplier_id
+LEFT JOIN purchase_order_items ON purchase_order_items.purchase_order_id = purchase_orders.id
+WHERE ($1::text = '' OR purchase_orders.status::text = $1::text)
+AND ($2::int = 0 OR purchase_orders.supplier_id = $2::int)
+GROUP BY purchase_orders.id, suppliers.name
+ORDER BY purchase_orders.id DESC
+LIMIT 200
+`
+
+type ListPurchaseOrdersParams struct {
+	Status     string `json:"status"`
+	SupplierID int32  `json:"supplier_id"`
+}
+
+type ListPurchaseOrdersRow struct {
+	ID           int32               `json:"id"`
+	SupplierID   int32               `json:"supplier_id"`
+	Status       PurchaseOrderStatus `json:"status"`
+	ExpectedDate dbtypes.Date        `json:"expected_date"`
+	Note         string              `json:"note"`
+	InvoiceID    *int32              `json:"invoice_id"`
+	CreatedBy    int32               `json:"created_by"`
+	SentAt       *time.Time          `json:"sent_at"`
+	ClosedAt     *time.Time          `json:"closed_at"`
+	CreatedAt    time.Time           `json:"created_at"`
+	SupplierName string              `json:"supplier_name"`
+	Lines        int64               `json:"lines"`
+	OrderedValue float64             `json:"ordered_value"`
+}
+
+// The latest purchase orders with their number of lines and value ordered.
+// An empty status or a supplier_id of 0 matches all orders.
+func (q *Queries) ListPurchaseOrders(ctx context.Context, arg ListPurchaseOrdersParams) ([]ListPurchaseOrdersRow, error) {
+	rows, err := q.db.Query(ctx, listPurchaseOrders, arg.Status, arg.SupplierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListPurchaseOrdersRow{}
+	for rows.Next() {
+		var i ListPurchaseOrdersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SupplierID,
+			&i.Status,
+			&i.ExpectedDate,
+			&i.Note,
+			&i.InvoiceID,
+			&i.CreatedBy,
+			&i.SentAt,
+			&i.ClosedAt,
+			&i.CreatedAt,
+			&i.SupplierName,
+			&i.Lines,
+			&i.OrderedValue,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRoleDiscountLimits = `-- name: ListRoleDiscountLimits :many
+SELECT role, max_percent FROM role_discount_limits ORDER BY role
+`
+
+func (q *Queries) ListRoleDiscountLimits(ctx context.Context) ([]RoleDiscountLimit, error) {
+	rows, err := q.db.Query(ctx, listRoleDiscountLimits)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []RoleDiscountLimit{}
+	for rows.Next() {
+		var i RoleDiscountLimit
+		if err := rows.Scan(&i.Role, &i.MaxPercent); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStockAdjustments = `-- name: ListStockAdjustments :many
+SELECT stock_adjustments.id, stock_adjustments.product_id, stock_adjustments.quantity, stock_adjustments.reason, stock_adjustments.note, stock_adjustments.status, stock_adjustments.requested_by, stock_adjustments.reviewed_by, stock_adjustments.reviewed_at, stock_adjustments.created_at, stock_adjustments.stock_take_id, products.generic_name, products.brand_name,
+    requester.username AS requested_by_username,
+    COALESCE(reviewer.username, '')::text AS reviewed_by_username
+FROM stock_adjustments
+JOIN products ON products.id = stock_adjustments.product_id
+JOIN users requester ON requester.id = stock_adjustments.requested_by
+LEFT JOIN users reviewer ON reviewer.id = stock_adjustments.reviewed_by
+WHERE ($1::text = '' OR stock_adjustments.status::text = $1::text)
+AND ($2::int = 0 OR stock_adjustments.product_id = $2::int)
+ORDER BY stock_adjustments.id DESC
+LIMIT 200
+`
+
+type ListStockAdjustmentsParams struct {
+	Status    string `json:"status"`
+	ProductID int32  `json:"product_id"`
+}
+
+type ListStockAdjustmentsRow struct {
+	ID                  int32            `json:"id"`
+	ProductID           int32            `json:"product_id"`
+	Quantity            int32            `json:"quantity"`
+	Reason              AdjustmentReason `json:"reason"`
+	Note                string           `json:"note"`
+	Status              AdjustmentStatus `json:"status"`
+	RequestedBy         int32            `json:"requested_by"`
+	ReviewedBy          *int32           `json:"reviewed_by"`
+	ReviewedAt          *time.Time       `json:"reviewed_at"`
+	CreatedAt           time.Time        `json:"created_at"`
+	StockTakeID         *int32           `json:"stock_take_id"`
+	GenericName         string           `json:"generic_name"`
+	BrandName           string           `json:"brand_name"`
+	RequestedByUsername string           `json:"requested_by_username"`
+	ReviewedByUsername  string           `json:"reviewed_by_username"`
+}
+
+// The latest adjustments with their product and users. An empty status
+// or a product_id of 0 matches all adjustments.
+func (q *Queries) ListStockAdjustments(ctx context.Context, arg ListStockAdjustmentsParams) ([]ListStockAdjustmentsRow, error) {
+	rows, err := q.db.Query(ctx, listStockAdjustments, arg.Status, arg.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListStockAdjustmentsRow{}
+	for rows.Next() {
+		var i ListStockAdjustmentsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.Quantity,
+			&i.Reason,
+			&i.Note,
+			&i.Status,
+			&i.RequestedBy,
+			&i.ReviewedBy,
+			&i.ReviewedAt,
+			&i.CreatedAt,
+			&i.StockTakeID,
+			&i.GenericName,
+			&i.BrandName,
+			&i.RequestedByUsername,
+			&i.ReviewedByUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStockTakeItems = `-- name: ListStockTakeItems :many
+SELECT stock_take_items.id, stock_take_items.stock_take_id, stock_take_items.product_id, stock_take_items.expected_quantity, stock_take_items.unit_cost, stock_take_items.counted_quantity, stock_take_items.counted_by, stock_take_items.counted_at, products.generic_name, products.brand_name, products.barcode
+FROM stock_take_items
+JOIN products ON products.id = stock_take_items.product_id
+WHERE stock_take_items.stock_take_id = $1
+ORDER BY products.generic_name, products.brand_name
+`
+
+type ListStockTakeItemsRow struct {
+	ID               int32      `json:"id"`
+	StockTakeID      int32      `json:"stock_take_id"`
+	ProductID        int32      `json:"product_id"`
+	ExpectedQuantity int32      `json:"expected_quantity"`
+	UnitCost         float64    `json:"unit_cost"`
+	CountedQuantity  *int32     `json:"counted_quantity"`
+	CountedBy        *int32     `json:"counted_by"`
+	CountedAt        *time.Time `json:"counted_at"`
+	GenericName      string     `json:"generic_name"`
+	BrandName        string     `json:"brand_name"`
+	Barcode          string     `json:"barcode"`
+}
+
+func (q *Queries) ListStockTakeItems(ctx context.Context, stockTakeID int32) ([]ListStockTakeItemsRow, error) {
+	rows, err := q.db.Query(ctx, listStockTakeItems, stockTakeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListStockTakeItemsRow{}
+	for rows.Next() {
+		var i ListStockTakeItemsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.StockTakeID,
+			&i.ProductID,
+			&i.ExpectedQuantity,
+			&i.UnitCost,
+			&i.CountedQuantity,
+			&i.CountedBy,
+			&i.CountedAt,
+			&i.GenericName,
+			&i.BrandName,
+			&i.Barcode,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStockTakes = `-- name: ListStockTakes :many
+SELECT stock_takes.id, stock_takes.note, stock_takes.status, stock_takes.opened_by, stock_takes.closed_by, stock_takes.closed_at, stock_takes.created_at, users.username AS opened_by_username,
+    COUNT(stock_take_items.id) AS products,
+    COUNT(stock_take_items.counted_quantity) AS counted,
+    COALESCE(SUM((stock_take_items.counted_quantity - stock_take_items.expected_quantity)
+        * stock_take_items.unit_cost), 0)::double precision AS variance_value
+FROM stock_takes
+JOIN users ON users.id = stock_takes.opened_by
+LEFT JOIN stock_take_items ON stock_take_items.stock_take_id = stock_takes.id
+GROUP BY stock_takes.id, users.username
+ORDER BY stock_takes.id DESC
+LIMIT 50
+`
+
+type ListStockTakesRow struct {
+	ID               int32           `json:"id"`
+	Note             string          `json:"note"`
+	Status           StockTakeStatus `json:"status"`
+	OpenedBy         int32           `json:"opened_by"`
+	ClosedBy         *int32          `json:"closed_by"`
+	ClosedAt         *time.Time      `json:"closed_at"`
+	CreatedAt        time.Time       `json:"created_at"`
+	OpenedByUsername string          `json:"opened_by_username"`
+	Products         int64           `json:"products"`
+	Counted          int64           `json:"counted"`
+	VarianceValue    float64         `json:"variance_value"`
+}
+
+// The latest stock takes with how many products were counted
+// and the net variance at cost.
+func (q *Queries) ListStockTakes(ctx context.Context) ([]ListStockTakesRow, error) {
+	rows, err := q.db.Query(ctx, listStockTakes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListStockTakesRow{}
+	for rows.Next() {
+		var i ListStockTakesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Note,
+			&i.Status,
+			&i.OpenedBy,
+			&i.ClosedBy,
+			&i.ClosedAt,
+			&i.CreatedAt,
+			&i.OpenedByUsername,
+			&i.Products,
+			&i.Counted,
+			&i.VarianceValue,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSupplierInvoices = `-- name: ListSupplierInvoices :many
+SELECT id, invoice_number, purchase_date, invoice_total, amount_paid, balance, user_id, created_at, supplier_id, closed_at FROM invoices WHERE supplier_id = $1 ORDER BY purchase_date DESC, id DESC
+`
+
+func (q *Queries) ListSupplierInvoices(ctx context.Context, supplierID int32) ([]Invoice, error) {
+	rows, err := q.db.Query(ctx, listSupplierInvoices, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Invoice{}
+	for rows.Next() {
+		var i Invoice
+		if err := rows.Scan(
+			&i.ID,
+			&i.InvoiceNumber,
+			&i.PurchaseDate,
+			&i.InvoiceTotal,
+			&i.AmountPaid,
+			&i.Balance,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.SupplierID,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSuppliers = `-- name: ListSuppliers :many
+
+SELECT suppliers.id, suppliers.name, suppliers.contact_person, suppliers.phone, suppliers.email, suppliers.tin, suppliers.address, suppliers.payment_terms_days, suppliers.lead_time_days, suppliers.created_at, suppliers.updated_at, COUNT(invoices.id) AS invoices
+FROM suppliers
+LEFT JOIN invoices ON invoices.supplier_id = suppliers.id
+GROUP BY suppliers.id
+ORDER BY suppliers.name
+`
+
+type ListSuppliersRow struct {
+	ID               int32     `json:"id"`
+	Name             string    `json:"name"`
+	ContactPerson    string    `json:"contact_person"`
+	Phone            string    `json:"phone"`
+	Email            string    `json:"email"`
+	Tin              string    `json:"tin"`
+	Address          string    `json:"address"`
+	PaymentTermsDays int32     `json:"payment_terms_days"`
+	LeadTimeDays     int32     `json:"lead_time_days"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Invoices         int64     `json:"invoices"`
+}
+
+func (q *Queries) ListSuppliers(ctx context.Context) ([]ListSuppliersRow, error) {
+	rows, err := q.db.Query(ctx, listSuppliers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListSuppliersRow{}
+	for rows.Next() {
+		var i ListSuppliersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.ContactPerson,
+			&i.Phone,
+			&i.Email,
+			&i.Tin,
+			&i.Address,
+			&i.PaymentTermsDays,
+			&i.LeadTimeDays,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Invoices,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTaxClasses = `-- name: ListTaxClasses :many
+SELECT id, name, kind, rate, created_at FROM tax_classes ORDER BY id
+`
+
+func (q *Queries) ListTaxClasses(ctx context.Context) ([]TaxClass, error) {
+	rows, err := q.db.Query(ctx, listTaxClasses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []TaxClass{}
+	for rows.Next() {
+		var i TaxClass
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Kind,
+			&i.Rate,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransactionBatches = `-- name: ListTransactionBatches :many
+SELECT transaction_batches.id, transaction_batches.transaction_id, transaction_batches.product_id, transaction_batches.batch_id, transaction_batches.quantity, transaction_batches.unit_cost,
+    COALESCE(product_batches.batch_number, '')::text AS batch_number,
+    product_batches.expiry_date
+FROM transaction_batches
+LEFT JOIN product_batches ON transaction_batches.batch_id = product_batches.id
+WHERE transaction_batches.transaction_id = $1
+ORDER BY transaction_batches.id
+`
+
+type ListTransactionBatchesRow struct {
+	ID            int32        `json:"id"`
+	TransactionID int32        `json:"transaction_id"`
+	ProductID     int32        `json:"product_id"`
+	BatchID       *int32       `json:"batch_id"`
+	Quantity      int32        `json:"quantity"`
+	UnitCost      float64      `json:"unit_cost"`
+	BatchNumber   string       `json:"batch_number"`
+	ExpiryDate    dbtypes.Date `json:"expiry_date"`
+}
+
+func (q *Queries) ListTransactionBatches(ctx context.Context, transactionID int32) ([]ListTransactionBatchesRow, error) {
+	rows, err := q.db.Query(ctx, listTransactionBatches, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTransactionBatchesRow{}
+	for rows.Next() {
+		var i ListTransactionBatchesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.TransactionID,
+			&i.ProductID,
+			&i.BatchID,
+			&i.Quantity,
+			&i.UnitCost,
+			&i.BatchNumber,
+			&i.ExpiryDate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransactionItems = `-- name: ListTransactionItems :many
+SELECT transaction_items.id, transaction_items.transaction_id, transaction_items.product_id, transaction_items.product_name, transaction_items.quantity, transaction_items.unit_price, transaction_items.unit_cost, transaction_items.discount, transaction_items.line_total, transaction_items.basket_discount, transaction_items.tax_class_id, transaction_items.tax_rate, transaction_items.tax, transaction_items.tax_inclusive,
+    COALESCE(products.generic_name, transaction_items.product_name)::text AS generic_name,
+    COALESCE(products.brand_name, '')::text AS brand_name,
+    COALESCE(products.barcode, '')::text AS barcode
+FROM transaction_items
+LEFT JOIN products ON transaction_items.product_id = products.id
+WHERE transaction_items.transaction_id = ANY($1::int[])
+ORDER BY transaction_items.id
+`
+
+type ListTransactionItemsRow struct {
+	ID             int32   `json:"id"`
+	TransactionID  int32   `json:"transaction_id"`
+	ProductID      *int32  `json:"product_id"`
+	ProductName    string  `json:"product_name"`
+	Quantity       int32   `json:"quantity"`
+	UnitPrice      float64 `json:"unit_price"`
+	UnitCost       float64 `json:"unit_cost"`
+	Discount       float64 `json:"discount"`
+	LineTotal      float64 `json:"line_total"`
+	BasketDiscount float64 `json:"basket_discount"`
+	TaxClassID     *int32  `json:"tax_class_id"`
+	TaxRate        float64 `json:"tax_rate"`
+	Tax            float64 `json:"tax"`
+	TaxInclusive   bool    `json:"tax_inclusive"`
+	GenericName    string  `json:"generic_name"`
+	BrandName      string  `json:"brand_name"`
+	Barcode        string  `json:"barcode"`
+}
+
+// Line items of the given transactions with their product names.
+// Lines of deleted products are named as they were sold.
+func (q *Queries) ListTransactionItems(ctx context.Context, transactionIds []int32) ([]ListTransactionItemsRow, error) {
+	rows, err := q.db.Query(ctx, listTransactionItems, transactionIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTransactionItemsRow{}
+	for rows.Next() {
+		var i ListTransactionItemsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.TransactionID,
+			&i.ProductID,
+			&i.ProductName,
+			&i.Quantity,
+			&i.UnitPrice,
+			&i.UnitCost,
+			&i.Discount,
+			&i.LineTotal,
+			&i.BasketDiscount,
+			&i.TaxClassID,
+			&i.TaxRate,
+			&i.Tax,
+			&i.TaxInclusive,
+			&i.GenericName,
+			&i.BrandName,
+			&i.Barcode,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransactionsPaginated = `-- name: ListTransactionsPaginated :many
+SELECT id, created_at, user_id, idempotency_key, discount_approved_by FROM transactions ORDER BY created_at DESC LIMIT $1 OFFSET $2
+`
+
+type ListTransactionsPaginatedParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+func (q *Queries) ListTransactionsPaginated(ctx context.Context, arg ListTransactionsPaginatedParams) ([]Transaction, error) {
+	rows, err := q.db.Query(ctx, listTransactionsPaginated, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transaction{}
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.IdempotencyKey,
+			&i.DiscountApprovedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, username, password, is_active, created_at, role FROM users
+`
+
+func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Password,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockIdempotencyKey = `-- name: LockIdempotencyKey :exec
+SELECT pg_advisory_xact_lock(hashtext($1::text))
 `
 
-type InvoiceItemsRow struct {
-	ID          int32        `json:"id"`
-	ProductID   int32        `json:"product_id"`
-	InvoiceID   int32        `json:"invoice_id"`
-	Quantity    int32        `json:"quantity"`
-	CostPrice   float64      `json:"cost_price"`
-	ExpiryDate  dbtypes.Date `json:"expiry_date"`
-	Comment     string       `json:"comment"`
-	CreatedAt   time.Time    `json:"created_at"`
-	GenericName string       `json:"generic_name"`
-	BrandName   string       `json:"brand_name"`
+// Serialise the submissions of a sale with the same idempotency key until
+// the end of the transaction.
+func (q *Queries) LockIdempotencyKey(ctx context.Context, key string) error {
+	_, err := q.db.Exec(ctx, lockIdempotencyKey, key)
+	return err
 }
 
-// ================== StockIN Queries =========================
-func (q *Queries) InvoiceItems(ctx context.Context, invoiceNumber string) ([]InvoiceItemsRow, error) {
-	rows, err := q.db.Query(ctx, invoiceItems, invoiceNumber)
+const lockProducts = `-- name: LockProducts :many
+SELECT id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at, tax_class_id, price_includes_tax, reorder_level, reorder_quantity, preferred_supplier_id FROM products WHERE id = ANY($1::int[]) ORDER BY id FOR UPDATE
+`
+
+// Lock the products in id order so that concurrent sales do not deadlock.
+func (q *Queries) LockProducts(ctx context.Context, ids []int32) ([]Product, error) {
+	rows, err := q.db.Query(ctx, lockProducts, ids)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []InvoiceItemsRow{}
+	items := []Product{}
 	for rows.Next() {
-		var i InvoiceItemsRow
+		var i Product
 		if err := rows.Scan(
 			&i.ID,
-			&i.ProductID,
-			&i.InvoiceID,
+			&i.GenericName,
+			&i.BrandName,
 			&i.Quantity,
 			&i.CostPrice,
-			&i.ExpiryDate,
-			&i.Comment,
+			&i.SellingPrice,
+			&i.ExpiryDates,
+			&i.Barcode,
 			&i.CreatedAt,
-			&i.GenericName,
-			&i.BrandName,
+			&i.UpdatedAt,
+			&i.TaxClassID,
+			&i.PriceIncludesTax,
+			&i.ReorderLevel,
+			&i.ReorderQuantity,
+			&i.PreferredSupplierID,
 		); err != nil {
 			return nil, err
 		}
@@ -712,36 +2804,55 @@ func (q *Queries) InvoiceItems(ctx context.Context, invoiceNumber string) ([]Inv
 	return items, nil
 }
 
-const listInvoicesPaginated = `-- name: ListInvoicesPaginated :many
-
-SELECT id, invoice_number, purchase_date, invoice_total, amount_paid, balance, supplier, user_id, created_at FROM invoices ORDER BY id LIMIT $1 OFFSET $2
+const monthlyProductSales = `-- name: MonthlyProductSales :many
+SELECT DATE_TRUNC('month', transaction_date)::date AS month, 
+    product_id, product_name,
+    (SUM(cost) / SUM(quantity_sold))::double precision AS cost_price,
+    (SUM(selling_price * quantity_sold) / SUM(quantity_sold))::double precision AS selling_price,
+    SUM(quantity_sold)::int AS quantity_sold,
+    SUM(income)::double precision AS income,
+    SUM(cost)::double precision AS cost,
+    SUM(profit)::double precision AS profit
+FROM product_sales
+WHERE CASE WHEN $1::text != ''
+    THEN DATE_TRUNC('month', transaction_date)::date = $1::date
+    ELSE TRUE
+END
+GROUP BY month, product_id, product_name
+ORDER BY month DESC
 `
 
-type ListInvoicesPaginatedParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+type MonthlyProductSalesRow struct {
+	Month        dbtypes.Date `json:"month"`
+	ProductID    int32        `json:"product_id"`
+	ProductName  string       `json:"product_name"`
+	CostPrice    float64      `json:"cost_price"`
+	SellingPrice float64      `json:"selling_price"`
+	QuantitySold int32        `json:"quantity_sold"`
+	Income       float64      `json:"income"`
+	Cost         float64      `json:"cost"`
+	Profit       float64      `json:"profit"`
 }
 
-// -- Invoices queries ----------------
-func (q *Queries) ListInvoicesPaginated(ctx context.Context, arg ListInvoicesPaginatedParams) ([]Invoice, error) {
-	rows, err := q.db.Query(ctx, listInvoicesPaginated, arg.Limit, arg.Offset)
+func (q *Queries) MonthlyProductSales(ctx context.Context, date string) ([]MonthlyProductSalesRow, error) {
+	rows, err := q.db.Query(ctx, monthlyProductSales, date)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Invoice{}
+	items := []MonthlyProductSalesRow{}
 	for rows.Next() {
-		var i Invoice
+		var i MonthlyProductSalesRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.InvoiceNumber,
-			&i.PurchaseDate,
-			&i.InvoiceTotal,
-			&i.AmountPaid,
-			&i.Balance,
-			&i.Supplier,
-			&i.UserID,
-			&i.CreatedAt,
+			&i.Month,
+			&i.ProductID,
+			&i.ProductName,
+			&i.CostPrice,
+			&i.SellingPrice,
+			&i.QuantitySold,
+			&i.Income,
+			&i.Cost,
+			&i.Profit,
 		); err != nil {
 			return nil, err
 		}
@@ -753,33 +2864,83 @@ func (q *Queries) ListInvoicesPaginated(ctx context.Context, arg ListInvoicesPag
 	return items, nil
 }
 
-const listProductsPaginated = `-- name: ListProductsPaginated :many
-
-SELECT id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at FROM products WHERE 
-CASE WHEN $1::text != ''
-    THEN generic_name ILIKE '%' || $1::text || '%' OR brand_name ILIKE '%' || $1::text || '%'
+const monthlySalesReports = `-- name: MonthlySalesReports :many
+SELECT DATE_TRUNC('month', transaction_date)::date AS month, SUM(total_income)::double precision AS total_income
+FROM sales_reports
+WHERE CASE WHEN $1::text != ''
+    THEN DATE_TRUNC('month', transaction_date)::date = $1::date
     ELSE TRUE
 END
-ORDER BY id LIMIT $3 OFFSET $2
+GROUP BY month
+ORDER BY month DESC
 `
 
-type ListProductsPaginatedParams struct {
-	Name string `json:"name"`
-	Off  int32  `json:"off"`
-	Lim  int32  `json:"lim"`
+type MonthlySalesReportsRow struct {
+	Month       dbtypes.Date `json:"month"`
+	TotalIncome float64      `json:"total_income"`
 }
 
-// -- Product queries ----------------
-// Filter by name if provided.
-func (q *Queries) ListProductsPaginated(ctx context.Context, arg ListProductsPaginatedParams) ([]Product, error) {
-	rows, err := q.db.Query(ctx, listProductsPaginated, arg.Name, arg.Off, arg.Lim)
+func (q *Queries) MonthlySalesReports(ctx context.Context, date string) ([]MonthlySalesReportsRow, error) {
+	rows, err := q.db.Query(ctx, monthlySalesReports, date)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Product{}
+	items := []MonthlySalesReportsRow{}
 	for rows.Next() {
-		var i Product
+		var i MonthlySalesReportsRow
+		if err := rows.Scan(&i.Month, &i.TotalIncome); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const mostCommonProducts = `-- name: MostCommonProducts :many
+SELECT p.id, p.generic_name, p.brand_name, p.quantity, p.cost_price, p.selling_price, p.expiry_dates, p.barcode, p.created_at, p.updated_at, p.tax_class_id, p.price_includes_tax, p.reorder_level, p.reorder_quantity, p.preferred_supplier_id, t.count FROM products p
+JOIN (
+    SELECT product_id, COUNT(*) AS count
+    FROM transaction_items
+    GROUP BY product_id
+) t ON p.id = t.product_id
+ORDER BY t.count DESC
+LIMIT $1
+`
+
+type MostCommonProductsRow struct {
+	ID                  int32          `json:"id"`
+	GenericName         string         `json:"generic_name"`
+	BrandName           string         `json:"brand_name"`
+	Quantity            int32          `json:"quantity"`
+	CostPrice           float64        `json:"cost_price"`
+	SellingPrice        float64        `json:"selling_price"`
+	ExpiryDates         []dbtypes.Date `json:"expiry_dates"`
+	Barcode             string         `json:"barcode"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	TaxClassID          *int32         `json:"tax_class_id"`
+	PriceIncludesTax    bool           `json:"price_includes_tax"`
+	ReorderLevel        int32          `json:"reorder_level"`
+	ReorderQuantity     int32          `json:"reorder_quantity"`
+	PreferredSupplierID *int32         `json:"preferred_supplier_id"`
+	Count               int64          `json:"count"`
+}
+
+// Ruturn 10 most common products in transactions
+// order by count
+func (q *Queries) MostCommonProducts(ctx context.Context, limit int32) ([]MostCommonProductsRow, error) {
+	rows, err := q.db.Query(ctx, mostCommonProducts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []MostCommonProductsRow{}
+	for rows.Next() {
+		var i MostCommonProductsRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.GenericName,
@@ -791,6 +2952,12 @@ func (q *Queries) ListProductsPaginated(ctx context.Context, arg ListProductsPag
 			&i.Barcode,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.TaxClassID,
+			&i.PriceIncludesTax,
+			&i.ReorderLevel,
+			&i.ReorderQuantity,
+			&i.PreferredSupplierID,
+			&i.Count,
 		); err != nil {
 			return nil, err
 		}
@@ -802,30 +2969,119 @@ func (q *Queries) ListProductsPaginated(ctx context.Context, arg ListProductsPag
 	return items, nil
 }
 
-const listTransactionsPaginated = `-- name: ListTransactionsPaginated :many
-SELECT id, items, created_at, user_id FROM transactions ORDER BY created_at DESC LIMIT $1 OFFSET $2
+const moveSupplierInvoices = `-- name: MoveSupplierInvoices :execrows
+UPDATE invoices SET supplier_id = $1 WHERE supplier_id = $2
 `
 
-type ListTransactionsPaginatedParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+type MoveSupplierInvoicesParams struct {
+	IntoID int32 `json:"into_id"`
+	FromID int32 `json:"from_id"`
 }
 
-// -- Transactions queries ----------------
-func (q *Queries) ListTransactionsPaginated(ctx context.Context, arg ListTransactionsPaginatedParams) ([]Transaction, error) {
-	rows, err := q.db.Query(ctx, listTransactionsPaginated, arg.Limit, arg.Offset)
+// Moves the invoices of a duplicate supplier to the supplier kept.
+func (q *Queries) MoveSupplierInvoices(ctx context.Context, arg MoveSupplierInvoicesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, moveSupplierInvoices, arg.IntoID, arg.FromID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const moveSupplierProducts = `-- name: MoveSupplierProducts :execrows
+UPDATE products SET preferred_supplier_id = $1::int WHERE preferred_supplier_id = $2::int
+`
+
+type MoveSupplierProductsParams struct {
+	IntoID int32 `json:"into_id"`
+	FromID int32 `json:"from_id"`
+}
+
+// Makes the supplier kept the preferred supplier of the products of a duplicate supplier.
+func (q *Queries) MoveSupplierProducts(ctx context.Context, arg MoveSupplierProductsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, moveSupplierProducts, arg.IntoID, arg.FromID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const moveSupplierPurchaseOrders = `-- name: MoveSupplierPurchaseOrders :execrows
+UPDATE purchase_orders SET supplier_id = $1 WHERE supplier_id = $2
+`
+
+type MoveSupplierPurchaseOrdersParams struct {
+	IntoID int32 `json:"into_id"`
+	FromID int32 `json:"from_id"`
+}
+
+// Moves the purchase orders of a duplicate supplier to the supplier kept.
+func (q *Queries) MoveSupplierPurchaseOrders(ctx context.Context, arg MoveSupplierPurchaseOrdersParams) (int64, error) {
+	result, err := q.db.Exec(ctx, moveSupplierPurchaseOrders, arg.IntoID, arg.FromID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const payablesAgeing = `-- name: PayablesAgeing :many
+WITH balances AS (
+    SELECT invoices.supplier_id,
+        $1::date - (invoices.purchase_date + suppliers.payment_terms_days) AS overdue,
+        invoices.invoice_total - COALESCE((
+            SELECT SUM(amount) FROM supplier_payments
+            WHERE supplier_payments.invoice_id = invoices.id
+            AND supplier_payments.payment_date <= $1::date
+        ), 0) AS balance
+    FROM invoices
+    JOIN suppliers ON suppliers.id = invoices.supplier_id
+    WHERE invoices.purchase_date <= $1::date
+)
+SELECT suppliers.id AS supplier_id, suppliers.name AS supplier_name,
+    COALESCE(SUM(balance) FILTER (WHERE overdue <= 0), 0)::double precision AS current,
+    COALESCE(SUM(balance) FILTER (WHERE overdue BETWEEN 1 AND 30), 0)::double precision AS days_30,
+    COALESCE(SUM(balance) FILTER (WHERE overdue BETWEEN 31 AND 60), 0)::double precision AS days_60,
+    COALESCE(SUM(balance) FILTER (WHERE overdue BETWEEN 61 AND 90), 0)::double precision AS days_90,
+    COALESCE(SUM(balance) FILTER (WHERE overdue > 90), 0)::double precision AS over_90,
+    SUM(balance)::double precision AS total
+FROM balances
+JOIN suppliers ON suppliers.id = balances.supplier_id
+WHERE ABS(balance) >= 0.005
+GROUP BY suppliers.id
+ORDER BY suppliers.name
+`
+
+type PayablesAgeingRow struct {
+	SupplierID   int32   `json:"supplier_id"`
+	SupplierName string  `json:"supplier_name"`
+	Current      float64 `json:"current"`
+	Days30       float64 `json:"days_30"`
+	Days60       float64 `json:"days_60"`
+	Days90       float64 `json:"days_90"`
+	Over90       float64 `json:"over_90"`
+	Total        float64 `json:"total"`
+}
+
+// The balances owed to each supplier on a date by the days the invoices are
+// overdue: not yet due, 1 to 30, 31 to 60, 61 to 90 and over 90 days. An
+// invoice is due the supplier's payment terms after its purchase date.
+func (q *Queries) PayablesAgeing(ctx context.Context, asOf dbtypes.Date) ([]PayablesAgeingRow, error) {
+	rows, err := q.db.Query(ctx, payablesAgeing, asOf)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Transaction{}
+	items := []PayablesAgeingRow{}
 	for rows.Next() {
-		var i Transaction
+		var i PayablesAgeingRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.Items,
-			&i.CreatedAt,
-			&i.UserID,
+			&i.SupplierID,
+			&i.SupplierName,
+			&i.Current,
+			&i.Days30,
+			&i.Days60,
+			&i.Days90,
+			&i.Over90,
+			&i.Total,
 		); err != nil {
 			return nil, err
 		}
@@ -837,26 +3093,81 @@ func (q *Queries) ListTransactionsPaginated(ctx context.Context, arg ListTransac
 	return items, nil
 }
 
-const listUsers = `-- name: ListUsers :many
-SELECT id, username, password, is_active, is_admin, created_at FROM users
+const paymentMethodTotals = `-- name: PaymentMethodTotals :many
+SELECT payments.method,
+    COUNT(DISTINCT payments.transaction_id)::int AS sales,
+    SUM(payments.amount)::double precision AS total
+FROM payments
+JOIN transactions ON payments.transaction_id = transactions.id
+WHERE transactions.created_at::date BETWEEN $1::date AND $2::date
+GROUP BY payments.method
+ORDER BY payments.method
 `
 
-func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
-	rows, err := q.db.Query(ctx, listUsers)
+type PaymentMethodTotalsParams struct {
+	FromDate dbtypes.Date `json:"from_date"`
+	ToDate   dbtypes.Date `json:"to_date"`
+}
+
+type PaymentMethodTotalsRow struct {
+	Method PaymentMethod `json:"method"`
+	Sales  int32         `json:"sales"`
+	Total  float64       `json:"total"`
+}
+
+// Amount settled by each payment method for sales made between the dates.
+func (q *Queries) PaymentMethodTotals(ctx context.Context, arg PaymentMethodTotalsParams) ([]PaymentMethodTotalsRow, error) {
+	rows, err := q.db.Query(ctx, paymentMethodTotals, arg.FromDate, arg.ToDate)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []User{}
+	items := []PaymentMethodTotalsRow{}
 	for rows.Next() {
-		var i User
+		var i PaymentMethodTotalsRow
+		if err := rows.Scan(&i.Method, &i.Sales, &i.Total); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const productStockCard = `-- name: ProductStockCard :many
+SELECT balance_date, product_id, generic_name, brand_name, opening_quantity, quantity_in, quantity_out, quantity_adjusted, closing_quantity FROM stock_card
+WHERE product_id = $1 AND balance_date BETWEEN $2::date AND $3::date
+ORDER BY balance_date
+`
+
+type ProductStockCardParams struct {
+	ProductID int32        `json:"product_id"`
+	FromDate  dbtypes.Date `json:"from_date"`
+	ToDate    dbtypes.Date `json:"to_date"`
+}
+
+// Daily stock movements of a product between two dates, oldest first.
+func (q *Queries) ProductStockCard(ctx context.Context, arg ProductStockCardParams) ([]StockCard, error) {
+	rows, err := q.db.Query(ctx, productStockCard, arg.ProductID, arg.FromDate, arg.ToDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []StockCard{}
+	for rows.Next() {
+		var i StockCard
 		if err := rows.Scan(
-			&i.ID,
-			&i.Username,
-			&i.Password,
-			&i.IsActive,
-			&i.IsAdmin,
-			&i.CreatedAt,
+			&i.BalanceDate,
+			&i.ProductID,
+			&i.GenericName,
+			&i.BrandName,
+			&i.OpeningQuantity,
+			&i.QuantityIn,
+			&i.QuantityOut,
+			&i.QuantityAdjusted,
+			&i.ClosingQuantity,
 		); err != nil {
 			return nil, err
 		}
@@ -868,50 +3179,224 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
-const monthlyProductSales = `-- name: MonthlyProductSales :many
-SELECT DATE_TRUNC('month', transaction_date)::date AS month, 
-    product_id, product_name, cost_price, selling_price,
-    SUM(quantity_sold)::int AS quantity_sold,
-    SUM(income)::double precision AS income,
-    SUM(profit)::double precision AS profit
-FROM product_sales
-WHERE CASE WHEN $1::text != ''
-    THEN DATE_TRUNC('month', transaction_date)::date = $1::date
-    ELSE TRUE
-END
-GROUP BY month, product_id, product_name, cost_price, selling_price
-ORDER BY month DESC
+const rebuildStockBalances = `-- name: RebuildStockBalances :execrows
+WITH days AS (
+    SELECT products.id AS product_id, products.quantity, pharmacy_date(products.created_at) AS created_on,
+        day::date AS balance_date
+    FROM products,
+    LATERAL generate_series(
+        GREATEST($1::date, pharmacy_date(products.created_at)),
+        pharmacy_date(CURRENT_TIMESTAMP),
+        INTERVAL '1 day'
+    ) AS day
+    WHERE $2::int = 0 OR products.id = $2::int
+),
+received AS (
+    SELECT product_id, pharmacy_date(created_at) AS balance_date, SUM(quantity) AS quantity
+    FROM stock_in
+    GROUP BY 1, 2
+),
+sold AS (
+    SELECT transaction_items.product_id, pharmacy_date(transactions.created_at) AS balance_date,
+        SUM(transaction_items.quantity) AS quantity
+    FROM transaction_items
+    JOIN transactions ON transaction_items.transaction_id = transactions.id
+    GROUP BY 1, 2
+),
+adjusted AS (
+    SELECT product_id, pharmacy_date(reviewed_at) AS balance_date, SUM(quantity) AS quantity
+    FROM stock_adjustments
+    WHERE status = 'approved'
+    GROUP BY 1, 2
+),
+balances AS (
+    SELECT days.product_id, days.balance_date, days.created_on,
+        COALESCE(received.quantity, 0) AS quantity_in,
+        COALESCE(adjusted.quantity, 0) AS quantity_adjusted,
+        days.quantity - SUM(COALESCE(received.quantity, 0) - COALESCE(sold.quantity, 0) + COALESCE(adjusted.quantity, 0))
+            OVER (PARTITION BY days.product_id ORDER BY days.balance_date DESC) AS opening_quantity
+    FROM days
+    LEFT JOIN received ON received.product_id = days.product_id AND received.balance_date = days.balance_date
+    LEFT JOIN sold ON sold.product_id = days.product_id AND sold.balance_date = days.balance_date
+    LEFT JOIN adjusted ON adjusted.product_id = days.product_id AND adjusted.balance_date = days.balance_date
+)
+INSERT INTO stock_balances (product_id, balance_date, opening_quantity, quantity_in, quantity_adjusted)
+SELECT product_id, balance_date,
+    CASE WHEN balance_date = created_on THEN 0 ELSE opening_quantity END,
+    quantity_in + CASE WHEN balance_date = created_on THEN opening_quantity ELSE 0 END,
+    quantity_adjusted
+FROM balances
+ON CONFLICT (product_id, balance_date)
+DO UPDATE SET opening_quantity = EXCLUDED.opening_quantity, quantity_in = EXCLUDED.quantity_in,
+    quantity_adjusted = EXCLUDED.quantity_adjusted
+`
+
+type RebuildStockBalancesParams struct {
+	FromDate  dbtypes.Date `json:"from_date"`
+	ProductID int32        `json:"product_id"`
+}
+
+// Recomputes the balances of every day from a date to today from the stock received,
+// sold and adjusted, for a product or for all products if product_id is 0. Working back
+// from the quantity in stock, each day opens with the quantity in stock less the stock
+// received and adjusted and plus the stock sold on and after it. The quantity a product
+// was created with is received on the day it was created.
+func (q *Queries) RebuildStockBalances(ctx context.Context, arg RebuildStockBalancesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rebuildStockBalances, arg.FromDate, arg.ProductID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const receiveStockIn = `-- name: ReceiveStockIn :one
+INSERT INTO stock_in (product_id, purchase_order_item_id, quantity, cost_price, expiry_date, comment, batch_number)
+VALUES ($1, $2::int, $3, $4, $5, $6, $7) RETURNING id, product_id, invoice_id, quantity, cost_price, expiry_date, comment, created_at, batch_number, purchase_order_item_id
+`
+
+type ReceiveStockInParams struct {
+	ProductID           int32        `json:"product_id"`
+	PurchaseOrderItemID int32        `json:"purchase_order_item_id"`
+	Quantity            int32        `json:"quantity"`
+	CostPrice           float64      `json:"cost_price"`
+	ExpiryDate          dbtypes.Date `json:"expiry_date"`
+	Comment             string       `json:"comment"`
+	BatchNumber         string       `json:"batch_number"`
+}
+
+// Records stock received against a line of a purchase order. It is put on
+// an invoice when the order is converted.
+func (q *Queries) ReceiveStockIn(ctx context.Context, arg ReceiveStockInParams) (StockIn, error) {
+	row := q.db.QueryRow(ctx, receiveStockIn,
+		arg.ProductID,
+		arg.PurchaseOrderItemID,
+		arg.Quantity,
+		arg.CostPrice,
+		arg.ExpiryDate,
+		arg.Comment,
+		arg.BatchNumber,
+	)
+	var i StockIn
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.InvoiceID,
+		&i.Quantity,
+		&i.CostPrice,
+		&i.ExpiryDate,
+		&i.Comment,
+		&i.CreatedAt,
+		&i.BatchNumber,
+		&i.PurchaseOrderItemID,
+	)
+	return i, err
+}
+
+const recentPinFailures = `-- name: RecentPinFailures :one
+SELECT
+    COUNT(*)::int AS failures,
+    COALESCE(MAX(created_at), $1::timestamptz)::timestamptz AS last_failure
+FROM pin_attempts
+WHERE user_id = $2 AND NOT succeeded AND created_at > $1::timestamptz
+    AND created_at > COALESCE(
+        (SELECT MAX(created_at) FROM pin_attempts WHERE user_id = $2 AND succeeded),
+        '-infinity'
+    )
 `
 
-type MonthlyProductSalesRow struct {
-	Month        dbtypes.Date `json:"month"`
-	ProductID    int32        `json:"product_id"`
-	ProductName  string       `json:"product_name"`
-	CostPrice    float64      `json:"cost_price"`
-	SellingPrice float64      `json:"selling_price"`
-	QuantitySold int32        `json:"quantity_sold"`
-	Income       float64      `json:"income"`
-	Profit       float64      `json:"profit"`
+type RecentPinFailuresParams struct {
+	Since  time.Time `json:"since"`
+	UserID int32     `json:"user_id"`
+}
+
+type RecentPinFailuresRow struct {
+	Failures    int32     `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
 }
 
-func (q *Queries) MonthlyProductSales(ctx context.Context, date string) ([]MonthlyProductSalesRow, error) {
-	rows, err := q.db.Query(ctx, monthlyProductSales, date)
+// Wrong override PINs entered by a user since a time and since their last
+// right one, and when the latest was entered.
+func (q *Queries) RecentPinFailures(ctx context.Context, arg RecentPinFailuresParams) (RecentPinFailuresRow, error) {
+	row := q.db.QueryRow(ctx, recentPinFailures, arg.Since, arg.UserID)
+	var i RecentPinFailuresRow
+	err := row.Scan(&i.Failures, &i.LastFailure)
+	return i, err
+}
+
+const reorderCandidates = `-- name: ReorderCandidates :many
+SELECT products.id, products.generic_name, products.brand_name, products.quantity, products.cost_price,
+    products.reorder_level, products.reorder_quantity, products.preferred_supplier_id,
+    COALESCE(suppliers.name, '')::text AS supplier_name,
+    COALESCE(suppliers.lead_time_days, 0)::int AS lead_time_days,
+    COALESCE(sold.units, 0)::int AS units_sold,
+    COALESCE(ordered.units, 0)::int AS on_order
+FROM products
+LEFT JOIN suppliers ON suppliers.id = products.preferred_supplier_id
+LEFT JOIN (
+    SELECT transaction_items.product_id, SUM(transaction_items.quantity) AS units
+    FROM transaction_items
+    JOIN transactions ON transactions.id = transaction_items.transaction_id
+    WHERE pharmacy_date(transactions.created_at) >= $1::date
+    GROUP BY transaction_items.product_id
+) sold ON sold.product_id = products.id
+LEFT JOIN (
+    SELECT purchase_order_items.product_id,
+        SUM(GREATEST(purchase_order_items.quantity - COALESCE(received.units, 0), 0)) AS units
+    FROM purchase_order_items
+    JOIN purchase_orders ON purchase_orders.id = purchase_order_items.purchase_order_id
+    LEFT JOIN (
+        SELECT purchase_order_item_id, SUM(quantity) AS units FROM stock_in
+        WHERE purchase_order_item_id IS NOT NULL
+        GROUP BY purchase_order_item_id
+    ) received ON received.purchase_order_item_id = purchase_order_items.id
+    WHERE purchase_orders.status <> 'closed'
+    GROUP BY purchase_order_items.product_id
+) ordered ON ordered.product_id = products.id
+WHERE products.reorder_level > 0 OR sold.units > 0
+ORDER BY products.preferred_supplier_id IS NULL, suppliers.name, products.generic_name, products.brand_name
+`
+
+type ReorderCandidatesRow struct {
+	ID                  int32   `json:"id"`
+	GenericName         string  `json:"generic_name"`
+	BrandName           string  `json:"brand_name"`
+	Quantity            int32   `json:"quantity"`
+	CostPrice           float64 `json:"cost_price"`
+	ReorderLevel        int32   `json:"reorder_level"`
+	ReorderQuantity     int32   `json:"reorder_quantity"`
+	PreferredSupplierID *int32  `json:"preferred_supplier_id"`
+	SupplierName        string  `json:"supplier_name"`
+	LeadTimeDays        int32   `json:"lead_time_days"`
+	UnitsSold           int32   `json:"units_sold"`
+	OnOrder             int32   `json:"on_order"`
+}
+
+// The products with a reorder level or sales since from_date, with their
+// preferred supplier, the units sold since from_date and the units still to
+// be delivered on purchase orders that are not closed. Products without
+// a preferred supplier come last.
+func (q *Queries) ReorderCandidates(ctx context.Context, fromDate dbtypes.Date) ([]ReorderCandidatesRow, error) {
+	rows, err := q.db.Query(ctx, reorderCandidates, fromDate)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []MonthlyProductSalesRow{}
+	items := []ReorderCandidatesRow{}
 	for rows.Next() {
-		var i MonthlyProductSalesRow
+		var i ReorderCandidatesRow
 		if err := rows.Scan(
-			&i.Month,
-			&i.ProductID,
-			&i.ProductName,
+			&i.ID,
+			&i.GenericName,
+			&i.BrandName,
+			&i.Quantity,
 			&i.CostPrice,
-			&i.SellingPrice,
-			&i.QuantitySold,
-			&i.Income,
-			&i.Profit,
+			&i.ReorderLevel,
+			&i.ReorderQuantity,
+			&i.PreferredSupplierID,
+			&i.SupplierName,
+			&i.LeadTimeDays,
+			&i.UnitsSold,
+			&i.OnOrder,
 		); err != nil {
 			return nil, err
 		}
@@ -923,32 +3408,62 @@ func (q *Queries) MonthlyProductSales(ctx context.Context, date string) ([]Month
 	return items, nil
 }
 
-const monthlySalesReports = `-- name: MonthlySalesReports :many
-SELECT DATE_TRUNC('month', transaction_date)::date AS month, SUM(total_income)::double precision AS total_income
-FROM sales_reports
-WHERE CASE WHEN $1::text != ''
-    THEN DATE_TRUNC('month', transaction_date)::date = $1::date
-    ELSE TRUE
-END
-GROUP BY month
-ORDER BY month DESC
+const reviewStockAdjustment = `-- name: ReviewStockAdjustment :one
+UPDATE stock_adjustments SET status = $1, reviewed_by = $2, reviewed_at = CURRENT_TIMESTAMP
+WHERE id = $3 AND status = 'pending' RETURNING id, product_id, quantity, reason, note, status, requested_by, reviewed_by, reviewed_at, created_at, stock_take_id
 `
 
-type MonthlySalesReportsRow struct {
-	Month       dbtypes.Date `json:"month"`
-	TotalIncome float64      `json:"total_income"`
+type ReviewStockAdjustmentParams struct {
+	Status     AdjustmentStatus `json:"status"`
+	ReviewedBy *int32           `json:"reviewed_by"`
+	ID         int32            `json:"id"`
 }
 
-func (q *Queries) MonthlySalesReports(ctx context.Context, date string) ([]MonthlySalesReportsRow, error) {
-	rows, err := q.db.Query(ctx, monthlySalesReports, date)
+// Approves or rejects a pending adjustment.
+func (q *Queries) ReviewStockAdjustment(ctx context.Context, arg ReviewStockAdjustmentParams) (StockAdjustment, error) {
+	row := q.db.QueryRow(ctx, reviewStockAdjustment, arg.Status, arg.ReviewedBy, arg.ID)
+	var i StockAdjustment
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.Quantity,
+		&i.Reason,
+		&i.Note,
+		&i.Status,
+		&i.RequestedBy,
+		&i.ReviewedBy,
+		&i.ReviewedAt,
+		&i.CreatedAt,
+		&i.StockTakeID,
+	)
+	return i, err
+}
+
+const searchInvoices = `-- name: SearchInvoices :many
+SELECT id, invoice_number, purchase_date, invoice_total, amount_paid, balance, user_id, created_at, supplier_id, closed_at FROM invoices WHERE invoice_number = $1
+`
+
+func (q *Queries) SearchInvoices(ctx context.Context, invoiceNumber string) ([]Invoice, error) {
+	rows, err := q.db.Query(ctx, searchInvoices, invoiceNumber)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []MonthlySalesReportsRow{}
+	items := []Invoice{}
 	for rows.Next() {
-		var i MonthlySalesReportsRow
-		if err := rows.Scan(&i.Month, &i.TotalIncome); err != nil {
+		var i Invoice
+		if err := rows.Scan(
+			&i.ID,
+			&i.InvoiceNumber,
+			&i.PurchaseDate,
+			&i.InvoiceTotal,
+			&i.AmountPaid,
+			&i.Balance,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.SupplierID,
+			&i.ClosedAt,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -959,45 +3474,24 @@ func (q *Queries) MonthlySalesReports(ctx context.Context, date string) ([]Month
 	return items, nil
 }
 
-const mostCommonProducts = `-- name: MostCommonProducts :many
-SELECT DISTINCT id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at, product_id, count FROM products p
-JOIN (
-    SELECT DISTINCT (item->>'id')::int AS product_id,
-           COUNT(*) AS count
-    FROM transactions
-    CROSS JOIN LATERAL jsonb_array_elements(items) item
-    GROUP BY product_id
-) t ON p.id = t.product_id
-ORDER BY t.count DESC
-LIMIT $1
+const searchProducts = `-- name: SearchProducts :many
+SELECT id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at, tax_class_id, price_includes_tax, reorder_level, reorder_quantity, preferred_supplier_id FROM products
+WHERE
+    generic_name ILIKE '%' || $1::text || '%'
+    OR brand_name ILIKE '%' || $1::text || '%'
+ORDER BY id LIMIT 50
 `
 
-type MostCommonProductsRow struct {
-	ID           int32          `json:"id"`
-	GenericName  string         `json:"generic_name"`
-	BrandName    string         `json:"brand_name"`
-	Quantity     int32          `json:"quantity"`
-	CostPrice    float64        `json:"cost_price"`
-	SellingPrice float64        `json:"selling_price"`
-	ExpiryDates  []dbtypes.Date `json:"expiry_dates"`
-	Barcode      string         `json:"barcode"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	ProductID    int32          `json:"product_id"`
-	Count        int64          `json:"count"`
-}
-
-// Ruturn 10 most common products in transactions
-// order by count
-func (q *Queries) MostCommonProducts(ctx context.Context, limit int32) ([]MostCommonProductsRow, error) {
-	rows, err := q.db.Query(ctx, mostCommonProducts, limit)
+// Full text search for the products table
+func (q *Queries) SearchProducts(ctx context.Context, name string) ([]Product, error) {
+	rows, err := q.db.Query(ctx, searchProducts, name)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []MostCommonProductsRow{}
+	items := []Product{}
 	for rows.Next() {
-		var i MostCommonProductsRow
+		var i Product
 		if err := rows.Scan(
 			&i.ID,
 			&i.GenericName,
@@ -1009,8 +3503,11 @@ func (q *Queries) MostCommonProducts(ctx context.Context, limit int32) ([]MostCo
 			&i.Barcode,
 			&i.CreatedAt,
 			&i.UpdatedAt,
-			&i.ProductID,
-			&i.Count,
+			&i.TaxClassID,
+			&i.PriceIncludesTax,
+			&i.ReorderLevel,
+			&i.ReorderQuantity,
+			&i.PreferredSupplierID,
 		); err != nil {
 			return nil, err
 		}
@@ -1022,67 +3519,289 @@ func (q *Queries) MostCommonProducts(ctx context.Context, limit int32) ([]MostCo
 	return items, nil
 }
 
-const promoteUser = `-- name: PromoteUser :exec
-UPDATE users SET is_admin = TRUE WHERE id = $1
+const sellableQuantity = `-- name: SellableQuantity :one
+SELECT COALESCE(SUM(quantity), 0)::int AS quantity FROM product_batches
+WHERE product_id = $1 AND quantity > 0
+    AND (expiry_date IS NULL OR expiry_date >= $2::date)
+`
+
+type SellableQuantityParams struct {
+	ProductID int32        `json:"product_id"`
+	Today     dbtypes.Date `json:"today"`
+}
+
+// Units of the product in unexpired batches.
+func (q *Queries) SellableQuantity(ctx context.Context, arg SellableQuantityParams) (int32, error) {
+	row := q.db.QueryRow(ctx, sellableQuantity, arg.ProductID, arg.Today)
+	var quantity int32
+	err := row.Scan(&quantity)
+	return quantity, err
+}
+
+const sendPurchaseOrder = `-- name: SendPurchaseOrder :one
+UPDATE purchase_orders SET status = 'sent', sent_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND status = 'draft'
+AND EXISTS (SELECT 1 FROM purchase_order_items WHERE purchase_order_id = purchase_orders.id)
+RETURNING id, supplier_id, status, expected_date, note, invoice_id, created_by, sent_at, closed_at, created_at
+`
+
+// Marks a draft purchase order with lines as sent to the supplier.
+func (q *Queries) SendPurchaseOrder(ctx context.Context, id int32) (PurchaseOrder, error) {
+	row := q.db.QueryRow(ctx, sendPurchaseOrder, id)
+	var i PurchaseOrder
+	err := row.Scan(
+		&i.ID,
+		&i.SupplierID,
+		&i.Status,
+		&i.ExpectedDate,
+		&i.Note,
+		&i.InvoiceID,
+		&i.CreatedBy,
+		&i.SentAt,
+		&i.ClosedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const setPurchaseOrderInvoice = `-- name: SetPurchaseOrderInvoice :one
+UPDATE purchase_orders SET invoice_id = $1::int
+WHERE id = $2 AND status = 'closed' AND invoice_id IS NULL RETURNING id, supplier_id, status, expected_date, note, invoice_id, created_by, sent_at, closed_at, created_at
+`
+
+type SetPurchaseOrderInvoiceParams struct {
+	InvoiceID int32 `json:"invoice_id"`
+	ID        int32 `json:"id"`
+}
+
+// Links a closed purchase order to the invoice it was converted into.
+func (q *Queries) SetPurchaseOrderInvoice(ctx context.Context, arg SetPurchaseOrderInvoiceParams) (PurchaseOrder, error) {
+	row := q.db.QueryRow(ctx, setPurchaseOrderInvoice, arg.InvoiceID, arg.ID)
+	var i PurchaseOrder
+	err := row.Scan(
+		&i.ID,
+		&i.SupplierID,
+		&i.Status,
+		&i.ExpectedDate,
+		&i.Note,
+		&i.InvoiceID,
+		&i.CreatedBy,
+		&i.SentAt,
+		&i.ClosedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const setPurchaseOrderStatus = `-- name: SetPurchaseOrderStatus :one
+UPDATE purchase_orders SET status = $1,
+    closed_at = CASE WHEN $1 = 'closed' THEN CURRENT_TIMESTAMP END
+WHERE id = $2 AND status IN ('sent', 'partially_received') RETURNING id, supplier_id, status, expected_date, note, invoice_id, created_by, sent_at, closed_at, created_at
+`
+
+type SetPurchaseOrderStatusParams struct {
+	Status PurchaseOrderStatus `json:"status"`
+	ID     int32               `json:"id"`
+}
+
+// Marks a sent purchase order as partially received or closed.
+func (q *Queries) SetPurchaseOrderStatus(ctx context.Context, arg SetPurchaseOrderStatusParams) (PurchaseOrder, error) {
+	row := q.db.QueryRow(ctx, setPurchaseOrderStatus, arg.Status, arg.ID)
+	var i PurchaseOrder
+	err := row.Scan(
+		&i.ID,
+		&i.SupplierID,
+		&i.Status,
+		&i.ExpectedDate,
+		&i.Note,
+		&i.InvoiceID,
+		&i.CreatedBy,
+		&i.SentAt,
+		&i.ClosedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const setRoleDiscountLimit = `-- name: SetRoleDiscountLimit :exec
+INSERT INTO role_discount_limits (role, max_percent) VALUES ($1, $2)
+ON CONFLICT (role) DO UPDATE SET max_percent = EXCLUDED.max_percent
 `
 
-func (q *Queries) PromoteUser(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, promoteUser, id)
+type SetRoleDiscountLimitParams struct {
+	Role       UserRole `json:"role"`
+	MaxPercent float64  `json:"max_percent"`
+}
+
+func (q *Queries) SetRoleDiscountLimit(ctx context.Context, arg SetRoleDiscountLimitParams) error {
+	_, err := q.db.Exec(ctx, setRoleDiscountLimit, arg.Role, arg.MaxPercent)
 	return err
 }
 
-const removeProductExpiry = `-- name: RemoveProductExpiry :exec
-UPDATE products
-SET expiry_dates = array_remove(expiry_dates, $1::date)
-WHERE id = $2::int
+const setTransactionItemCost = `-- name: SetTransactionItemCost :exec
+UPDATE transaction_items SET unit_cost = $2 WHERE id = $1
 `
 
-type RemoveProductExpiryParams struct {
-	ExpiryDate dbtypes.Date `json:"expiry_date"`
-	ID         int32        `json:"id"`
+type SetTransactionItemCostParams struct {
+	ID       int32   `json:"id"`
+	UnitCost float64 `json:"unit_cost"`
 }
 
-func (q *Queries) RemoveProductExpiry(ctx context.Context, arg RemoveProductExpiryParams) error {
-	_, err := q.db.Exec(ctx, removeProductExpiry, arg.ExpiryDate, arg.ID)
+func (q *Queries) SetTransactionItemCost(ctx context.Context, arg SetTransactionItemCostParams) error {
+	_, err := q.db.Exec(ctx, setTransactionItemCost, arg.ID, arg.UnitCost)
 	return err
 }
 
-const replaceProductExpiry = `-- name: ReplaceProductExpiry :exec
-UPDATE products SET expiry_dates = $1 WHERE id = $2
+const setUserPin = `-- name: SetUserPin :exec
+INSERT INTO user_pins (user_id, pin_hash) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET pin_hash = EXCLUDED.pin_hash, updated_at = CURRENT_TIMESTAMP
 `
 
-type ReplaceProductExpiryParams struct {
-	ExpiryDates []dbtypes.Date `json:"expiry_dates"`
-	ID          int32          `json:"id"`
+type SetUserPinParams struct {
+	UserID  int32  `json:"user_id"`
+	PinHash string `json:"pin_hash"`
 }
 
-func (q *Queries) ReplaceProductExpiry(ctx context.Context, arg ReplaceProductExpiryParams) error {
-	_, err := q.db.Exec(ctx, replaceProductExpiry, arg.ExpiryDates, arg.ID)
+func (q *Queries) SetUserPin(ctx context.Context, arg SetUserPinParams) error {
+	_, err := q.db.Exec(ctx, setUserPin, arg.UserID, arg.PinHash)
 	return err
 }
 
-const searchInvoices = `-- name: SearchInvoices :many
-SELECT id, invoice_number, purchase_date, invoice_total, amount_paid, balance, supplier, user_id, created_at FROM invoices WHERE invoice_number = $1
+const setUserRole = `-- name: SetUserRole :exec
+UPDATE users SET role = $1 WHERE id = $2
 `
 
-func (q *Queries) SearchInvoices(ctx context.Context, invoiceNumber string) ([]Invoice, error) {
-	rows, err := q.db.Query(ctx, searchInvoices, invoiceNumber)
+type SetUserRoleParams struct {
+	Role UserRole `json:"role"`
+	ID   int32    `json:"id"`
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) error {
+	_, err := q.db.Exec(ctx, setUserRole, arg.Role, arg.ID)
+	return err
+}
+
+const snapshotStockBalances = `-- name: SnapshotStockBalances :execrows
+INSERT INTO stock_balances (product_id, balance_date, opening_quantity, quantity_in, quantity_adjusted)
+SELECT products.id, $1::date,
+    products.quantity
+    - COALESCE((
+        SELECT SUM(stock_in.quantity) FROM stock_in
+        WHERE stock_in.product_id = products.id AND pharmacy_date(stock_in.created_at) >= $1::date
+    ), 0)
+    + COALESCE((
+        SELECT SUM(transaction_items.quantity) FROM transaction_items
+        JOIN transactions ON transaction_items.transaction_id = transactions.id
+        WHERE transaction_items.product_id = products.id AND pharmacy_date(transactions.created_at) >= $1::date
+    ), 0)
+    - COALESCE((
+        SELECT SUM(stock_adjustments.quantity) FROM stock_adjustments
+        WHERE stock_adjustments.product_id = products.id AND stock_adjustments.status = 'approved'
+        AND pharmacy_date(stock_adjustments.reviewed_at) >= $1::date
+    ), 0),
+    COALESCE((
+        SELECT SUM(stock_in.quantity) FROM stock_in
+        WHERE stock_in.product_id = products.id AND pharmacy_date(stock_in.created_at) = $1::date
+    ), 0),
+    COALESCE((
+        SELECT SUM(stock_adjustments.quantity) FROM stock_adjustments
+        WHERE stock_adjustments.product_id = products.id AND stock_adjustments.status = 'approved'
+        AND pharmacy_date(stock_adjustments.reviewed_at) = $1::date
+    ), 0)
+FROM products
+WHERE pharmacy_date(products.created_at) <= $1::date
+ON CONFLICT (product_id, balance_date) DO NOTHING
+`
+
+// Records the opening balance on a day of the products that have no balance on it.
+// The opening balance is the quantity in stock less the stock received and adjusted
+// and plus the stock sold since the start of the day, so missed days can be recorded later.
+func (q *Queries) SnapshotStockBalances(ctx context.Context, balanceDate dbtypes.Date) (int64, error) {
+	result, err := q.db.Exec(ctx, snapshotStockBalances, balanceDate)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const supplierOpeningBalance = `-- name: SupplierOpeningBalance :one
+SELECT (
+    COALESCE((SELECT SUM(invoice_total) FROM invoices
+        WHERE supplier_id = $1 AND purchase_date < $2), 0)
+    - COALESCE((SELECT SUM(supplier_payments.amount) FROM supplier_payments
+        JOIN invoices ON invoices.id = supplier_payments.invoice_id
+        WHERE invoices.supplier_id = $1 AND supplier_payments.payment_date < $2), 0)
+)::double precision AS balance
+`
+
+type SupplierOpeningBalanceParams struct {
+	SupplierID int32        `json:"supplier_id"`
+	FromDate   dbtypes.Date `json:"from_date"`
+}
+
+// The amount owed to a supplier before a date.
+func (q *Queries) SupplierOpeningBalance(ctx context.Context, arg SupplierOpeningBalanceParams) (float64, error) {
+	row := q.db.QueryRow(ctx, supplierOpeningBalance, arg.SupplierID, arg.FromDate)
+	var balance float64
+	err := row.Scan(&balance)
+	return balance, err
+}
+
+const supplierStatement = `-- name: SupplierStatement :many
+SELECT invoices.purchase_date AS entry_date, 'invoice'::text AS kind,
+    invoices.id AS invoice_id, invoices.invoice_number, ''::text AS method, ''::text AS reference,
+    invoices.invoice_total AS debit, 0::double precision AS credit, invoices.created_at
+FROM invoices
+WHERE invoices.supplier_id = $1
+AND invoices.purchase_date BETWEEN $2 AND $3
+UNION ALL
+SELECT supplier_payments.payment_date, 'payment',
+    invoices.id, invoices.invoice_number, supplier_payments.method::text, supplier_payments.reference,
+    0, supplier_payments.amount, supplier_payments.created_at
+FROM supplier_payments
+JOIN invoices ON invoices.id = supplier_payments.invoice_id
+WHERE invoices.supplier_id = $1
+AND supplier_payments.payment_date BETWEEN $2 AND $3
+ORDER BY entry_date, created_at
+`
+
+type SupplierStatementParams struct {
+	SupplierID int32        `json:"supplier_id"`
+	FromDate   dbtypes.Date `json:"from_date"`
+	ToDate     dbtypes.Date `json:"to_date"`
+}
+
+type SupplierStatementRow struct {
+	EntryDate     dbtypes.Date `json:"entry_date"`
+	Kind          string       `json:"kind"`
+	InvoiceID     int32        `json:"invoice_id"`
+	InvoiceNumber string       `json:"invoice_number"`
+	Method        string       `json:"method"`
+	Reference     string       `json:"reference"`
+	Debit         float64      `json:"debit"`
+	Credit        float64      `json:"credit"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// The invoices and payments of a supplier between two dates, oldest first.
+func (q *Queries) SupplierStatement(ctx context.Context, arg SupplierStatementParams) ([]SupplierStatementRow, error) {
+	rows, err := q.db.Query(ctx, supplierStatement, arg.SupplierID, arg.FromDate, arg.ToDate)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Invoice{}
+	items := []SupplierStatementRow{}
 	for rows.Next() {
-		var i Invoice
+		var i SupplierStatementRow
 		if err := rows.Scan(
-			&i.ID,
+			&i.EntryDate,
+			&i.Kind,
+			&i.InvoiceID,
 			&i.InvoiceNumber,
-			&i.PurchaseDate,
-			&i.InvoiceTotal,
-			&i.AmountPaid,
-			&i.Balance,
-			&i.Supplier,
-			&i.UserID,
+			&i.Method,
+			&i.Reference,
+			&i.Debit,
+			&i.Credit,
 			&i.CreatedAt,
 		); err != nil {
 			return nil, err
@@ -1095,35 +3814,55 @@ func (q *Queries) SearchInvoices(ctx context.Context, invoiceNumber string) ([]I
 	return items, nil
 }
 
-const searchProducts = `-- name: SearchProducts :many
-SELECT id, generic_name, brand_name, quantity, cost_price, selling_price, expiry_dates, barcode, created_at, updated_at FROM products
-WHERE
-    generic_name ILIKE '%' || $1::text || '%'
-    OR brand_name ILIKE '%' || $1::text || '%'
-ORDER BY id LIMIT 50
+const taxSummary = `-- name: TaxSummary :many
+SELECT DATE_TRUNC($1::text, transactions.created_at)::date AS period,
+    COALESCE(tax_classes.name, 'Unclassified')::text AS tax_class,
+    transaction_items.tax_rate,
+    SUM(transaction_items.line_total
+        - CASE WHEN transaction_items.tax_inclusive THEN transaction_items.tax ELSE 0 END)::double precision AS net_sales,
+    SUM(transaction_items.tax)::double precision AS tax,
+    SUM(transaction_items.line_total
+        + CASE WHEN transaction_items.tax_inclusive THEN 0 ELSE transaction_items.tax END)::double precision AS gross_sales
+FROM transaction_items
+JOIN transactions ON transaction_items.transaction_id = transactions.id
+LEFT JOIN tax_classes ON transaction_items.tax_class_id = tax_classes.id
+WHERE transactions.created_at::date BETWEEN $2::date AND $3::date
+GROUP BY period, tax_class, transaction_items.tax_rate
+ORDER BY period DESC, tax_class, transaction_items.tax_rate
 `
 
-// Full text search for the products table
-func (q *Queries) SearchProducts(ctx context.Context, name string) ([]Product, error) {
-	rows, err := q.db.Query(ctx, searchProducts, name)
+type TaxSummaryParams struct {
+	Period   string       `json:"period"`
+	FromDate dbtypes.Date `json:"from_date"`
+	ToDate   dbtypes.Date `json:"to_date"`
+}
+
+type TaxSummaryRow struct {
+	Period     dbtypes.Date `json:"period"`
+	TaxClass   string       `json:"tax_class"`
+	TaxRate    float64      `json:"tax_rate"`
+	NetSales   float64      `json:"net_sales"`
+	Tax        float64      `json:"tax"`
+	GrossSales float64      `json:"gross_sales"`
+}
+
+// Sales and tax by period and tax class. Period is day, month or year.
+func (q *Queries) TaxSummary(ctx context.Context, arg TaxSummaryParams) ([]TaxSummaryRow, error) {
+	rows, err := q.db.Query(ctx, taxSummary, arg.Period, arg.FromDate, arg.ToDate)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Product{}
+	items := []TaxSummaryRow{}
 	for rows.Next() {
-		var i Product
+		var i TaxSummaryRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.GenericName,
-			&i.BrandName,
-			&i.Quantity,
-			&i.CostPrice,
-			&i.SellingPrice,
-			&i.ExpiryDates,
-			&i.Barcode,
-			&i.CreatedAt,
-			&i.UpdatedAt,
+			&i.Period,
+			&i.TaxClass,
+			&i.TaxRate,
+			&i.NetSales,
+			&i.Tax,
+			&i.GrossSales,
 		); err != nil {
 			return nil, err
 		}
@@ -1135,34 +3874,56 @@ func (q *Queries) SearchProducts(ctx context.Context, name string) ([]Product, e
 	return items, nil
 }
 
+const touchSession = `-- name: TouchSession :exec
+UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP WHERE id = $1
+`
+
+func (q *Queries) TouchSession(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, touchSession, id)
+	return err
+}
+
+const uninvoicePurchaseOrderStock = `-- name: UninvoicePurchaseOrderStock :execrows
+UPDATE stock_in SET invoice_id = NULL
+WHERE invoice_id = $1::int AND purchase_order_item_id IS NOT NULL
+`
+
+// Takes the stock received against purchase orders off an invoice, back
+// onto the orders, so that the invoice can be deleted.
+func (q *Queries) UninvoicePurchaseOrderStock(ctx context.Context, invoiceID int32) (int64, error) {
+	result, err := q.db.Exec(ctx, uninvoicePurchaseOrderStock, invoiceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateInvoice = `-- name: UpdateInvoice :exec
 UPDATE invoices SET 
         invoice_number = $1, 
         purchase_date = $2, 
         invoice_total = $3, 
-        amount_paid = $4, 
-        supplier = $5, 
-        user_id = $6 
-        WHERE id = $7
+        supplier_id = $4, 
+        user_id = $5 
+        WHERE id = $6
 `
 
 type UpdateInvoiceParams struct {
 	InvoiceNumber string       `json:"invoice_number"`
 	PurchaseDate  dbtypes.Date `json:"purchase_date"`
 	InvoiceTotal  float64      `json:"invoice_total"`
-	AmountPaid    float64      `json:"amount_paid"`
-	Supplier      string       `json:"supplier"`
+	SupplierID    int32        `json:"supplier_id"`
 	UserID        int32        `json:"user_id"`
 	ID            int32        `json:"id"`
 }
 
+// The amount paid is the sum of the payments of the invoice.
 func (q *Queries) UpdateInvoice(ctx context.Context, arg UpdateInvoiceParams) error {
 	_, err := q.db.Exec(ctx, updateInvoice,
 		arg.InvoiceNumber,
 		arg.PurchaseDate,
 		arg.InvoiceTotal,
-		arg.AmountPaid,
-		arg.Supplier,
+		arg.SupplierID,
 		arg.UserID,
 		arg.ID,
 	)
@@ -1171,30 +3932,100 @@ func (q *Queries) UpdateInvoice(ctx context.Context, arg UpdateInvoiceParams) er
 
 const updateProduct = `-- name: UpdateProduct :exec
 UPDATE products SET generic_name = $1, brand_name = $2, 
-    quantity = $3, cost_price = $4, selling_price = $5, 
-    barcode = $6, expiry_dates=$7 WHERE id = $8
+    cost_price = $3, selling_price = $4, 
+    barcode = $5, tax_class_id = $6, price_includes_tax = $7,
+    reorder_level = $8, reorder_quantity = $9, preferred_supplier_id = $10 WHERE id = $11
 `
 
 type UpdateProductParams struct {
-	GenericName  string         `json:"generic_name"`
-	BrandName    string         `json:"brand_name"`
-	Quantity     int32          `json:"quantity"`
-	CostPrice    float64        `json:"cost_price"`
-	SellingPrice float64        `json:"selling_price"`
-	Barcode      string         `json:"barcode"`
-	ExpiryDates  []dbtypes.Date `json:"expiry_dates"`
-	ID           int32          `json:"id"`
-}
-
+	GenericName         string  `json:"generic_name"`
+	BrandName           string  `json:"brand_name"`
+	CostPrice           float64 `json:"cost_price"`
+	SellingPrice        float64 `json:"selling_price"`
+	Barcode             string  `json:"barcode"`
+	TaxClassID          *int32  `json:"tax_class_id"`
+	PriceIncludesTax    bool    `json:"price_includes_tax"`
+	ReorderLevel        int32   `json:"reorder_level"`
+	ReorderQuantity     int32   `json:"reorder_quantity"`
+	PreferredSupplierID *int32  `json:"preferred_supplier_id"`
+	ID                  int32   `json:"id"`
+}
+
+// Quantity and expiry dates are derived from the product batches.
 func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) error {
 	_, err := q.db.Exec(ctx, updateProduct,
 		arg.GenericName,
 		arg.BrandName,
-		arg.Quantity,
 		arg.CostPrice,
 		arg.SellingPrice,
 		arg.Barcode,
-		arg.ExpiryDates,
+		arg.TaxClassID,
+		arg.PriceIncludesTax,
+		arg.ReorderLevel,
+		arg.ReorderQuantity,
+		arg.PreferredSupplierID,
+		arg.ID,
+	)
+	return err
+}
+
+const updateSupplier = `-- name: UpdateSupplier :exec
+UPDATE suppliers SET
+    name = $1,
+    contact_person = $2,
+    phone = $3,
+    email = $4,
+    tin = $5,
+    address = $6,
+    payment_terms_days = $7,
+    lead_time_days = $8,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $9
+`
+
+type UpdateSupplierParams struct {
+	Name             string `json:"name"`
+	ContactPerson    string `json:"contact_person"`
+	Phone            string `json:"phone"`
+	Email            string `json:"email"`
+	Tin              string `json:"tin"`
+	Address          string `json:"address"`
+	PaymentTermsDays int32  `json:"payment_terms_days"`
+	LeadTimeDays     int32  `json:"lead_time_days"`
+	ID               int32  `json:"id"`
+}
+
+func (q *Queries) UpdateSupplier(ctx context.Context, arg UpdateSupplierParams) error {
+	_, err := q.db.Exec(ctx, updateSupplier,
+		arg.Name,
+		arg.ContactPerson,
+		arg.Phone,
+		arg.Email,
+		arg.Tin,
+		arg.Address,
+		arg.PaymentTermsDays,
+		arg.LeadTimeDays,
+		arg.ID,
+	)
+	return err
+}
+
+const updateTaxClass = `-- name: UpdateTaxClass :exec
+UPDATE tax_classes SET name = $1, kind = $2, rate = $3 WHERE id = $4
+`
+
+type UpdateTaxClassParams struct {
+	Name string  `json:"name"`
+	Kind TaxKind `json:"kind"`
+	Rate float64 `json:"rate"`
+	ID   int32   `json:"id"`
+}
+
+func (q *Queries) UpdateTaxClass(ctx context.Context, arg UpdateTaxClassParams) error {
+	_, err := q.db.Exec(ctx, updateTaxClass,
+		arg.Name,
+		arg.Kind,
+		arg.Rate,
 		arg.ID,
 	)
 	return err
@@ -1226,3 +4057,28 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
 	)
 	return err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users SET password = $1 WHERE id = $2
+`
+
+type UpdateUserPasswordParams struct {
+	Password string `json:"password"`
+	ID       int32  `json:"id"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, updateUserPassword, arg.Password, arg.ID)
+	return err
+}
+
+const userHasPin = `-- name: UserHasPin :one
+SELECT EXISTS(SELECT 1 FROM user_pins WHERE user_id = $1)::bool AS has_pin
+`
+
+func (q *Queries) UserHasPin(ctx context.Context, userID int32) (bool, error) {
+	row := q.db.QueryRow(ctx, userHasPin, userID)
+	var has_pin bool
+	err := row.Scan(&has_pin)
+	return has_pin, err
+}
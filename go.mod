@@ -6,12 +6,13 @@ require (
 	github.com/abiiranathan/dbtypes v0.0.6
 	github.com/abiiranathan/egor v0.2.5
 	github.com/jackc/pgx/v5 v5.5.5
+	golang.org/x/crypto v0.17.0
 	golang.org/x/text v0.14.0
 )
 
 require (
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
-	golang.org/x/crypto v0.17.0 // indirect
+	github.com/jackc/puddle/v2 v2.2.1 // indirect
 	golang.org/x/sync v0.6.0 // indirect
 )
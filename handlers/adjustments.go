@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/abiiranathan/egor/egor"
+	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/jackc/pgx/v5"
+)
+
+// applyStockAdjustment changes the batches of the product by an approved adjustment.
+// Stock is removed from the batches earliest expiry first, so that expired stock
+// goes first, and added to the latest batch. The adjustment must be approved
+// before it is applied so that the stock balance of the day opens with the
+// quantity in stock before it. It must be called within a database transaction.
+func applyStockAdjustment(ctx context.Context, qtx *epharma.Queries, adjustment epharma.StockAdjustment) error {
+	if adjustment.Quantity > 0 {
+		return returnToBatch(ctx, qtx, adjustment.ProductID, adjustment.Quantity)
+	}
+
+	err := takeFromBatches(ctx, qtx, adjustment.ProductID, -adjustment.Quantity)
+	if errors.Is(err, errInsufficientStock) {
+		return fmt.Errorf("can not remove %d units of product %d: %w", -adjustment.Quantity, adjustment.ProductID, err)
+	}
+	return err
+}
+
+// parseStockAdjustment reads and validates the stock adjustment form.
+// The quantity is the number of units removed, or added if direction is "add".
+// Only count corrections may add stock.
+func parseStockAdjustment(r *http.Request) (epharma.CreateStockAdjustmentParams, error) {
+	params := epharma.CreateStockAdjustmentParams{
+		Reason: epharma.AdjustmentReason(r.FormValue("reason")),
+		Note:   strings.TrimSpace(r.FormValue("note")),
+	}
+
+	productID, err := strconv.ParseInt(r.FormValue("product_id"), 10, 32)
+	if err != nil || productID <= 0 {
+		return params, fmt.Errorf("product is required")
+	}
+	params.ProductID = int32(productID)
+
+	if !params.Reason.Valid() {
+		return params, fmt.Errorf("invalid adjustment reason: %q", params.Reason)
+	}
+
+	quantity, err := parseQuantity(r.FormValue("quantity"))
+	if err != nil {
+		return params, err
+	}
+
+	if quantity <= 0 {
+		return params, fmt.Errorf("quantity must be greater than zero")
+	}
+
+	switch r.FormValue("direction") {
+	case "add":
+		if params.Reason != epharma.AdjustmentReasonCountCorrection {
+			return params, fmt.Errorf("only count corrections can add stock")
+		}
+		params.Quantity = quantity
+	case "", "remove":
+		params.Quantity = -quantity
+	default:
+		return params, fmt.Errorf("invalid direction: %q", r.FormValue("direction"))
+	}
+	return params, nil
+}
+
+// ListStockAdjustments renders the latest adjustments, filtered by status
+// and product, with a form to request an adjustment.
+func (h *Handlers) ListStockAdjustments(w http.ResponseWriter, r *http.Request) {
+	status := egor.Query(r, "status")
+	if status != "" && !epharma.AdjustmentStatus(status).Valid() {
+		egor.SendError(w, r, fmt.Errorf("invalid status: %q", status), http.StatusBadRequest)
+		return
+	}
+
+	productID := int32(egor.QueryInt(r, "product_id", 0))
+	adjustments, err := h.Queries.ListStockAdjustments(r.Context(), epharma.ListStockAdjustmentsParams{
+		Status:    status,
+		ProductID: productID,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	data := egor.Map{
+		"adjustments": adjustments,
+		"status":      status,
+		"statuses":    epharma.AllAdjustmentStatusValues(),
+		"reasons":     epharma.AllAdjustmentReasonValues(),
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Products", URL: "/products"},
+			{Label: "Stock Adjustments", IsLast: true},
+		},
+	}
+
+	if productID != 0 {
+		product, err := h.Queries.GetProduct(r.Context(), productID)
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		data["product"] = product
+	}
+	egor.Render(w, r, "adjustments/list.html", data)
+}
+
+// CreateStockAdjustment requests an adjustment. Adjustments requested by
+// a user allowed to approve them are approved and applied at once.
+func (h *Handlers) CreateStockAdjustment(w http.ResponseWriter, r *http.Request) {
+	user := egor.GetContextValue(r, "user").(epharma.User)
+
+	params, err := parseStockAdjustment(r)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	params.RequestedBy = user.ID
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	adjustment, err := qtx.CreateStockAdjustment(r.Context(), params)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	if HasPermission(user.Role, PermissionApproveStock) {
+		adjustment, err = qtx.ReviewStockAdjustment(r.Context(), epharma.ReviewStockAdjustmentParams{
+			Status:     epharma.AdjustmentStatusApproved,
+			ReviewedBy: &user.ID,
+			ID:         adjustment.ID,
+		})
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		if err := applyStockAdjustment(r.Context(), qtx, adjustment); err != nil {
+			egor.SendError(w, r, err, http.StatusConflict)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/stock-adjustments?product_id=%d", adjustment.ProductID), http.StatusSeeOther)
+}
+
+// ApproveStockAdjustment approves a pending adjustment and changes the stock by it.
+func (h *Handlers) ApproveStockAdjustment(w http.ResponseWriter, r *http.Request) {
+	h.reviewStockAdjustment(w, r, epharma.AdjustmentStatusApproved)
+}
+
+// RejectStockAdjustment rejects a pending adjustment. The stock is not changed.
+func (h *Handlers) RejectStockAdjustment(w http.ResponseWriter, r *http.Request) {
+	h.reviewStockAdjustment(w, r, epharma.AdjustmentStatusRejected)
+}
+
+func (h *Handlers) reviewStockAdjustment(w http.ResponseWriter, r *http.Request, status epharma.AdjustmentStatus) {
+	user := egor.GetContextValue(r, "user").(epharma.User)
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	adjustment, err := qtx.ReviewStockAdjustment(r.Context(), epharma.ReviewStockAdjustmentParams{
+		Status:     status,
+		ReviewedBy: &user.ID,
+		ID:         int32(egor.ParamInt(r, "id")),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		egor.SendError(w, r, fmt.Errorf("the adjustment does not exist or has already been reviewed"), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if adjustment.Status == epharma.AdjustmentStatusApproved {
+		if err := applyStockAdjustment(r.Context(), qtx, adjustment); err != nil {
+			egor.SendError(w, r, err, http.StatusConflict)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, "/stock-adjustments?status=pending", http.StatusSeeOther)
+}
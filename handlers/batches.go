@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/abiiranathan/dbtypes"
+	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/jackc/pgx/v5"
+)
+
+var errInsufficientStock = errors.New("insufficient stock")
+
+// Units taken from a single batch.
+type batchAllocation struct {
+	BatchID  int32
+	Quantity int32
+	UnitCost float64 // Cost price of the batch
+}
+
+// averageUnitCost returns the cost per unit of the allocations weighted by quantity.
+func averageUnitCost(allocations []batchAllocation) float64 {
+	var cost float64
+	var quantity int32
+	for _, allocation := range allocations {
+		cost += allocation.UnitCost * float64(allocation.Quantity)
+		quantity += allocation.Quantity
+	}
+
+	if quantity == 0 {
+		return 0
+	}
+	return cost / float64(quantity)
+}
+
+// sellFromBatches takes quantity units of the product from its unexpired batches,
+// earliest expiry first, splitting across batches when one is not enough.
+// It must be called within a database transaction.
+func sellFromBatches(ctx context.Context, qtx *epharma.Queries, productID, quantity int32) ([]batchAllocation, error) {
+	// Batches are sellable up to and including their expiry date.
+	batches, err := qtx.ListProductBatchesForSale(ctx, epharma.ListProductBatchesForSaleParams{
+		ProductID: productID,
+		Today:     dbtypes.Date(Now()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return drawFromBatches(ctx, qtx, batches, quantity)
+}
+
+// takeFromBatches removes quantity units of the product from any of its batches,
+// including expired ones, earliest expiry first. Used to correct stock.
+// The product is locked before its batches, in the order sales lock them.
+func takeFromBatches(ctx context.Context, qtx *epharma.Queries, productID, quantity int32) error {
+	if _, err := qtx.LockProducts(ctx, []int32{productID}); err != nil {
+		return err
+	}
+
+	batches, err := qtx.ListProductBatchesForUpdate(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	_, err = drawFromBatches(ctx, qtx, batches, quantity)
+	return err
+}
+
+func drawFromBatches(ctx context.Context, qtx *epharma.Queries, batches []epharma.ProductBatch, quantity int32) ([]batchAllocation, error) {
+	var allocations []batchAllocation
+	for _, batch := range batches {
+		if quantity == 0 {
+			break
+		}
+
+		taken := min(batch.Quantity, quantity)
+		err := qtx.DecrementBatch(ctx, epharma.DecrementBatchParams{
+			ID:       batch.ID,
+			Quantity: taken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		allocations = append(allocations, batchAllocation{
+			BatchID:  batch.ID,
+			Quantity: taken,
+			UnitCost: batch.CostPrice,
+		})
+		quantity -= taken
+	}
+
+	if quantity > 0 {
+		return nil, errInsufficientStock
+	}
+	return allocations, nil
+}
+
+// returnToBatch adds quantity units of the product back to its latest batch.
+// An opening batch is created if the product has never had a batch.
+// The product is locked before its batch, in the order sales lock them.
+func returnToBatch(ctx context.Context, qtx *epharma.Queries, productID, quantity int32) error {
+	if _, err := qtx.LockProducts(ctx, []int32{productID}); err != nil {
+		return err
+	}
+
+	batch, err := qtx.GetLatestProductBatch(ctx, productID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		product, err := qtx.GetProduct(ctx, productID)
+		if err != nil {
+			return err
+		}
+
+		_, err = qtx.CreateProductBatch(ctx, epharma.CreateProductBatchParams{
+			ProductID:   product.ID,
+			BatchNumber: "OPENING",
+			Quantity:    quantity,
+			CostPrice:   product.CostPrice,
+		})
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return qtx.IncrementBatch(ctx, epharma.IncrementBatchParams{
+		ID:       batch.ID,
+		Quantity: quantity,
+	})
+}
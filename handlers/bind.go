@@ -13,7 +13,7 @@ func ConnectRoutes(h *Handlers) {
 	h.Router.Get("/", h.Home)
 
 	// Users
-	users := h.Router.Group("/users", h.AdminRequired)
+	users := h.Router.Group("/users", h.PermissionRequired(PermissionManageUsers))
 	users.Post("/", h.CreateUser)
 	users.Get("/", h.ListUsers)
 	users.Get("/{id}", h.GetUser)
@@ -24,51 +24,109 @@ func ConnectRoutes(h *Handlers) {
 
 	users.Post("/activate/{id}", h.ActivateUser)
 	users.Post("/deactivate/{id}", h.DeActivateUser)
-	users.Post("/promote/{id}", h.PromoteUser)
-	users.Post("/demote/{id}", h.DemoteUser)
+	users.Post("/role/{id}", h.SetUserRole)
+	users.Post("/pin/{id}", h.SetUserPin)
+	users.Post("/discount-limits", h.SetDiscountLimits)
 
 	// Products
-	products := h.Router.Group("/products")
+	editProducts := h.PermissionRequired(PermissionEditProducts)
+	products := h.Router.Group("/products", h.PermissionRequired(PermissionViewProducts))
 	products.Get("/", h.ListProductsPaginated)
-	products.Get("/create", h.RenderProductCreatePage)
-	products.Post("/create", h.CreateProduct)
+	products.Get("/create", h.RenderProductCreatePage, editProducts)
+	products.Post("/create", h.CreateProduct, editProducts)
 	products.Get("/view/{id}", h.GetProduct)
 	products.Get("/search", h.SearchProducts)
 	products.Get("/search/barcode/{barcode}", h.GetProductByBarcode)
-	products.Get("/update/{id}", h.RenderProductUpdatePage)
-	products.Post("/update/{id}", h.UpdateProduct)
-	products.Post("/delete/{id}", h.DeleteProduct)
-	products.Get("/import", h.RenderProductImportPage)
-	products.Post("/import", h.ImportProducts)
+	products.Get("/update/{id}", h.RenderProductUpdatePage, editProducts)
+	products.Post("/update/{id}", h.UpdateProduct, editProducts)
+	products.Post("/delete/{id}", h.DeleteProduct, h.PermissionRequired(PermissionDeleteProducts))
+	products.Get("/import", h.RenderProductImportPage, editProducts)
+	products.Post("/import", h.ImportProducts, editProducts)
+	products.Get("/tax-classes", h.ListTaxClasses)
+	products.Post("/tax-classes", h.CreateTaxClass, editProducts)
+	products.Post("/tax-classes/{id}", h.UpdateTaxClass, editProducts)
 
 	// Transactions
-	transactions := h.Router.Group("/transactions")
-	transactions.Post("/", h.CreateTransaction)
+	transactions := h.Router.Group("/transactions", h.PermissionRequired(PermissionViewSales))
+	transactions.Post("/", h.CreateTransaction, h.PermissionRequired(PermissionSell))
 	transactions.Get("/", h.ListTransactionsPaginated)
 	transactions.Get("/{id}", h.GetTransaction)
-	transactions.Post("/delete/{id}", h.DeleteTransaction)
+	transactions.Get("/{id}/receipt", h.GetReceipt)
+	transactions.Post("/{id}/receipt/print", h.PrintReceipt)
+	transactions.Post("/delete/{id}", h.DeleteTransaction, h.PermissionRequired(PermissionCancelSales))
 
 	// Invoices
-	invoices := h.Router.Group("/invoices")
+	editInvoices := h.PermissionRequired(PermissionEditInvoices)
+	invoices := h.Router.Group("/invoices", h.PermissionRequired(PermissionViewInvoices))
 	invoices.Get("/", h.ListInvoicesPaginated)
-	invoices.Get("/create", h.RenderInvoiceCreatePage)
-	invoices.Post("/create", h.CreateInvoice)
-	invoices.Get("/update/{id}", h.RenderInvoiceUpdatePage)
-	invoices.Post("/update/{id}", h.UpdateInvoice)
+	invoices.Get("/create", h.RenderInvoiceCreatePage, editInvoices)
+	invoices.Post("/create", h.CreateInvoice, editInvoices)
+	invoices.Get("/update/{id}", h.RenderInvoiceUpdatePage, editInvoices)
+	invoices.Post("/update/{id}", h.UpdateInvoice, editInvoices)
 	invoices.Get("/view/{id}", h.GetInvoice)
-	invoices.Post("/delete/{id}", h.DeleteInvoice)
+	invoices.Post("/delete/{id}", h.DeleteInvoice, h.PermissionRequired(PermissionDeleteInvoices))
+	invoices.Post("/close/{id}", h.CloseInvoice, editInvoices)
 	invoices.Get("/search", h.GetInvoiceByNumber)
 	invoices.Get("/list-products", h.ListInvoiceProducts)
+	invoices.Post("/payments/{id}", h.CreateSupplierPayment, editInvoices)
+	invoices.Post("/payments/{id}/delete/{payment_id}", h.DeleteSupplierPayment, h.PermissionRequired(PermissionDeleteInvoices))
+
+	// Suppliers
+	suppliers := h.Router.Group("/suppliers", h.PermissionRequired(PermissionViewInvoices))
+	suppliers.Get("/", h.ListSuppliers)
+	suppliers.Post("/", h.CreateSupplier, editInvoices)
+	suppliers.Get("/new", h.RenderSupplierCreatePage, editInvoices)
+	suppliers.Get("/{id}", h.GetSupplier)
+	suppliers.Post("/{id}", h.UpdateSupplier, editInvoices)
+	suppliers.Get("/edit/{id}", h.RenderSupplierEditPage, editInvoices)
+	suppliers.Post("/delete/{id}", h.DeleteSupplier, h.PermissionRequired(PermissionDeleteInvoices))
+	suppliers.Post("/merge/{id}", h.MergeSupplier, editInvoices)
+	suppliers.Get("/statement/{id}", h.SupplierStatement)
+
+	// Purchase orders
+	purchaseOrders := h.Router.Group("/purchase-orders", h.PermissionRequired(PermissionViewInvoices))
+	purchaseOrders.Get("/", h.ListPurchaseOrders)
+	purchaseOrders.Post("/", h.CreatePurchaseOrder, editInvoices)
+	purchaseOrders.Get("/{id}", h.GetPurchaseOrder)
+	purchaseOrders.Post("/{id}/items", h.AddPurchaseOrderItem, editInvoices)
+	purchaseOrders.Post("/{id}/items/{item_id}/delete", h.DeletePurchaseOrderItem, editInvoices)
+	purchaseOrders.Post("/{id}/delete", h.DeletePurchaseOrder, editInvoices)
+	purchaseOrders.Post("/{id}/send", h.SendPurchaseOrder, editInvoices)
+	purchaseOrders.Post("/{id}/receive", h.ReceivePurchaseOrder, h.PermissionRequired(PermissionReceiveStock))
+	purchaseOrders.Post("/{id}/close", h.ClosePurchaseOrder, editInvoices)
+	purchaseOrders.Post("/{id}/convert", h.ConvertPurchaseOrder, editInvoices)
 
 	// Stock in
-	stockin := h.Router.Group("/stockin")
+	stockin := h.Router.Group("/stockin", h.PermissionRequired(PermissionReceiveStock))
 	stockin.Post("/create", h.NewStockIn)
 	stockin.Post("/delete/{invoice_id}/{stockin_id}", h.DeleteStockIn)
 
+	// Stock adjustments
+	approveStock := h.PermissionRequired(PermissionApproveStock)
+	adjustments := h.Router.Group("/stock-adjustments", h.PermissionRequired(PermissionAdjustStock))
+	adjustments.Get("/", h.ListStockAdjustments)
+	adjustments.Post("/", h.CreateStockAdjustment)
+	adjustments.Post("/{id}/approve", h.ApproveStockAdjustment, approveStock)
+	adjustments.Post("/{id}/reject", h.RejectStockAdjustment, approveStock)
+
+	// Stock takes
+	stockTakes := h.Router.Group("/stock-takes", h.PermissionRequired(PermissionAdjustStock))
+	stockTakes.Get("/", h.ListStockTakes)
+	stockTakes.Post("/", h.OpenStockTake, approveStock)
+	stockTakes.Get("/{id}", h.GetStockTake)
+	stockTakes.Post("/{id}/count", h.CountStockTake)
+	stockTakes.Post("/{id}/approve", h.ApproveStockTake, approveStock)
+	stockTakes.Post("/{id}/cancel", h.CancelStockTake, approveStock)
+
 	// Reports
-	reports := h.Router.Group("/reports")
+	reports := h.Router.Group("/reports", h.PermissionRequired(PermissionViewReports))
 	reports.Get("/", h.RenderReportsDashboard)
 	reports.Get("/sales/daily", h.DailyProductSalesReport)
 	reports.Get("/sales/monthly", h.MonthlyProductSalesReport)
 	reports.Get("/sales/annually", h.AnnualProductSalesReport)
+	reports.Get("/sales/tax", h.TaxSummaryReport)
+	reports.Get("/stock-card", h.StockCardReport)
+	reports.Get("/payables-ageing", h.PayablesAgeingReport)
+	reports.Get("/reorder", h.ReorderReport)
+	reports.Post("/reorder/{supplier_id}", h.CreateReorderPurchaseOrder, editInvoices)
 }
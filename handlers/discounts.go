@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abiiranathan/egor/egor"
+	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DiscountType is how a discount value is applied.
+type DiscountType string
+
+const (
+	DiscountPercent DiscountType = "percent" // Percentage of the amount
+	DiscountFixed   DiscountType = "fixed"   // Amount off
+)
+
+const (
+	minPinLength = 6
+	maxPinLength = 8
+)
+
+const (
+	maxPinFailures = 5                // Wrong override PINs before a cashier is locked out
+	pinLockout     = 15 * time.Minute // How long a cashier is locked out
+)
+
+// Discount on a sale line or on the whole sale.
+type Discount struct {
+	Type  DiscountType `json:"discount_type"`
+	Value float64      `json:"discount"`
+}
+
+// amount returns the discount in cents off an amount of gross cents.
+func (d Discount) amount(gross int64) (int64, error) {
+	if d.Value == 0 {
+		return 0, nil
+	}
+
+	if d.Value < 0 {
+		return 0, fmt.Errorf("discount can not be negative")
+	}
+
+	switch d.Type {
+	case DiscountPercent:
+		if d.Value > 100 {
+			return 0, fmt.Errorf("discount of %.2f%% is more than 100%%", d.Value)
+		}
+		return int64(math.Round(float64(gross) * d.Value / 100)), nil
+	case DiscountFixed, "":
+		if cents(d.Value) > gross {
+			return 0, fmt.Errorf("discount of %s is more than the amount of %s",
+				CurrencyF64(d.Value), CurrencyF64(float64(gross)/100))
+		}
+		return cents(d.Value), nil
+	default:
+		return 0, fmt.Errorf("invalid discount type: %q", d.Type)
+	}
+}
+
+// DiscountLimitError is returned when a sale is discounted beyond the
+// cashier's limit and no valid override PIN was given.
+type DiscountLimitError struct {
+	Percent    float64 // Largest discount on a line
+	Limit      float64 // Cashier's limit
+	InvalidPin bool    // An override PIN was given but does not approve the discount
+}
+
+func (e *DiscountLimitError) Error() string {
+	if e.InvalidPin {
+		return fmt.Sprintf("the PIN does not approve a discount of %.2f%%", e.Percent)
+	}
+	return fmt.Sprintf("discount of %.2f%% is above your limit of %.2f%%", e.Percent, e.Limit)
+}
+
+// PinLockoutError is returned when a cashier has entered too many wrong
+// override PINs and must wait before entering another.
+type PinLockoutError struct {
+	Until time.Time // When the cashier may enter a PIN again
+}
+
+func (e *PinLockoutError) Error() string {
+	minutes := int(math.Ceil(time.Until(e.Until).Minutes()))
+	return fmt.Sprintf("too many wrong PINs, try again in %d minute(s)", max(minutes, 1))
+}
+
+// applyDiscounts sets the discount of each line from its line discount
+// and its share of the basket discount, which is split across the lines
+// in proportion to their amounts after line discounts.
+// It returns the largest discount on a line as a percentage of the line.
+func applyDiscounts(lines []epharma.CreateTransactionItemParams, lineDiscounts []Discount, basket Discount) (float64, error) {
+	nets := make([]int64, len(lines))
+	var subtotal int64
+	for i := range lines {
+		gross := cents(float64(lines[i].Quantity) * lines[i].UnitPrice)
+		discount, err := lineDiscounts[i].amount(gross)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		lines[i].Discount = float64(discount) / 100
+		nets[i] = gross - discount
+		subtotal += nets[i]
+	}
+
+	basketDiscount, err := basket.amount(subtotal)
+	if err != nil {
+		return 0, fmt.Errorf("basket: %w", err)
+	}
+
+	if basketDiscount > 0 {
+		// The rounding remainder goes to the largest line.
+		largest, allocated := 0, int64(0)
+		shares := make([]int64, len(lines))
+		for i, net := range nets {
+			shares[i] = basketDiscount * net / subtotal
+			allocated += shares[i]
+			if net > nets[largest] {
+				largest = i
+			}
+		}
+		shares[largest] += basketDiscount - allocated
+
+		for i, share := range shares {
+			lines[i].BasketDiscount = float64(share) / 100
+			lines[i].Discount += float64(share) / 100
+		}
+	}
+
+	var maxPercent float64
+	for _, line := range lines {
+		gross := float64(line.Quantity) * line.UnitPrice
+		if gross > 0 {
+			maxPercent = max(maxPercent, line.Discount/gross*100)
+		}
+	}
+	return maxPercent, nil
+}
+
+// errWrongPin is returned when an override PIN is not the PIN of any user
+// whose role may give more discount than the cashier's.
+var errWrongPin = errors.New("wrong PIN")
+
+// verifyOverridePin finds the user whose discount override PIN a cashier
+// entered. It returns nil if no PIN was entered and errWrongPin if the PIN
+// is not the PIN of an active user whose role may give more discount than
+// the cashier's. It is called before the sale locks its products since
+// bcrypt is slow.
+//
+// Every PIN entered is recorded. After maxPinFailures wrong PINs within
+// pinLockout the cashier may not enter another until pinLockout has passed.
+func verifyOverridePin(ctx context.Context, q *epharma.Queries, user epharma.User, pin string) (*epharma.ListDiscountApproversRow, error) {
+	if pin == "" {
+		return nil, nil
+	}
+
+	recent, err := q.RecentPinFailures(ctx, epharma.RecentPinFailuresParams{
+		Since:  time.Now().Add(-pinLockout),
+		UserID: user.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if recent.Failures >= maxPinFailures {
+		return nil, &PinLockoutError{Until: recent.LastFailure.Add(pinLockout)}
+	}
+
+	limit, err := q.GetRoleDiscountLimit(ctx, user.Role)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	approvers, err := q.ListDiscountApprovers(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var approver *epharma.ListDiscountApproversRow
+	for i := range approvers {
+		if bcrypt.CompareHashAndPassword([]byte(approvers[i].PinHash), []byte(pin)) == nil {
+			approver = &approvers[i]
+			break
+		}
+	}
+
+	attempt := epharma.CreatePinAttemptParams{UserID: user.ID, Succeeded: approver != nil}
+	if approver != nil {
+		attempt.ApprovedBy = &approver.ID
+	}
+
+	if err := q.CreatePinAttempt(ctx, attempt); err != nil {
+		return nil, err
+	}
+
+	if approver == nil {
+		return nil, errWrongPin
+	}
+	return approver, nil
+}
+
+// approveDiscount checks a discount against the cashier's role limit.
+// A discount above the limit needs the override PIN of a user whose role
+// may give it, found by verifyOverridePin. It returns the id of that user,
+// or nil if no approval was needed.
+func approveDiscount(ctx context.Context, qtx *epharma.Queries, user epharma.User, percent float64, approver *epharma.ListDiscountApproversRow) (*int32, error) {
+	limit, err := qtx.GetRoleDiscountLimit(ctx, user.Role)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	// Allow for rounding of the discount to whole cents.
+	const tolerance = 0.005
+	if percent <= limit+tolerance {
+		return nil, nil
+	}
+
+	if approver == nil {
+		return nil, &DiscountLimitError{Percent: percent, Limit: limit}
+	}
+
+	if percent > approver.MaxPercent+tolerance {
+		return nil, &DiscountLimitError{Percent: percent, Limit: limit, InvalidPin: true}
+	}
+	return &approver.ID, nil
+}
+
+// hashPin validates a discount override PIN and returns its bcrypt hash.
+func hashPin(pin string) (string, error) {
+	if len(pin) < minPinLength || len(pin) > maxPinLength {
+		return "", fmt.Errorf("PIN must be %d to %d digits", minPinLength, maxPinLength)
+	}
+
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return "", fmt.Errorf("PIN must only contain digits")
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// SetUserPin sets or, when the PIN is blank, removes the discount override PIN of a user.
+func (h *Handlers) SetUserPin(w http.ResponseWriter, r *http.Request) {
+	userID := int32(egor.ParamInt(r, "id"))
+	pin := r.FormValue("pin")
+
+	var err error
+	if pin == "" {
+		err = h.Queries.DeleteUserPin(r.Context(), userID)
+	} else {
+		var hash string
+		hash, err = hashPin(pin)
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		err = h.Queries.SetUserPin(r.Context(), epharma.SetUserPinParams{
+			UserID:  userID,
+			PinHash: hash,
+		})
+	}
+
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/users/%d", userID))
+}
+
+// SetDiscountLimits updates the maximum discount of every role.
+// The form has a max_discount_<role> field per role.
+func (h *Handlers) SetDiscountLimits(w http.ResponseWriter, r *http.Request) {
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	for _, role := range epharma.AllUserRoleValues() {
+		value := r.FormValue("max_discount_" + string(role))
+		if value == "" {
+			continue
+		}
+
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			egor.SendError(w, r, fmt.Errorf("invalid discount limit for %s: %q", humanize(role), value), http.StatusBadRequest)
+			return
+		}
+
+		err = qtx.SetRoleDiscountLimit(r.Context(), epharma.SetRoleDiscountLimitParams{
+			Role:       role,
+			MaxPercent: percent,
+		})
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, "/users")
+}
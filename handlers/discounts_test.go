@@ -0,0 +1,440 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDiscountAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		discount Discount
+		gross    int64
+		want     int64
+		wantErr  bool
+	}{
+		{"none", Discount{}, 1000, 0, false},
+		{"percent", Discount{Type: DiscountPercent, Value: 10}, 1000, 100, false},
+		{"percent rounded to the cent", Discount{Type: DiscountPercent, Value: 12.5}, 333, 42, false},
+		{"whole amount", Discount{Type: DiscountPercent, Value: 100}, 1000, 1000, false},
+		{"fixed", Discount{Type: DiscountFixed, Value: 2.5}, 1000, 250, false},
+		{"fixed without a type", Discount{Value: 2.5}, 1000, 250, false},
+		{"fixed equal to the amount", Discount{Type: DiscountFixed, Value: 10}, 1000, 1000, false},
+		{"fixed above the amount", Discount{Type: DiscountFixed, Value: 10.01}, 1000, 0, true},
+		{"percent above 100", Discount{Type: DiscountPercent, Value: 100.01}, 1000, 0, true},
+		{"negative", Discount{Type: DiscountFixed, Value: -1}, 1000, 0, true},
+		{"invalid type", Discount{Type: "free", Value: 1}, 1000, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.discount.amount(tt.gross)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("amount() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("amount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyDiscounts(t *testing.T) {
+	line := func(quantity int32, price float64) epharma.CreateTransactionItemParams {
+		return epharma.CreateTransactionItemParams{Quantity: quantity, UnitPrice: price}
+	}
+
+	tests := []struct {
+		name        string
+		lines       []epharma.CreateTransactionItemParams
+		discounts   []Discount
+		basket      Discount
+		wantPercent float64
+		wantLine    []float64 // Discount of each line
+		wantBasket  []float64 // Share of the basket discount of each line
+		wantErr     bool
+	}{
+		{
+			name:        "no discounts",
+			lines:       []epharma.CreateTransactionItemParams{line(2, 10), line(1, 5)},
+			discounts:   []Discount{{}, {}},
+			wantPercent: 0,
+			wantLine:    []float64{0, 0},
+			wantBasket:  []float64{0, 0},
+		},
+		{
+			name:        "line discount",
+			lines:       []epharma.CreateTransactionItemParams{line(2, 10), line(1, 5)},
+			discounts:   []Discount{{Type: DiscountPercent, Value: 10}, {}},
+			wantPercent: 10,
+			wantLine:    []float64{2, 0},
+			wantBasket:  []float64{0, 0},
+		},
+		{
+			name:        "basket remainder goes to the largest line",
+			lines:       []epharma.CreateTransactionItemParams{line(1, 10), line(1, 10), line(1, 10)},
+			discounts:   []Discount{{}, {}, {}},
+			basket:      Discount{Type: DiscountFixed, Value: 1},
+			wantPercent: 3.4,
+			wantLine:    []float64{0.34, 0.33, 0.33},
+			wantBasket:  []float64{0.34, 0.33, 0.33},
+		},
+		{
+			name:        "basket split after line discounts",
+			lines:       []epharma.CreateTransactionItemParams{line(1, 50), line(1, 5)},
+			discounts:   []Discount{{Type: DiscountPercent, Value: 10}, {}},
+			basket:      Discount{Type: DiscountPercent, Value: 10},
+			wantPercent: 19,
+			wantLine:    []float64{9.5, 0.5},
+			wantBasket:  []float64{4.5, 0.5},
+		},
+		{
+			name:      "line discount above the line",
+			lines:     []epharma.CreateTransactionItemParams{line(1, 5)},
+			discounts: []Discount{{Type: DiscountFixed, Value: 6}},
+			wantErr:   true,
+		},
+		{
+			name:      "basket discount above the sale",
+			lines:     []epharma.CreateTransactionItemParams{line(1, 5), line(1, 5)},
+			discounts: []Discount{{Type: DiscountFixed, Value: 5}, {}},
+			basket:    Discount{Type: DiscountFixed, Value: 5.01},
+			wantErr:   true,
+		},
+	}
+
+	const epsilon = 1e-9
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			percent, err := applyDiscounts(tt.lines, tt.discounts, tt.basket)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("applyDiscounts() = %v, want an error", percent)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("applyDiscounts() error = %v", err)
+			}
+
+			if math.Abs(percent-tt.wantPercent) > epsilon {
+				t.Errorf("applyDiscounts() = %v, want %v", percent, tt.wantPercent)
+			}
+
+			for i, line := range tt.lines {
+				if math.Abs(line.Discount-tt.wantLine[i]) > epsilon {
+					t.Errorf("line %d discount = %v, want %v", i+1, line.Discount, tt.wantLine[i])
+				}
+
+				if math.Abs(line.BasketDiscount-tt.wantBasket[i]) > epsilon {
+					t.Errorf("line %d basket discount = %v, want %v", i+1, line.BasketDiscount, tt.wantBasket[i])
+				}
+			}
+		})
+	}
+}
+
+// fakeDB answers the queries of verifyOverridePin and approveDiscount.
+type fakeDB struct {
+	epharma.DBTX
+	limit       *float64 // Discount limit of the cashier's role, nil if the role has none
+	failures    int32    // Recent wrong PINs of the cashier
+	lastFailure time.Time
+	approvers   []epharma.ListDiscountApproversRow
+	attempts    []epharma.CreatePinAttemptParams // PINs recorded
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	switch {
+	case strings.Contains(sql, "name: GetRoleDiscountLimit "):
+		if db.limit == nil {
+			return fakeRow{err: pgx.ErrNoRows}
+		}
+		return fakeRow{values: []any{*db.limit}}
+	case strings.Contains(sql, "name: RecentPinFailures "):
+		return fakeRow{values: []any{db.failures, db.lastFailure}}
+	}
+	return fakeRow{err: fmt.Errorf("unexpected query: %s", sql)}
+}
+
+func (db *fakeDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if !strings.Contains(sql, "name: ListDiscountApprovers ") {
+		return nil, fmt.Errorf("unexpected query: %s", sql)
+	}
+
+	rows := &fakeRows{}
+	for _, approver := range db.approvers {
+		if approver.MaxPercent > args[0].(float64) {
+			rows.values = append(rows.values,
+				[]any{approver.ID, approver.Username, approver.PinHash, approver.MaxPercent})
+		}
+	}
+	return rows, nil
+}
+
+func (db *fakeDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if !strings.Contains(sql, "name: CreatePinAttempt ") {
+		return pgconn.CommandTag{}, fmt.Errorf("unexpected query: %s", sql)
+	}
+
+	db.attempts = append(db.attempts, epharma.CreatePinAttemptParams{
+		UserID:     args[0].(int32),
+		ApprovedBy: args[1].(*int32),
+		Succeeded:  args[2].(bool),
+	})
+	return pgconn.CommandTag{}, nil
+}
+
+type fakeRow struct {
+	values []any
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.values[i]))
+	}
+	return nil
+}
+
+type fakeRows struct {
+	pgx.Rows
+	values [][]any
+	next   int
+}
+
+func (r *fakeRows) Next() bool {
+	r.next++
+	return r.next <= len(r.values)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	return fakeRow{values: r.values[r.next-1]}.Scan(dest...)
+}
+
+func (r *fakeRows) Close()     {}
+func (r *fakeRows) Err() error { return nil }
+
+func TestVerifyOverridePin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("246810"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	five := 5.0
+	manager := epharma.ListDiscountApproversRow{ID: 7, Username: "manager", PinHash: string(hash), MaxPercent: 100}
+	pharmacist := epharma.ListDiscountApproversRow{ID: 8, Username: "pharmacist", PinHash: string(hash), MaxPercent: 5}
+	cashier := epharma.User{ID: 3, Username: "cashier", Role: "cashier"}
+
+	tests := []struct {
+		name         string
+		db           fakeDB
+		pin          string
+		wantApprover int32 // 0 if no user was found
+		wantErr      error
+		wantLockout  bool
+		wantAttempts []bool // Whether each PIN recorded was right
+	}{
+		{
+			name: "no PIN",
+			db:   fakeDB{limit: &five, approvers: []epharma.ListDiscountApproversRow{manager}},
+		},
+		{
+			name:         "right PIN",
+			db:           fakeDB{limit: &five, approvers: []epharma.ListDiscountApproversRow{manager}},
+			pin:          "246810",
+			wantApprover: manager.ID,
+			wantAttempts: []bool{true},
+		},
+		{
+			name:         "wrong PIN",
+			db:           fakeDB{limit: &five, approvers: []epharma.ListDiscountApproversRow{manager}},
+			pin:          "135790",
+			wantErr:      errWrongPin,
+			wantAttempts: []bool{false},
+		},
+		{
+			name:         "PIN of a user who may not give more than the cashier",
+			db:           fakeDB{limit: &five, approvers: []epharma.ListDiscountApproversRow{pharmacist}},
+			pin:          "246810",
+			wantErr:      errWrongPin,
+			wantAttempts: []bool{false},
+		},
+		{
+			name: "right PIN after wrong ones",
+			db: fakeDB{limit: &five, failures: maxPinFailures - 1, lastFailure: time.Now(),
+				approvers: []epharma.ListDiscountApproversRow{manager}},
+			pin:          "246810",
+			wantApprover: manager.ID,
+			wantAttempts: []bool{true},
+		},
+		{
+			name: "locked out",
+			db: fakeDB{limit: &five, failures: maxPinFailures, lastFailure: time.Now(),
+				approvers: []epharma.ListDiscountApproversRow{manager}},
+			pin:         "246810",
+			wantLockout: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver, err := verifyOverridePin(context.Background(), epharma.New(&tt.db), cashier, tt.pin)
+
+			var lockoutErr *PinLockoutError
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("verifyOverridePin() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantLockout:
+				if !errors.As(err, &lockoutErr) {
+					t.Fatalf("verifyOverridePin() error = %v, want a lockout", err)
+				}
+			case err != nil:
+				t.Fatalf("verifyOverridePin() error = %v", err)
+			}
+
+			var id int32
+			if approver != nil {
+				id = approver.ID
+			}
+
+			if id != tt.wantApprover {
+				t.Errorf("verifyOverridePin() found user %d, want %d", id, tt.wantApprover)
+			}
+
+			if len(tt.db.attempts) != len(tt.wantAttempts) {
+				t.Fatalf("recorded %d PINs, want %d", len(tt.db.attempts), len(tt.wantAttempts))
+			}
+
+			for i, attempt := range tt.db.attempts {
+				if attempt.UserID != cashier.ID || attempt.Succeeded != tt.wantAttempts[i] {
+					t.Errorf("PIN %d recorded as %+v, want right %v", i+1, attempt, tt.wantAttempts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApproveDiscount(t *testing.T) {
+	five := 5.0
+	manager := &epharma.ListDiscountApproversRow{ID: 7, Username: "manager", MaxPercent: 100}
+	pharmacist := &epharma.ListDiscountApproversRow{ID: 8, Username: "pharmacist", MaxPercent: 10}
+	cashier := epharma.User{ID: 3, Username: "cashier", Role: "cashier"}
+
+	tests := []struct {
+		name         string
+		limit        *float64 // Limit of the cashier's role, nil if it has none
+		percent      float64
+		approver     *epharma.ListDiscountApproversRow
+		wantApprover int32 // 0 if no approval was needed
+		wantErr      *DiscountLimitError
+	}{
+		{name: "below the limit", limit: &five, percent: 4.99},
+		{name: "at the limit", limit: &five, percent: 5},
+		{name: "above the limit by less than a rounded cent", limit: &five, percent: 5.005},
+		{name: "approval not needed", limit: &five, percent: 5, approver: manager},
+		{
+			name:    "above the limit without a PIN",
+			limit:   &five,
+			percent: 5.01,
+			wantErr: &DiscountLimitError{Percent: 5.01, Limit: 5},
+		},
+		{name: "no discount for a role without a limit", percent: 0},
+		{
+			name:    "any discount for a role without a limit",
+			percent: 0.5,
+			wantErr: &DiscountLimitError{Percent: 0.5, Limit: 0},
+		},
+		{
+			name:         "approved",
+			limit:        &five,
+			percent:      20,
+			approver:     manager,
+			wantApprover: manager.ID,
+		},
+		{
+			name:         "at the approver's limit",
+			limit:        &five,
+			percent:      10.004,
+			approver:     pharmacist,
+			wantApprover: pharmacist.ID,
+		},
+		{
+			name:     "above the approver's limit",
+			limit:    &five,
+			percent:  10.01,
+			approver: pharmacist,
+			wantErr:  &DiscountLimitError{Percent: 10.01, Limit: 5, InvalidPin: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := epharma.New(&fakeDB{limit: tt.limit})
+			approvedBy, err := approveDiscount(context.Background(), q, cashier, tt.percent, tt.approver)
+
+			var limitErr *DiscountLimitError
+			if tt.wantErr != nil {
+				if !errors.As(err, &limitErr) || *limitErr != *tt.wantErr {
+					t.Fatalf("approveDiscount() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("approveDiscount() error = %v", err)
+			}
+
+			var approver int32
+			if approvedBy != nil {
+				approver = *approvedBy
+			}
+
+			if approver != tt.wantApprover {
+				t.Errorf("approveDiscount() approved by %d, want %d", approver, tt.wantApprover)
+			}
+		})
+	}
+}
+
+func TestHashPin(t *testing.T) {
+	tests := []struct {
+		pin     string
+		wantErr bool
+	}{
+		{"12345", true},
+		{"123456", false},
+		{"12345678", false},
+		{"123456789", true},
+		{"12a456", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		hash, err := hashPin(tt.pin)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("hashPin(%q) error = %v, want error %v", tt.pin, err, tt.wantErr)
+			continue
+		}
+
+		if err == nil && bcrypt.CompareHashAndPassword([]byte(hash), []byte(tt.pin)) != nil {
+			t.Errorf("hashPin(%q) does not match the PIN", tt.pin)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ESC/POS commands understood by common thermal receipt printers.
+var (
+	escposInit        = []byte{0x1B, 0x40}             // ESC @
+	escposAlignLeft   = []byte{0x1B, 0x61, 0x00}       // ESC a 0
+	escposAlignCenter = []byte{0x1B, 0x61, 0x01}       // ESC a 1
+	escposBoldOn      = []byte{0x1B, 0x45, 0x01}       // ESC E 1
+	escposBoldOff     = []byte{0x1B, 0x45, 0x00}       // ESC E 0
+	escposDoubleSize  = []byte{0x1D, 0x21, 0x11}       // GS ! double width and height
+	escposNormalSize  = []byte{0x1D, 0x21, 0x00}       // GS ! normal
+	escposFeedAndCut  = []byte{0x1D, 0x56, 0x42, 0x03} // GS V B feed 3 lines and partial cut
+)
+
+// escpos builds a receipt as raw ESC/POS bytes for a printer
+// whose lines are width characters wide.
+type escpos struct {
+	buf   bytes.Buffer
+	width int
+}
+
+func newEscpos(width int) *escpos {
+	p := &escpos{width: width}
+	p.buf.Write(escposInit)
+	return p
+}
+
+// text writes a line, wrapped to the paper width.
+// Printers use a single byte code page so characters outside ASCII are replaced.
+func (p *escpos) text(s string) {
+	for _, line := range wrapText(asciiOnly(s), p.width) {
+		p.buf.WriteString(line)
+		p.buf.WriteByte('\n')
+	}
+}
+
+// columns writes left and right aligned text on one line.
+func (p *escpos) columns(left, right string) {
+	p.buf.WriteString(padColumns(asciiOnly(left), asciiOnly(right), p.width))
+	p.buf.WriteByte('\n')
+}
+
+// rule writes a line of dashes across the paper.
+func (p *escpos) rule() {
+	p.text(strings.Repeat("-", p.width))
+}
+
+func (p *escpos) center()       { p.buf.Write(escposAlignCenter) }
+func (p *escpos) left()         { p.buf.Write(escposAlignLeft) }
+func (p *escpos) bold(on bool)  { p.buf.Write(pick(on, escposBoldOn, escposBoldOff)) }
+func (p *escpos) large(on bool) { p.buf.Write(pick(on, escposDoubleSize, escposNormalSize)) }
+
+// cut feeds the paper past the tear bar and cuts it.
+func (p *escpos) cut() {
+	p.buf.Write(escposFeedAndCut)
+}
+
+func (p *escpos) Bytes() []byte {
+	return p.buf.Bytes()
+}
+
+func pick(on bool, a, b []byte) []byte {
+	if on {
+		return a
+	}
+	return b
+}
+
+func asciiOnly(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return ' '
+		}
+		if r < 0x20 || r > 0x7E {
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+// padColumns puts right at the end of a line of width characters after left.
+// If both do not fit, right goes on a line of its own.
+func padColumns(left, right string, width int) string {
+	gap := width - len(left) - len(right)
+	if gap < 1 {
+		return left + "\n" + strings.Repeat(" ", max(width-len(right), 0)) + right
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
+
+// wrapText breaks s into lines of at most width characters, at spaces where possible.
+func wrapText(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for len(word) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, word[:width])
+			word = word[width:]
+		}
+
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	return append(lines, line)
+}
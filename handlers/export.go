@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/abiiranathan/dbtypes"
+	"github.com/abiiranathan/egor/egor"
+	"github.com/abiiranathan/epharmacy/xlsx"
+)
+
+// Rows fetched per query when exporting a listing.
+const exportBatchSize = 500
+
+// exporter writes the rows of a report or listing as a CSV or XLSX download.
+// Cells may be strings, integers, float64 amounts of money,
+// dbtypes.Date or time.Time.
+type exporter interface {
+	WriteRow(cells ...any) error
+	Flush() error
+	Close() error
+}
+
+// exportFormat returns the format query parameter if it is an export format.
+func exportFormat(r *http.Request) string {
+	switch format := egor.Query(r, "format"); format {
+	case "csv", "xlsx":
+		return format
+	}
+	return ""
+}
+
+// newExporter starts a download of the named file, without extension,
+// in the given format and writes the header row.
+func newExporter(w http.ResponseWriter, format, filename string, headers ...string) (exporter, error) {
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+
+		e := &csvExporter{w: csv.NewWriter(w)}
+		if err := e.w.Write(headers); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case "xlsx":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".xlsx"))
+
+		xw, err := xlsx.NewWriter(w, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := xw.WriteHeader(headers...); err != nil {
+			return nil, err
+		}
+		return &xlsxExporter{w: xw}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %q", format)
+	}
+}
+
+// csvExporter formats amounts with CurrencyF64 as shown on the pages.
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func (e *csvExporter) WriteRow(cells ...any) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case float64:
+			record[i] = CurrencyF64(v)
+		case dbtypes.Date:
+			if !v.IsZero() {
+				record[i] = v.Format("2006-01-02")
+			}
+		case time.Time:
+			record[i] = v.Format("2006-01-02 15:04:05")
+		case nil:
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExporter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) Close() error {
+	return e.Flush()
+}
+
+// xlsxExporter writes amounts and dates as numbers so they can be summed,
+// with amounts shown to two decimal places like CurrencyF64.
+type xlsxExporter struct {
+	w *xlsx.Writer
+}
+
+func (e *xlsxExporter) WriteRow(cells ...any) error {
+	for i, cell := range cells {
+		if date, ok := cell.(dbtypes.Date); ok {
+			if date.IsZero() {
+				cells[i] = nil
+			} else {
+				cells[i] = xlsx.Date(date)
+			}
+		}
+	}
+	return e.w.WriteRow(cells...)
+}
+
+func (e *xlsxExporter) Flush() error {
+	return e.w.Flush()
+}
+
+func (e *xlsxExporter) Close() error {
+	return e.w.Close()
+}
+
+// exportRows streams rows to a download. fetch is called with increasing
+// offsets, in steps of exportBatchSize, until it reports there are no more
+// rows. Each batch is written and flushed before the next is fetched.
+func exportRows(w http.ResponseWriter, r *http.Request, format, filename string, headers []string,
+	fetch func(offset int32) (rows [][]any, more bool, err error)) {
+	e, err := newExporter(w, format, filename, headers...)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	for offset := int32(0); ; offset += exportBatchSize {
+		rows, more, err := fetch(offset)
+		if err != nil {
+			// The download has started so the error can not be sent.
+			log.Printf("export of %s failed: %v\n", filename, err)
+			return
+		}
+
+		for _, row := range rows {
+			if err := e.WriteRow(row...); err != nil {
+				return
+			}
+		}
+
+		if err := e.Flush(); err != nil || !more {
+			break
+		}
+	}
+
+	if err := e.Close(); err != nil {
+		log.Printf("export of %s failed: %v\n", filename, err)
+	}
+}
+
+// exportTable sends rows that are already loaded as a download.
+func exportTable(w http.ResponseWriter, r *http.Request, format, filename string, headers []string, rows [][]any) {
+	exportRows(w, r, format, filename, headers, func(int32) ([][]any, bool, error) {
+		return rows, false, nil
+	})
+}
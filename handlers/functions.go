@@ -24,16 +24,27 @@ var FuncMap = template.FuncMap{
 	"multiply": func(a, b int) int {
 		return a * b
 	},
-	"product_subtotal": func(product epharma.Product) float64 {
-		return product.SellingPrice * float64(product.Quantity)
-	},
 	"transaction_total": func(transaction Transaction) float64 {
 		var total float64
-		for _, product := range transaction.Products {
-			total += product.SellingPrice * float64(product.Quantity)
+		for _, item := range transaction.Items {
+			total += lineAmountDue(item.LineTotal, item.Tax, item.TaxInclusive)
 		}
 		return total
 	},
+	"transaction_tax": func(transaction Transaction) float64 {
+		var tax float64
+		for _, item := range transaction.Items {
+			tax += item.Tax
+		}
+		return tax
+	},
+	"line_gross": func(item epharma.ListTransactionItemsRow) float64 {
+		return float64(item.Quantity) * item.UnitPrice
+	},
+	// Lines of deleted products have no product and no batches.
+	"line_batch": func(item epharma.ListTransactionItemsRow, batch epharma.ListTransactionBatchesRow) bool {
+		return item.ProductID != nil && *item.ProductID == batch.ProductID
+	},
 	"invoice_subtotal": func(invoice epharma.InvoiceItemsRow) float64 {
 		return invoice.CostPrice * float64(invoice.Quantity)
 	},
@@ -49,13 +60,7 @@ var FuncMap = template.FuncMap{
 	"plus": func(a, b int) int {
 		return a + b
 	},
-	"join_dates": func(arr []dbtypes.Date) string {
-		var dates []string
-		for _, date := range arr {
-			dates = append(dates, date.String())
-		}
-		return strings.Join(dates, ", ")
-	},
+	"join_dates":     joinDates,
 	"days_to_expiry": daysToExpiry,
 	"expiryColor": func(expiry dbtypes.Date) string {
 		if expiry.IsZero() {
@@ -73,6 +78,22 @@ var FuncMap = template.FuncMap{
 		}
 	},
 	"CurrencyF64": CurrencyF64,
+	"margin":      grossMargin,
+	"can":         userCan,
+	"humanize":    humanize,
+}
+
+func joinDates(arr []dbtypes.Date) string {
+	var dates []string
+	for _, date := range arr {
+		dates = append(dates, date.String())
+	}
+	return strings.Join(dates, ", ")
+}
+
+// humanize replaces underscores in enum values e.g mobile_money with spaces.
+func humanize(s any) string {
+	return strings.ReplaceAll(fmt.Sprint(s), "_", " ")
 }
 
 func formatDuration(duration time.Duration) string {
@@ -110,6 +131,14 @@ func daysToExpiry(expiry dbtypes.Date) string {
 	return fmt.Sprintf("EXP: %s ago", formatDuration(duration))
 }
 
+// grossMargin returns the profit as a percentage of income.
+func grossMargin(profit, income float64) float64 {
+	if income == 0 {
+		return 0
+	}
+	return profit / income * 100
+}
+
 func CurrencyF64(number float64) string {
 	p := message.NewPrinter(language.BritishEnglish)
 	return p.Sprintf("%.2f", number)
@@ -3,19 +3,34 @@ package handlers
 import (
 	"github.com/abiiranathan/egor/egor"
 	"github.com/abiiranathan/epharmacy/epharma"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Pharmacy details printed on receipts and on the letterhead of documents.
+type Pharmacy struct {
+	Name    string
+	Address string
+	Phone   string
+	Email   string
+	TIN     string // Taxpayer identification number
+}
+
 type Handlers struct {
 	Queries *epharma.Queries
 	Router  *egor.Router
-	Conn    *pgx.Conn
+	Pool    *pgxpool.Pool
+
+	Pharmacy Pharmacy
+
+	// Address (host:port) of a network receipt printer that accepts
+	// raw ESC/POS on e.g port 9100. Empty if there is none.
+	ReceiptPrinter string
 }
 
-func New(queries *epharma.Queries, conn *pgx.Conn, router *egor.Router) *Handlers {
+func New(queries *epharma.Queries, pool *pgxpool.Pool, router *egor.Router) *Handlers {
 	return &Handlers{
 		Queries: queries,
 		Router:  router,
-		Conn:    conn,
+		Pool:    pool,
 	}
 }
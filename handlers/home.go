@@ -15,7 +15,19 @@ func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	taxClasses, err := h.Queries.ListTaxClasses(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	taxRates := make(map[int32]float64, len(taxClasses))
+	for _, class := range taxClasses {
+		taxRates[class.ID] = class.Rate
+	}
+
 	egor.Render(w, r, "index.html", egor.Map{
 		"products": products,
+		"taxRates": taxRates,
 	})
 }
@@ -1,16 +1,62 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
-	"github.com/abiiranathan/dbtypes"
 	"github.com/abiiranathan/egor/egor"
 	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// Statuses of an invoice, by the value of the stock in on it against its total.
+const (
+	invoiceOpen       = "open"       // Short of the invoice total, more stock is to be added.
+	invoiceBalanced   = "balanced"   // Adds up to the invoice total.
+	invoiceDiscrepant = "discrepant" // More than the invoice total.
+)
+
+// invoiceStatus compares the value of the stock in on an invoice,
+// its line total, with the total declared on the invoice.
+func invoiceStatus(invoiceTotal, lineTotal float64) string {
+	switch declared, lines := cents(invoiceTotal), cents(lineTotal); {
+	case lines == declared:
+		return invoiceBalanced
+	case lines < declared:
+		return invoiceOpen
+	default:
+		return invoiceDiscrepant
+	}
+}
+
 // ListInvoicesPaginated
 func (h *Handlers) ListInvoicesPaginated(w http.ResponseWriter, r *http.Request) {
+	if format := exportFormat(r); format != "" {
+		headers := []string{"Invoice ID", "Invoice No", "Purchase Date", "Supplier", "Invoice Total",
+			"Amount Paid", "Balance", "User ID", "Created At"}
+
+		exportRows(w, r, format, "invoices", headers, func(offset int32) ([][]any, bool, error) {
+			invoices, err := h.Queries.ListInvoicesPaginated(r.Context(), epharma.ListInvoicesPaginatedParams{
+				Offset: offset,
+				Limit:  exportBatchSize,
+			})
+			if err != nil {
+				return nil, false, err
+			}
+
+			rows := make([][]any, 0, len(invoices))
+			for _, inv := range invoices {
+				rows = append(rows, []any{inv.ID, inv.InvoiceNumber, inv.PurchaseDate, inv.SupplierName,
+					inv.InvoiceTotal, inv.AmountPaid, inv.InvoiceTotal - inv.AmountPaid, inv.UserID, inv.CreatedAt})
+			}
+			return rows, len(invoices) == exportBatchSize, nil
+		})
+		return
+	}
+
 	page := min(egor.QueryInt(r, "page", 1), 1)
 	limit := max(egor.QueryInt(r, "limit", 10), 10)
 
@@ -35,7 +81,15 @@ func (h *Handlers) ListInvoicesPaginated(w http.ResponseWriter, r *http.Request)
 
 // RenderInvoiceCreatePage
 func (h *Handlers) RenderInvoiceCreatePage(w http.ResponseWriter, r *http.Request) {
+	suppliers, err := h.Queries.ListSuppliers(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
 	egor.Render(w, r, "invoices/create", egor.Map{
+		"suppliers":      suppliers,
+		"paymentMethods": epharma.AllSupplierPaymentMethodValues(),
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Invoices", URL: "/invoices"},
 			{Label: "Create Invoice", IsLast: true},
@@ -43,7 +97,8 @@ func (h *Handlers) RenderInvoiceCreatePage(w http.ResponseWriter, r *http.Reques
 	})
 }
 
-// CreateInvoice
+// CreateInvoice records an invoice and the amount paid on it when it
+// was received, if any, as its first payment.
 func (h *Handlers) CreateInvoice(w http.ResponseWriter, r *http.Request) {
 	var params epharma.CreateInvoiceParams
 	err := egor.BodyParser(r, &params)
@@ -53,21 +108,58 @@ func (h *Handlers) CreateInvoice(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// validate invoice
-	if params.InvoiceNumber == "" || params.InvoiceTotal == 0 || params.AmountPaid == 0 {
+	if params.InvoiceNumber == "" || params.InvoiceTotal <= 0 {
 		egor.SendError(w, r, fmt.Errorf("invalid payload"), http.StatusBadRequest)
 		return
 	}
 
+	if params.SupplierID <= 0 {
+		egor.SendError(w, r, fmt.Errorf("supplier is required"), http.StatusBadRequest)
+		return
+	}
+
 	// Add user id
 	user := egor.GetContextValue(r, "user").(epharma.User)
 	params.UserID = user.ID
 
-	invoice, err := h.Queries.CreateInvoice(r.Context(), params)
+	var payment epharma.CreateSupplierPaymentParams
+	if amount := strings.TrimSpace(r.FormValue("amount")); amount != "" && amount != "0" {
+		// The payment is dated the purchase date unless it has a date of its own.
+		payment, err = parseSupplierPayment(r, params.InvoiceTotal, params.PurchaseDate)
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusBadRequest)
+			return
+		}
+		payment.UserID = user.ID
+	}
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	invoice, err := qtx.CreateInvoice(r.Context(), params)
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
 	}
 
+	if payment.Amount > 0 {
+		payment.InvoiceID = invoice.ID
+		if _, err := qtx.CreateSupplierPayment(r.Context(), payment); err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
 	// Allow user add items to invoice.
 	egor.Redirect(w, r, fmt.Sprintf("/invoices/view/%d", invoice.ID))
 }
@@ -87,9 +179,31 @@ func (h *Handlers) GetInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	payments, err := h.Queries.ListInvoicePayments(r.Context(), invoice.ID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if egor.Query(r, "format") == "pdf" {
+		h.invoicePDF(w, invoice, invoiceItems)
+		return
+	}
+
+	var lineTotal float64
+	for _, item := range invoiceItems {
+		lineTotal += item.CostPrice * float64(item.Quantity)
+	}
+
 	egor.Render(w, r, "invoices/view", egor.Map{
-		"invoice":      invoice,
-		"invoiceItems": invoiceItems,
+		"invoice":        invoice,
+		"invoiceItems":   invoiceItems,
+		"lineTotal":      lineTotal,
+		"difference":     invoice.InvoiceTotal - lineTotal,
+		"status":         invoiceStatus(invoice.InvoiceTotal, lineTotal),
+		"payments":       payments,
+		"paymentMethods": epharma.AllSupplierPaymentMethodValues(),
+		"today":          currentDate(),
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Invoices", URL: "/invoices"},
 			{Label: invoice.InvoiceNumber, IsLast: true},
@@ -106,8 +220,15 @@ func (h *Handlers) RenderInvoiceUpdatePage(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	egor.Render(w, r, "invoices/update", egor.Map{
-		"invoice": invoice,
+	suppliers, err := h.Queries.ListSuppliers(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	egor.Render(w, r, "invoices/create", egor.Map{
+		"invoice":   invoice,
+		"suppliers": suppliers,
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Invoices", URL: "/invoices"},
 			{Label: invoice.InvoiceNumber, URL: fmt.Sprintf("/invoices/view/%d", invoice.ID)},
@@ -126,29 +247,169 @@ func (h *Handlers) UpdateInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if params.SupplierID <= 0 {
+		egor.SendError(w, r, fmt.Errorf("supplier is required"), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	// Locked so that the invoice is not closed while its total changes.
+	qtx := h.Queries.WithTx(tx)
+	invoice, err := qtx.GetInvoiceForUpdate(r.Context(), int32(invoiceID))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	// The stock in on a closed invoice adds up to its total.
+	if invoice.ClosedAt != nil && cents(params.InvoiceTotal) != cents(invoice.InvoiceTotal) {
+		egor.SendError(w, r, fmt.Errorf("the invoice is closed and its total can not be changed"), http.StatusConflict)
+		return
+	}
+
+	if cents(params.InvoiceTotal) < cents(invoice.AmountPaid) {
+		egor.SendError(w, r, fmt.Errorf("the invoice total of %s is less than the %s already paid",
+			CurrencyF64(params.InvoiceTotal), CurrencyF64(invoice.AmountPaid)), http.StatusBadRequest)
+		return
+	}
+
+	user := egor.GetContextValue(r, "user").(epharma.User)
 	params.ID = int32(invoiceID)
+	params.UserID = user.ID
 
-	err = h.Queries.UpdateInvoice(r.Context(), params)
+	err = qtx.UpdateInvoice(r.Context(), params)
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
 	}
 
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
 	// redirect to invoice details page.
 	egor.Redirect(w, r, fmt.Sprintf("/invoices/view/%d", invoiceID))
 }
 
-// DeleteInvoice
+// DeleteInvoice deletes an open invoice without stock in or payments.
+// The stock in is removed a line at a time so that stock already
+// sold is not deleted with the invoice. Stock received against a
+// purchase order goes back onto the order, which can be converted
+// again.
 func (h *Handlers) DeleteInvoice(w http.ResponseWriter, r *http.Request) {
 	invoiceID := egor.ParamInt(r, "id")
-	err := h.Queries.DeleteInvoice(r.Context(), int32(invoiceID))
+
+	tx, err := h.Pool.Begin(r.Context())
 	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	if _, ok := requireOpenInvoice(w, r, qtx, int32(invoiceID)); !ok {
+		return
+	}
+
+	_, err = qtx.UninvoicePurchaseOrderStock(r.Context(), int32(invoiceID))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = qtx.DeleteInvoice(r.Context(), int32(invoiceID))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			if pgErr.TableName == "stock_in" {
+				egor.SendError(w, r, fmt.Errorf("the invoice has stock in, remove its products first"), http.StatusConflict)
+				return
+			}
+			egor.SendError(w, r, fmt.Errorf("the invoice has payments, delete them first"), http.StatusConflict)
+			return
+		}
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
 	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
 	egor.Redirect(w, r, "/invoices")
 }
 
+// CloseInvoice closes an invoice whose stock in adds up to its total.
+// Stock can no longer be added to or removed from a closed invoice.
+func (h *Handlers) CloseInvoice(w http.ResponseWriter, r *http.Request) {
+	invoiceID := int32(egor.ParamInt(r, "id"))
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	invoice, ok := requireOpenInvoice(w, r, qtx, invoiceID)
+	if !ok {
+		return
+	}
+
+	lineTotal, err := qtx.InvoiceLineTotal(r.Context(), invoiceID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if invoiceStatus(invoice.InvoiceTotal, lineTotal) != invoiceBalanced {
+		egor.SendError(w, r, fmt.Errorf("the stock in adds up to %s and not to the invoice total of %s",
+			CurrencyF64(lineTotal), CurrencyF64(invoice.InvoiceTotal)), http.StatusConflict)
+		return
+	}
+
+	_, err = qtx.CloseInvoice(r.Context(), invoiceID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/invoices/view/%d", invoiceID), http.StatusSeeOther)
+}
+
+// requireOpenInvoice locks an invoice for the rest of the transaction.
+// It sends an error and returns false unless the invoice is open and
+// its stock in can still be changed or it can be deleted.
+func requireOpenInvoice(w http.ResponseWriter, r *http.Request, q *epharma.Queries, id int32) (epharma.Invoice, bool) {
+	invoice, err := q.GetInvoiceForUpdate(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			egor.SendError(w, r, fmt.Errorf("invoice not found"), http.StatusNotFound)
+			return invoice, false
+		}
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return invoice, false
+	}
+
+	if invoice.ClosedAt != nil {
+		egor.SendError(w, r, fmt.Errorf("the invoice is closed and can not be changed"), http.StatusConflict)
+		return invoice, false
+	}
+	return invoice, true
+}
+
 // GetInvoiceByNumber
 func (h *Handlers) GetInvoiceByNumber(w http.ResponseWriter, r *http.Request) {
 	invoiceNumber := egor.Query(r, "invoice_number")
@@ -190,7 +451,7 @@ func (h *Handlers) NewStockIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := h.Conn.Begin(r.Context())
+	tx, err := h.Pool.Begin(r.Context())
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
@@ -198,47 +459,27 @@ func (h *Handlers) NewStockIn(w http.ResponseWriter, r *http.Request) {
 	defer tx.Rollback(r.Context())
 
 	qtx := h.Queries.WithTx(tx)
-
-	// New stock in
-	err = qtx.AddProductToInvoice(r.Context(), stockin)
-	if err != nil {
-		egor.SendError(w, r, err, http.StatusBadRequest)
-		return
-	}
-
-	// If out of stock, replace expiry date
-	product, err := h.Queries.GetProduct(r.Context(), stockin.ProductID)
-	if err != nil {
-		egor.SendError(w, r, err, http.StatusBadRequest)
+	if _, ok := requireOpenInvoice(w, r, qtx, stockin.InvoiceID); !ok {
 		return
 	}
 
-	// If product is out of stock, replace expiry date with new one
-	// if expiry date for the new stock in is set.
-	if product.Quantity <= 0 && !stockin.ExpiryDate.IsZero() {
-		err = qtx.ReplaceProductExpiry(r.Context(), epharma.ReplaceProductExpiryParams{
-			ID:          stockin.ProductID,
-			ExpiryDates: []dbtypes.Date{stockin.ExpiryDate},
-		})
-	} else if product.Quantity > 0 && !stockin.ExpiryDate.IsZero() {
-		// Add a new expiry date to the product expiry dates.
-		// Do this only if this expiry date is not already in the list.
-		err = qtx.AddProductExpiry(r.Context(), epharma.AddProductExpiryParams{
-			ID:         stockin.ProductID,
-			ExpiryDate: stockin.ExpiryDate,
-		})
-	}
+	// New stock in
+	stock, err := qtx.AddProductToInvoice(r.Context(), stockin)
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
 	}
 
-	// update product quantity
-	err = qtx.IncrementProductQuantity(r.Context(), epharma.IncrementProductQuantityParams{
-		ID:       stockin.ProductID,
-		Quantity: stockin.Quantity,
+	// Each stock in is its own batch. The product quantity and
+	// expiry dates are updated from the batches by the database.
+	_, err = qtx.CreateProductBatch(r.Context(), epharma.CreateProductBatchParams{
+		ProductID:   stock.ProductID,
+		StockInID:   &stock.ID,
+		BatchNumber: stock.BatchNumber,
+		ExpiryDate:  stock.ExpiryDate,
+		Quantity:    stock.Quantity,
+		CostPrice:   stock.CostPrice,
 	})
-
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
@@ -252,7 +493,7 @@ func (h *Handlers) DeleteStockIn(w http.ResponseWriter, r *http.Request) {
 	stockinID := egor.ParamInt(r, "stockin_id")
 	invoiceID := egor.ParamInt(r, "invoice_id")
 
-	tx, err := h.Conn.Begin(r.Context())
+	tx, err := h.Pool.Begin(r.Context())
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
@@ -261,44 +502,93 @@ func (h *Handlers) DeleteStockIn(w http.ResponseWriter, r *http.Request) {
 
 	qtx := h.Queries.WithTx(tx)
 
-	// decrement product quantity
-	stockin, err := h.Queries.GetStockIn(r.Context(), int32(stockinID))
+	stockin, err := qtx.GetStockIn(r.Context(), int32(stockinID))
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
 	}
 
-	// decrement product quantity
-	err = qtx.DecrementProductQuantity(r.Context(), epharma.DecrementProductQuantityParams{
-		ID:       stockin.ProductID,
-		Quantity: stockin.Quantity,
-	})
+	if stockin.InvoiceID != nil {
+		if _, ok := requireOpenInvoice(w, r, qtx, *stockin.InvoiceID); !ok {
+			return
+		}
+	}
 
-	if err != nil {
-		egor.SendError(w, r, err, http.StatusBadRequest)
+	// Stock received against a purchase order can only be removed while
+	// the order is still being received; the order is locked until then.
+	var order *epharma.PurchaseOrder
+	if stockin.PurchaseOrderItemID != nil {
+		locked, err := qtx.GetPurchaseOrderItemOrderForUpdate(r.Context(), *stockin.PurchaseOrderItemID)
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		if locked.Status != epharma.PurchaseOrderStatusSent &&
+			locked.Status != epharma.PurchaseOrderStatusPartiallyReceived {
+			egor.SendError(w, r,
+				fmt.Errorf("the stock was received on a %s purchase order and can not be removed", humanize(locked.Status)),
+				http.StatusConflict)
+			return
+		}
+		order = &locked
+	}
+
+	// Lock the product before its batch, in the order sales lock them, so
+	// that a sale can not take units from the batch while it is removed.
+	if _, err := qtx.LockProducts(r.Context(), []int32{stockin.ProductID}); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	// delete stock in
-	err = qtx.DeleteStockIn(r.Context(), int32(stockinID))
-	if err != nil {
+	// Stock in that is partly sold can not be removed.
+	// Stock in without a batch was sold out before batches were introduced.
+	var onHand int32
+	batch, err := qtx.GetStockInBatchForUpdate(r.Context(), &stockin.ID)
+	if err == nil {
+		onHand = batch.Quantity
+	} else if !errors.Is(err, pgx.ErrNoRows) {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
 	}
 
-	// remove expiry date from product expiry dates
-	err = qtx.RemoveProductExpiry(r.Context(), epharma.RemoveProductExpiryParams{
-		ID:         stockin.ProductID,
-		ExpiryDate: stockin.ExpiryDate,
-	})
+	if onHand < stockin.Quantity {
+		egor.SendError(w, r,
+			fmt.Errorf("%d of %d units from this stock in have already been sold", stockin.Quantity-onHand, stockin.Quantity),
+			http.StatusConflict)
+		return
+	}
 
+	// delete stock in, its batch is deleted with it.
+	err = qtx.DeleteStockIn(r.Context(), int32(stockinID))
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
 	}
 
+	// The order goes back to partially received, or sent if nothing is left.
+	if order != nil {
+		lines, _, err := purchaseOrderLines(r, qtx, order.ID)
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		_, err = qtx.SetPurchaseOrderStatus(r.Context(), epharma.SetPurchaseOrderStatusParams{
+			Status: receiptStatus(lines),
+			ID:     order.ID,
+		})
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// commit the transaction
-	tx.Commit(r.Context())
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
 
 	egor.Redirect(w, r, fmt.Sprintf("/invoices/view/%d", invoiceID))
 }
@@ -0,0 +1,27 @@
+package handlers
+
+import "testing"
+
+func TestInvoiceStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		invoiceTotal float64
+		lineTotal    float64
+		want         string
+	}{
+		{"no stock in", 1000, 0, invoiceOpen},
+		{"short by a cent", 1000, 999.99, invoiceOpen},
+		{"balanced", 1000, 1000, invoiceBalanced},
+		{"balanced within a rounded cent", 0.3, 0.1 + 0.2, invoiceBalanced},
+		{"balanced below half a cent", 1000, 999.996, invoiceBalanced},
+		{"over by a cent", 1000, 1000.01, invoiceDiscrepant},
+		{"stock in on a zero invoice", 0, 5, invoiceDiscrepant},
+		{"empty zero invoice", 0, 0, invoiceBalanced},
+	}
+
+	for _, tt := range tests {
+		if got := invoiceStatus(tt.invoiceTotal, tt.lineTotal); got != tt.want {
+			t.Errorf("%s: invoiceStatus(%v, %v) = %q, want %q", tt.name, tt.invoiceTotal, tt.lineTotal, got, tt.want)
+		}
+	}
+}
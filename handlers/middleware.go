@@ -16,63 +16,39 @@ func (h *Handlers) AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		var id int32
-		var userId string
-		var err error
-		var user epharma.User
-
-		userIdCookier, err := r.Cookie("user_id")
+		user, err := h.sessionUser(r)
 		if err != nil {
-			goto loginFailed
-		}
-
-		userId = userIdCookier.Value
-		if userId == "" {
-			goto loginFailed
-		}
-
-		id, err = decodeUserId(userId)
-		if err != nil {
-			goto loginFailed
-		}
-
-		user, err = h.Queries.GetUser(r.Context(), id)
-		if err != nil {
-			goto loginFailed
-		}
+			log.Println(err)
 
-		if !user.IsActive {
-			goto loginFailed
+			nextUrl := r.URL.Path
+			if r.URL.RawQuery != "" {
+				nextUrl += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, "/login?next="+nextUrl, http.StatusSeeOther)
+			return
 		}
 
 		egor.SetContextValue(r, "user", user)
 		next.ServeHTTP(w, r)
-		return
-
-	loginFailed:
-		nextUrl := r.URL.Path
-		if r.URL.RawQuery != "" {
-			nextUrl += "?" + r.URL.RawQuery
-		}
-		http.Redirect(w, r, "/login?next="+nextUrl, http.StatusSeeOther)
-		if err != nil {
-			log.Println(err)
-		}
 	})
 }
 
-func (h *Handlers) AdminRequired(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, ok := egor.GetContextValue(r, "user").(epharma.User)
-		if !ok {
-			h.Router.RenderError(w, fmt.Errorf("you are not authenticated"), http.StatusForbidden)
-			return
-		}
-
-		if !user.IsAdmin {
-			h.Router.RenderError(w, fmt.Errorf("admin permission is required"), http.StatusForbidden)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+// PermissionRequired returns a middleware that only lets through users
+// whose role has the given permission.
+func (h *Handlers) PermissionRequired(permission Permission) egor.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := egor.GetContextValue(r, "user").(epharma.User)
+			if !ok {
+				h.Router.RenderError(w, fmt.Errorf("you are not authenticated"), http.StatusForbidden)
+				return
+			}
+
+			if !HasPermission(user.Role, permission) {
+				h.Router.RenderError(w, fmt.Errorf("permission %q is required", permission), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything past 72 bytes.
+)
+
+// Hash compared against when the username does not exist so that
+// login takes the same time for known and unknown usernames.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("epharmacy-dummy"), bcrypt.DefaultCost)
+
+// validatePassword enforces the password policy on a new password.
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("password must be at most %d characters", maxPasswordLength)
+	}
+	return nil
+}
+
+// hashPassword validates the password and returns its bcrypt hash.
+func hashPassword(password string) (string, error) {
+	if err := validatePassword(password); err != nil {
+		return "", err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// verifyPassword reports whether password matches the stored password.
+// Rows created before passwords were hashed store the plaintext,
+// in which case legacy is true and the caller should rehash the password.
+func verifyPassword(stored, password string) (ok bool, legacy bool) {
+	if _, err := bcrypt.Cost([]byte(stored)); err != nil {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1, true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil, false
+}
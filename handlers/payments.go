@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/abiiranathan/epharmacy/epharma"
+)
+
+// Tender is a payment handed over by the customer at the till.
+// For cash, Amount is the cash tendered and may exceed the amount due.
+type Tender struct {
+	Method    epharma.PaymentMethod `json:"method"`
+	Amount    float64               `json:"amount"`
+	Reference string                `json:"reference"`
+}
+
+// cents rounds a currency amount to whole cents for comparisons.
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// settlePayments applies the tenders to a sale total. Non-cash tenders
+// settle exactly their amount and cash covers the rest, so change is
+// only ever given from cash.
+func settlePayments(total float64, tenders []Tender) ([]epharma.CreatePaymentParams, error) {
+	if len(tenders) == 0 {
+		return nil, fmt.Errorf("no payment for the sale")
+	}
+
+	var nonCash, cash int64
+	for _, tender := range tenders {
+		if !tender.Method.Valid() {
+			return nil, fmt.Errorf("invalid payment method: %q", tender.Method)
+		}
+
+		if cents(tender.Amount) <= 0 {
+			return nil, fmt.Errorf("invalid %s payment amount", humanize(tender.Method))
+		}
+
+		switch tender.Method {
+		case epharma.PaymentMethodCash:
+			cash += cents(tender.Amount)
+		case epharma.PaymentMethodInsurance, epharma.PaymentMethodCredit:
+			if strings.TrimSpace(tender.Reference) == "" {
+				return nil, fmt.Errorf("a reference is required for %s payments", humanize(tender.Method))
+			}
+			nonCash += cents(tender.Amount)
+		default:
+			nonCash += cents(tender.Amount)
+		}
+	}
+
+	due := cents(total)
+	if nonCash > due {
+		return nil, fmt.Errorf("non-cash payments of %s exceed the sale total of %s",
+			CurrencyF64(float64(nonCash)/100), CurrencyF64(total))
+	}
+
+	if nonCash+cash < due {
+		return nil, fmt.Errorf("payments are short of the sale total by %s",
+			CurrencyF64(float64(due-nonCash-cash)/100))
+	}
+
+	// Cash settles what the other tenders do not, in the order tendered.
+	cashDue := due - nonCash
+	payments := make([]epharma.CreatePaymentParams, 0, len(tenders))
+	for _, tender := range tenders {
+		tendered := cents(tender.Amount)
+		amount := tendered
+		if tender.Method == epharma.PaymentMethodCash {
+			amount = min(tendered, cashDue)
+			cashDue -= amount
+		}
+
+		payments = append(payments, epharma.CreatePaymentParams{
+			Method:    tender.Method,
+			Amount:    float64(amount) / 100,
+			Tendered:  float64(tendered) / 100,
+			Reference: strings.TrimSpace(tender.Reference),
+		})
+	}
+	return payments, nil
+}
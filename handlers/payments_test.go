@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/abiiranathan/epharmacy/epharma"
+)
+
+func TestCents(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   int64
+	}{
+		{0, 0},
+		{12.35, 1235},
+		{0.1 + 0.2, 30},
+		{1.005, 100}, // 1.005 is stored as 1.00499...
+		{2.675, 268},
+		{-3.333, -333},
+	}
+
+	for _, tt := range tests {
+		if got := cents(tt.amount); got != tt.want {
+			t.Errorf("cents(%v) = %d, want %d", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestSettlePayments(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   float64
+		tenders []Tender
+		want    []epharma.CreatePaymentParams
+		wantErr bool
+	}{
+		{
+			name:    "exact cash",
+			total:   12.35,
+			tenders: []Tender{{Method: epharma.PaymentMethodCash, Amount: 12.35}},
+			want: []epharma.CreatePaymentParams{
+				{Method: epharma.PaymentMethodCash, Amount: 12.35, Tendered: 12.35},
+			},
+		},
+		{
+			name:    "cash with change",
+			total:   12.35,
+			tenders: []Tender{{Method: epharma.PaymentMethodCash, Amount: 20}},
+			want: []epharma.CreatePaymentParams{
+				{Method: epharma.PaymentMethodCash, Amount: 12.35, Tendered: 20},
+			},
+		},
+		{
+			name:  "card and cash with change",
+			total: 100,
+			tenders: []Tender{
+				{Method: epharma.PaymentMethodCash, Amount: 50},
+				{Method: epharma.PaymentMethodCard, Amount: 60, Reference: " 4411 "},
+			},
+			want: []epharma.CreatePaymentParams{
+				{Method: epharma.PaymentMethodCash, Amount: 40, Tendered: 50},
+				{Method: epharma.PaymentMethodCard, Amount: 60, Tendered: 60, Reference: "4411"},
+			},
+		},
+		{
+			name:  "second cash tender gives the change",
+			total: 15,
+			tenders: []Tender{
+				{Method: epharma.PaymentMethodCash, Amount: 10},
+				{Method: epharma.PaymentMethodCash, Amount: 10},
+			},
+			want: []epharma.CreatePaymentParams{
+				{Method: epharma.PaymentMethodCash, Amount: 10, Tendered: 10},
+				{Method: epharma.PaymentMethodCash, Amount: 5, Tendered: 10},
+			},
+		},
+		{
+			name:  "total rounded to the cent",
+			total: 0.1 + 0.2,
+			tenders: []Tender{
+				{Method: epharma.PaymentMethodMobileMoney, Amount: 0.1},
+				{Method: epharma.PaymentMethodCash, Amount: 0.2},
+			},
+			want: []epharma.CreatePaymentParams{
+				{Method: epharma.PaymentMethodMobileMoney, Amount: 0.1, Tendered: 0.1},
+				{Method: epharma.PaymentMethodCash, Amount: 0.2, Tendered: 0.2},
+			},
+		},
+		{
+			name:  "insurance covers the whole sale",
+			total: 45.5,
+			tenders: []Tender{
+				{Method: epharma.PaymentMethodInsurance, Amount: 45.5, Reference: "AAR-1001"},
+			},
+			want: []epharma.CreatePaymentParams{
+				{Method: epharma.PaymentMethodInsurance, Amount: 45.5, Tendered: 45.5, Reference: "AAR-1001"},
+			},
+		},
+		{
+			name:    "no tenders",
+			total:   10,
+			wantErr: true,
+		},
+		{
+			name:    "invalid method",
+			total:   10,
+			tenders: []Tender{{Method: "cheque", Amount: 10}},
+			wantErr: true,
+		},
+		{
+			name:    "amount rounds to zero",
+			total:   10,
+			tenders: []Tender{{Method: epharma.PaymentMethodCash, Amount: 0.004}},
+			wantErr: true,
+		},
+		{
+			name:    "credit without a reference",
+			total:   10,
+			tenders: []Tender{{Method: epharma.PaymentMethodCredit, Amount: 10, Reference: "  "}},
+			wantErr: true,
+		},
+		{
+			name:    "non-cash above the total",
+			total:   10,
+			tenders: []Tender{{Method: epharma.PaymentMethodCard, Amount: 10.01}},
+			wantErr: true,
+		},
+		{
+			name:  "short by a cent",
+			total: 10,
+			tenders: []Tender{
+				{Method: epharma.PaymentMethodCard, Amount: 4},
+				{Method: epharma.PaymentMethodCash, Amount: 5.99},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := settlePayments(tt.total, tt.tenders)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("settlePayments() = %+v, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("settlePayments() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("settlePayments() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/abiiranathan/epharmacy/pdf"
+)
+
+// newPDF starts a document with the pharmacy letterhead followed by its title.
+func (h *Handlers) newPDF(title string) *pdf.Document {
+	doc := pdf.New(title)
+
+	name := h.Pharmacy.Name
+	if name == "" {
+		name = "EPharmacy"
+	}
+
+	doc.SetFont(true, 18)
+	doc.Text(name, pdf.Center)
+	doc.SetFont(false, 9)
+
+	var contacts []string
+	for _, line := range []string{h.Pharmacy.Phone, h.Pharmacy.Email} {
+		if line != "" {
+			contacts = append(contacts, line)
+		}
+	}
+
+	if h.Pharmacy.Address != "" {
+		doc.Text(h.Pharmacy.Address, pdf.Center)
+	}
+	if len(contacts) > 0 {
+		doc.Text(strings.Join(contacts, "  |  "), pdf.Center)
+	}
+	if h.Pharmacy.TIN != "" {
+		doc.Text("TIN: "+h.Pharmacy.TIN, pdf.Center)
+	}
+	doc.Rule()
+
+	doc.Space(4)
+	doc.SetFont(true, 13)
+	doc.Text(title, pdf.Left)
+	doc.SetFont(false, 9)
+	doc.Space(4)
+	return doc
+}
+
+// sendPDF sends the document as a download named filename.
+func sendPDF(w http.ResponseWriter, doc *pdf.Document, filename string) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	doc.WriteTo(w)
+}
+
+// invoicePDF sends a supplier's invoice and the stock received on it.
+func (h *Handlers) invoicePDF(w http.ResponseWriter, invoice epharma.GetInvoiceRow, items []epharma.InvoiceItemsRow) {
+	doc := h.newPDF("Invoice No. " + invoice.InvoiceNumber)
+	doc.Columns("Supplier: "+invoice.SupplierName, "Purchase date: "+invoice.PurchaseDate.Format("02 Jan 2006"))
+	doc.Columns("Invoice total: "+CurrencyF64(invoice.InvoiceTotal), "Amount paid: "+CurrencyF64(invoice.AmountPaid))
+	doc.Columns("Balance: "+CurrencyF64(invoice.InvoiceTotal-invoice.AmountPaid),
+		"Recorded: "+invoice.CreatedAt.Format("02 Jan 2006 15:04"))
+	doc.Space(8)
+
+	var total float64
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		subtotal := item.CostPrice * float64(item.Quantity)
+		total += subtotal
+
+		expiry := "-"
+		if !item.ExpiryDate.IsZero() {
+			expiry = item.ExpiryDate.Format("02 Jan 2006")
+		}
+
+		rows = append(rows, []string{
+			item.GenericName + " " + item.BrandName,
+			item.BatchNumber,
+			expiry,
+			fmt.Sprint(item.Quantity),
+			CurrencyF64(item.CostPrice),
+			CurrencyF64(subtotal),
+		})
+	}
+
+	doc.Table([]pdf.Column{
+		{Header: "Product", Width: 5},
+		{Header: "Batch", Width: 2},
+		{Header: "Expiry", Width: 2},
+		{Header: "Qty", Width: 1, Align: pdf.Right},
+		{Header: "Unit Cost", Width: 2, Align: pdf.Right},
+		{Header: "Subtotal", Width: 2, Align: pdf.Right},
+	}, rows, []string{"Total", "", "", "", "", CurrencyF64(total)})
+
+	sendPDF(w, doc, fmt.Sprintf("invoice-%s.pdf", invoice.InvoiceNumber))
+}
+
+// receiptPDF sends the items, totals and payments of a sale.
+func (h *Handlers) receiptPDF(w http.ResponseWriter, receipt *Receipt) {
+	doc := h.newPDF(fmt.Sprintf("Sale No. %d", receipt.ID))
+	doc.Columns("Date: "+receipt.CreatedAt.Format("02 Jan 2006 15:04"), "Cashier: "+receipt.Cashier)
+	doc.Space(8)
+
+	rows := make([][]string, 0, len(receipt.Items))
+	for _, item := range receipt.Items {
+		tax := "-"
+		if item.TaxClassID != nil {
+			tax = fmt.Sprintf("%s (%g%%)", CurrencyF64(item.Tax), item.TaxRate)
+		}
+
+		rows = append(rows, []string{
+			item.GenericName + " " + item.BrandName,
+			fmt.Sprint(item.Quantity),
+			CurrencyF64(item.UnitPrice),
+			CurrencyF64(item.Discount),
+			tax,
+			CurrencyF64(lineAmountDue(item.LineTotal, item.Tax, item.TaxInclusive)),
+		})
+	}
+
+	doc.Table([]pdf.Column{
+		{Header: "Product", Width: 5},
+		{Header: "Qty", Width: 1, Align: pdf.Right},
+		{Header: "Unit Price", Width: 2, Align: pdf.Right},
+		{Header: "Discount", Width: 2, Align: pdf.Right},
+		{Header: "Tax", Width: 2, Align: pdf.Right},
+		{Header: "Amount", Width: 2, Align: pdf.Right},
+	}, rows, []string{"Total", "", "", CurrencyF64(receipt.Discount), CurrencyF64(receipt.Tax), CurrencyF64(receipt.Total)})
+
+	if len(receipt.Payments) > 0 {
+		payments := make([][]string, 0, len(receipt.Payments))
+		for _, payment := range receipt.Payments {
+			payments = append(payments, []string{
+				humanize(payment.Method),
+				payment.Reference,
+				CurrencyF64(payment.Tendered),
+				CurrencyF64(payment.Amount),
+				CurrencyF64(payment.Change),
+			})
+		}
+
+		doc.Space(8)
+		doc.Table([]pdf.Column{
+			{Header: "Payment", Width: 3},
+			{Header: "Reference", Width: 4},
+			{Header: "Tendered", Width: 2, Align: pdf.Right},
+			{Header: "Amount", Width: 2, Align: pdf.Right},
+			{Header: "Change", Width: 2, Align: pdf.Right},
+		}, payments, nil)
+	}
+
+	sendPDF(w, doc, fmt.Sprintf("sale-%d.pdf", receipt.ID))
+}
+
+// salesReportPDF sends a product sales report. The discount column
+// is only shown if withDiscount is set.
+func (h *Handlers) salesReportPDF(w http.ResponseWriter, title, filename string, sales []SalesReportRow, summary SalesSummary, withDiscount bool) {
+	doc := h.newPDF(title)
+
+	columns := []pdf.Column{
+		{Header: "ID", Width: 1},
+		{Header: "Product", Width: 5},
+		{Header: "Qty", Width: 1.2, Align: pdf.Right},
+		{Header: "Unit Cost", Width: 2, Align: pdf.Right},
+		{Header: "Unit Price", Width: 2, Align: pdf.Right},
+	}
+	if withDiscount {
+		columns = append(columns, pdf.Column{Header: "Discount", Width: 2, Align: pdf.Right})
+	}
+	columns = append(columns,
+		pdf.Column{Header: "Income", Width: 2.2, Align: pdf.Right},
+		pdf.Column{Header: "Cost", Width: 2.2, Align: pdf.Right},
+		pdf.Column{Header: "Profit", Width: 2.2, Align: pdf.Right},
+		pdf.Column{Header: "Margin", Width: 1.4, Align: pdf.Right},
+	)
+
+	rows := make([][]string, 0, len(sales))
+	for _, sale := range sales {
+		row := []string{
+			fmt.Sprint(sale.ProductID),
+			sale.ProductName,
+			fmt.Sprint(sale.QuantitySold),
+			CurrencyF64(sale.CostPrice),
+			CurrencyF64(sale.SellingPrice),
+		}
+		if withDiscount {
+			row = append(row, CurrencyF64(sale.Discount))
+		}
+		row = append(row,
+			CurrencyF64(sale.Income),
+			CurrencyF64(sale.Cost),
+			CurrencyF64(sale.Profit),
+			CurrencyF64(grossMargin(sale.Profit, sale.Income))+"%",
+		)
+		rows = append(rows, row)
+	}
+
+	footer := []string{"Total", "", "", "", ""}
+	if withDiscount {
+		footer = append(footer, CurrencyF64(summary.Discount))
+	}
+	footer = append(footer,
+		CurrencyF64(summary.Income),
+		CurrencyF64(summary.Cost),
+		CurrencyF64(summary.Profit),
+		CurrencyF64(summary.GrossMargin())+"%",
+	)
+
+	doc.SetFont(false, 8)
+	doc.Table(columns, rows, footer)
+	sendPDF(w, doc, filename)
+}
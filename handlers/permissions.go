@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"slices"
+
+	"github.com/abiiranathan/epharmacy/epharma"
+)
+
+// Permission is an action a user role may be allowed to perform.
+type Permission string
+
+const (
+	PermissionSell           Permission = "sales.create"
+	PermissionViewSales      Permission = "sales.view"
+	PermissionCancelSales    Permission = "sales.delete"
+	PermissionViewProducts   Permission = "products.view"
+	PermissionEditProducts   Permission = "products.edit"
+	PermissionDeleteProducts Permission = "products.delete"
+	PermissionViewInvoices   Permission = "invoices.view"
+	PermissionEditInvoices   Permission = "invoices.edit"
+	PermissionDeleteInvoices Permission = "invoices.delete"
+	PermissionReceiveStock   Permission = "stock.receive"
+	PermissionAdjustStock    Permission = "stock.adjust"
+	PermissionApproveStock   Permission = "stock.approve"
+	PermissionViewReports    Permission = "reports.view"
+	PermissionManageUsers    Permission = "users.manage"
+)
+
+// All permissions in the order they are displayed.
+var allPermissions = []Permission{
+	PermissionSell,
+	PermissionViewSales,
+	PermissionCancelSales,
+	PermissionViewProducts,
+	PermissionEditProducts,
+	PermissionDeleteProducts,
+	PermissionViewInvoices,
+	PermissionEditInvoices,
+	PermissionDeleteInvoices,
+	PermissionReceiveStock,
+	PermissionAdjustStock,
+	PermissionApproveStock,
+	PermissionViewReports,
+	PermissionManageUsers,
+}
+
+var cashierPermissions = []Permission{
+	PermissionSell,
+	PermissionViewSales,
+	PermissionViewProducts,
+}
+
+var pharmacistPermissions = append(slices.Clone(cashierPermissions),
+	PermissionCancelSales,
+	PermissionEditProducts,
+	PermissionAdjustStock,
+)
+
+var storeKeeperPermissions = []Permission{
+	PermissionViewProducts,
+	PermissionEditProducts,
+	PermissionViewInvoices,
+	PermissionEditInvoices,
+	PermissionReceiveStock,
+	PermissionAdjustStock,
+}
+
+// Permission matrix. Admin has every permission.
+var rolePermissions = map[epharma.UserRole][]Permission{
+	epharma.UserRoleCashier:     cashierPermissions,
+	epharma.UserRolePharmacist:  pharmacistPermissions,
+	epharma.UserRoleStoreKeeper: storeKeeperPermissions,
+	epharma.UserRoleManager: slices.DeleteFunc(slices.Clone(allPermissions), func(p Permission) bool {
+		return p == PermissionManageUsers
+	}),
+	epharma.UserRoleAdmin: allPermissions,
+}
+
+// HasPermission reports whether the role is allowed the permission.
+func HasPermission(role epharma.UserRole, permission Permission) bool {
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// userCan is used in templates to show or hide actions.
+// user is the logged in user and is nil on public pages.
+func userCan(user any, permission string) bool {
+	u, ok := user.(epharma.User)
+	if !ok {
+		return false
+	}
+	return HasPermission(u.Role, Permission(permission))
+}
+
+// Row of the permission matrix displayed on the accounts page.
+type PermissionRow struct {
+	Permission Permission
+	Roles      []bool // Indexed like epharma.AllUserRoleValues()
+}
+
+func permissionMatrix() []PermissionRow {
+	roles := epharma.AllUserRoleValues()
+	matrix := make([]PermissionRow, 0, len(allPermissions))
+	for _, p := range allPermissions {
+		row := PermissionRow{Permission: p, Roles: make([]bool, len(roles))}
+		for i, role := range roles {
+			row.Roles[i] = HasPermission(role, p)
+		}
+		matrix = append(matrix, row)
+	}
+	return matrix
+}
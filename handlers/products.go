@@ -3,6 +3,7 @@ package handlers
 import (
 	"bytes"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,11 +13,26 @@ import (
 	"github.com/abiiranathan/dbtypes"
 	"github.com/abiiranathan/egor/egor"
 	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // RenderProductCreatePage
 func (h *Handlers) RenderProductCreatePage(w http.ResponseWriter, r *http.Request) {
+	taxClasses, err := h.Queries.ListTaxClasses(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err)
+		return
+	}
+
+	suppliers, err := h.Queries.ListSuppliers(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err)
+		return
+	}
+
 	egor.Render(w, r, "products/create.html", egor.Map{
+		"taxClasses": taxClasses,
+		"suppliers":  suppliers,
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Products", URL: "/products"},
 			{Label: "Create Product", IsLast: true},
@@ -33,8 +49,36 @@ func (h *Handlers) RenderProductUpdatePage(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	taxClasses, err := h.Queries.ListTaxClasses(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err)
+		return
+	}
+
+	// The selected tax class, 0 if the product is not taxed.
+	var taxClassID int32
+	if product.TaxClassID != nil {
+		taxClassID = *product.TaxClassID
+	}
+
+	suppliers, err := h.Queries.ListSuppliers(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err)
+		return
+	}
+
+	// The preferred supplier, 0 if there is none.
+	var supplierID int32
+	if product.PreferredSupplierID != nil {
+		supplierID = *product.PreferredSupplierID
+	}
+
 	egor.Render(w, r, "products/update.html", egor.Map{
-		"product": product,
+		"product":    product,
+		"taxClasses": taxClasses,
+		"taxClassID": taxClassID,
+		"suppliers":  suppliers,
+		"supplierID": supplierID,
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Products", URL: "/products"},
 			{Label: product.GenericName, URL: fmt.Sprintf("/products/view/%d", product.ID)},
@@ -199,6 +243,30 @@ func (h *Handlers) ListProductsPaginated(w http.ResponseWriter, r *http.Request)
 	limit := egor.QueryInt(r, "limit", 50)
 	name := r.URL.Query().Get("name")
 
+	if format := exportFormat(r); format != "" {
+		headers := []string{"Product ID", "Generic Name", "Brand Name", "Quantity", "Cost Price",
+			"Selling Price", "Barcode", "Expiry Dates"}
+
+		exportRows(w, r, format, "products", headers, func(offset int32) ([][]any, bool, error) {
+			products, err := h.Queries.ListProductsPaginated(r.Context(), epharma.ListProductsPaginatedParams{
+				Off:  offset,
+				Lim:  exportBatchSize,
+				Name: name,
+			})
+			if err != nil {
+				return nil, false, err
+			}
+
+			rows := make([][]any, 0, len(products))
+			for _, p := range products {
+				rows = append(rows, []any{p.ID, p.GenericName, p.BrandName, p.Quantity, p.CostPrice,
+					p.SellingPrice, p.Barcode, joinDates(p.ExpiryDates)})
+			}
+			return rows, len(products) == exportBatchSize, nil
+		})
+		return
+	}
+
 	if page < 1 {
 		page = 1
 	}
@@ -252,8 +320,37 @@ func (h *Handlers) GetProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	batches, err := h.Queries.ListProductBatches(r.Context(), product.ID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	var taxClass *epharma.TaxClass
+	if product.TaxClassID != nil {
+		class, err := h.Queries.GetTaxClass(r.Context(), *product.TaxClassID)
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		taxClass = &class
+	}
+
+	var supplier *epharma.Supplier
+	if product.PreferredSupplierID != nil {
+		s, err := h.Queries.GetSupplier(r.Context(), *product.PreferredSupplierID)
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		supplier = &s
+	}
+
 	egor.Render(w, r, "products/view.html", egor.Map{
-		"product": product,
+		"product":  product,
+		"batches":  batches,
+		"taxClass": taxClass,
+		"supplier": supplier,
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Products", URL: "/products"},
 			{Label: product.GenericName, IsLast: true},
@@ -272,8 +369,9 @@ func (h *Handlers) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The quantity is not changed here. Stock is changed by
+	// sales, stock in and approved stock adjustments.
 	params.ID = int32(productID)
-
 	err = h.Queries.UpdateProduct(r.Context(), params)
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
@@ -287,6 +385,12 @@ func (h *Handlers) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	productID := egor.ParamInt(r, "id")
 	err := h.Queries.DeleteProduct(r.Context(), int32(productID))
 	if err != nil {
+		// Sales and purchase orders keep their products.
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			egor.SendError(w, r, fmt.Errorf("the product has been sold or ordered and can not be deleted"), http.StatusConflict)
+			return
+		}
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
 	}
@@ -0,0 +1,599 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/abiiranathan/dbtypes"
+	"github.com/abiiranathan/egor/egor"
+	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/abiiranathan/epharmacy/pdf"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PurchaseOrderLine is a product on a purchase order with what was
+// received against it.
+type PurchaseOrderLine struct {
+	epharma.ListPurchaseOrderItemsRow
+}
+
+// OrderedValue is the quantity ordered at the unit cost agreed.
+func (l PurchaseOrderLine) OrderedValue() float64 {
+	return float64(l.Quantity) * l.UnitCost
+}
+
+// Outstanding is the quantity still to be delivered, 0 if the line
+// was delivered in full or in excess.
+func (l PurchaseOrderLine) Outstanding() int32 {
+	return max(l.Quantity-l.QuantityReceived, 0)
+}
+
+// Variance is the quantity received less the quantity ordered. It is
+// negative for an under-delivery and positive for an over-delivery.
+func (l PurchaseOrderLine) Variance() int32 {
+	return l.QuantityReceived - l.Quantity
+}
+
+// PurchaseOrderTotals sums the lines of a purchase order.
+type PurchaseOrderTotals struct {
+	Ordered  float64 // Value of the quantities ordered.
+	Received float64 // Value of the stock received at the cost received.
+	Over     int     // Lines delivered in excess.
+	Under    int     // Lines not delivered in full.
+}
+
+// purchaseOrderLines reads the lines of a purchase order and their totals.
+func purchaseOrderLines(r *http.Request, q *epharma.Queries, id int32) ([]PurchaseOrderLine, PurchaseOrderTotals, error) {
+	var totals PurchaseOrderTotals
+	items, err := q.ListPurchaseOrderItems(r.Context(), id)
+	if err != nil {
+		return nil, totals, err
+	}
+
+	lines := make([]PurchaseOrderLine, 0, len(items))
+	for _, item := range items {
+		line := PurchaseOrderLine{item}
+		totals.Ordered += line.OrderedValue()
+		totals.Received += line.ReceivedValue
+		if line.Variance() > 0 {
+			totals.Over++
+		} else if line.Variance() < 0 {
+			totals.Under++
+		}
+		lines = append(lines, line)
+	}
+	return lines, totals, nil
+}
+
+// ListPurchaseOrders renders the latest purchase orders. They are filtered
+// by status and supplier_id if given.
+func (h *Handlers) ListPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	status := egor.Query(r, "status")
+	supplierID := egor.QueryInt(r, "supplier_id", 0)
+
+	orders, err := h.Queries.ListPurchaseOrders(r.Context(), epharma.ListPurchaseOrdersParams{
+		Status:     status,
+		SupplierID: int32(supplierID),
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	suppliers, err := h.Queries.ListSuppliers(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	egor.Render(w, r, "purchaseorders/list", egor.Map{
+		"orders":     orders,
+		"suppliers":  suppliers,
+		"statuses":   epharma.AllPurchaseOrderStatusValues(),
+		"status":     status,
+		"supplierID": int32(supplierID),
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Invoices", URL: "/invoices"},
+			{Label: "Purchase Orders", IsLast: true},
+		},
+	})
+}
+
+// CreatePurchaseOrder opens a draft purchase order for a supplier.
+func (h *Handlers) CreatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	user := egor.GetContextValue(r, "user").(epharma.User)
+	supplierID, err := strconv.ParseInt(r.FormValue("supplier_id"), 10, 32)
+	if err != nil || supplierID <= 0 {
+		egor.SendError(w, r, fmt.Errorf("supplier is required"), http.StatusBadRequest)
+		return
+	}
+
+	params := epharma.CreatePurchaseOrderParams{
+		SupplierID: int32(supplierID),
+		Note:       strings.TrimSpace(r.FormValue("note")),
+		CreatedBy:  user.ID,
+	}
+
+	if value := r.FormValue("expected_date"); value != "" {
+		date, err := dbtypes.ParseDateFromString(value)
+		if err != nil {
+			egor.SendError(w, r, fmt.Errorf("invalid expected date: %w", err), http.StatusBadRequest)
+			return
+		}
+		params.ExpectedDate = date
+	}
+
+	order, err := h.Queries.CreatePurchaseOrder(r.Context(), params)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			egor.SendError(w, r, fmt.Errorf("supplier not found"), http.StatusBadRequest)
+			return
+		}
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d", order.ID), http.StatusSeeOther)
+}
+
+// GetPurchaseOrder renders a purchase order with the quantities received
+// against each line. With format=pdf the order is downloaded to be sent
+// to the supplier.
+func (h *Handlers) GetPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.Queries.GetPurchaseOrder(r.Context(), int32(egor.ParamInt(r, "id")))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	lines, totals, err := purchaseOrderLines(r, h.Queries, order.ID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if egor.Query(r, "format") == "pdf" {
+		h.purchaseOrderPDF(w, order, lines, totals)
+		return
+	}
+
+	egor.Render(w, r, "purchaseorders/view", egor.Map{
+		"order":  order,
+		"lines":  lines,
+		"totals": totals,
+		"today":  currentDate(),
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Purchase Orders", URL: "/purchase-orders"},
+			{Label: fmt.Sprintf("PO #%d", order.ID), IsLast: true},
+		},
+	})
+}
+
+// purchaseOrderPDF sends a purchase order with the products and quantities ordered.
+func (h *Handlers) purchaseOrderPDF(w http.ResponseWriter, order epharma.GetPurchaseOrderRow,
+	lines []PurchaseOrderLine, totals PurchaseOrderTotals) {
+	doc := h.newPDF(fmt.Sprintf("Purchase Order No. %d", order.ID))
+	expected := "-"
+	if !order.ExpectedDate.IsZero() {
+		expected = order.ExpectedDate.Format("02 Jan 2006")
+	}
+	doc.Columns("Supplier: "+order.SupplierName, "Date: "+order.CreatedAt.Format("02 Jan 2006"))
+	doc.Columns("Expected delivery: "+expected, "Ordered by: "+order.CreatedByUsername)
+	if order.Note != "" {
+		doc.Text(order.Note, pdf.Left)
+	}
+	doc.Space(8)
+
+	rows := make([][]string, 0, len(lines))
+	for _, line := range lines {
+		rows = append(rows, []string{
+			line.GenericName + " " + line.BrandName,
+			fmt.Sprint(line.Quantity),
+			CurrencyF64(line.UnitCost),
+			CurrencyF64(line.OrderedValue()),
+		})
+	}
+
+	doc.Table([]pdf.Column{
+		{Header: "Product", Width: 6},
+		{Header: "Qty", Width: 1, Align: pdf.Right},
+		{Header: "Unit Cost", Width: 2, Align: pdf.Right},
+		{Header: "Amount", Width: 2, Align: pdf.Right},
+	}, rows, []string{"Total", "", "", CurrencyF64(totals.Ordered)})
+
+	sendPDF(w, doc, fmt.Sprintf("purchase-order-%d.pdf", order.ID))
+}
+
+// requireDraftPurchaseOrder sends an error and returns false unless the
+// purchase order is a draft whose lines can still be changed.
+func (h *Handlers) requireDraftPurchaseOrder(w http.ResponseWriter, r *http.Request, id int32) bool {
+	order, err := h.Queries.GetPurchaseOrder(r.Context(), id)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return false
+	}
+
+	if order.Status != epharma.PurchaseOrderStatusDraft {
+		egor.SendError(w, r, fmt.Errorf("the purchase order has been sent and can not be changed"), http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// AddPurchaseOrderItem adds a product to a draft purchase order. The unit
+// cost defaults to the cost price of the product.
+func (h *Handlers) AddPurchaseOrderItem(w http.ResponseWriter, r *http.Request) {
+	id := int32(egor.ParamInt(r, "id"))
+	if !h.requireDraftPurchaseOrder(w, r, id) {
+		return
+	}
+
+	productID, err := strconv.ParseInt(r.FormValue("product_id"), 10, 32)
+	if err != nil || productID <= 0 {
+		egor.SendError(w, r, fmt.Errorf("product is required"), http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.Queries.GetProduct(r.Context(), int32(productID))
+	if err != nil {
+		egor.SendError(w, r, fmt.Errorf("product not found"), http.StatusNotFound)
+		return
+	}
+
+	quantity, err := parseQuantity(r.FormValue("quantity"))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	if quantity <= 0 {
+		egor.SendError(w, r, fmt.Errorf("quantity must be more than 0"), http.StatusBadRequest)
+		return
+	}
+
+	unitCost := product.CostPrice
+	if value := strings.ReplaceAll(strings.TrimSpace(r.FormValue("unit_cost")), ",", ""); value != "" {
+		unitCost, err = strconv.ParseFloat(value, 64)
+		if err != nil || unitCost < 0 {
+			egor.SendError(w, r, fmt.Errorf("invalid unit cost: %q", r.FormValue("unit_cost")), http.StatusBadRequest)
+			return
+		}
+	}
+
+	_, err = h.Queries.AddPurchaseOrderItem(r.Context(), epharma.AddPurchaseOrderItemParams{
+		PurchaseOrderID: id,
+		ProductID:       product.ID,
+		Quantity:        quantity,
+		UnitCost:        unitCost,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d", id), http.StatusSeeOther)
+}
+
+// DeletePurchaseOrderItem removes a product from a draft purchase order.
+func (h *Handlers) DeletePurchaseOrderItem(w http.ResponseWriter, r *http.Request) {
+	id := int32(egor.ParamInt(r, "id"))
+	if !h.requireDraftPurchaseOrder(w, r, id) {
+		return
+	}
+
+	n, err := h.Queries.DeletePurchaseOrderItem(r.Context(), epharma.DeletePurchaseOrderItemParams{
+		ID:              int32(egor.ParamInt(r, "item_id")),
+		PurchaseOrderID: id,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if n == 0 {
+		egor.SendError(w, r, fmt.Errorf("line not found"), http.StatusNotFound)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d", id), http.StatusSeeOther)
+}
+
+// DeletePurchaseOrder deletes a draft purchase order.
+func (h *Handlers) DeletePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	n, err := h.Queries.DeletePurchaseOrder(r.Context(), int32(egor.ParamInt(r, "id")))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if n == 0 {
+		egor.SendError(w, r, fmt.Errorf("only a draft purchase order can be deleted"), http.StatusConflict)
+		return
+	}
+	egor.Redirect(w, r, "/purchase-orders", http.StatusSeeOther)
+}
+
+// SendPurchaseOrder marks a draft purchase order as sent to the supplier.
+// Its lines can not be changed after it is sent.
+func (h *Handlers) SendPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.Queries.SendPurchaseOrder(r.Context(), int32(egor.ParamInt(r, "id")))
+	if errors.Is(err, pgx.ErrNoRows) {
+		egor.SendError(w, r, fmt.Errorf("only a draft purchase order with products can be sent"), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d", order.ID), http.StatusSeeOther)
+}
+
+// receiptStatus is the status of a sent purchase order given the stock
+// received against its lines. It is closed once every line is delivered
+// in full, partially received once anything is, and otherwise sent.
+func receiptStatus(lines []PurchaseOrderLine) epharma.PurchaseOrderStatus {
+	status := epharma.PurchaseOrderStatusClosed
+	var received int32
+	for _, line := range lines {
+		received += line.QuantityReceived
+		if line.Outstanding() > 0 {
+			status = epharma.PurchaseOrderStatusPartiallyReceived
+		}
+	}
+
+	if received == 0 {
+		return epharma.PurchaseOrderStatusSent
+	}
+	return status
+}
+
+// lockPurchaseOrder locks a purchase order for the rest of the transaction
+// so that its status can not change under a receipt or a conversion. It
+// sends an error and returns false if the order can not be read.
+func lockPurchaseOrder(w http.ResponseWriter, r *http.Request, q *epharma.Queries, id int32) (epharma.PurchaseOrder, bool) {
+	order, err := q.GetPurchaseOrderForUpdate(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			egor.SendError(w, r, fmt.Errorf("purchase order not found"), http.StatusNotFound)
+			return order, false
+		}
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return order, false
+	}
+	return order, true
+}
+
+// ReceivePurchaseOrder records the stock delivered against the lines of a
+// sent purchase order. Each line is received with the fields quantity_<line>,
+// cost_price_<line>, batch_number_<line> and expiry_date_<line>; the cost
+// defaults to the unit cost ordered. Each delivery is a stock in with its own
+// batch. The order is closed once every line is delivered in full, otherwise
+// it is partially received. More than ordered may be received and is shown
+// as an over-delivery.
+func (h *Handlers) ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id := int32(egor.ParamInt(r, "id"))
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	order, ok := lockPurchaseOrder(w, r, qtx, id)
+	if !ok {
+		return
+	}
+
+	if order.Status != epharma.PurchaseOrderStatusSent && order.Status != epharma.PurchaseOrderStatusPartiallyReceived {
+		egor.SendError(w, r, fmt.Errorf("stock can not be received on a %s purchase order", humanize(order.Status)),
+			http.StatusConflict)
+		return
+	}
+
+	lines, _, err := purchaseOrderLines(r, qtx, order.ID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	var received int
+	for _, line := range lines {
+		field := func(name string) string {
+			return strings.TrimSpace(r.FormValue(fmt.Sprintf("%s_%d", name, line.ID)))
+		}
+
+		quantity, err := parseQuantity(field("quantity"))
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusBadRequest)
+			return
+		}
+
+		if quantity < 0 {
+			egor.SendError(w, r, fmt.Errorf("quantity can not be negative"), http.StatusBadRequest)
+			return
+		}
+
+		if quantity == 0 {
+			continue
+		}
+
+		stockin := epharma.ReceiveStockInParams{
+			ProductID:           line.ProductID,
+			PurchaseOrderItemID: line.ID,
+			Quantity:            quantity,
+			CostPrice:           line.UnitCost,
+			BatchNumber:         field("batch_number"),
+			Comment:             fmt.Sprintf("PO #%d", order.ID),
+		}
+
+		if value := strings.ReplaceAll(field("cost_price"), ",", ""); value != "" {
+			stockin.CostPrice, err = strconv.ParseFloat(value, 64)
+			if err != nil || stockin.CostPrice <= 0 {
+				egor.SendError(w, r, fmt.Errorf("invalid cost of %s: %q", line.GenericName, value), http.StatusBadRequest)
+				return
+			}
+		}
+
+		stockin.ExpiryDate, err = dbtypes.ParseDateFromString(field("expiry_date"))
+		if err != nil {
+			egor.SendError(w, r, fmt.Errorf("the expiry date of %s is required", line.GenericName), http.StatusBadRequest)
+			return
+		}
+
+		stock, err := qtx.ReceiveStockIn(r.Context(), stockin)
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		_, err = qtx.CreateProductBatch(r.Context(), epharma.CreateProductBatchParams{
+			ProductID:   stock.ProductID,
+			StockInID:   &stock.ID,
+			BatchNumber: stock.BatchNumber,
+			ExpiryDate:  stock.ExpiryDate,
+			Quantity:    stock.Quantity,
+			CostPrice:   stock.CostPrice,
+		})
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		received++
+	}
+
+	if received == 0 {
+		egor.SendError(w, r, fmt.Errorf("enter the quantity received of at least one product"), http.StatusBadRequest)
+		return
+	}
+
+	lines, _, err = purchaseOrderLines(r, qtx, order.ID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	_, err = qtx.SetPurchaseOrderStatus(r.Context(), epharma.SetPurchaseOrderStatusParams{
+		Status: receiptStatus(lines),
+		ID:     order.ID,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d", order.ID), http.StatusSeeOther)
+}
+
+// ClosePurchaseOrder closes a sent purchase order that will not be
+// delivered in full. What was not delivered is left as an under-delivery.
+func (h *Handlers) ClosePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.Queries.SetPurchaseOrderStatus(r.Context(), epharma.SetPurchaseOrderStatusParams{
+		Status: epharma.PurchaseOrderStatusClosed,
+		ID:     int32(egor.ParamInt(r, "id")),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		egor.SendError(w, r, fmt.Errorf("only a sent purchase order can be closed"), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d", order.ID), http.StatusSeeOther)
+}
+
+// ConvertPurchaseOrder records the supplier's invoice for a closed purchase
+// order. The invoice total is the value of the stock received and the stock
+// received against the order is put on the invoice.
+func (h *Handlers) ConvertPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	user := egor.GetContextValue(r, "user").(epharma.User)
+	invoiceNumber := strings.TrimSpace(r.FormValue("invoice_number"))
+	if invoiceNumber == "" {
+		egor.SendError(w, r, fmt.Errorf("invoice number is required"), http.StatusBadRequest)
+		return
+	}
+
+	purchaseDate := currentDate()
+	if value := r.FormValue("purchase_date"); value != "" {
+		date, err := dbtypes.ParseDateFromString(value)
+		if err != nil {
+			egor.SendError(w, r, fmt.Errorf("invalid purchase date: %w", err), http.StatusBadRequest)
+			return
+		}
+		purchaseDate = date
+	}
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	order, ok := lockPurchaseOrder(w, r, qtx, int32(egor.ParamInt(r, "id")))
+	if !ok {
+		return
+	}
+
+	if order.Status != epharma.PurchaseOrderStatusClosed || order.InvoiceID != nil {
+		egor.SendError(w, r, fmt.Errorf("only a closed purchase order without an invoice can be converted"),
+			http.StatusConflict)
+		return
+	}
+
+	_, totals, err := purchaseOrderLines(r, qtx, order.ID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if totals.Received <= 0 {
+		egor.SendError(w, r, fmt.Errorf("no stock was received against the purchase order"), http.StatusConflict)
+		return
+	}
+
+	invoice, err := qtx.CreateInvoice(r.Context(), epharma.CreateInvoiceParams{
+		InvoiceNumber: invoiceNumber,
+		PurchaseDate:  purchaseDate,
+		InvoiceTotal:  totals.Received,
+		SupplierID:    order.SupplierID,
+		UserID:        user.ID,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	_, err = qtx.SetPurchaseOrderInvoice(r.Context(), epharma.SetPurchaseOrderInvoiceParams{
+		InvoiceID: invoice.ID,
+		ID:        order.ID,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	_, err = qtx.InvoicePurchaseOrderStock(r.Context(), epharma.InvoicePurchaseOrderStockParams{
+		InvoiceID:       invoice.ID,
+		PurchaseOrderID: order.ID,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/invoices/view/%d", invoice.ID), http.StatusSeeOther)
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/abiiranathan/egor/egor"
+	"github.com/abiiranathan/epharmacy/epharma"
+)
+
+// Characters per line of the receipt printer font on each paper width in mm.
+var receiptWidths = map[int]int{
+	80: 48,
+	58: 32,
+}
+
+// Receipt is a sale as printed for the customer.
+type Receipt struct {
+	Transaction
+	Pharmacy Pharmacy
+	Cashier  string
+	Paper    int // Paper width in mm
+	Subtotal float64
+	Discount float64
+	Tax      float64
+	Total    float64
+}
+
+// loadReceipt loads the sale with the given id and works out its totals.
+func (h *Handlers) loadReceipt(ctx context.Context, id int32, paper int) (*Receipt, error) {
+	transaction, err := h.Queries.GetTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := loadTransactions(ctx, h.Queries, []epharma.Transaction{transaction})
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &Receipt{
+		Transaction: result[0],
+		Pharmacy:    h.Pharmacy,
+		Paper:       paper,
+	}
+
+	if receipt.Pharmacy.Name == "" {
+		receipt.Pharmacy.Name = "EPharmacy"
+	}
+
+	if location, err := time.LoadLocation(timezone); err == nil {
+		receipt.CreatedAt = receipt.CreatedAt.In(location)
+	}
+
+	cashier, err := h.Queries.GetUser(ctx, transaction.UserID)
+	if err == nil {
+		receipt.Cashier = cashier.Username
+	}
+
+	for _, item := range receipt.Items {
+		receipt.Subtotal += float64(item.Quantity) * item.UnitPrice
+		receipt.Discount += item.Discount
+		receipt.Tax += item.Tax
+		receipt.Total += lineAmountDue(item.LineTotal, item.Tax, item.TaxInclusive)
+	}
+	return receipt, nil
+}
+
+// escpos renders the receipt as ESC/POS bytes.
+func (receipt *Receipt) escpos() []byte {
+	p := newEscpos(receiptWidths[receipt.Paper])
+
+	p.center()
+	p.bold(true)
+	p.large(true)
+	p.text(receipt.Pharmacy.Name)
+	p.large(false)
+	p.bold(false)
+	for _, line := range []string{receipt.Pharmacy.Address, receipt.Pharmacy.Phone} {
+		if line != "" {
+			p.text(line)
+		}
+	}
+	if receipt.Pharmacy.TIN != "" {
+		p.text("TIN: " + receipt.Pharmacy.TIN)
+	}
+
+	p.left()
+	p.rule()
+	p.columns(fmt.Sprintf("Receipt #%d", receipt.ID), receipt.CreatedAt.Format("02 Jan 2006 15:04"))
+	if receipt.Cashier != "" {
+		p.text("Cashier: " + receipt.Cashier)
+	}
+	p.rule()
+
+	for _, item := range receipt.Items {
+		p.text(item.GenericName)
+		p.columns(fmt.Sprintf("  %d x %s", item.Quantity, CurrencyF64(item.UnitPrice)),
+			CurrencyF64(float64(item.Quantity)*item.UnitPrice))
+		if item.Discount > 0 {
+			p.columns("  Discount", "-"+CurrencyF64(item.Discount))
+		}
+		if item.Tax > 0 {
+			label := fmt.Sprintf("  Tax %g%%", item.TaxRate)
+			if item.TaxInclusive {
+				label += " (incl.)"
+			}
+			p.columns(label, CurrencyF64(item.Tax))
+		}
+	}
+
+	p.rule()
+	p.columns("Subtotal", CurrencyF64(receipt.Subtotal))
+	if receipt.Discount > 0 {
+		p.columns("Discount", "-"+CurrencyF64(receipt.Discount))
+	}
+	if receipt.Tax > 0 {
+		p.columns("Tax", CurrencyF64(receipt.Tax))
+	}
+	p.bold(true)
+	p.columns("TOTAL", CurrencyF64(receipt.Total))
+	p.bold(false)
+
+	if len(receipt.Payments) > 0 {
+		p.rule()
+		for _, payment := range receipt.Payments {
+			label := humanize(payment.Method)
+			if payment.Reference != "" {
+				label += " " + payment.Reference
+			}
+			p.columns(label, CurrencyF64(payment.Tendered))
+		}
+		p.columns("Change", CurrencyF64(receipt.Change))
+	}
+
+	p.rule()
+	p.center()
+	p.text("Thank you. Get well soon!")
+	p.cut()
+	return p.Bytes()
+}
+
+// GetReceipt renders the receipt of a sale for an 80mm or 58mm thermal printer,
+// as a printable page or with format=escpos as raw ESC/POS bytes that can be
+// sent to a USB printer e.g by copying them to /dev/usb/lp0.
+func (h *Handlers) GetReceipt(w http.ResponseWriter, r *http.Request) {
+	paper := egor.QueryInt(r, "paper", 80)
+	if _, ok := receiptWidths[paper]; !ok {
+		egor.SendError(w, r, fmt.Errorf("unsupported paper width: %dmm", paper), http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := h.loadReceipt(r.Context(), int32(egor.ParamInt(r, "id")), paper)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	switch format := egor.Query(r, "format"); format {
+	case "", "html":
+		w.Header().Set("Content-Type", egor.ContentTypeHTML)
+		err = egor.ExecuteTemplate(w, r, "transactions/receipt.html", egor.Map{
+			"receipt": receipt,
+			"print":   egor.Query(r, "print") != "",
+		})
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+		}
+	case "escpos":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=receipt-%d.bin", receipt.ID))
+		w.Write(receipt.escpos())
+	default:
+		egor.SendError(w, r, fmt.Errorf("unsupported receipt format: %q", format), http.StatusBadRequest)
+	}
+}
+
+// PrintReceipt sends the receipt of a sale to the network receipt printer.
+func (h *Handlers) PrintReceipt(w http.ResponseWriter, r *http.Request) {
+	if h.ReceiptPrinter == "" {
+		egor.SendError(w, r, fmt.Errorf("no receipt printer is configured"), http.StatusServiceUnavailable)
+		return
+	}
+
+	paper := egor.QueryInt(r, "paper", 80)
+	if _, ok := receiptWidths[paper]; !ok {
+		egor.SendError(w, r, fmt.Errorf("unsupported paper width: %dmm", paper), http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := h.loadReceipt(r.Context(), int32(egor.ParamInt(r, "id")), paper)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	conn, err := net.DialTimeout("tcp", h.ReceiptPrinter, 5*time.Second)
+	if err != nil {
+		egor.SendError(w, r, fmt.Errorf("unable to reach the receipt printer: %w", err), http.StatusBadGateway)
+		return
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Write(receipt.escpos()); err != nil {
+		egor.SendError(w, r, fmt.Errorf("printing the receipt failed: %w", err), http.StatusBadGateway)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/transactions/%d", receipt.ID), http.StatusSeeOther)
+}
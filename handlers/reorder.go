@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abiiranathan/dbtypes"
+	"github.com/abiiranathan/egor/egor"
+	"github.com/abiiranathan/epharmacy/epharma"
+)
+
+// Default and longest window of sales the velocity of a product is averaged over.
+const (
+	defaultVelocityDays = 30
+	maxVelocityDays     = 365
+)
+
+// ReorderLine is a product to reorder with the quantity suggested.
+type ReorderLine struct {
+	epharma.ReorderCandidatesRow
+	Velocity     float64 // Average units sold a day over the window.
+	ReorderPoint int32   // Stock at or below which the product is reordered.
+	Suggested    int32   // Quantity suggested to order.
+}
+
+// Value is the suggested quantity at the cost price of the product.
+func (l ReorderLine) Value() float64 {
+	return float64(l.Suggested) * l.CostPrice
+}
+
+// Position is the quantity in stock and still to be delivered on purchase orders.
+func (l ReorderLine) Position() int32 {
+	return l.Quantity + l.OnOrder
+}
+
+// suggestReorder works out whether a product should be reordered given its
+// sales over the last days. The reorder point is the reorder level of the
+// product or the sales expected over the lead time of its preferred supplier,
+// whichever is more. A product whose stock and quantity on order are at or
+// below its reorder point is reordered back up to it with enough for another
+// window of sales, and at least its reorder quantity.
+func suggestReorder(row epharma.ReorderCandidatesRow, days int) (ReorderLine, bool) {
+	line := ReorderLine{ReorderCandidatesRow: row}
+	line.Velocity = float64(row.UnitsSold) / float64(days)
+	line.ReorderPoint = max(row.ReorderLevel, int32(math.Ceil(line.Velocity*float64(row.LeadTimeDays))))
+	if line.ReorderPoint == 0 || line.Position() > line.ReorderPoint {
+		return line, false
+	}
+
+	demand := int32(math.Ceil(line.Velocity * float64(days)))
+	line.Suggested = max(line.ReorderPoint+demand-line.Position(), row.ReorderQuantity, 1)
+	return line, true
+}
+
+// ReorderGroup is the products to reorder from a supplier.
+type ReorderGroup struct {
+	SupplierID   int32 // 0 for products without a preferred supplier.
+	SupplierName string
+	Lines        []ReorderLine
+	Value        float64
+}
+
+// reorderGroups reads the products to reorder given the sales of the
+// last days, grouped by preferred supplier.
+func reorderGroups(r *http.Request, q *epharma.Queries, days int) ([]*ReorderGroup, error) {
+	from := dbtypes.Date(time.Time(currentDate()).AddDate(0, 0, 1-days))
+	rows, err := q.ReorderCandidates(r.Context(), from)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*ReorderGroup
+	for _, row := range rows {
+		line, ok := suggestReorder(row, days)
+		if !ok {
+			continue
+		}
+
+		var supplierID int32
+		if row.PreferredSupplierID != nil {
+			supplierID = *row.PreferredSupplierID
+		}
+
+		// The rows are ordered by supplier.
+		if len(groups) == 0 || groups[len(groups)-1].SupplierID != supplierID {
+			groups = append(groups, &ReorderGroup{SupplierID: supplierID, SupplierName: row.SupplierName})
+		}
+
+		group := groups[len(groups)-1]
+		group.Lines = append(group.Lines, line)
+		group.Value += line.Value()
+	}
+	return groups, nil
+}
+
+// parseVelocityDays reads the days of sales the velocity is averaged over.
+func parseVelocityDays(value string) (int, error) {
+	if value == "" {
+		return defaultVelocityDays, nil
+	}
+
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 || days > maxVelocityDays {
+		return 0, fmt.Errorf("days must be between 1 and %d", maxVelocityDays)
+	}
+	return days, nil
+}
+
+// ReorderReport lists the products at or below their reorder point with the
+// quantities suggested, grouped by preferred supplier. The velocity of sales
+// is averaged over the last days, 30 by default. With format=csv or xlsx the
+// report is downloaded.
+func (h *Handlers) ReorderReport(w http.ResponseWriter, r *http.Request) {
+	days, err := parseVelocityDays(egor.Query(r, "days"))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	groups, err := reorderGroups(r, h.Queries, days)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if format := exportFormat(r); format != "" {
+		headers := []string{"Supplier", "Product ID", "Product", "In Stock", "On Order", "Units Sold", "Daily Velocity",
+			"Reorder Level", "Reorder Point", "Suggested Qty", "Unit Cost", "Value"}
+		var rows [][]any
+		for _, group := range groups {
+			for _, line := range group.Lines {
+				rows = append(rows, []any{group.SupplierName, line.ID, line.GenericName + " " + line.BrandName,
+					line.Quantity, line.OnOrder, line.UnitsSold, math.Round(line.Velocity*100) / 100, line.ReorderLevel,
+					line.ReorderPoint, line.Suggested, line.CostPrice, line.Value()})
+			}
+		}
+		exportTable(w, r, format, fmt.Sprintf("reorder-%s", currentDate().Format("20060102")), headers, rows)
+		return
+	}
+
+	egor.Render(w, r, "reports/reorder.html", egor.Map{
+		"groups": groups,
+		"days":   days,
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Dashboard", URL: "/reports"},
+			{Label: "Reorder Suggestions", IsLast: true},
+		},
+	})
+}
+
+// CreateReorderPurchaseOrder opens a draft purchase order for the products
+// suggested to reorder from a supplier, at their cost prices. The order can
+// be changed before it is sent.
+func (h *Handlers) CreateReorderPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	user := egor.GetContextValue(r, "user").(epharma.User)
+	days, err := parseVelocityDays(r.FormValue("days"))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	supplierID := int32(egor.ParamInt(r, "supplier_id"))
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	groups, err := reorderGroups(r, qtx, days)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	var group *ReorderGroup
+	for _, g := range groups {
+		if g.SupplierID == supplierID && supplierID != 0 {
+			group = g
+			break
+		}
+	}
+
+	if group == nil {
+		egor.SendError(w, r, fmt.Errorf("nothing to reorder from this supplier"), http.StatusNotFound)
+		return
+	}
+
+	order, err := qtx.CreatePurchaseOrder(r.Context(), epharma.CreatePurchaseOrderParams{
+		SupplierID: group.SupplierID,
+		Note:       fmt.Sprintf("Reorder suggestions from %d days of sales", days),
+		CreatedBy:  user.ID,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	for _, line := range group.Lines {
+		_, err := qtx.AddPurchaseOrderItem(r.Context(), epharma.AddPurchaseOrderItemParams{
+			PurchaseOrderID: order.ID,
+			ProductID:       line.ID,
+			Quantity:        line.Suggested,
+			UnitCost:        line.CostPrice,
+		})
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d", order.ID), http.StatusSeeOther)
+}
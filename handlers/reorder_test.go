@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/abiiranathan/epharmacy/epharma"
+)
+
+func TestSuggestReorder(t *testing.T) {
+	tests := []struct {
+		name          string
+		row           epharma.ReorderCandidatesRow
+		days          int
+		wantReorder   bool
+		wantPoint     int32
+		wantSuggested int32
+	}{
+		{
+			name:          "no sales, below the reorder level",
+			row:           epharma.ReorderCandidatesRow{Quantity: 4, ReorderLevel: 10},
+			days:          30,
+			wantReorder:   true,
+			wantPoint:     10,
+			wantSuggested: 6,
+		},
+		{
+			name:          "no sales, at the reorder level",
+			row:           epharma.ReorderCandidatesRow{Quantity: 10, ReorderLevel: 10},
+			days:          30,
+			wantReorder:   true,
+			wantPoint:     10,
+			wantSuggested: 1,
+		},
+		{
+			name:      "no sales, above the reorder level",
+			row:       epharma.ReorderCandidatesRow{Quantity: 11, ReorderLevel: 10},
+			days:      30,
+			wantPoint: 10,
+		},
+		{
+			name:          "no sales, at least the reorder quantity",
+			row:           epharma.ReorderCandidatesRow{Quantity: 4, ReorderLevel: 10, ReorderQuantity: 24},
+			days:          30,
+			wantReorder:   true,
+			wantPoint:     10,
+			wantSuggested: 24,
+		},
+		{
+			name:      "no sales, on order above the reorder level",
+			row:       epharma.ReorderCandidatesRow{Quantity: 4, ReorderLevel: 10, OnOrder: 12},
+			days:      30,
+			wantPoint: 10,
+		},
+		{
+			name: "no sales and no reorder level",
+			row:  epharma.ReorderCandidatesRow{Quantity: 0, LeadTimeDays: 7},
+			days: 30,
+		},
+		{
+			name:          "sales over the lead time above the reorder level",
+			row:           epharma.ReorderCandidatesRow{Quantity: 10, ReorderLevel: 5, LeadTimeDays: 7, UnitsSold: 60},
+			days:          30,
+			wantReorder:   true,
+			wantPoint:     14,
+			wantSuggested: 64,
+		},
+		{
+			name:      "sales, stock above the reorder point",
+			row:       epharma.ReorderCandidatesRow{Quantity: 20, ReorderLevel: 5, LeadTimeDays: 7, UnitsSold: 60},
+			days:      30,
+			wantPoint: 14,
+		},
+		{
+			name:          "sales, stock and order below the reorder point",
+			row:           epharma.ReorderCandidatesRow{Quantity: 6, OnOrder: 4, LeadTimeDays: 7, UnitsSold: 60},
+			days:          30,
+			wantReorder:   true,
+			wantPoint:     14,
+			wantSuggested: 64,
+		},
+		{
+			name:          "fractional velocity rounded up",
+			row:           epharma.ReorderCandidatesRow{Quantity: 1, LeadTimeDays: 4, UnitsSold: 10},
+			days:          30,
+			wantReorder:   true,
+			wantPoint:     2,
+			wantSuggested: 11,
+		},
+		{
+			name:          "sales without a supplier lead time",
+			row:           epharma.ReorderCandidatesRow{Quantity: 2, ReorderLevel: 3, UnitsSold: 7},
+			days:          7,
+			wantReorder:   true,
+			wantPoint:     3,
+			wantSuggested: 8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, reorder := suggestReorder(tt.row, tt.days)
+			if reorder != tt.wantReorder {
+				t.Fatalf("suggestReorder() reorder = %v, want %v", reorder, tt.wantReorder)
+			}
+
+			if line.ReorderPoint != tt.wantPoint {
+				t.Errorf("reorder point = %d, want %d", line.ReorderPoint, tt.wantPoint)
+			}
+
+			if reorder && line.Suggested != tt.wantSuggested {
+				t.Errorf("suggested = %d, want %d", line.Suggested, tt.wantSuggested)
+			}
+		})
+	}
+}
+
+func TestParseVelocityDays(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"", defaultVelocityDays, false},
+		{"1", 1, false},
+		{"365", 365, false},
+		{"0", 0, true},
+		{"366", 0, true},
+		{"week", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseVelocityDays(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseVelocityDays(%q) = %d, %v, want %d, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -29,6 +29,45 @@ type AnnualIncome struct {
 	Income []float64
 }
 
+// Amount settled by each payment method over a period.
+type PaymentBreakdown struct {
+	Label  string
+	Totals []epharma.PaymentMethodTotalsRow
+	Total  float64
+}
+
+// fetchPaymentBreakdowns returns the payment method totals for today,
+// this month and this year.
+func (h *Handlers) fetchPaymentBreakdowns(ctx context.Context) ([]PaymentBreakdown, error) {
+	today := Now()
+	periods := []struct {
+		label string
+		from  time.Time
+	}{
+		{"Today", today},
+		{"This month", time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())},
+		{"This year", time.Date(today.Year(), 1, 1, 0, 0, 0, 0, today.Location())},
+	}
+
+	breakdowns := make([]PaymentBreakdown, 0, len(periods))
+	for _, period := range periods {
+		totals, err := h.Queries.PaymentMethodTotals(ctx, epharma.PaymentMethodTotalsParams{
+			FromDate: dbtypes.Date(period.from),
+			ToDate:   dbtypes.Date(today),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		breakdown := PaymentBreakdown{Label: period.label, Totals: totals}
+		for _, total := range totals {
+			breakdown.Total += total.Total
+		}
+		breakdowns = append(breakdowns, breakdown)
+	}
+	return breakdowns, nil
+}
+
 func (h *Handlers) fetchDashboard(ctx context.Context) (
 	dailyProductSales []epharma.SalesReport,
 	monthlyProductSales []epharma.MonthlySalesReportsRow,
@@ -86,6 +125,13 @@ func (h *Handlers) RenderReportsDashboard(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	paymentBreakdowns, err := h.fetchPaymentBreakdowns(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err)
+		log.Printf("Error fetching payment breakdown for dashboard: %v\n", err)
+		return
+	}
+
 	// Aggregate data for today, this week, this month, this year
 	var incomeToday, incomeThisWeek, incomeThisMonth, incomeThisYear float64
 
@@ -191,6 +237,8 @@ func (h *Handlers) RenderReportsDashboard(w http.ResponseWriter, r *http.Request
 		"incomeThisMonth": incomeThisMonth,
 		"incomeThisYear":  incomeThisYear,
 
+		"paymentBreakdowns": paymentBreakdowns,
+
 		"WeeklyIncome":  string(weeklyIncomeJSON),
 		"MonthlyIncome": string(monthlyIncomeJSON),
 		"AnnualIncome":  string(annualIncomeJSON),
@@ -200,6 +248,65 @@ func (h *Handlers) RenderReportsDashboard(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// Totals of a product sales report.
+// parseDateRange reads the from and to query parameters, yyyy-mm-dd.
+// They default to the first of the current month and today.
+func parseDateRange(r *http.Request) (from, to dbtypes.Date, err error) {
+	now := Now()
+	from = dbtypes.Date(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()))
+	to = dbtypes.Date(now)
+
+	if value := egor.Query(r, "from"); value != "" {
+		from, err = dbtypes.ParseDateFromString(value)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid from date: %w", err)
+		}
+	}
+
+	if value := egor.Query(r, "to"); value != "" {
+		to, err = dbtypes.ParseDateFromString(value)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid to date: %w", err)
+		}
+	}
+
+	if to.Before(from) {
+		return from, to, fmt.Errorf("the from date is after the to date")
+	}
+	return from, to, nil
+}
+
+type SalesSummary struct {
+	Discount float64 // Only reported on the daily sales
+	Income   float64
+	Cost     float64
+	Profit   float64
+}
+
+func (s *SalesSummary) add(income, cost, profit float64) {
+	s.Income += income
+	s.Cost += cost
+	s.Profit += profit
+}
+
+// GrossMargin is the profit as a percentage of income.
+func (s SalesSummary) GrossMargin() float64 {
+	return grossMargin(s.Profit, s.Income)
+}
+
+// SalesReportRow is a product's line on a sales report.
+type SalesReportRow struct {
+	ProductID    int32
+	ProductName  string
+	QuantitySold int64
+	CostPrice    float64
+	SellingPrice float64
+	Discount     float64
+	Income       float64
+	Cost         float64
+	Profit       float64
+}
+
 func (h *Handlers) DailyProductSalesReport(w http.ResponseWriter, r *http.Request) {
 	date := egor.Query(r, "date") // Format: "yyyy-mm-dd"
 	if date == "" {
@@ -213,9 +320,36 @@ func (h *Handlers) DailyProductSalesReport(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	var summary SalesSummary
+	for _, sale := range dailyProductSales {
+		summary.add(sale.Income, sale.Cost, sale.Profit)
+		summary.Discount += sale.Discount
+	}
+
 	dateObj, _ := dbtypes.ParseDateFromString(date)
+	if format := egor.Query(r, "format"); format != "" {
+		rows := make([]SalesReportRow, 0, len(dailyProductSales))
+		for _, sale := range dailyProductSales {
+			rows = append(rows, SalesReportRow{
+				ProductID:    sale.ProductID,
+				ProductName:  sale.ProductName,
+				QuantitySold: sale.QuantitySold,
+				CostPrice:    sale.CostPrice,
+				SellingPrice: sale.SellingPrice,
+				Discount:     sale.Discount,
+				Income:       sale.Income,
+				Cost:         sale.Cost,
+				Profit:       sale.Profit,
+			})
+		}
+		h.exportSalesReport(w, r, format, "Daily Sales: "+dateObj.Format("Monday, 02 Jan 2006"),
+			fmt.Sprintf("sales-%s", dateObj.Format("2006-01-02")), rows, summary, true)
+		return
+	}
+
 	egor.Render(w, r, "reports/daily_product_sales.html", egor.Map{
 		"DailyProductSales": dailyProductSales,
+		"Summary":           summary,
 		"Date":              dateObj,
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Dashboard", URL: "/reports"},
@@ -248,10 +382,34 @@ func (h *Handlers) MonthlyProductSalesReport(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	var summary SalesSummary
+	for _, sale := range monthlyProductSales {
+		summary.add(sale.Income, sale.Cost, sale.Profit)
+	}
+
 	dateObj, _ := dbtypes.ParseDateFromString(date)
+	if format := egor.Query(r, "format"); format != "" {
+		rows := make([]SalesReportRow, 0, len(monthlyProductSales))
+		for _, sale := range monthlyProductSales {
+			rows = append(rows, SalesReportRow{
+				ProductID:    sale.ProductID,
+				ProductName:  sale.ProductName,
+				QuantitySold: int64(sale.QuantitySold),
+				CostPrice:    sale.CostPrice,
+				SellingPrice: sale.SellingPrice,
+				Income:       sale.Income,
+				Cost:         sale.Cost,
+				Profit:       sale.Profit,
+			})
+		}
+		h.exportSalesReport(w, r, format, "Monthly Sales: "+dateObj.Format("January 2006"),
+			fmt.Sprintf("sales-%s", dateObj.Format("2006-01")), rows, summary, false)
+		return
+	}
 
 	egor.Render(w, r, "reports/monthly_product_sales.html", egor.Map{
 		"MonthlyProductSales": monthlyProductSales,
+		"Summary":             summary,
 		"Date":                dateObj,
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Dashboard", URL: "/reports"},
@@ -276,10 +434,34 @@ func (h *Handlers) AnnualProductSalesReport(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	var summary SalesSummary
+	for _, sale := range annualProductSales {
+		summary.add(sale.Income, sale.Cost, sale.Profit)
+	}
+
 	dateObj, _ := dbtypes.ParseDateFromString(date)
+	if format := egor.Query(r, "format"); format != "" {
+		rows := make([]SalesReportRow, 0, len(annualProductSales))
+		for _, sale := range annualProductSales {
+			rows = append(rows, SalesReportRow{
+				ProductID:    sale.ProductID,
+				ProductName:  sale.ProductName,
+				QuantitySold: int64(sale.QuantitySold),
+				CostPrice:    sale.CostPrice,
+				SellingPrice: sale.SellingPrice,
+				Income:       sale.Income,
+				Cost:         sale.Cost,
+				Profit:       sale.Profit,
+			})
+		}
+		h.exportSalesReport(w, r, format, "Annual Sales: "+dateObj.Format("2006"),
+			fmt.Sprintf("sales-%s", dateObj.Format("2006")), rows, summary, false)
+		return
+	}
 
 	egor.Render(w, r, "reports/annual_product_sales.html", egor.Map{
 		"AnnualProductSales": annualProductSales,
+		"Summary":            summary,
 		"Date":               dateObj,
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Dashboard", URL: "/reports"},
@@ -287,3 +469,40 @@ func (h *Handlers) AnnualProductSalesReport(w http.ResponseWriter, r *http.Reque
 		},
 	})
 }
+
+// exportSalesReport sends a product sales report as a PDF, CSV or XLSX download.
+func (h *Handlers) exportSalesReport(w http.ResponseWriter, r *http.Request, format, title, filename string,
+	sales []SalesReportRow, summary SalesSummary, withDiscount bool) {
+	if format == "pdf" {
+		h.salesReportPDF(w, title, filename+".pdf", sales, summary, withDiscount)
+		return
+	}
+
+	if exportFormat(r) == "" {
+		egor.SendError(w, r, fmt.Errorf("unsupported format: %q", format), http.StatusBadRequest)
+		return
+	}
+
+	headers := []string{"Product ID", "Product Name", "Quantity Sold", "Unit Cost", "Unit Price"}
+	if withDiscount {
+		headers = append(headers, "Discount")
+	}
+	headers = append(headers, "Income", "Cost", "Profit", "Margin (%)")
+
+	rows := make([][]any, 0, len(sales)+1)
+	for _, sale := range sales {
+		row := []any{sale.ProductID, sale.ProductName, sale.QuantitySold, sale.CostPrice, sale.SellingPrice}
+		if withDiscount {
+			row = append(row, sale.Discount)
+		}
+		rows = append(rows, append(row, sale.Income, sale.Cost, sale.Profit, grossMargin(sale.Profit, sale.Income)))
+	}
+
+	total := []any{"Total", nil, nil, nil, nil}
+	if withDiscount {
+		total = append(total, summary.Discount)
+	}
+	rows = append(rows, append(total, summary.Income, summary.Cost, summary.Profit, summary.GrossMargin()))
+
+	exportTable(w, r, format, filename, headers, rows)
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/abiiranathan/epharmacy/epharma"
+)
+
+const (
+	// Name of the cookie holding the session token.
+	sessionCookieName = "session_id"
+
+	// A session is revoked if it is not used for this long.
+	sessionIdleTimeout = 2 * time.Hour
+
+	// A session is revoked this long after login, even if it is in use.
+	sessionMaxAge = 24 * time.Hour
+)
+
+var (
+	errSessionExpired  = errors.New("session expired")
+	errAccountInactive = errors.New("account is deactivated")
+)
+
+// newSessionToken returns a random, url-safe session token.
+func newSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sessions are stored by the hash of their token so that the sessions
+// table can not be used to forge cookies.
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// startSession creates a server-side session for the user and sets the session cookie.
+func (h *Handlers) startSession(w http.ResponseWriter, r *http.Request, user epharma.User) error {
+	// Housekeeping: drop sessions that can no longer be used.
+	err := h.Queries.DeleteExpiredSessions(r.Context(), time.Now().Add(-sessionIdleTimeout))
+	if err != nil {
+		return err
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(sessionMaxAge)
+	_, err = h.Queries.CreateSession(r.Context(), epharma.CreateSessionParams{
+		ID:        hashSessionToken(token),
+		UserID:    user.ID,
+		UserAgent: r.UserAgent(),
+		IpAddress: r.RemoteAddr,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		MaxAge:   int(sessionMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil,
+	})
+	return nil
+}
+
+// sessionUser returns the active user that owns the request's session.
+// Idle and expired sessions are deleted.
+func (h *Handlers) sessionUser(r *http.Request) (epharma.User, error) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return epharma.User{}, err
+	}
+
+	id := hashSessionToken(cookie.Value)
+	session, err := h.Queries.GetSession(r.Context(), id)
+	if err != nil {
+		return epharma.User{}, err
+	}
+
+	now := time.Now()
+	if now.After(session.ExpiresAt) || now.Sub(session.LastSeenAt) > sessionIdleTimeout {
+		if err := h.Queries.DeleteSession(r.Context(), id); err != nil {
+			return epharma.User{}, err
+		}
+		return epharma.User{}, errSessionExpired
+	}
+
+	user, err := h.Queries.GetUser(r.Context(), session.UserID)
+	if err != nil {
+		return epharma.User{}, err
+	}
+
+	if !user.IsActive {
+		if err := h.Queries.DeleteUserSessions(r.Context(), user.ID); err != nil {
+			return epharma.User{}, err
+		}
+		return epharma.User{}, errAccountInactive
+	}
+
+	err = h.Queries.TouchSession(r.Context(), id)
+	if err != nil {
+		return epharma.User{}, err
+	}
+	return user, nil
+}
+
+// endSession deletes the request's session and clears the session cookie.
+func (h *Handlers) endSession(w http.ResponseWriter, r *http.Request) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil,
+	})
+
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil
+	}
+	return h.Queries.DeleteSession(r.Context(), hashSessionToken(cookie.Value))
+}
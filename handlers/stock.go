@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/abiiranathan/dbtypes"
+	"github.com/abiiranathan/epharmacy/epharma"
+)
+
+// StockShortage is a sale line that can not be filled from stock.
+type StockShortage struct {
+	ProductID   int32  `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Requested   int32  `json:"requested"`
+	Available   int32  `json:"available"` // Units in unexpired batches
+}
+
+// InsufficientStockError lists every line of a sale that is short.
+type InsufficientStockError struct {
+	Shortages []StockShortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	lines := make([]string, 0, len(e.Shortages))
+	for _, s := range e.Shortages {
+		lines = append(lines, fmt.Sprintf("%s: requested %d, available %d", s.ProductName, s.Requested, s.Available))
+	}
+	return "insufficient stock: " + strings.Join(lines, "; ")
+}
+
+// lockStock locks the sold products for the rest of the database transaction
+// and checks that each has enough unexpired stock for the requested quantities.
+// Quantities of a product on several lines are added up.
+// It returns the locked products by id or an *InsufficientStockError.
+func lockStock(ctx context.Context, qtx *epharma.Queries, lines []SaleLine) (map[int32]epharma.Product, error) {
+	requested := make(map[int32]int32, len(lines))
+	ids := make([]int32, 0, len(lines))
+	for _, line := range lines {
+		if _, ok := requested[line.ID]; !ok {
+			ids = append(ids, line.ID)
+		}
+		requested[line.ID] += line.Quantity
+	}
+
+	locked, err := qtx.LockProducts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	products := make(map[int32]epharma.Product, len(locked))
+	for _, product := range locked {
+		products[product.ID] = product
+	}
+
+	var shortages []StockShortage
+	for _, id := range ids {
+		product, ok := products[id]
+		if !ok {
+			return nil, fmt.Errorf("product %d not found", id)
+		}
+
+		available, err := qtx.SellableQuantity(ctx, epharma.SellableQuantityParams{
+			ProductID: id,
+			Today:     dbtypes.Date(Now()),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if requested[id] > available {
+			name := product.GenericName
+			if product.BrandName != "" {
+				name = fmt.Sprintf("%s (%s)", product.GenericName, product.BrandName)
+			}
+
+			shortages = append(shortages, StockShortage{
+				ProductID:   id,
+				ProductName: name,
+				Requested:   requested[id],
+				Available:   available,
+			})
+		}
+	}
+
+	if len(shortages) > 0 {
+		return nil, &InsufficientStockError{Shortages: shortages}
+	}
+	return products, nil
+}
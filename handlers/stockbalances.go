@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/abiiranathan/dbtypes"
+	"github.com/abiiranathan/epharmacy/epharma"
+)
+
+// currentDate is the date in the pharmacy's time zone.
+// It is at midnight UTC like the dates read from the database.
+func currentDate() dbtypes.Date {
+	y, m, d := Now().Date()
+	return dbtypes.Date(time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+}
+
+// snapshotStockBalances records the opening balance on a day of every product
+// without a balance on that day and marks the day as done.
+func (h *Handlers) snapshotStockBalances(ctx context.Context, day dbtypes.Date) error {
+	tx, err := h.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := h.Queries.WithTx(tx)
+	n, err := qtx.SnapshotStockBalances(ctx, day)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.CreateStockBalanceSnapshot(ctx, epharma.CreateStockBalanceSnapshotParams{
+		BalanceDate: day,
+		Products:    int32(n),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// BackfillStockBalances snapshots the opening balances of the days
+// after the last snapshot up to and including today.
+func (h *Handlers) BackfillStockBalances(ctx context.Context) error {
+	last, err := h.Queries.LastStockBalanceSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	today := currentDate()
+	for day := last.AddDays(1); !day.After(today); day = day.AddDays(1) {
+		if err := h.snapshotStockBalances(ctx, day); err != nil {
+			return fmt.Errorf("stock balance snapshot of %s: %w", day, err)
+		}
+	}
+	return nil
+}
+
+// ScheduleStockBalances back-fills the snapshots missed while the server was
+// down and then snapshots the opening balances at every midnight in the
+// pharmacy's time zone until ctx is done. Failed snapshots are retried
+// after a minute.
+func (h *Handlers) ScheduleStockBalances(ctx context.Context) {
+	for {
+		now := Now()
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+
+		// Wake a little after midnight so that the new day has begun.
+		wait := midnight.Sub(now) + time.Second
+		if err := h.BackfillStockBalances(ctx); err != nil {
+			log.Printf("stock balances: %v\n", err)
+			wait = time.Minute
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// RepairStockBalances recomputes the daily balances of a product, or of all
+// products if productID is 0, from the stock received and sold between the from
+// date and today. Days without a balance are filled in. It returns the number
+// of balances written.
+func (h *Handlers) RepairStockBalances(ctx context.Context, productID int32, from dbtypes.Date) (int64, error) {
+	n, err := h.Queries.RebuildStockBalances(ctx, epharma.RebuildStockBalancesParams{
+		FromDate:  from,
+		ProductID: productID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, h.BackfillStockBalances(ctx)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/abiiranathan/dbtypes"
+	"github.com/abiiranathan/egor/egor"
+	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/abiiranathan/epharmacy/pdf"
+)
+
+// StockCardLine is a day on the stock card of a product.
+type StockCardLine struct {
+	epharma.StockCard
+
+	// Balance carried forward from the previous day, if there is one.
+	PreviousClosing *int32
+}
+
+// Broken reports whether the day does not open with the closing
+// balance of the previous day on the card.
+func (l StockCardLine) Broken() bool {
+	return l.PreviousClosing != nil && *l.PreviousClosing != l.OpeningQuantity
+}
+
+// StockCard is the stock card of a product with its running balance checked.
+type StockCard struct {
+	Product epharma.Product
+	From    dbtypes.Date
+	To      dbtypes.Date
+	Lines   []StockCardLine
+
+	QuantityIn       int64
+	QuantityOut      int64
+	QuantityAdjusted int64
+	Breaks           int // Days that do not open with the previous closing balance
+
+	// The last closing balance is checked against the quantity in stock
+	// when the card runs to today.
+	Checked  bool
+	Expected int32 // Closing balance of the last day
+}
+
+// loadStockCard loads the stock card of a product and validates its running balance.
+func (h *Handlers) loadStockCard(r *http.Request, productID int32, from, to dbtypes.Date) (*StockCard, error) {
+	product, err := h.Queries.GetProduct(r.Context(), productID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := h.Queries.ProductStockCard(r.Context(), epharma.ProductStockCardParams{
+		ProductID: productID,
+		FromDate:  from,
+		ToDate:    to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	card := &StockCard{Product: product, From: from, To: to}
+	var previous *int32
+	for _, row := range rows {
+		line := StockCardLine{StockCard: row, PreviousClosing: previous}
+		if line.Broken() {
+			card.Breaks++
+		}
+
+		card.Lines = append(card.Lines, line)
+		card.QuantityIn += int64(row.QuantityIn)
+		card.QuantityOut += row.QuantityOut
+		card.QuantityAdjusted += int64(row.QuantityAdjusted)
+
+		closing := row.ClosingQuantity
+		previous = &closing
+	}
+
+	if previous != nil && !to.Before(currentDate()) {
+		card.Checked = true
+		card.Expected = *previous
+	}
+	return card, nil
+}
+
+// StockCardReport shows the daily opening, in, out, adjusted and closing quantities
+// of a product between the from and to dates, checking that each day opens
+// with the previous closing balance and that the card closes with the
+// quantity in stock. With format=csv, xlsx or pdf the card is downloaded.
+func (h *Handlers) StockCardReport(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	data := egor.Map{
+		"from": from,
+		"to":   to,
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Dashboard", URL: "/reports"},
+			{Label: "Stock Card", IsLast: true},
+		},
+	}
+
+	productID := egor.QueryInt(r, "product_id", 0)
+	if productID == 0 {
+		egor.Render(w, r, "reports/stock_card.html", data)
+		return
+	}
+
+	card, err := h.loadStockCard(r, int32(productID), from, to)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	filename := fmt.Sprintf("stock-card-%d-%s-%s", card.Product.ID, from.Format("20060102"), to.Format("20060102"))
+	switch format := egor.Query(r, "format"); format {
+	case "":
+		data["card"] = card
+		egor.Render(w, r, "reports/stock_card.html", data)
+	case "pdf":
+		h.stockCardPDF(w, card, filename+".pdf")
+	case "csv", "xlsx":
+		headers := []string{"Date", "Opening", "In", "Out", "Adjusted", "Closing", "Check"}
+		rows := make([][]any, 0, len(card.Lines)+1)
+		for _, line := range card.Lines {
+			rows = append(rows, []any{line.BalanceDate, line.OpeningQuantity, line.QuantityIn,
+				line.QuantityOut, line.QuantityAdjusted, line.ClosingQuantity, stockCardCheck(line)})
+		}
+		rows = append(rows, []any{"Total", nil, card.QuantityIn, card.QuantityOut, card.QuantityAdjusted,
+			nil, stockCardSummary(card)})
+		exportTable(w, r, format, filename, headers, rows)
+	default:
+		egor.SendError(w, r, fmt.Errorf("unsupported format: %q", format), http.StatusBadRequest)
+	}
+}
+
+// stockCardCheck describes a break in the running balance on a day.
+func stockCardCheck(line StockCardLine) string {
+	if !line.Broken() {
+		return "OK"
+	}
+	return fmt.Sprintf("Opening %d differs from previous closing %d", line.OpeningQuantity, *line.PreviousClosing)
+}
+
+// stockCardSummary describes the result of the checks on the card.
+func stockCardSummary(card *StockCard) string {
+	switch {
+	case card.Breaks > 0:
+		return fmt.Sprintf("%d day(s) do not open with the previous closing balance", card.Breaks)
+	case card.Checked && card.Expected != card.Product.Quantity:
+		return fmt.Sprintf("Closing balance %d differs from %d in stock", card.Expected, card.Product.Quantity)
+	case card.Checked:
+		return fmt.Sprintf("Closing balance agrees with %d in stock", card.Product.Quantity)
+	default:
+		return "Running balance OK"
+	}
+}
+
+// stockCardPDF sends the stock card of a product.
+func (h *Handlers) stockCardPDF(w http.ResponseWriter, card *StockCard, filename string) {
+	doc := h.newPDF(fmt.Sprintf("Stock Card: %s %s", card.Product.GenericName, card.Product.BrandName))
+	doc.Columns(fmt.Sprintf("Product ID: %d", card.Product.ID),
+		fmt.Sprintf("%s to %s", card.From.Format("02 Jan 2006"), card.To.Format("02 Jan 2006")))
+	doc.Text(stockCardSummary(card), pdf.Left)
+	doc.Space(8)
+
+	rows := make([][]string, 0, len(card.Lines))
+	for _, line := range card.Lines {
+		rows = append(rows, []string{
+			line.BalanceDate.Format("02 Jan 2006"),
+			fmt.Sprint(line.OpeningQuantity),
+			fmt.Sprint(line.QuantityIn),
+			fmt.Sprint(line.QuantityOut),
+			fmt.Sprint(line.QuantityAdjusted),
+			fmt.Sprint(line.ClosingQuantity),
+			stockCardCheck(line),
+		})
+	}
+
+	doc.Table([]pdf.Column{
+		{Header: "Date", Width: 2},
+		{Header: "Opening", Width: 1.5, Align: pdf.Right},
+		{Header: "In", Width: 1.5, Align: pdf.Right},
+		{Header: "Out", Width: 1.5, Align: pdf.Right},
+		{Header: "Adjusted", Width: 1.5, Align: pdf.Right},
+		{Header: "Closing", Width: 1.5, Align: pdf.Right},
+		{Header: "Check", Width: 5},
+	}, rows, []string{"Total", "", fmt.Sprint(card.QuantityIn), fmt.Sprint(card.QuantityOut),
+		fmt.Sprint(card.QuantityAdjusted), "", ""})
+
+	sendPDF(w, doc, filename)
+}
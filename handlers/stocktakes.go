@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/abiiranathan/egor/egor"
+	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// StockTakeLine is a product on a stock take with its variance.
+type StockTakeLine struct {
+	epharma.ListStockTakeItemsRow
+}
+
+// Counted reports whether the product has been counted.
+func (l StockTakeLine) Counted() bool {
+	return l.CountedQuantity != nil
+}
+
+// Variance is the counted quantity less the expected quantity.
+// It is 0 if the product has not been counted.
+func (l StockTakeLine) Variance() int32 {
+	if l.CountedQuantity == nil {
+		return 0
+	}
+	return *l.CountedQuantity - l.ExpectedQuantity
+}
+
+// VarianceValue is the variance valued at the cost frozen with the stock take.
+func (l StockTakeLine) VarianceValue() float64 {
+	return float64(l.Variance()) * l.UnitCost
+}
+
+// StockTakeTotals sums the variances of a stock take at cost.
+type StockTakeTotals struct {
+	Products int
+	Counted  int
+	Shortage float64 // Value of the units missing, as a positive amount.
+	Surplus  float64 // Value of the units found in excess.
+}
+
+// Net is the surplus less the shortage.
+func (t StockTakeTotals) Net() float64 {
+	return t.Surplus - t.Shortage
+}
+
+// ListStockTakes renders the latest stock takes.
+func (h *Handlers) ListStockTakes(w http.ResponseWriter, r *http.Request) {
+	stockTakes, err := h.Queries.ListStockTakes(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	egor.Render(w, r, "stocktakes/list.html", egor.Map{
+		"stockTakes": stockTakes,
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Products", URL: "/products"},
+			{Label: "Stock Takes", IsLast: true},
+		},
+	})
+}
+
+// OpenStockTake opens a stock take and freezes the quantity in stock
+// and cost of every product as expected by it.
+func (h *Handlers) OpenStockTake(w http.ResponseWriter, r *http.Request) {
+	user := egor.GetContextValue(r, "user").(epharma.User)
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	stockTake, err := qtx.CreateStockTake(r.Context(), epharma.CreateStockTakeParams{
+		Note:     strings.TrimSpace(r.FormValue("note")),
+		OpenedBy: user.ID,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			egor.SendError(w, r, fmt.Errorf("a stock take is already open"), http.StatusConflict)
+			return
+		}
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := qtx.FreezeStockTakeItems(r.Context(), stockTake.ID); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/stock-takes/%d", stockTake.ID), http.StatusSeeOther)
+}
+
+// GetStockTake renders a stock take with the variance of every product.
+// The products shown are filtered by show: counted, uncounted or variance.
+func (h *Handlers) GetStockTake(w http.ResponseWriter, r *http.Request) {
+	id := int32(egor.ParamInt(r, "id"))
+	stockTake, err := h.Queries.GetStockTake(r.Context(), id)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	items, err := h.Queries.ListStockTakeItems(r.Context(), id)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	show := egor.Query(r, "show")
+	var totals StockTakeTotals
+	lines := make([]StockTakeLine, 0, len(items))
+	for _, item := range items {
+		line := StockTakeLine{item}
+		totals.Products++
+		if line.Counted() {
+			totals.Counted++
+		}
+
+		if value := line.VarianceValue(); value < 0 {
+			totals.Shortage -= value
+		} else {
+			totals.Surplus += value
+		}
+
+		switch show {
+		case "counted":
+			if !line.Counted() {
+				continue
+			}
+		case "uncounted":
+			if line.Counted() {
+				continue
+			}
+		case "variance":
+			if line.Variance() == 0 {
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	egor.Render(w, r, "stocktakes/view.html", egor.Map{
+		"stockTake": stockTake,
+		"lines":     lines,
+		"totals":    totals,
+		"show":      show,
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Products", URL: "/products"},
+			{Label: "Stock Takes", URL: "/stock-takes"},
+			{Label: fmt.Sprintf("Stock Take #%d", stockTake.ID), IsLast: true},
+		},
+	})
+}
+
+// CountStockTake records the counted quantity of a product on an open
+// stock take. The product is found by its barcode if one is scanned.
+// If add is checked the quantity is added to what was counted before.
+func (h *Handlers) CountStockTake(w http.ResponseWriter, r *http.Request) {
+	user := egor.GetContextValue(r, "user").(epharma.User)
+	id := int32(egor.ParamInt(r, "id"))
+
+	stockTake, err := h.Queries.GetStockTake(r.Context(), id)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	if stockTake.Status != epharma.StockTakeStatusOpen {
+		egor.SendError(w, r, fmt.Errorf("the stock take is %s", stockTake.Status), http.StatusConflict)
+		return
+	}
+
+	var productID int32
+	if barcode := strings.TrimSpace(r.FormValue("barcode")); barcode != "" {
+		product, err := h.Queries.GetProductByBarcode(r.Context(), barcode)
+		if err != nil {
+			egor.SendError(w, r, fmt.Errorf("no product with barcode %q", barcode), http.StatusNotFound)
+			return
+		}
+		productID = product.ID
+	} else {
+		n, err := strconv.ParseInt(r.FormValue("product_id"), 10, 32)
+		if err != nil || n <= 0 {
+			egor.SendError(w, r, fmt.Errorf("product is required"), http.StatusBadRequest)
+			return
+		}
+		productID = int32(n)
+	}
+
+	if strings.TrimSpace(r.FormValue("quantity")) == "" {
+		egor.SendError(w, r, fmt.Errorf("quantity is required"), http.StatusBadRequest)
+		return
+	}
+
+	quantity, err := parseQuantity(r.FormValue("quantity"))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	if quantity < 0 {
+		egor.SendError(w, r, fmt.Errorf("quantity can not be negative"), http.StatusBadRequest)
+		return
+	}
+
+	_, err = h.Queries.CountStockTakeItem(r.Context(), epharma.CountStockTakeItemParams{
+		Add:         r.FormValue("add") == "on",
+		Quantity:    quantity,
+		CountedBy:   &user.ID,
+		StockTakeID: id,
+		ProductID:   productID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		egor.SendError(w, r, fmt.Errorf("product is not on this stock take"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/stock-takes/%d?show=%s", id, egor.Query(r, "show")), http.StatusSeeOther)
+}
+
+// ApproveStockTake closes a stock take and posts an approved count correction
+// for every product counted with a different quantity than expected.
+// The stock take is not approved if any correction can not be applied.
+func (h *Handlers) ApproveStockTake(w http.ResponseWriter, r *http.Request) {
+	user := egor.GetContextValue(r, "user").(epharma.User)
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	stockTake, err := qtx.CloseStockTake(r.Context(), epharma.CloseStockTakeParams{
+		Status:   epharma.StockTakeStatusApproved,
+		ClosedBy: &user.ID,
+		ID:       int32(egor.ParamInt(r, "id")),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		egor.SendError(w, r, fmt.Errorf("the stock take does not exist or is not open"), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	items, err := qtx.ListStockTakeItems(r.Context(), stockTake.ID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Lock every product corrected at once, in id order as sales do, so that
+	// posting the corrections one by one does not deadlock against a sale.
+	var productIDs []int32
+	for _, item := range items {
+		if (StockTakeLine{item}).Variance() != 0 {
+			productIDs = append(productIDs, item.ProductID)
+		}
+	}
+
+	if _, err := qtx.LockProducts(r.Context(), productIDs); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	for _, item := range items {
+		line := StockTakeLine{item}
+		if line.Variance() == 0 {
+			continue
+		}
+
+		adjustment, err := qtx.CreateStockAdjustment(r.Context(), epharma.CreateStockAdjustmentParams{
+			ProductID:   item.ProductID,
+			Quantity:    line.Variance(),
+			Reason:      epharma.AdjustmentReasonCountCorrection,
+			Note:        fmt.Sprintf("Stock take #%d", stockTake.ID),
+			RequestedBy: user.ID,
+			StockTakeID: &stockTake.ID,
+		})
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		adjustment, err = qtx.ReviewStockAdjustment(r.Context(), epharma.ReviewStockAdjustmentParams{
+			Status:     epharma.AdjustmentStatusApproved,
+			ReviewedBy: &user.ID,
+			ID:         adjustment.ID,
+		})
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		if err := applyStockAdjustment(r.Context(), qtx, adjustment); err != nil {
+			egor.SendError(w, r, err, http.StatusConflict)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/stock-takes/%d", stockTake.ID), http.StatusSeeOther)
+}
+
+// CancelStockTake closes a stock take without changing the stock.
+func (h *Handlers) CancelStockTake(w http.ResponseWriter, r *http.Request) {
+	user := egor.GetContextValue(r, "user").(epharma.User)
+	stockTake, err := h.Queries.CloseStockTake(r.Context(), epharma.CloseStockTakeParams{
+		Status:   epharma.StockTakeStatusCancelled,
+		ClosedBy: &user.ID,
+		ID:       int32(egor.ParamInt(r, "id")),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		egor.SendError(w, r, fmt.Errorf("the stock take does not exist or is not open"), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/stock-takes/%d", stockTake.ID), http.StatusSeeOther)
+}
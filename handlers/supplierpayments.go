@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/abiiranathan/dbtypes"
+	"github.com/abiiranathan/egor/egor"
+	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/abiiranathan/epharmacy/pdf"
+)
+
+// parseSupplierPayment reads and validates a payment of at most balance
+// on an invoice. The payment date defaults to date.
+func parseSupplierPayment(r *http.Request, balance float64, date dbtypes.Date) (epharma.CreateSupplierPaymentParams, error) {
+	params := epharma.CreateSupplierPaymentParams{
+		PaymentDate: date,
+		Method:      epharma.SupplierPaymentMethod(r.FormValue("method")),
+		Reference:   strings.TrimSpace(r.FormValue("reference")),
+	}
+
+	if value := r.FormValue("payment_date"); value != "" {
+		date, err := dbtypes.ParseDateFromString(value)
+		if err != nil {
+			return params, fmt.Errorf("invalid payment date: %w", err)
+		}
+		params.PaymentDate = date
+	}
+
+	if params.PaymentDate.After(currentDate()) {
+		return params, fmt.Errorf("the payment date is in the future")
+	}
+
+	if !params.Method.Valid() {
+		return params, fmt.Errorf("invalid payment method: %q", params.Method)
+	}
+
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(r.FormValue("amount"), ",", ""), 64)
+	if err != nil || amount <= 0 {
+		return params, fmt.Errorf("invalid amount: %q", r.FormValue("amount"))
+	}
+
+	if cents(amount) > cents(balance) {
+		return params, fmt.Errorf("the payment of %s is more than the balance of %s", CurrencyF64(amount), CurrencyF64(balance))
+	}
+	params.Amount = amount
+	return params, nil
+}
+
+// CreateSupplierPayment records a payment on an invoice. The invoice is
+// locked until the payment is recorded so that concurrent payments can
+// not together pay more than its balance.
+func (h *Handlers) CreateSupplierPayment(w http.ResponseWriter, r *http.Request) {
+	user := egor.GetContextValue(r, "user").(epharma.User)
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	invoice, err := qtx.GetInvoiceForUpdate(r.Context(), int32(egor.ParamInt(r, "id")))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	params, err := parseSupplierPayment(r, invoice.Balance, currentDate())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	params.InvoiceID = invoice.ID
+	params.UserID = user.ID
+
+	if _, err := qtx.CreateSupplierPayment(r.Context(), params); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/invoices/view/%d", invoice.ID))
+}
+
+// DeleteSupplierPayment removes a payment recorded in error.
+func (h *Handlers) DeleteSupplierPayment(w http.ResponseWriter, r *http.Request) {
+	invoiceID := int32(egor.ParamInt(r, "id"))
+	n, err := h.Queries.DeleteSupplierPayment(r.Context(), epharma.DeleteSupplierPaymentParams{
+		ID:        int32(egor.ParamInt(r, "payment_id")),
+		InvoiceID: invoiceID,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if n == 0 {
+		egor.SendError(w, r, fmt.Errorf("payment not found"), http.StatusNotFound)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/invoices/view/%d", invoiceID))
+}
+
+// StatementLine is an invoice or payment on a supplier statement
+// with the balance owed after it.
+type StatementLine struct {
+	epharma.SupplierStatementRow
+	Balance float64
+}
+
+// Details describes the invoice or payment.
+func (line StatementLine) Details() string {
+	if line.Kind == "invoice" {
+		return "Invoice " + line.InvoiceNumber
+	}
+
+	details := fmt.Sprintf("Payment (%s) on invoice %s", humanize(line.Method), line.InvoiceNumber)
+	if line.Reference != "" {
+		details += ", ref. " + line.Reference
+	}
+	return details
+}
+
+// Statement is the account of a supplier between two dates.
+type Statement struct {
+	Supplier epharma.Supplier
+	From     dbtypes.Date
+	To       dbtypes.Date
+	Opening  float64
+	Lines    []StatementLine
+	Invoiced float64
+	Paid     float64
+	Closing  float64
+}
+
+// loadStatement reads the invoices and payments of a supplier between
+// the from and to dates and the running balance owed.
+func (h *Handlers) loadStatement(r *http.Request, supplierID int32, from, to dbtypes.Date) (*Statement, error) {
+	supplier, err := h.Queries.GetSupplier(r.Context(), supplierID)
+	if err != nil {
+		return nil, err
+	}
+
+	opening, err := h.Queries.SupplierOpeningBalance(r.Context(), epharma.SupplierOpeningBalanceParams{
+		SupplierID: supplierID,
+		FromDate:   from,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := h.Queries.SupplierStatement(r.Context(), epharma.SupplierStatementParams{
+		SupplierID: supplierID,
+		FromDate:   from,
+		ToDate:     to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{Supplier: supplier, From: from, To: to, Opening: opening}
+	balance := opening
+	for _, row := range rows {
+		balance += row.Debit - row.Credit
+		statement.Lines = append(statement.Lines, StatementLine{SupplierStatementRow: row, Balance: balance})
+		statement.Invoiced += row.Debit
+		statement.Paid += row.Credit
+	}
+	statement.Closing = balance
+	return statement, nil
+}
+
+// SupplierStatement shows the invoices and payments of a supplier between
+// the from and to dates with the running balance owed. With format=csv,
+// xlsx or pdf the statement is downloaded.
+func (h *Handlers) SupplierStatement(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	statement, err := h.loadStatement(r, int32(egor.ParamInt(r, "id")), from, to)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%d-%s-%s", statement.Supplier.ID, from.Format("20060102"), to.Format("20060102"))
+	switch format := egor.Query(r, "format"); format {
+	case "":
+		egor.Render(w, r, "suppliers/statement", egor.Map{
+			"statement": statement,
+			"breadcrumbs": Breadcrumbs{
+				{Label: "Suppliers", URL: "/suppliers"},
+				{Label: statement.Supplier.Name, URL: fmt.Sprintf("/suppliers/%d", statement.Supplier.ID)},
+				{Label: "Statement", IsLast: true},
+			},
+		})
+	case "pdf":
+		h.statementPDF(w, statement, filename+".pdf")
+	case "csv", "xlsx":
+		headers := []string{"Date", "Details", "Invoiced", "Paid", "Balance"}
+		rows := make([][]any, 0, len(statement.Lines)+2)
+		rows = append(rows, []any{from, "Opening balance", nil, nil, statement.Opening})
+		for _, line := range statement.Lines {
+			rows = append(rows, []any{line.EntryDate, line.Details(), line.Debit, line.Credit, line.Balance})
+		}
+		rows = append(rows, []any{to, "Closing balance", statement.Invoiced, statement.Paid, statement.Closing})
+		exportTable(w, r, format, filename, headers, rows)
+	default:
+		egor.SendError(w, r, fmt.Errorf("unsupported format: %q", format), http.StatusBadRequest)
+	}
+}
+
+// statementPDF sends the statement of a supplier.
+func (h *Handlers) statementPDF(w http.ResponseWriter, statement *Statement, filename string) {
+	doc := h.newPDF("Statement: " + statement.Supplier.Name)
+	doc.Columns("TIN: "+statement.Supplier.Tin,
+		fmt.Sprintf("%s to %s", statement.From.Format("02 Jan 2006"), statement.To.Format("02 Jan 2006")))
+	doc.Space(8)
+
+	rows := make([][]string, 0, len(statement.Lines)+1)
+	rows = append(rows, []string{statement.From.Format("02 Jan 2006"), "Opening balance", "", "",
+		CurrencyF64(statement.Opening)})
+	for _, line := range statement.Lines {
+		var debit, credit string
+		if line.Debit != 0 {
+			debit = CurrencyF64(line.Debit)
+		}
+
+		if line.Credit != 0 {
+			credit = CurrencyF64(line.Credit)
+		}
+
+		rows = append(rows, []string{
+			line.EntryDate.Format("02 Jan 2006"),
+			line.Details(),
+			debit,
+			credit,
+			CurrencyF64(line.Balance),
+		})
+	}
+
+	doc.Table([]pdf.Column{
+		{Header: "Date", Width: 2},
+		{Header: "Details", Width: 6},
+		{Header: "Invoiced", Width: 2, Align: pdf.Right},
+		{Header: "Paid", Width: 2, Align: pdf.Right},
+		{Header: "Balance", Width: 2, Align: pdf.Right},
+	}, rows, []string{"Closing balance", "", CurrencyF64(statement.Invoiced), CurrencyF64(statement.Paid),
+		CurrencyF64(statement.Closing)})
+
+	sendPDF(w, doc, filename)
+}
+
+// PayablesAgeingReport shows the balances owed to each supplier on a date by
+// the days the invoices are overdue, from the supplier's payment terms. With
+// format=csv or xlsx the report is downloaded.
+func (h *Handlers) PayablesAgeingReport(w http.ResponseWriter, r *http.Request) {
+	asOf := currentDate()
+	if value := egor.Query(r, "date"); value != "" {
+		date, err := dbtypes.ParseDateFromString(value)
+		if err != nil {
+			egor.SendError(w, r, fmt.Errorf("invalid date: %w", err), http.StatusBadRequest)
+			return
+		}
+		asOf = date
+	}
+
+	rows, err := h.Queries.PayablesAgeing(r.Context(), asOf)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	var total epharma.PayablesAgeingRow
+	for _, row := range rows {
+		total.Current += row.Current
+		total.Days30 += row.Days30
+		total.Days60 += row.Days60
+		total.Days90 += row.Days90
+		total.Over90 += row.Over90
+		total.Total += row.Total
+	}
+
+	if format := exportFormat(r); format != "" {
+		headers := []string{"Supplier", "Not Due", "1-30 Days", "31-60 Days", "61-90 Days", "Over 90 Days", "Total"}
+		export := make([][]any, 0, len(rows)+1)
+		for _, row := range rows {
+			export = append(export, []any{row.SupplierName, row.Current, row.Days30, row.Days60, row.Days90,
+				row.Over90, row.Total})
+		}
+		export = append(export, []any{"Total", total.Current, total.Days30, total.Days60, total.Days90,
+			total.Over90, total.Total})
+		exportTable(w, r, format, "payables-ageing-"+asOf.Format("20060102"), headers, export)
+		return
+	}
+
+	egor.Render(w, r, "reports/payables_ageing.html", egor.Map{
+		"rows":  rows,
+		"total": total,
+		"date":  asOf,
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Dashboard", URL: "/reports"},
+			{Label: "Payables Ageing", IsLast: true},
+		},
+	})
+}
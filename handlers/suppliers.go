@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/abiiranathan/egor/egor"
+	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// parseSupplier reads and validates the supplier form.
+func parseSupplier(r *http.Request) (epharma.CreateSupplierParams, error) {
+	params := epharma.CreateSupplierParams{
+		Name:          strings.Join(strings.Fields(r.FormValue("name")), " "),
+		ContactPerson: strings.TrimSpace(r.FormValue("contact_person")),
+		Phone:         strings.TrimSpace(r.FormValue("phone")),
+		Email:         strings.TrimSpace(r.FormValue("email")),
+		Tin:           strings.TrimSpace(r.FormValue("tin")),
+		Address:       strings.TrimSpace(r.FormValue("address")),
+	}
+
+	if params.Name == "" {
+		return params, fmt.Errorf("supplier name is required")
+	}
+
+	days := func(field string) (int32, error) {
+		n, err := strconv.ParseInt(strings.TrimSpace(r.FormValue(field)), 10, 32)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid %s: %q", humanize(field), r.FormValue(field))
+		}
+		return int32(n), nil
+	}
+
+	var err error
+	if params.PaymentTermsDays, err = days("payment_terms_days"); err != nil {
+		return params, err
+	}
+
+	if params.LeadTimeDays, err = days("lead_time_days"); err != nil {
+		return params, err
+	}
+	return params, nil
+}
+
+// supplierError explains the constraint violations of the suppliers table.
+func supplierError(err error, name string) (int, error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			return http.StatusConflict, fmt.Errorf("a supplier named %q already exists", name)
+		case "23503":
+			return http.StatusConflict, fmt.Errorf("the supplier has invoices or purchase orders, merge it into another supplier instead")
+		}
+	}
+	return http.StatusInternalServerError, err
+}
+
+// ListSuppliers renders the suppliers with the number of invoices of each.
+func (h *Handlers) ListSuppliers(w http.ResponseWriter, r *http.Request) {
+	suppliers, err := h.Queries.ListSuppliers(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	egor.Render(w, r, "suppliers/list", egor.Map{
+		"suppliers": suppliers,
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Suppliers", IsLast: true},
+		},
+	})
+}
+
+// RenderSupplierCreatePage
+func (h *Handlers) RenderSupplierCreatePage(w http.ResponseWriter, r *http.Request) {
+	egor.Render(w, r, "suppliers/create", egor.Map{
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Suppliers", URL: "/suppliers"},
+			{Label: "Add Supplier", IsLast: true},
+		},
+	})
+}
+
+// CreateSupplier
+func (h *Handlers) CreateSupplier(w http.ResponseWriter, r *http.Request) {
+	params, err := parseSupplier(r)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	supplier, err := h.Queries.CreateSupplier(r.Context(), params)
+	if err != nil {
+		status, err := supplierError(err, params.Name)
+		egor.SendError(w, r, err, status)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/suppliers/%d", supplier.ID))
+}
+
+// GetSupplier renders a supplier with its invoices and a form to merge
+// a duplicate supplier into another.
+func (h *Handlers) GetSupplier(w http.ResponseWriter, r *http.Request) {
+	supplier, err := h.Queries.GetSupplier(r.Context(), int32(egor.ParamInt(r, "id")))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	invoices, err := h.Queries.ListSupplierInvoices(r.Context(), supplier.ID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	suppliers, err := h.Queries.ListSuppliers(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	egor.Render(w, r, "suppliers/view", egor.Map{
+		"supplier":  supplier,
+		"invoices":  invoices,
+		"suppliers": suppliers,
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Suppliers", URL: "/suppliers"},
+			{Label: supplier.Name, IsLast: true},
+		},
+	})
+}
+
+// RenderSupplierEditPage
+func (h *Handlers) RenderSupplierEditPage(w http.ResponseWriter, r *http.Request) {
+	supplier, err := h.Queries.GetSupplier(r.Context(), int32(egor.ParamInt(r, "id")))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	egor.Render(w, r, "suppliers/create", egor.Map{
+		"supplier": supplier,
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Suppliers", URL: "/suppliers"},
+			{Label: supplier.Name, URL: fmt.Sprintf("/suppliers/%d", supplier.ID)},
+			{Label: "Update", IsLast: true},
+		},
+	})
+}
+
+// UpdateSupplier
+func (h *Handlers) UpdateSupplier(w http.ResponseWriter, r *http.Request) {
+	supplierID := int32(egor.ParamInt(r, "id"))
+	params, err := parseSupplier(r)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Queries.UpdateSupplier(r.Context(), epharma.UpdateSupplierParams{
+		Name:             params.Name,
+		ContactPerson:    params.ContactPerson,
+		Phone:            params.Phone,
+		Email:            params.Email,
+		Tin:              params.Tin,
+		Address:          params.Address,
+		PaymentTermsDays: params.PaymentTermsDays,
+		LeadTimeDays:     params.LeadTimeDays,
+		ID:               supplierID,
+	})
+	if err != nil {
+		status, err := supplierError(err, params.Name)
+		egor.SendError(w, r, err, status)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/suppliers/%d", supplierID))
+}
+
+// DeleteSupplier deletes a supplier without invoices.
+func (h *Handlers) DeleteSupplier(w http.ResponseWriter, r *http.Request) {
+	err := h.Queries.DeleteSupplier(r.Context(), int32(egor.ParamInt(r, "id")))
+	if err != nil {
+		status, err := supplierError(err, "")
+		egor.SendError(w, r, err, status)
+		return
+	}
+	egor.Redirect(w, r, "/suppliers")
+}
+
+// MergeSupplier moves the invoices, purchase orders and preferred products
+// of a duplicate supplier to the supplier chosen to keep and deletes the duplicate.
+// Names that differ only in case, punctuation, company form or plurals are one
+// supplier already; other duplicates, such as an acronym, are merged here.
+func (h *Handlers) MergeSupplier(w http.ResponseWriter, r *http.Request) {
+	fromID := int32(egor.ParamInt(r, "id"))
+	intoID, err := strconv.ParseInt(r.FormValue("into_id"), 10, 32)
+	if err != nil || intoID <= 0 {
+		egor.SendError(w, r, fmt.Errorf("choose the supplier to keep"), http.StatusBadRequest)
+		return
+	}
+
+	if int32(intoID) == fromID {
+		egor.SendError(w, r, fmt.Errorf("can not merge a supplier into itself"), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	qtx := h.Queries.WithTx(tx)
+	if _, err := qtx.GetSupplier(r.Context(), int32(intoID)); err != nil {
+		egor.SendError(w, r, err, http.StatusNotFound)
+		return
+	}
+
+	_, err = qtx.MoveSupplierInvoices(r.Context(), epharma.MoveSupplierInvoicesParams{
+		IntoID: int32(intoID),
+		FromID: fromID,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	_, err = qtx.MoveSupplierPurchaseOrders(r.Context(), epharma.MoveSupplierPurchaseOrdersParams{
+		IntoID: int32(intoID),
+		FromID: fromID,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	_, err = qtx.MoveSupplierProducts(r.Context(), epharma.MoveSupplierProductsParams{
+		IntoID: int32(intoID),
+		FromID: fromID,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := qtx.DeleteSupplier(r.Context(), fromID); err != nil {
+		status, err := supplierError(err, "")
+		egor.SendError(w, r, err, status)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	egor.Redirect(w, r, fmt.Sprintf("/suppliers/%d", intoID))
+}
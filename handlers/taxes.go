@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/abiiranathan/egor/egor"
+	"github.com/abiiranathan/epharmacy/epharma"
+)
+
+// applyTax sets the tax of each line from the tax class of its product.
+// Tax is charged on the line amount after discounts. For tax inclusive
+// prices the tax is part of the line amount, otherwise it is added to it.
+// Products without a tax class are not taxed.
+func applyTax(lines []epharma.CreateTransactionItemParams, products map[int32]epharma.Product, classes map[int32]epharma.TaxClass) {
+	for i := range lines {
+		product := products[lines[i].ProductID]
+		lines[i].TaxInclusive = product.PriceIncludesTax
+		if product.TaxClassID == nil {
+			continue
+		}
+
+		class := classes[*product.TaxClassID]
+		lines[i].TaxClassID = &class.ID
+		lines[i].TaxRate = class.Rate
+		if class.Rate == 0 {
+			continue
+		}
+
+		amount := float64(cents(float64(lines[i].Quantity)*lines[i].UnitPrice - lines[i].Discount))
+		var tax float64
+		if product.PriceIncludesTax {
+			tax = math.Round(amount * class.Rate / (100 + class.Rate))
+		} else {
+			tax = math.Round(amount * class.Rate / 100)
+		}
+		lines[i].Tax = tax / 100
+	}
+}
+
+// lineAmountDue is what the customer pays for a line, after discounts and
+// including tax.
+func lineAmountDue(lineTotal, tax float64, taxInclusive bool) float64 {
+	if taxInclusive {
+		return lineTotal
+	}
+	return lineTotal + tax
+}
+
+// parseTaxClass reads and validates the tax class form.
+func parseTaxClass(r *http.Request) (epharma.CreateTaxClassParams, error) {
+	params := epharma.CreateTaxClassParams{
+		Name: strings.TrimSpace(r.FormValue("name")),
+		Kind: epharma.TaxKind(r.FormValue("kind")),
+	}
+
+	if params.Name == "" {
+		return params, fmt.Errorf("tax class name is required")
+	}
+
+	if !params.Kind.Valid() {
+		return params, fmt.Errorf("invalid tax kind: %q", params.Kind)
+	}
+
+	if params.Kind == epharma.TaxKindStandard {
+		rate, err := strconv.ParseFloat(r.FormValue("rate"), 64)
+		if err != nil || rate < 0 || rate > 100 {
+			return params, fmt.Errorf("invalid tax rate: %q", r.FormValue("rate"))
+		}
+		params.Rate = rate
+	}
+	return params, nil
+}
+
+// ListTaxClasses renders the tax classes with forms to edit them.
+func (h *Handlers) ListTaxClasses(w http.ResponseWriter, r *http.Request) {
+	classes, err := h.Queries.ListTaxClasses(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	egor.Render(w, r, "products/tax_classes.html", egor.Map{
+		"taxClasses": classes,
+		"taxKinds":   epharma.AllTaxKindValues(),
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Products", URL: "/products"},
+			{Label: "Tax Classes", IsLast: true},
+		},
+	})
+}
+
+// CreateTaxClass
+func (h *Handlers) CreateTaxClass(w http.ResponseWriter, r *http.Request) {
+	params, err := parseTaxClass(r)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.Queries.CreateTaxClass(r.Context(), params); err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	egor.Redirect(w, r, "/products/tax-classes", http.StatusSeeOther)
+}
+
+// UpdateTaxClass changes a tax class. Sales already made keep
+// the rate they were taxed at.
+func (h *Handlers) UpdateTaxClass(w http.ResponseWriter, r *http.Request) {
+	params, err := parseTaxClass(r)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Queries.UpdateTaxClass(r.Context(), epharma.UpdateTaxClassParams{
+		Name: params.Name,
+		Kind: params.Kind,
+		Rate: params.Rate,
+		ID:   int32(egor.ParamInt(r, "id")),
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	egor.Redirect(w, r, "/products/tax-classes", http.StatusSeeOther)
+}
+
+// TaxSummaryReport shows sales and tax by tax class for each day, month or year
+// between the from and to dates. Defaults to the days of the current month.
+func (h *Handlers) TaxSummaryReport(w http.ResponseWriter, r *http.Request) {
+	group := egor.Query(r, "group")
+	if group == "" {
+		group = "day"
+	}
+
+	if group != "day" && group != "month" && group != "year" {
+		egor.SendError(w, r, fmt.Errorf("invalid group: %q", group), http.StatusBadRequest)
+		return
+	}
+
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.Queries.TaxSummary(r.Context(), epharma.TaxSummaryParams{
+		Period:   group,
+		FromDate: from,
+		ToDate:   to,
+	})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	var total epharma.TaxSummaryRow
+	for _, row := range rows {
+		total.NetSales += row.NetSales
+		total.Tax += row.Tax
+		total.GrossSales += row.GrossSales
+	}
+
+	egor.Render(w, r, "reports/tax_summary.html", egor.Map{
+		"rows":  rows,
+		"total": total,
+		"group": group,
+		"from":  from,
+		"to":    to,
+		"breadcrumbs": Breadcrumbs{
+			{Label: "Dashboard", URL: "/reports"},
+			{Label: "Tax Summary", IsLast: true},
+		},
+	})
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+
+	"github.com/abiiranathan/epharmacy/epharma"
+)
+
+func TestApplyTax(t *testing.T) {
+	classes := map[int32]epharma.TaxClass{
+		1: {ID: 1, Name: "VAT", Kind: epharma.TaxKindStandard, Rate: 18},
+		2: {ID: 2, Name: "Zero rated", Kind: epharma.TaxKindZeroRated},
+		3: {ID: 3, Name: "Exempt", Kind: epharma.TaxKindExempt},
+	}
+	vat, zeroRated, exempt := int32(1), int32(2), int32(3)
+
+	tests := []struct {
+		name      string
+		product   epharma.Product
+		quantity  int32
+		price     float64
+		discount  float64
+		wantClass *int32
+		wantRate  float64
+		wantTax   float64
+		wantDue   float64
+	}{
+		{
+			name:      "exclusive",
+			product:   epharma.Product{ID: 1, TaxClassID: &vat},
+			quantity:  2,
+			price:     50,
+			wantClass: &vat,
+			wantRate:  18,
+			wantTax:   18,
+			wantDue:   118,
+		},
+		{
+			name:      "inclusive",
+			product:   epharma.Product{ID: 1, TaxClassID: &vat, PriceIncludesTax: true},
+			quantity:  1,
+			price:     118,
+			wantClass: &vat,
+			wantRate:  18,
+			wantTax:   18,
+			wantDue:   118,
+		},
+		{
+			name:      "exclusive after a discount",
+			product:   epharma.Product{ID: 1, TaxClassID: &vat},
+			quantity:  1,
+			price:     118,
+			discount:  18,
+			wantClass: &vat,
+			wantRate:  18,
+			wantTax:   18,
+			wantDue:   118,
+		},
+		{
+			name:      "inclusive after a discount",
+			product:   epharma.Product{ID: 1, TaxClassID: &vat, PriceIncludesTax: true},
+			quantity:  1,
+			price:     118,
+			discount:  18,
+			wantClass: &vat,
+			wantRate:  18,
+			wantTax:   15.25,
+			wantDue:   100,
+		},
+		{
+			name:      "exclusive rounded to the cent",
+			product:   epharma.Product{ID: 1, TaxClassID: &vat},
+			quantity:  1,
+			price:     3.33,
+			wantClass: &vat,
+			wantRate:  18,
+			wantTax:   0.6,
+			wantDue:   3.93,
+		},
+		{
+			name:      "zero rated",
+			product:   epharma.Product{ID: 1, TaxClassID: &zeroRated},
+			quantity:  3,
+			price:     10,
+			wantClass: &zeroRated,
+			wantDue:   30,
+		},
+		{
+			name:      "exempt",
+			product:   epharma.Product{ID: 1, TaxClassID: &exempt, PriceIncludesTax: true},
+			quantity:  3,
+			price:     10,
+			wantClass: &exempt,
+			wantDue:   30,
+		},
+		{
+			name:     "no tax class",
+			product:  epharma.Product{ID: 1},
+			quantity: 3,
+			price:    10,
+			wantDue:  30,
+		},
+	}
+
+	const epsilon = 1e-9
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := []epharma.CreateTransactionItemParams{{
+				ProductID: tt.product.ID,
+				Quantity:  tt.quantity,
+				UnitPrice: tt.price,
+				Discount:  tt.discount,
+			}}
+			applyTax(lines, map[int32]epharma.Product{tt.product.ID: tt.product}, classes)
+			line := lines[0]
+
+			if (line.TaxClassID == nil) != (tt.wantClass == nil) ||
+				(line.TaxClassID != nil && *line.TaxClassID != *tt.wantClass) {
+				t.Errorf("tax class = %v, want %v", line.TaxClassID, tt.wantClass)
+			}
+
+			if line.TaxRate != tt.wantRate {
+				t.Errorf("tax rate = %v, want %v", line.TaxRate, tt.wantRate)
+			}
+
+			if line.TaxInclusive != tt.product.PriceIncludesTax {
+				t.Errorf("tax inclusive = %v, want %v", line.TaxInclusive, tt.product.PriceIncludesTax)
+			}
+
+			if math.Abs(line.Tax-tt.wantTax) > epsilon {
+				t.Errorf("tax = %v, want %v", line.Tax, tt.wantTax)
+			}
+
+			lineTotal := float64(line.Quantity)*line.UnitPrice - line.Discount
+			if due := lineAmountDue(lineTotal, line.Tax, line.TaxInclusive); math.Abs(due-tt.wantDue) > epsilon {
+				t.Errorf("lineAmountDue() = %v, want %v", due, tt.wantDue)
+			}
+		})
+	}
+}
+
+func TestLineAmountDue(t *testing.T) {
+	tests := []struct {
+		name         string
+		lineTotal    float64
+		tax          float64
+		taxInclusive bool
+		want         float64
+	}{
+		{"exclusive", 100, 18, false, 118},
+		{"inclusive", 118, 18, true, 118},
+		{"untaxed", 100, 0, false, 100},
+	}
+
+	for _, tt := range tests {
+		if got := lineAmountDue(tt.lineTotal, tt.tax, tt.taxInclusive); got != tt.want {
+			t.Errorf("%s: lineAmountDue() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,23 +1,35 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/abiiranathan/egor/egor"
 	"github.com/abiiranathan/epharmacy/epharma"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 const timezone = "Africa/Kampala"
 
 type Transaction struct {
-	ID        int32             `json:"id"`
-	Products  []epharma.Product `json:"products"`
-	CreatedAt time.Time         `json:"created_at"`
-	UserID    int32             `json:"user_id"`
+	ID        int32                             `json:"id"`
+	Items     []epharma.ListTransactionItemsRow `json:"items"`
+	Payments  []epharma.Payment                 `json:"payments"`
+	Change    float64                           `json:"change"`
+	CreatedAt time.Time                         `json:"created_at"`
+	UserID    int32                             `json:"user_id"`
+}
+
+// SaleLine is a line of a sale submitted by the POS.
+type SaleLine struct {
+	ID       int32 `json:"id"`
+	Quantity int32 `json:"quantity"`
+	Discount
 }
 
 func Now() time.Time {
@@ -42,23 +54,56 @@ func groupTransactionsByDate(transactions []Transaction) map[string][]Transactio
 	return transactionsByDate
 }
 
-func convertTransaction(transaction epharma.Transaction) Transaction {
-	t := Transaction{
-		ID:        transaction.ID,
-		Products:  []epharma.Product{},
-		CreatedAt: transaction.CreatedAt,
-		UserID:    transaction.UserID,
+// loadTransactions fetches the line items and payments of the transactions.
+func loadTransactions(ctx context.Context, q *epharma.Queries, transactions []epharma.Transaction) ([]Transaction, error) {
+	ids := make([]int32, 0, len(transactions))
+	for _, transaction := range transactions {
+		ids = append(ids, transaction.ID)
+	}
+
+	items, err := q.ListTransactionItems(ctx, ids)
+	if err != nil {
+		return nil, err
 	}
 
-	err := json.Unmarshal(transaction.Items, &t.Products)
+	payments, err := q.ListPayments(ctx, ids)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
-	return t
+
+	itemsByTransaction := make(map[int32][]epharma.ListTransactionItemsRow)
+	for _, item := range items {
+		itemsByTransaction[item.TransactionID] = append(itemsByTransaction[item.TransactionID], item)
+	}
+
+	paymentsByTransaction := make(map[int32][]epharma.Payment)
+	changeByTransaction := make(map[int32]float64)
+	for _, payment := range payments {
+		paymentsByTransaction[payment.TransactionID] = append(paymentsByTransaction[payment.TransactionID], payment)
+		changeByTransaction[payment.TransactionID] += payment.Change
+	}
+
+	result := make([]Transaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		result = append(result, Transaction{
+			ID:        transaction.ID,
+			Items:     itemsByTransaction[transaction.ID],
+			Payments:  paymentsByTransaction[transaction.ID],
+			Change:    changeByTransaction[transaction.ID],
+			CreatedAt: transaction.CreatedAt,
+			UserID:    transaction.UserID,
+		})
+	}
+	return result, nil
 }
 
 // ListTransactionsPaginated
 func (h *Handlers) ListTransactionsPaginated(w http.ResponseWriter, r *http.Request) {
+	if format := exportFormat(r); format != "" {
+		h.exportTransactions(w, r, format)
+		return
+	}
+
 	page := egor.QueryInt(r, "page", 1)
 	limit := egor.QueryInt(r, "limit", 10)
 
@@ -74,10 +119,10 @@ func (h *Handlers) ListTransactionsPaginated(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// convert to a slice of transactions
-	transactionsList := []Transaction{}
-	for _, transaction := range transactions {
-		transactionsList = append(transactionsList, convertTransaction(transaction))
+	transactionsList, err := loadTransactions(r.Context(), h.Queries, transactions)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
 	}
 
 	egor.Render(w, r, "transactions/list", egor.Map{
@@ -90,10 +135,13 @@ func (h *Handlers) ListTransactionsPaginated(w http.ResponseWriter, r *http.Requ
 
 // CreateTransaction /POST
 func (h *Handlers) CreateTransaction(w http.ResponseWriter, r *http.Request) {
-	userId := egor.GetContextValue(r, "user").(epharma.User).ID
+	user := egor.GetContextValue(r, "user").(epharma.User)
 
 	type Payload struct {
-		Products []epharma.Product `json:"products"`
+		Products       []SaleLine `json:"products"`
+		BasketDiscount Discount   `json:"basket_discount"`
+		OverridePin    string     `json:"override_pin"` // PIN approving a discount above the cashier's limit
+		Payments       []Tender   `json:"payments"`
 	}
 
 	var payload Payload
@@ -103,38 +151,50 @@ func (h *Handlers) CreateTransaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// for each product, check if the quantity is available and update the stock
-	for i := range payload.Products {
-		product := payload.Products[i]
-		stock, err := h.Queries.GetProduct(r.Context(), product.ID)
-		if err != nil {
-			egor.SendJSONError(w, map[string]any{"error": err}, http.StatusBadRequest)
+	if len(payload.Products) == 0 {
+		egor.SendJSONError(w, map[string]any{"error": "No products in transaction"}, http.StatusBadRequest)
+		return
+	}
+
+	for _, product := range payload.Products {
+		if product.Quantity <= 0 {
+			egor.SendJSONError(w, map[string]any{"error": "Invalid quantity"}, http.StatusBadRequest)
 			return
 		}
+	}
 
-		if stock.Quantity < product.Quantity {
-			egor.SendJSONError(w, map[string]any{"error": "Insufficient stock"}, http.StatusUnprocessableEntity)
+	// A retried submission carries the key of the original sale.
+	var idempotencyKey *string
+	if key := strings.TrimSpace(r.Header.Get("Idempotency-Key")); key != "" {
+		if len(key) > 100 {
+			egor.SendJSONError(w, map[string]any{"error": "Idempotency-Key is too long"}, http.StatusBadRequest)
 			return
 		}
+		idempotencyKey = &key
 
-		product.ID = stock.ID
-		product.Barcode = stock.Barcode
-		product.GenericName = stock.GenericName
-		product.BrandName = stock.BrandName
-		product.CostPrice = stock.CostPrice
-		product.SellingPrice = stock.SellingPrice
-		product.CreatedAt = Now()
-		payload.Products[i] = product
+		if h.replayTransaction(w, r, idempotencyKey) {
+			return
+		}
 	}
 
-	items, err := json.Marshal(payload.Products)
+	// The override PIN is checked before the products are locked so that
+	// the sale does not hold the locks while bcrypt runs.
+	approver, err := verifyOverridePin(r.Context(), h.Queries, user, payload.OverridePin)
 	if err != nil {
-		egor.SendJSONError(w, map[string]any{"error": err}, http.StatusUnprocessableEntity)
+		var lockoutErr *PinLockoutError
+		switch {
+		case errors.Is(err, errWrongPin):
+			egor.SendJSONError(w, map[string]any{"error": err.Error(), "approval_required": true}, http.StatusForbidden)
+		case errors.As(err, &lockoutErr):
+			egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusTooManyRequests)
+		default:
+			egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		}
 		return
 	}
 
 	// Start transaction
-	tx, err := h.Conn.Begin(r.Context())
+	tx, err := h.Pool.Begin(r.Context())
 	if err != nil {
 		egor.SendJSONError(w, map[string]any{"error": "unable to init database transaction"})
 		return
@@ -143,46 +203,289 @@ func (h *Handlers) CreateTransaction(w http.ResponseWriter, r *http.Request) {
 	defer tx.Rollback(r.Context())
 	qtx := h.Queries.WithTx(tx)
 
-	// Reduce quantity for each product
+	// A concurrent retry waits here for the first submission to finish and
+	// then replays its sale instead of finding the stock already sold.
+	if idempotencyKey != nil {
+		if err := qtx.LockIdempotencyKey(r.Context(), *idempotencyKey); err != nil {
+			egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+
+		if h.replayTransaction(w, r, idempotencyKey) {
+			return
+		}
+	}
+
+	// Lock the products and check the stock of every line before selling.
+	// A concurrent sale of the same product waits until this one commits.
+	products, err := lockStock(r.Context(), qtx, payload.Products)
+	if err != nil {
+		var stockErr *InsufficientStockError
+		if errors.As(err, &stockErr) {
+			egor.SendJSONError(w, map[string]any{
+				"error":     "Insufficient stock",
+				"shortages": stockErr.Shortages,
+			}, http.StatusUnprocessableEntity)
+			return
+		}
+		egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Capture the price and cost at the time of sale.
+	lines := make([]epharma.CreateTransactionItemParams, 0, len(payload.Products))
+	discounts := make([]Discount, 0, len(payload.Products))
 	for _, product := range payload.Products {
-		err = qtx.DecrementProduct(r.Context(), epharma.DecrementProductParams{
-			ID:       product.ID,
-			Quantity: product.Quantity,
+		stock := products[product.ID]
+		lines = append(lines, epharma.CreateTransactionItemParams{
+			ProductID: stock.ID,
+			Quantity:  product.Quantity,
+			UnitPrice: stock.SellingPrice,
+			UnitCost:  stock.CostPrice,
 		})
-		if err != nil {
-			egor.SendError(w, r, fmt.Errorf("decrement product quantity failed: %v", err), http.StatusNotFound)
+		discounts = append(discounts, product.Discount)
+	}
+
+	discountPercent, err := applyDiscounts(lines, discounts, payload.BasketDiscount)
+	if err != nil {
+		egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	approvedBy, err := approveDiscount(r.Context(), qtx, user, discountPercent, approver)
+	if err != nil {
+		var limitErr *DiscountLimitError
+		if errors.As(err, &limitErr) {
+			egor.SendJSONError(w, map[string]any{
+				"error":             err.Error(),
+				"approval_required": true,
+				"discount":          limitErr.Percent,
+				"limit":             limitErr.Limit,
+			}, http.StatusForbidden)
 			return
 		}
+		egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	taxClasses, err := qtx.ListTaxClasses(r.Context())
+	if err != nil {
+		egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	classes := make(map[int32]epharma.TaxClass, len(taxClasses))
+	for _, class := range taxClasses {
+		classes[class.ID] = class
+	}
+	applyTax(lines, products, classes)
+
+	var total float64
+	for _, line := range lines {
+		lineTotal := float64(line.Quantity)*line.UnitPrice - line.Discount
+		total += lineAmountDue(lineTotal, line.Tax, line.TaxInclusive)
+	}
+
+	payments, err := settlePayments(total, payload.Payments)
+	if err != nil {
+		egor.SendJSONError(w, map[string]any{"error": err.Error(), "total": total}, http.StatusUnprocessableEntity)
+		return
 	}
 
 	transaction, err := qtx.CreateTransaction(r.Context(), epharma.CreateTransactionParams{
-		UserID: userId,
-		Items:  items,
+		UserID:             user.ID,
+		IdempotencyKey:     idempotencyKey,
+		DiscountApprovedBy: approvedBy,
 	})
-
 	if err != nil {
+		// A concurrent retry recorded the sale first.
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && idempotencyKey != nil {
+			tx.Rollback(r.Context())
+			if h.replayTransaction(w, r, idempotencyKey) {
+				return
+			}
+		}
 		egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
 		return
 	}
 
+	for _, line := range lines {
+		// The line is inserted before the stock is taken so that the
+		// stock balance trigger records the opening quantity.
+		line.TransactionID = transaction.ID
+		item, err := qtx.CreateTransactionItem(r.Context(), line)
+		if err != nil {
+			egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+
+		// Take the product from its batches, earliest expiry first
+		// and record the batches so that a cancellation can return them.
+		allocations, err := sellFromBatches(r.Context(), qtx, line.ProductID, item.Quantity)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errInsufficientStock) {
+				status = http.StatusConflict
+			}
+			egor.SendJSONError(w, map[string]any{
+				"error": fmt.Sprintf("decrement product quantity failed: %v", err),
+			}, status)
+			return
+		}
+
+		for _, allocation := range allocations {
+			err = qtx.CreateTransactionBatch(r.Context(), epharma.CreateTransactionBatchParams{
+				TransactionID: transaction.ID,
+				ProductID:     line.ProductID,
+				BatchID:       &allocation.BatchID,
+				Quantity:      allocation.Quantity,
+				UnitCost:      allocation.UnitCost,
+			})
+			if err != nil {
+				egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// The cost of the line is the cost of the batches it was drawn from.
+		err = qtx.SetTransactionItemCost(r.Context(), epharma.SetTransactionItemCostParams{
+			ID:       item.ID,
+			UnitCost: averageUnitCost(allocations),
+		})
+		if err != nil {
+			egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for _, payment := range payments {
+		payment.TransactionID = transaction.ID
+		if _, err := qtx.CreatePayment(r.Context(), payment); err != nil {
+			egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// commit transaction
-	tx.Commit(r.Context())
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
 
 	// Return the JSON
-	egor.SendJSON(w, convertTransaction(transaction))
+	result, err := loadTransactions(r.Context(), h.Queries, []epharma.Transaction{transaction})
+	if err != nil {
+		egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	egor.SendJSON(w, result[0])
+}
+
+// exportTransactions streams every sale, a row per item sold, newest first.
+func (h *Handlers) exportTransactions(w http.ResponseWriter, r *http.Request, format string) {
+	headers := []string{"Transaction ID", "Date", "User ID", "Product ID", "Generic Name", "Brand Name",
+		"Quantity", "Unit Price", "Discount", "Tax", "Amount"}
+
+	exportRows(w, r, format, "transactions", headers, func(offset int32) ([][]any, bool, error) {
+		transactions, err := h.Queries.ListTransactionsPaginated(r.Context(), epharma.ListTransactionsPaginatedParams{
+			Offset: offset,
+			Limit:  exportBatchSize,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+
+		loaded, err := loadTransactions(r.Context(), h.Queries, transactions)
+		if err != nil {
+			return nil, false, err
+		}
+
+		var rows [][]any
+		for _, t := range loaded {
+			for _, item := range t.Items {
+				// Lines of deleted products have no product ID.
+				var productID any
+				if item.ProductID != nil {
+					productID = *item.ProductID
+				}
+
+				rows = append(rows, []any{t.ID, t.CreatedAt, t.UserID, productID, item.GenericName,
+					item.BrandName, item.Quantity, item.UnitPrice, item.Discount, item.Tax,
+					lineAmountDue(item.LineTotal, item.Tax, item.TaxInclusive)})
+			}
+		}
+		return rows, len(transactions) == exportBatchSize, nil
+	})
+}
+
+// replayTransaction sends the sale recorded with the idempotency key.
+// It reports false if there is no such sale.
+func (h *Handlers) replayTransaction(w http.ResponseWriter, r *http.Request, key *string) bool {
+	transaction, err := h.Queries.GetTransactionByIdempotencyKey(r.Context(), key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return true
+	}
+
+	result, err := loadTransactions(r.Context(), h.Queries, []epharma.Transaction{transaction})
+	if err != nil {
+		egor.SendJSONError(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return true
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	egor.SendJSON(w, result[0])
+	return true
 }
 
 // GetTransaction
 func (h *Handlers) GetTransaction(w http.ResponseWriter, r *http.Request) {
 	transactionID := egor.ParamInt(r, "id")
+	if egor.Query(r, "format") == "pdf" {
+		receipt, err := h.loadReceipt(r.Context(), int32(transactionID), 80)
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusNotFound)
+			return
+		}
+		h.receiptPDF(w, receipt)
+		return
+	}
+
 	transaction, err := h.Queries.GetTransaction(r.Context(), int32(transactionID))
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
 	}
 
+	batches, err := h.Queries.ListTransactionBatches(r.Context(), transaction.ID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	result, err := loadTransactions(r.Context(), h.Queries, []epharma.Transaction{transaction})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	// User who approved a discount above the cashier's limit.
+	var approver *epharma.User
+	if transaction.DiscountApprovedBy != nil {
+		user, err := h.Queries.GetUser(r.Context(), *transaction.DiscountApprovedBy)
+		if err == nil {
+			approver = &user
+		}
+	}
+
 	egor.Render(w, r, "transactions/detail", egor.Map{
-		"transaction": convertTransaction(transaction),
+		"transaction":    result[0],
+		"batches":        batches,
+		"approver":       approver,
+		"receiptPrinter": h.ReceiptPrinter != "",
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Transactions", URL: "/transactions"},
 			{Label: fmt.Sprintf("Transaction #%d", transactionID), IsLast: true},
@@ -193,7 +496,7 @@ func (h *Handlers) GetTransaction(w http.ResponseWriter, r *http.Request) {
 // DeleteTransaction
 func (h *Handlers) DeleteTransaction(w http.ResponseWriter, r *http.Request) {
 	transactionID := egor.ParamInt(r, "id")
-	tx, err := h.Conn.Begin(r.Context())
+	tx, err := h.Pool.Begin(r.Context())
 	if err != nil {
 		egor.SendError(w, r, fmt.Errorf("unable to init database transaction: %v", err))
 		return
@@ -202,27 +505,14 @@ func (h *Handlers) DeleteTransaction(w http.ResponseWriter, r *http.Request) {
 
 	qtx := h.Queries.WithTx(tx)
 
-	trans, err := qtx.GetTransaction(r.Context(), int32(transactionID))
+	// The sale is locked so that a concurrent cancellation waits and then
+	// finds it deleted instead of returning its stock a second time.
+	trans, err := qtx.GetTransactionForUpdate(r.Context(), int32(transactionID))
 	if err != nil {
 		egor.SendError(w, r, fmt.Errorf("transaction not found: %v", err), http.StatusNotFound)
 		return
 	}
 
-	// convert items from bytes (JSONB) to []epharma.Product
-	transaction := convertTransaction(trans)
-
-	// For each item, re-increment quantity
-	for _, product := range transaction.Products {
-		err = qtx.IncrementProduct(r.Context(), epharma.IncrementProductParams{
-			ID:       product.ID,
-			Quantity: product.Quantity,
-		})
-		if err != nil {
-			egor.SendError(w, r, fmt.Errorf("re-increment product quantity failed: %v", err), http.StatusNotFound)
-			return
-		}
-	}
-
 	// Only delete transaction within 1 hour
 	duration := time.Since(trans.CreatedAt)
 	if duration > time.Hour {
@@ -232,6 +522,66 @@ func (h *Handlers) DeleteTransaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	allocations, err := qtx.ListTransactionBatches(r.Context(), trans.ID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	items, err := qtx.ListTransactionItems(r.Context(), []int32{trans.ID})
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Lock the products before their batches, in id order as sales do.
+	productIDs := make([]int32, 0, len(items))
+	for _, item := range items {
+		if item.ProductID != nil {
+			productIDs = append(productIDs, *item.ProductID)
+		}
+	}
+
+	if _, err := qtx.LockProducts(r.Context(), productIDs); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Transactions made before batch allocations were recorded
+	// return their quantity to each product's latest batch.
+	if len(allocations) == 0 {
+		for _, item := range items {
+			// The product of the line has been deleted.
+			if item.ProductID == nil {
+				continue
+			}
+
+			err = returnToBatch(r.Context(), qtx, *item.ProductID, item.Quantity)
+			if err != nil {
+				egor.SendError(w, r, fmt.Errorf("re-increment product quantity failed: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	// Return units to the batches they were taken from.
+	for _, allocation := range allocations {
+		if allocation.BatchID != nil {
+			err = qtx.IncrementBatch(r.Context(), epharma.IncrementBatchParams{
+				ID:       *allocation.BatchID,
+				Quantity: allocation.Quantity,
+			})
+		} else {
+			// The batch was deleted since the sale.
+			err = returnToBatch(r.Context(), qtx, allocation.ProductID, allocation.Quantity)
+		}
+
+		if err != nil {
+			egor.SendError(w, r, fmt.Errorf("re-increment product quantity failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	err = qtx.DeleteTransaction(r.Context(), int32(transactionID))
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
@@ -239,6 +589,9 @@ func (h *Handlers) DeleteTransaction(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Commit the transaction
-	tx.Commit(r.Context())
+	if err := tx.Commit(r.Context()); err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
 	egor.Redirect(w, r, "/transactions")
 }
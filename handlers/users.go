@@ -1,20 +1,37 @@
 package handlers
 
 import (
-	"encoding/base64"
 	"fmt"
+	"log"
 	"net/http"
-	"strconv"
 
 	"github.com/abiiranathan/egor/egor"
 	"github.com/abiiranathan/epharmacy/epharma"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // CreateUser
 func (h *Handlers) CreateUser(w http.ResponseWriter, r *http.Request) {
+	password, err := hashPassword(r.FormValue("password"))
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	role := epharma.UserRole(r.FormValue("role"))
+	if role == "" {
+		role = epharma.UserRoleCashier
+	}
+
+	if !role.Valid() {
+		egor.SendError(w, r, fmt.Errorf("invalid role: %q", role), http.StatusBadRequest)
+		return
+	}
+
 	user, err := h.Queries.CreateUser(r.Context(), epharma.CreateUserParams{
 		Username: r.FormValue("username"),
-		Password: r.FormValue("password"),
+		Password: password,
+		Role:     role,
 	})
 
 	if err != nil {
@@ -31,8 +48,18 @@ func (h *Handlers) ListUsers(w http.ResponseWriter, r *http.Request) {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
 	}
+
+	discountLimits, err := h.Queries.ListRoleDiscountLimits(r.Context())
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
 	egor.Render(w, r, "accounts/list", egor.Map{
-		"users": users,
+		"users":          users,
+		"roles":          epharma.AllUserRoleValues(),
+		"permissions":    permissionMatrix(),
+		"discountLimits": discountLimits,
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Users", IsLast: true},
 		},
@@ -41,6 +68,7 @@ func (h *Handlers) ListUsers(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handlers) RenderUserCreatePage(w http.ResponseWriter, r *http.Request) {
 	egor.Render(w, r, "accounts/create", egor.Map{
+		"roles": epharma.AllUserRoleValues(),
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Users", URL: "/users"},
 			{Label: "Create User", IsLast: true},
@@ -57,7 +85,7 @@ func (h *Handlers) RenderUserEditPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	egor.Render(w, r, "accounts/update", egor.Map{
-		"user": user,
+		"account": user,
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Users", URL: "/users"},
 			{Label: user.Username, URL: fmt.Sprintf("/users/%d", user.ID)},
@@ -75,8 +103,15 @@ func (h *Handlers) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hasPin, err := h.Queries.UserHasPin(r.Context(), user.ID)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
 	egor.Render(w, r, "accounts/view", egor.Map{
-		"user": user,
+		"account": user,
+		"hasPin":  hasPin,
 		"breadcrumbs": Breadcrumbs{
 			{Label: "Users", URL: "/users"},
 			{Label: user.Username, IsLast: true},
@@ -97,11 +132,28 @@ func (h *Handlers) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	params.ID = int32(userID)
 	params.UpdatePassword = params.Password != ""
 
+	if params.UpdatePassword {
+		params.Password, err = hashPassword(params.Password)
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusBadRequest)
+			return
+		}
+	}
+
 	err = h.Queries.UpdateUser(r.Context(), params)
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusBadRequest)
 		return
 	}
+
+	// A new password logs the user out of every device.
+	if params.UpdatePassword {
+		err = h.Queries.DeleteUserSessions(r.Context(), params.ID)
+		if err != nil {
+			egor.SendError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+	}
 	egor.Redirect(w, r, fmt.Sprintf("/users/%d", userID))
 }
 
@@ -135,13 +187,9 @@ func (h *Handlers) DeActivateUser(w http.ResponseWriter, r *http.Request) {
 		egor.SendError(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	egor.Redirect(w, r, "/users")
-}
 
-// PromoteUser
-func (h *Handlers) PromoteUser(w http.ResponseWriter, r *http.Request) {
-	userID := egor.ParamInt(r, "id")
-	err := h.Queries.PromoteUser(r.Context(), int32(userID))
+	// log the user out of every device
+	err = h.Queries.DeleteUserSessions(r.Context(), int32(userID))
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusInternalServerError)
 		return
@@ -149,10 +197,26 @@ func (h *Handlers) PromoteUser(w http.ResponseWriter, r *http.Request) {
 	egor.Redirect(w, r, "/users")
 }
 
-// DemoteUser
-func (h *Handlers) DemoteUser(w http.ResponseWriter, r *http.Request) {
+// SetUserRole
+func (h *Handlers) SetUserRole(w http.ResponseWriter, r *http.Request) {
 	userID := egor.ParamInt(r, "id")
-	err := h.Queries.DemoteUser(r.Context(), int32(userID))
+	role := epharma.UserRole(r.FormValue("role"))
+	if !role.Valid() {
+		egor.SendError(w, r, fmt.Errorf("invalid role: %q", role), http.StatusBadRequest)
+		return
+	}
+
+	// Prevent admins from locking themselves out of user management.
+	user := egor.GetContextValue(r, "user").(epharma.User)
+	if user.ID == int32(userID) {
+		egor.SendError(w, r, fmt.Errorf("you can not change your own role"), http.StatusForbidden)
+		return
+	}
+
+	err := h.Queries.SetUserRole(r.Context(), epharma.SetUserRoleParams{
+		ID:   int32(userID),
+		Role: role,
+	})
 	if err != nil {
 		egor.SendError(w, r, err, http.StatusInternalServerError)
 		return
@@ -170,28 +234,6 @@ func (h *Handlers) RenderLoginPage(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func encodeUserId(id int32) string {
-	// base64 encode the user id using 32 bytes
-	userIdStr := fmt.Sprintf("%d", id)
-
-	return base64.StdEncoding.EncodeToString([]byte(userIdStr))
-}
-
-func decodeUserId(encodedId string) (int32, error) {
-	// base64 decode the user id
-	userIdStr, err := base64.StdEncoding.DecodeString(encodedId)
-	if err != nil {
-		return 0, err
-	}
-
-	userId, err := strconv.Atoi(string(userIdStr))
-	if err != nil {
-		return 0, err
-	}
-
-	return int32(userId), nil
-}
-
 // Login
 func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
 	username := r.FormValue("username")
@@ -208,27 +250,46 @@ func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.Queries.GetUserByUsername(r.Context(), username)
 	if err != nil {
+		// Keep the response time the same as for a wrong password.
+		verifyPassword(string(dummyPasswordHash), password)
 		egor.Render(w, r, "login", invalidCtx)
 		return
 	}
 
-	if user.Password != password {
+	ok, legacy := verifyPassword(user.Password, password)
+	if !ok {
 		egor.Render(w, r, "login", invalidCtx)
 		return
 	}
 
-	// Set the cookie to the request
-	cookie := http.Cookie{
-		Name:     "user_id",
-		Value:    encodeUserId(user.ID),
-		Path:     "/",
-		MaxAge:   3600 * 24, // 24 hours
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-		Secure:   false,
+	// Rehash passwords stored in plaintext before passwords were hashed.
+	// The policy is not applied here since the password is already in use.
+	// A password that can not be hashed, such as one over 72 bytes, is left
+	// as it is so that the user can still log in and change it.
+	if legacy {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err == nil {
+			err = h.Queries.UpdateUserPassword(r.Context(), epharma.UpdateUserPasswordParams{
+				ID:       user.ID,
+				Password: string(hash),
+			})
+		}
+
+		if err != nil {
+			log.Printf("Error rehashing the legacy password of user %d: %v\n", user.ID, err)
+		}
+	}
+
+	if !user.IsActive {
+		egor.Render(w, r, "login", invalidCtx)
+		return
 	}
 
-	http.SetCookie(w, &cookie)
+	err = h.startSession(w, r, user)
+	if err != nil {
+		egor.SendError(w, r, err, http.StatusInternalServerError)
+		return
+	}
 
 	next := r.URL.Query().Get("next")
 	if next == "" {
@@ -240,13 +301,10 @@ func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
 
 // Logout
 func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
-	// get the cookie and make it expire
-	cookie := http.Cookie{
-		Name:   "user_id",
-		Path:   "/",
-		MaxAge: -1,
+	// delete the session and expire the cookie
+	err := h.endSession(w, r)
+	if err != nil {
+		log.Println(err)
 	}
-
-	http.SetCookie(w, &cookie)
 	egor.Redirect(w, r, "/login")
 }
@@ -3,14 +3,17 @@ package main
 import (
 	"context"
 	"embed"
+	"flag"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/abiiranathan/dbtypes"
+	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/abiiranathan/egor/egor"
 	"github.com/abiiranathan/egor/egor/middleware/cors"
@@ -41,8 +44,26 @@ const (
 	// Database connection URI environment variable.
 	EPHARMA_PG_URL_ENV = "EPHARMA_PG_URL"
 
+	// Connection pool environment variables.
+	// Durations are parsed with time.ParseDuration e.g "30m", "1h".
+	EPHARMA_PG_MAX_CONNS_ENV          = "EPHARMA_PG_MAX_CONNS"
+	EPHARMA_PG_MIN_CONNS_ENV          = "EPHARMA_PG_MIN_CONNS"
+	EPHARMA_PG_HEALTH_CHECK_ENV       = "EPHARMA_PG_HEALTH_CHECK_PERIOD"
+	EPHARMA_PG_MAX_CONN_LIFETIME_ENV  = "EPHARMA_PG_MAX_CONN_LIFETIME"
+	EPHARMA_PG_MAX_CONN_IDLE_TIME_ENV = "EPHARMA_PG_MAX_CONN_IDLE_TIME"
+
 	// Port environment variable.
 	PORT_ENV = "PORT"
+
+	// Pharmacy details printed on receipts and the letterhead of PDF documents.
+	EPHARMA_PHARMACY_NAME_ENV    = "EPHARMA_PHARMACY_NAME"
+	EPHARMA_PHARMACY_ADDRESS_ENV = "EPHARMA_PHARMACY_ADDRESS"
+	EPHARMA_PHARMACY_PHONE_ENV   = "EPHARMA_PHARMACY_PHONE"
+	EPHARMA_PHARMACY_EMAIL_ENV   = "EPHARMA_PHARMACY_EMAIL"
+	EPHARMA_PHARMACY_TIN_ENV     = "EPHARMA_PHARMACY_TIN"
+
+	// Address (host:port) of a network thermal printer for receipts.
+	EPHARMA_RECEIPT_PRINTER_ENV = "EPHARMA_RECEIPT_PRINTER"
 )
 
 // Loads all templates in TemplateDir recursively.
@@ -54,15 +75,114 @@ func loadTemplates() *template.Template {
 	return tmpl
 }
 
+// Parses the database URL and applies the pool settings from the environment.
+// Settings that are not set keep the pgxpool defaults.
+func poolConfig() (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig(os.Getenv(EPHARMA_PG_URL_ENV))
+	if err != nil {
+		return nil, err
+	}
+
+	if v := os.Getenv(EPHARMA_PG_MAX_CONNS_ENV); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", EPHARMA_PG_MAX_CONNS_ENV, err)
+		}
+
+		if n < 1 {
+			return nil, fmt.Errorf("invalid %s: %d, must be at least 1", EPHARMA_PG_MAX_CONNS_ENV, n)
+		}
+		config.MaxConns = int32(n)
+	}
+
+	if v := os.Getenv(EPHARMA_PG_MIN_CONNS_ENV); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", EPHARMA_PG_MIN_CONNS_ENV, err)
+		}
+
+		if n < 0 {
+			return nil, fmt.Errorf("invalid %s: %d, can not be negative", EPHARMA_PG_MIN_CONNS_ENV, n)
+		}
+		config.MinConns = int32(n)
+	}
+
+	if config.MinConns > config.MaxConns {
+		return nil, fmt.Errorf("%s of %d is more than %s of %d", EPHARMA_PG_MIN_CONNS_ENV, config.MinConns,
+			EPHARMA_PG_MAX_CONNS_ENV, config.MaxConns)
+	}
+
+	durations := map[string]*time.Duration{
+		EPHARMA_PG_HEALTH_CHECK_ENV:       &config.HealthCheckPeriod,
+		EPHARMA_PG_MAX_CONN_LIFETIME_ENV:  &config.MaxConnLifetime,
+		EPHARMA_PG_MAX_CONN_IDLE_TIME_ENV: &config.MaxConnIdleTime,
+	}
+
+	for env, duration := range durations {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", env, err)
+		}
+
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid %s: %s, must be more than 0", env, v)
+		}
+		*duration = d
+	}
+	return config, nil
+}
+
+// repairStockBalances recomputes the daily stock balances from the stock
+// received and sold.
+//
+//	epharmacy repair-stock-balances [-product ID] [-from YYYY-MM-DD]
+func repairStockBalances(ctx context.Context, handler *handlers.Handlers, args []string) error {
+	flags := flag.NewFlagSet("repair-stock-balances", flag.ExitOnError)
+	productID := flags.Int("product", 0, "ID of the product to repair, or 0 for all products")
+	from := flags.String("from", "", "first day to repair, defaults to the day each product was created")
+	flags.Parse(args)
+
+	var fromDate dbtypes.Date
+	if *from != "" {
+		var err error
+		fromDate, err = dbtypes.ParseDateFromString(*from)
+		if err != nil {
+			return fmt.Errorf("invalid from date: %w", err)
+		}
+	}
+
+	n, err := handler.RepairStockBalances(ctx, int32(*productID), fromDate)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Repaired %d stock balances\n", n)
+	return nil
+}
+
 func main() {
 	ctx := context.Background()
 
-	// Connect to database using the pgx/v5 driver.
-	conn, err := pgx.Connect(ctx, os.Getenv(EPHARMA_PG_URL_ENV))
+	config, err := poolConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	// Connect to database using a pgx/v5 connection pool.
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		panic(err)
+	}
+	defer pool.Close()
+
+	err = pool.Ping(ctx)
 	if err != nil {
 		panic(err)
 	}
-	defer conn.Close(ctx)
 
 	// Slice of router options.
 	options := []egor.RouterOption{
@@ -88,8 +208,26 @@ func main() {
 	router.Use(cors.New())
 
 	// create a new instance of the epharma queries
-	queries := epharma.New(conn)
-	handler := handlers.New(queries, conn, router)
+	queries := epharma.New(pool)
+	handler := handlers.New(queries, pool, router)
+	handler.Pharmacy = handlers.Pharmacy{
+		Name:    os.Getenv(EPHARMA_PHARMACY_NAME_ENV),
+		Address: os.Getenv(EPHARMA_PHARMACY_ADDRESS_ENV),
+		Phone:   os.Getenv(EPHARMA_PHARMACY_PHONE_ENV),
+		Email:   os.Getenv(EPHARMA_PHARMACY_EMAIL_ENV),
+		TIN:     os.Getenv(EPHARMA_PHARMACY_TIN_ENV),
+	}
+	handler.ReceiptPrinter = os.Getenv(EPHARMA_RECEIPT_PRINTER_ENV)
+
+	if len(os.Args) > 1 && os.Args[1] == "repair-stock-balances" {
+		if err := repairStockBalances(ctx, handler, os.Args[2:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	// Snapshot the opening stock balances every day at midnight.
+	go handler.ScheduleStockBalances(ctx)
 
 	// Serve the static files
 	router.StaticFS("/static", http.FS(static))
@@ -0,0 +1,328 @@
+// Package pdf writes simple A4 documents of text, rules and tables
+// using the standard Helvetica fonts, which PDF readers provide,
+// so that no fonts need to be embedded.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A4 page size and margins in points.
+const (
+	pageWidth    = 595.28
+	pageHeight   = 841.89
+	margin       = 40.0
+	contentWidth = pageWidth - 2*margin
+	footerHeight = 20.0
+	lineSpacing  = 1.4
+	cellPadding  = 3.0
+)
+
+// Align is the horizontal alignment of text.
+type Align int
+
+const (
+	Left Align = iota
+	Center
+	Right
+)
+
+// Column of a table. Widths are relative to the other columns
+// and the table spans the width of the page.
+type Column struct {
+	Header string
+	Width  float64
+	Align  Align
+}
+
+// Document is a PDF document written top to bottom. Text and tables
+// flow onto new pages when a page is full.
+type Document struct {
+	Title string
+
+	pages    []*bytes.Buffer
+	page     *bytes.Buffer
+	y        float64 // Distance of the cursor from the top of the page
+	bold     bool
+	fontSize float64
+}
+
+// New creates a document with one empty page.
+func New(title string) *Document {
+	d := &Document{Title: title, fontSize: 10}
+	d.AddPage()
+	return d
+}
+
+// AddPage starts a new page and moves the cursor to its top.
+func (d *Document) AddPage() {
+	d.page = new(bytes.Buffer)
+	d.pages = append(d.pages, d.page)
+	d.y = margin
+}
+
+// SetFont sets the font of the text that follows.
+func (d *Document) SetFont(bold bool, size float64) {
+	d.bold = bold
+	d.fontSize = size
+}
+
+// Space moves the cursor down by h points.
+func (d *Document) Space(h float64) {
+	d.y += h
+}
+
+// Text writes a paragraph wrapped to the width of the page.
+func (d *Document) Text(s string, align Align) {
+	for _, line := range d.wrap(encode(s), contentWidth) {
+		d.ensureSpace(d.lineHeight())
+		d.drawText(line, margin, contentWidth, align)
+		d.y += d.lineHeight()
+	}
+}
+
+// Columns writes left and right aligned text on one line.
+func (d *Document) Columns(left, right string) {
+	d.ensureSpace(d.lineHeight())
+	d.drawText(encode(left), margin, contentWidth, Left)
+	d.drawText(encode(right), margin, contentWidth, Right)
+	d.y += d.lineHeight()
+}
+
+// Rule draws a line across the page.
+func (d *Document) Rule() {
+	d.ensureSpace(6)
+	d.y += 3
+	d.line(margin, margin+contentWidth)
+	d.y += 3
+}
+
+// Table writes a table with a header row, repeated on every page it
+// spans, and an optional footer row of totals in bold.
+// Cell text that does not fit its column is cut short.
+func (d *Document) Table(columns []Column, rows [][]string, footer []string) {
+	var total float64
+	for _, column := range columns {
+		total += column.Width
+	}
+
+	widths := make([]float64, len(columns))
+	for i, column := range columns {
+		widths[i] = column.Width / total * contentWidth
+	}
+
+	bold, size := d.bold, d.fontSize
+	defer d.SetFont(bold, size)
+
+	header := func() {
+		d.SetFont(true, size)
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = column.Header
+		}
+		d.row(columns, widths, cells)
+		d.line(margin, margin+contentWidth)
+		d.SetFont(false, size)
+	}
+
+	d.ensureSpace(3 * d.rowHeight())
+	header()
+	for _, cells := range rows {
+		if d.y+d.rowHeight() > pageHeight-margin-footerHeight {
+			d.AddPage()
+			header()
+		}
+		d.row(columns, widths, cells)
+	}
+
+	if footer != nil {
+		d.ensureSpace(d.rowHeight())
+		d.line(margin, margin+contentWidth)
+		d.SetFont(true, size)
+		d.row(columns, widths, footer)
+	}
+	d.Space(d.lineHeight() / 2)
+}
+
+func (d *Document) row(columns []Column, widths []float64, cells []string) {
+	x := margin
+	for i, column := range columns {
+		if i < len(cells) {
+			text := d.truncate(encode(cells[i]), widths[i]-2*cellPadding)
+			d.drawText(text, x+cellPadding, widths[i]-2*cellPadding, column.Align)
+		}
+		x += widths[i]
+	}
+	d.y += d.rowHeight()
+}
+
+func (d *Document) lineHeight() float64 {
+	return d.fontSize * lineSpacing
+}
+
+func (d *Document) rowHeight() float64 {
+	return d.fontSize*lineSpacing + 2
+}
+
+// ensureSpace starts a new page if h points do not fit on the current page.
+func (d *Document) ensureSpace(h float64) {
+	if d.y+h > pageHeight-margin-footerHeight {
+		d.AddPage()
+	}
+}
+
+// width of encoded text in the current font.
+func (d *Document) width(s []byte) float64 {
+	var w int
+	for _, c := range s {
+		w += charWidth(c, d.bold)
+	}
+	return float64(w) * d.fontSize / 1000
+}
+
+func (d *Document) drawText(s []byte, x, width float64, align Align) {
+	switch align {
+	case Center:
+		x += (width - d.width(s)) / 2
+	case Right:
+		x += width - d.width(s)
+	}
+
+	font := "F1"
+	if d.bold {
+		font = "F2"
+	}
+
+	// The baseline sits a little above the bottom of the line.
+	baseline := pageHeight - d.y - d.fontSize
+	fmt.Fprintf(d.page, "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, d.fontSize, x, baseline, escape(s))
+}
+
+func (d *Document) line(x1, x2 float64) {
+	y := pageHeight - d.y
+	fmt.Fprintf(d.page, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y, x2, y)
+}
+
+// truncate cuts s short with dots to fit in width.
+func (d *Document) truncate(s []byte, width float64) []byte {
+	if d.width(s) <= width {
+		return s
+	}
+
+	dots := []byte("...")
+	for len(s) > 0 && d.width(s)+d.width(dots) > width {
+		s = s[:len(s)-1]
+	}
+	return append(s, dots...)
+}
+
+// wrap breaks s into lines that fit in width, at spaces where possible.
+func (d *Document) wrap(s []byte, width float64) [][]byte {
+	var lines [][]byte
+	var line []byte
+	for _, word := range bytes.Fields(s) {
+		candidate := word
+		if len(line) > 0 {
+			candidate = append(append(append([]byte{}, line...), ' '), word...)
+		}
+
+		if d.width(candidate) <= width || len(line) == 0 {
+			line = candidate
+			continue
+		}
+		lines = append(lines, line)
+		line = word
+	}
+	return append(lines, line)
+}
+
+// encode converts text to the single byte WinAnsi encoding of the
+// standard fonts. Latin-1 characters are kept, others are replaced.
+func encode(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n':
+			b = append(b, ' ')
+		case r >= 32 && r <= 126, r >= 0xA0 && r <= 0xFF:
+			b = append(b, byte(r))
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+func escape(s []byte) string {
+	var b strings.Builder
+	for _, c := range s {
+		if c == '\\' || c == '(' || c == ')' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// WriteTo writes the document with page numbers in the footer of every page.
+// The document is complete once written and must not be written again.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	offsets := []int{}
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+
+	// Objects 1 to 4 are the catalog, the page tree, the fonts
+	// and then each page is followed by its content stream.
+	pageRefs := make([]string, len(d.pages))
+	for i := range d.pages {
+		pageRefs[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(pageRefs, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range d.pages {
+		// Footer with the page number.
+		d.page, d.y = page, pageHeight-margin
+		d.SetFont(false, 8)
+		d.drawText(encode(fmt.Sprintf("Page %d of %d", i+1, len(d.pages))), margin, contentWidth, Right)
+		d.drawText(encode(d.Title), margin, contentWidth, Left)
+
+		var stream bytes.Buffer
+		zw := zlib.NewWriter(&stream)
+		zw.Write(page.Bytes())
+		zw.Close()
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", stream.Len(), stream.Bytes()))
+	}
+
+	object(fmt.Sprintf("<< /Title (%s) /Producer (EPharmacy) /CreationDate (D:%s) >>",
+		escape(encode(d.Title)), time.Now().Format("20060102150405")))
+	info := len(offsets)
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\n", len(offsets)+1, info)
+	fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", xref)
+	return out.WriteTo(w)
+}
@@ -0,0 +1,162 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"(note)", `\(note\)`},
+		{`C:\dir`, `C:\\dir`},
+		{`a\(b)`, `a\\\(b\)`},
+	}
+
+	for _, tt := range tests {
+		if got := escape([]byte(tt.in)); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"abc", "abc"},
+		{"a\tb\nc", "a b c"},
+		{"café", "caf\xE9"},
+		{"€5", "?5"},
+	}
+
+	for _, tt := range tests {
+		if got := string(encode(tt.in)); got != tt.want {
+			t.Errorf("encode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	d := New(`Sales (March) \ 2024`)
+	d.Text("Paracetamol (500mg) and C:\\path", Left)
+	d.Table([]Column{{Header: "Item", Width: 3}, {Header: "Qty", Width: 1, Align: Right}},
+		[][]string{{"Amoxicillin (caps)", "10"}}, []string{"Total", "10"})
+
+	// Enough rows to spill onto a second page.
+	for i := 0; i < 80; i++ {
+		d.Text(fmt.Sprintf("Line %d", i), Left)
+	}
+
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.Bytes()
+
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) {
+		t.Fatalf("missing header: %q", out[:20])
+	}
+	if !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatalf("missing %%%%EOF trailer")
+	}
+
+	// startxref points at the xref table.
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(out)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(out[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+
+	// Each entry of the xref table points at its object.
+	lines := strings.Split(string(out[xref:]), "\n")
+	var count int
+	if _, err := fmt.Sscanf(lines[1], "0 %d", &count); err != nil {
+		t.Fatalf("xref subsection %q: %v", lines[1], err)
+	}
+	if lines[2] != "0000000000 65535 f " {
+		t.Errorf("xref entry 0 = %q", lines[2])
+	}
+
+	// Catalog, pages, two fonts, a page and a content stream per page and the info.
+	if want := 4 + 2*len(d.pages) + 1 + 1; count != want {
+		t.Errorf("xref has %d entries, want %d", count, want)
+	}
+	if len(d.pages) < 2 {
+		t.Errorf("got %d pages, want at least 2", len(d.pages))
+	}
+
+	for i := 1; i < count; i++ {
+		entry := lines[2+i]
+		if len(entry) != 19 || !strings.HasSuffix(entry, " 00000 n ") {
+			t.Fatalf("xref entry %d = %q", i, entry)
+		}
+		offset, _ := strconv.Atoi(entry[:10])
+		if want := fmt.Sprintf("%d 0 obj\n", i); !bytes.HasPrefix(out[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q, want %q", i, out[offset:offset+len(want)], want)
+		}
+	}
+
+	if !strings.Contains(string(out), fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>", count, count-1)) {
+		t.Error("trailer does not match the xref table")
+	}
+
+	if !bytes.Contains(out, []byte(`/Title (Sales \(March\) \\ 2024)`)) {
+		t.Error("title is not escaped in the document info")
+	}
+
+	// The first content stream has the escaped text.
+	content := stream(t, out, 6)
+	for _, want := range []string{
+		`(Paracetamol \(500mg\) and C:\\path) Tj`,
+		`(Amoxicillin \(caps\)) Tj`,
+		`(Sales \(March\) \\ 2024) Tj`,
+		`(Page 1 of 2) Tj`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content stream does not contain %q", want)
+		}
+	}
+}
+
+// stream returns the inflated content stream of object n.
+func stream(t *testing.T, out []byte, n int) string {
+	t.Helper()
+
+	start := bytes.Index(out, []byte(fmt.Sprintf("\n%d 0 obj\n", n)))
+	if start < 0 {
+		t.Fatalf("object %d not found", n)
+	}
+
+	var length int
+	header := out[start+1:]
+	if _, err := fmt.Sscanf(string(header), fmt.Sprintf("%d 0 obj\n<< /Length %%d", n), &length); err != nil {
+		t.Fatalf("object %d length: %v", n, err)
+	}
+
+	data := header[bytes.Index(header, []byte("stream\n"))+len("stream\n"):]
+	if !bytes.HasPrefix(data[length:], []byte("\nendstream")) {
+		t.Fatalf("object %d /Length %d does not end at endstream", n, length)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data[:length]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
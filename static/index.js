@@ -3,6 +3,14 @@ const tbody = document.getElementById("productsTable");
 const barcodeInput = document.getElementById("barcode");
 const createTransaction = document.querySelector(".create-transaction");
 const grandTotal = document.getElementById("grand_total");
+const paymentsBody = document.getElementById("payments");
+const paymentRow = document.getElementById("paymentRow");
+const amountPaid = document.getElementById("amount_paid");
+const balanceDue = document.getElementById("balance_due");
+const changeDue = document.getElementById("change_due");
+const basketDiscountType = document.getElementById("basket_discount_type");
+const basketDiscount = document.getElementById("basket_discount");
+const taxRates = JSON.parse(document.getElementById("taxRates").textContent);
 
 const numberFormatter = Intl.NumberFormat("en-GB", {
   currency: "UGX",
@@ -12,8 +20,29 @@ const numberFormatter = Intl.NumberFormat("en-GB", {
 
 barcodeInput.focus();
 
+// Key of the sale being submitted. It is kept until the sale is recorded
+// so that a retried submission is not recorded twice.
+let saleKey = null;
+
+function newSaleKey() {
+  if (window.crypto && crypto.randomUUID) {
+    return crypto.randomUUID();
+  }
+  return `${Date.now().toString(36)}-${Math.random().toString(36).slice(2)}`;
+}
+
+// exclusiveTaxRate is the rate of the tax added on top of the selling price,
+// 0 if the product is not taxed or its price includes tax.
+function exclusiveTaxRate(product) {
+  if (product.price_includes_tax || !product.tax_class_id) {
+    return 0;
+  }
+  return taxRates[product.tax_class_id] || 0;
+}
+
 function addProductToQueue(product) {
   const tr = document.createElement("tr");
+  tr.dataset.taxRate = exclusiveTaxRate(product);
 
   tr.innerHTML = `
       <td class="hidden queue-id">${product.id}</td>
@@ -23,6 +52,15 @@ function addProductToQueue(product) {
       <td class="queue-quantity" style="background-color: lightgreen; font-size:16px;" contenteditable>${
         product.quantity
       }</td>
+      <td>
+        <div class="flex gap-1">
+          <select class="queue-discount_type">
+            <option value="percent">%</option>
+            <option value="fixed">Amount</option>
+          </select>
+          <input type="number" min="0" step="any" class="w-24 queue-discount" placeholder="0" />
+        </div>
+      </td>
       <td class="queue-subtotal">${(product.selling_price * product.quantity).toFixed(2)}</td>
       <td>
         <button class="button remove-button" data-id="${product.id}">Remove</button>
@@ -59,28 +97,97 @@ function setProducts(products) {
             </div>
         </td>
         <td>
-          <button ${product.quantity == 0 ? "disabled" : ""} class="button add-button" data-id="${product.id}">Add</button>
+          <button ${product.quantity == 0 ? "disabled" : ""} class="button add-button" data-id="${product.id}"
+            data-tax-class-id="${product.tax_class_id ?? ""}" data-price-includes-tax="${product.price_includes_tax}">Add</button>
         </td>
       `;
     tbody.appendChild(tr);
   });
 }
 
-function computeGrandTotal() {
-  const subtotalElements = salesQueue.querySelectorAll(".queue-subtotal");
-  const subtotals = Array.from(subtotalElements).map((el) => {
-    return parseFloat(el.textContent.trim());
-  });
+// discountAmount returns the discount off an amount.
+function discountAmount(type, value, amount) {
+  value = parseFloat(value) || 0;
+  if (type === "percent") {
+    return Math.round(amount * value) / 100;
+  }
+  return value;
+}
 
-  const sum = subtotals.reduce((prev, curr) => prev + curr, 0);
+// updateSubtotal recomputes the subtotal of a queue row after its line discount.
+function updateSubtotal(tr) {
+  const quantity = parseInt(tr.querySelector(".queue-quantity").textContent) || 0;
+  const sellingPrice = parseFloat(tr.querySelector(".queue-selling_price").textContent);
+  const gross = quantity * sellingPrice;
+  const discount = discountAmount(
+    tr.querySelector(".queue-discount_type").value,
+    tr.querySelector(".queue-discount").value,
+    gross,
+  );
+  tr.querySelector(".queue-subtotal").textContent = (gross - discount).toFixed(2);
+}
 
-  grandTotal.innerText = numberFormatter.format(sum);
+// saleTotal is the amount due after discounts, including tax added to prices
+// that exclude it. The basket discount is shared across the lines before tax.
+function saleTotal() {
+  const rows = Array.from(salesQueue.children);
+  const subtotals = rows.map((tr) => parseFloat(tr.querySelector(".queue-subtotal").textContent.trim()));
+
+  const subtotal = subtotals.reduce((prev, curr) => prev + curr, 0);
+  const discount = discountAmount(basketDiscountType.value, basketDiscount.value, subtotal);
+  const share = subtotal > 0 ? 1 - discount / subtotal : 0;
+
+  const tax = rows.reduce((prev, tr, i) => {
+    const rate = parseFloat(tr.dataset.taxRate) || 0;
+    return prev + Math.round(subtotals[i] * share * rate) / 100;
+  }, 0);
+  return subtotal - discount + tax;
+}
+
+function computeGrandTotal() {
+  grandTotal.innerText = numberFormatter.format(saleTotal());
+  computePayments();
 }
 
 function resetGrandTotal() {
   grandTotal.innerHTML = "0.00";
 }
 
+function addPaymentRow() {
+  paymentsBody.appendChild(paymentRow.content.cloneNode(true));
+}
+
+function resetPayments() {
+  paymentsBody.innerHTML = "";
+  addPaymentRow();
+  computePayments();
+}
+
+// readPayments returns the tenders entered for the sale.
+// A single tender left blank is taken to be the exact sale total.
+function readPayments() {
+  const rows = Array.from(paymentsBody.children);
+  return rows.map((tr) => {
+    const amount = tr.querySelector(".payment-amount").value.trim();
+    return {
+      method: tr.querySelector(".payment-method").value,
+      amount: amount === "" && rows.length === 1 ? saleTotal() : parseFloat(amount) || 0,
+      reference: tr.querySelector(".payment-reference").value.trim(),
+    };
+  });
+}
+
+function computePayments() {
+  const total = saleTotal();
+  const paid = readPayments().reduce((prev, curr) => prev + curr.amount, 0);
+
+  amountPaid.innerText = numberFormatter.format(paid);
+  balanceDue.innerText = numberFormatter.format(Math.max(total - paid, 0));
+  changeDue.innerText = numberFormatter.format(Math.max(paid - total, 0));
+}
+
+resetPayments();
+
 function maxQuantityExceeded(product) {
   const qtyElement = document.getElementById("quantity-" + product.id);
   if (!qtyElement) return false;
@@ -112,7 +219,7 @@ function addProductOrUpdate(product) {
       const tr = item.closest("tr");
       const quantity = parseInt(tr.querySelector(".queue-quantity").textContent.trim());
       tr.querySelector(".queue-quantity").textContent = quantity + 1;
-      tr.querySelector(".queue-subtotal").textContent = (quantity + 1) * product.selling_price;
+      updateSubtotal(tr);
       return;
     }
   }
@@ -128,6 +235,8 @@ document.addEventListener("click", (e) => {
     const generic_name = tr.querySelector(".GenericName").textContent.trim();
     const brand_name = tr.querySelector(".BrandName").textContent.trim();
     const selling_price = tr.querySelector(".SellingPrice").textContent.trim();
+    const tax_class_id = parseInt(e.target.dataset.taxClassId) || null;
+    const price_includes_tax = e.target.dataset.priceIncludesTax === "true";
     const expiry_dates = Array.from(tr.querySelectorAll(".ExpiryDate")).map((date) =>
       date.textContent.trim(),
     );
@@ -140,6 +249,8 @@ document.addEventListener("click", (e) => {
       generic_name,
       brand_name,
       selling_price: parseFloat(selling_price),
+      tax_class_id,
+      price_includes_tax,
       expiry_dates,
       quantity,
     });
@@ -167,9 +278,33 @@ document.addEventListener("click", (e) => {
   }
 });
 
+// event delegation for the payment rows
+document.addEventListener("click", (e) => {
+  if (e.target.classList.contains("add-payment")) {
+    addPaymentRow();
+    computePayments();
+  } else if (e.target.classList.contains("remove-payment")) {
+    e.target.closest("tr").remove();
+    if (paymentsBody.children.length === 0) {
+      addPaymentRow();
+    }
+    computePayments();
+  }
+});
+
 // event delegation when quantity is changed
 document.addEventListener("input", (e) => {
-  if (e.target.classList.contains("queue-quantity")) {
+  if (e.target.classList.contains("payment-amount") || e.target.classList.contains("payment-method")) {
+    computePayments();
+  } else if (
+    e.target.classList.contains("queue-discount") ||
+    e.target.classList.contains("queue-discount_type")
+  ) {
+    updateSubtotal(e.target.closest("tr"));
+    computeGrandTotal();
+  } else if (e.target === basketDiscount || e.target === basketDiscountType) {
+    computeGrandTotal();
+  } else if (e.target.classList.contains("queue-quantity")) {
     const tr = e.target.closest("tr");
     if (!tr) return;
 
@@ -185,8 +320,7 @@ document.addEventListener("input", (e) => {
       }
     }
 
-    const sellingPrice = parseFloat(tr.querySelector(".queue-selling_price").textContent);
-    tr.querySelector(".queue-subtotal").textContent = (quantity * sellingPrice).toFixed(2);
+    updateSubtotal(tr);
 
     // compute grand total
     computeGrandTotal();
@@ -263,6 +397,8 @@ createTransaction.addEventListener("click", async (e) => {
       id: parseInt(id),
       selling_price: parseFloat(SellingPrice),
       quantity: parseInt(Quantity),
+      discount_type: tr.querySelector(".queue-discount_type").value,
+      discount: parseFloat(tr.querySelector(".queue-discount").value) || 0,
     };
   });
 
@@ -271,22 +407,59 @@ createTransaction.addEventListener("click", async (e) => {
     return;
   }
 
-  const response = await fetch(url, {
-    method,
-    headers: {
-      "Content-Type": "application/json",
+  const payments = readPayments();
+  const paid = payments.reduce((prev, curr) => prev + curr.amount, 0);
+  if (paid < saleTotal()) {
+    alert("The payments do not cover the receipt total!");
+    return;
+  }
+
+  if (!saleKey) {
+    saleKey = newSaleKey();
+  }
+
+  const sale = {
+    products,
+    payments,
+    basket_discount: {
+      discount_type: basketDiscountType.value,
+      discount: parseFloat(basketDiscount.value) || 0,
     },
-    body: JSON.stringify({ products }),
-  });
+  };
 
   try {
-    const data = await response.json();
+    let response = await submitSale(url, method, sale);
+    let data = await response.json();
+
+    // Discounts above the cashier's limit need a manager's PIN.
+    while (response.status === 403 && data.approval_required) {
+      const pin = prompt(`${data.error}.\nEnter a manager PIN to approve the discount:`);
+      if (!pin) {
+        return;
+      }
+
+      response = await submitSale(url, method, { ...sale, override_pin: pin });
+      data = await response.json();
+    }
+
     if (response.ok) {
+      saleKey = null;
       salesQueue.innerHTML = "";
       decrementQuantities(products);
 
       // compute grand total
       resetGrandTotal();
+      basketDiscount.value = "";
+      resetPayments();
+      changeDue.innerText = numberFormatter.format(data.change);
+
+      // Print the receipt of the sale.
+      window.open(`/transactions/${data.id}/receipt?print=1`, "_blank");
+    } else if (data.shortages) {
+      const lines = data.shortages.map(
+        (s) => `${s.product_name}: requested ${s.requested}, available ${s.available}`,
+      );
+      alert(`${data.error}:\n${lines.join("\n")}`);
     } else {
       alert(data.error || "Insufficient quantity in stock!");
     }
@@ -296,6 +469,17 @@ createTransaction.addEventListener("click", async (e) => {
   }
 });
 
+function submitSale(url, method, sale) {
+  return fetch(url, {
+    method,
+    headers: {
+      "Content-Type": "application/json",
+      "Idempotency-Key": saleKey,
+    },
+    body: JSON.stringify(sale),
+  });
+}
+
 function decrementQuantities(products) {
   for (const prod of products) {
     const qtyElement = document.getElementById("quantity-" + prod.id);
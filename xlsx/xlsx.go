@@ -0,0 +1,226 @@
+// Package xlsx streams a single worksheet as an Office Open XML
+// spreadsheet, writing each row as it is given.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cell styles defined in styles.xml.
+const (
+	styleDefault = iota
+	styleMoney
+	styleDate
+	styleDateTime
+	styleHeader
+)
+
+// Date is written as a date without a time of day.
+type Date time.Time
+
+var files = map[string]string{
+	"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`,
+	"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`,
+	"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`,
+	"xl/styles.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="2">
+<numFmt numFmtId="164" formatCode="yyyy\-mm\-dd"/>
+<numFmt numFmtId="165" formatCode="yyyy\-mm\-dd\ hh:mm"/>
+</numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="5">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+</cellXfs>
+</styleSheet>`,
+}
+
+// Writer writes the rows of a worksheet.
+type Writer struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+}
+
+// NewWriter starts a workbook of one worksheet named sheetName. Characters
+// Excel does not allow in a sheet name are removed and the name is cut to
+// 31 characters.
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+
+	workbook := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`, escape(sheetTitle(sheetName)))
+
+	parts := map[string]string{"xl/workbook.xml": workbook}
+	for name, content := range files {
+		parts[name] = content
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		f, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, parts[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{zw: zw, sheet: sheet}, nil
+}
+
+// WriteHeader writes a row of column names in bold.
+func (w *Writer) WriteHeader(names ...string) error {
+	values := make([]any, len(names))
+	for i, name := range names {
+		values[i] = name
+	}
+	return w.writeRow(values, styleHeader)
+}
+
+// WriteRow writes a row of cells. Integers are written as numbers,
+// float64 as money with two decimal places, time.Time as a date and time,
+// Date as a date and anything else as text.
+func (w *Writer) WriteRow(values ...any) error {
+	return w.writeRow(values, styleDefault)
+}
+
+func (w *Writer) writeRow(values []any, style int) error {
+	w.row++
+	if _, err := fmt.Fprintf(w.sheet, `<row r="%d">`, w.row); err != nil {
+		return err
+	}
+
+	for i, value := range values {
+		ref := columnName(i) + strconv.Itoa(w.row)
+
+		var cell string
+		switch v := value.(type) {
+		case nil:
+			continue
+		case int:
+			cell = fmt.Sprintf(`<c r="%s"><v>%d</v></c>`, ref, v)
+		case int32:
+			cell = fmt.Sprintf(`<c r="%s"><v>%d</v></c>`, ref, v)
+		case int64:
+			cell = fmt.Sprintf(`<c r="%s"><v>%d</v></c>`, ref, v)
+		case float64:
+			cell = fmt.Sprintf(`<c r="%s" s="%d"><v>%s</v></c>`, ref, styleMoney, strconv.FormatFloat(v, 'f', -1, 64))
+		case time.Time:
+			cell = fmt.Sprintf(`<c r="%s" s="%d"><v>%s</v></c>`, ref, styleDateTime, serial(v))
+		case Date:
+			year, month, day := time.Time(v).Date()
+			midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+			cell = fmt.Sprintf(`<c r="%s" s="%d"><v>%s</v></c>`, ref, styleDate, serial(midnight))
+		default:
+			s := ""
+			if style != styleDefault {
+				s = fmt.Sprintf(` s="%d"`, style)
+			}
+			cell = fmt.Sprintf(`<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`,
+				ref, s, escape(fmt.Sprint(v)))
+		}
+
+		if _, err := io.WriteString(w.sheet, cell); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w.sheet, `</row>`)
+	return err
+}
+
+// Close ends the worksheet and the workbook. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if _, err := io.WriteString(w.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// Flush flushes the compressed rows written so far to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.zw.Flush()
+}
+
+// columnName returns the letters of the column at index i e.g A, Z, AA.
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// serial converts t to a spreadsheet serial date, the days since 30 December 1899.
+func serial(t time.Time) string {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return strconv.FormatFloat(local.Sub(epoch).Hours()/24, 'f', 6, 64)
+}
+
+// sheetTitle makes name a valid sheet name: at most 31 characters, without
+// any of []:*?/\ and not starting or ending with an apostrophe.
+func sheetTitle(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+
+	name = strings.Trim(name, "' ")
+	if name == "" {
+		return "Sheet1"
+	}
+	return name
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
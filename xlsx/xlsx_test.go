@@ -0,0 +1,188 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type worksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string `xml:"r,attr"`
+			S      int    `xml:"s,attr"`
+			T      string `xml:"t,attr"`
+			V      string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+type workbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Sales: 2024/03")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kampala := time.FixedZone("EAT", 3*60*60)
+	rows := [][]any{
+		{"Cough <syrup> & \"drops\"", 3, 1234.5, Date(time.Date(2024, 3, 1, 23, 30, 0, 0, kampala)), time.Date(2024, 3, 1, 18, 0, 0, 0, kampala)},
+		{" padded ", int64(7), nil, Date(time.Date(1900, 3, 1, 0, 0, 0, 0, time.UTC))},
+	}
+
+	if err := w.WriteHeader("Product", "Qty", "Amount", "Expiry", "Sold At"); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = b
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml",
+		"xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		b, ok := parts[name]
+		if !ok {
+			t.Fatalf("missing part %s", name)
+		}
+		if err := xml.Unmarshal(b, new(struct{})); err != nil {
+			t.Errorf("%s is not well formed: %v", name, err)
+		}
+	}
+
+	var wb workbook
+	if err := xml.Unmarshal(parts["xl/workbook.xml"], &wb); err != nil {
+		t.Fatal(err)
+	}
+	if len(wb.Sheets) != 1 || wb.Sheets[0].Name != "Sales 202403" {
+		t.Errorf("sheets = %+v, want one named %q", wb.Sheets, "Sales 202403")
+	}
+
+	var ws worksheet
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &ws); err != nil {
+		t.Fatal(err)
+	}
+	if len(ws.Rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(ws.Rows))
+	}
+
+	type cell struct {
+		ref, t, value string
+		style         int
+	}
+	want := [][]cell{
+		{
+			{"A1", "inlineStr", "Product", styleHeader},
+			{"B1", "inlineStr", "Qty", styleHeader},
+			{"C1", "inlineStr", "Amount", styleHeader},
+			{"D1", "inlineStr", "Expiry", styleHeader},
+			{"E1", "inlineStr", "Sold At", styleHeader},
+		},
+		{
+			{"A2", "inlineStr", "Cough <syrup> & \"drops\"", styleDefault},
+			{"B2", "", "3", styleDefault},
+			{"C2", "", "1234.5", styleMoney},
+			// The date is kept as given, not moved to the next day in UTC.
+			{"D2", "", "45352.000000", styleDate},
+			{"E2", "", "45352.750000", styleDateTime},
+		},
+		{
+			{"A3", "inlineStr", " padded ", styleDefault},
+			{"B3", "", "7", styleDefault},
+			// C3 is nil and left out.
+			{"D3", "", "61.000000", styleDate},
+		},
+	}
+
+	for i, row := range ws.Rows {
+		if row.R != i+1 {
+			t.Errorf("row %d has r=%d", i+1, row.R)
+		}
+		if len(row.Cells) != len(want[i]) {
+			t.Errorf("row %d has %d cells, want %d", i+1, len(row.Cells), len(want[i]))
+			continue
+		}
+		for j, c := range row.Cells {
+			value := c.V
+			if c.T == "inlineStr" {
+				value = c.Inline
+			}
+			got := cell{c.R, c.T, value, c.S}
+			if got != want[i][j] {
+				t.Errorf("cell %s = %+v, want %+v", want[i][j].ref, got, want[i][j])
+			}
+		}
+	}
+
+	// Dates have no time of day, whatever the time zone of the value.
+	for _, row := range ws.Rows[1:] {
+		for _, c := range row.Cells {
+			if c.S == styleDate && !strings.HasSuffix(c.V, ".000000") {
+				t.Errorf("date cell %s = %s has a time of day", c.R, c.V)
+			}
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for i, want := range tests {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestSheetTitle(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Sales", "Sales"},
+		{"Sales [2024]: Q1/Q2", "Sales 2024 Q1Q2"},
+		{`*?\`, "Sheet1"},
+		{"", "Sheet1"},
+		{"'Quoted'", "Quoted"},
+		{strings.Repeat("a", 40), strings.Repeat("a", 31)},
+		{strings.Repeat("é", 40), strings.Repeat("é", 31)},
+	}
+
+	for _, tt := range tests {
+		if got := sheetTitle(tt.in); got != tt.want {
+			t.Errorf("sheetTitle(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}